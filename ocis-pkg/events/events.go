@@ -0,0 +1,45 @@
+// Package events contains the events emitted by the ocis services in addition to the ones defined by reva.
+package events
+
+import (
+	"encoding/json"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+)
+
+// UserAuthenticated is emitted when the proxy authenticated a request
+type UserAuthenticated struct {
+	// UserID is the id of the authenticated user, empty for anonymous public link access
+	UserID *user.UserId
+	// Login is what the client identified itself with, e.g. the username or a hash of the public link token
+	Login string
+	// Method is the authentication method: basic, oidc, signed_url or public_share
+	Method    string
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface
+func (UserAuthenticated) Unmarshal(v []byte) (interface{}, error) {
+	e := UserAuthenticated{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
+
+// UserAuthenticationFailed is emitted when the proxy rejected the credentials of a request
+type UserAuthenticationFailed struct {
+	// Login is what the client identified itself with, e.g. the username or a hash of the public link token
+	Login string
+	// Method is the authentication method: basic, oidc, signed_url or public_share
+	Method string
+	// Reason describes why the authentication failed
+	Reason    string
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface
+func (UserAuthenticationFailed) Unmarshal(v []byte) (interface{}, error) {
+	e := UserAuthenticationFailed{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/cs3org/reva/v2/pkg/events"
+	"github.com/owncloud/ocis/v2/ocis-pkg/requestmeta"
+	mevents "go-micro.dev/v4/events"
+)
+
+// Event is an event received together with the metadata of the request which caused it
+type Event struct {
+	Event   interface{}
+	Request requestmeta.Metadata
+}
+
+// Publish publishes the event like events.Publish. The metadata of the request found in the context is
+// added to the metadata of the event.
+func Publish(ctx context.Context, s events.Publisher, ev interface{}) error {
+	metadata := requestmeta.FromContext(ctx).Map()
+	metadata[events.MetadatakeyEventType] = reflect.TypeOf(ev).String()
+	return s.Publish(events.MainQueueName, ev, mevents.WithMetadata(metadata))
+}
+
+// Consume returns a channel emitting the given events like events.Consume. The events are wrapped in an
+// Event carrying the metadata of the request they have been published with.
+func Consume(s events.Consumer, group string, evs ...events.Unmarshaller) (<-chan interface{}, error) {
+	c, err := s.Consume(events.MainQueueName, mevents.WithGroup(group))
+	if err != nil {
+		return nil, err
+	}
+
+	registeredEvents := map[string]events.Unmarshaller{}
+	for _, e := range evs {
+		registeredEvents[reflect.TypeOf(e).String()] = e
+	}
+
+	outchan := make(chan interface{})
+	go func() {
+		for e := range c {
+			u, ok := registeredEvents[e.Metadata[events.MetadatakeyEventType]]
+			if !ok {
+				continue
+			}
+			ev, err := u.Unmarshal(e.Payload)
+			if err != nil {
+				continue
+			}
+			outchan <- Event{Event: ev, Request: requestmeta.FromMap(e.Metadata)}
+		}
+	}()
+	return outchan, nil
+}
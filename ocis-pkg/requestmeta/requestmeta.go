@@ -0,0 +1,130 @@
+// Package requestmeta carries the metadata of a client request, like the client ip and the user agent,
+// from the proxy through the services to the events they emit. Only the events published by the ocis
+// services carry the metadata, which are the user and group events of the graph service and the
+// authentication events of the proxy. The events published by reva, like the file, share and space
+// events, don't, as reva doesn't pass the metadata on.
+package requestmeta
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc/metadata"
+)
+
+// Keys of the metadata in gRPC metadata and in the metadata of events
+const (
+	KeyRemoteAddr = "x-ocis-remote-addr"
+	KeyUserAgent  = "x-ocis-user-agent"
+	KeyRequestID  = "x-ocis-request-id"
+	KeyURL        = "x-ocis-url"
+	KeyMethod     = "x-ocis-method"
+)
+
+// HeaderRealIP is the header the proxy passes the client ip in to the services
+const HeaderRealIP = "X-Real-IP"
+
+// Metadata describes the client request which caused an action
+type Metadata struct {
+	// RemoteAddr is the ip of the client
+	RemoteAddr string
+	UserAgent  string
+	RequestID  string
+	// URL is the path of the request, the query is left out as it may contain credentials
+	URL    string
+	Method string
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the metadata. The metadata is also added to the outgoing gRPC
+// metadata, so that it reaches the services called with the context.
+func NewContext(ctx context.Context, md Metadata) context.Context {
+	ctx = context.WithValue(ctx, contextKey{}, md)
+	kv := make([]string, 0, 10)
+	for k, v := range md.Map() {
+		kv = append(kv, k, v)
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// FromContext returns the metadata stored in the context or received as incoming gRPC metadata
+func FromContext(ctx context.Context) Metadata {
+	if md, ok := ctx.Value(contextKey{}).(Metadata); ok {
+		return md
+	}
+	in, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return Metadata{}
+	}
+	m := make(map[string]string, 5)
+	for _, k := range []string{KeyRemoteAddr, KeyUserAgent, KeyRequestID, KeyURL, KeyMethod} {
+		if v := in.Get(k); len(v) > 0 {
+			m[k] = v[0]
+		}
+	}
+	return FromMap(m)
+}
+
+// FromRequest returns the metadata of the request. The client ip is taken from the X-Real-IP header
+// set by the proxy and from the remote address of the connection otherwise.
+func FromRequest(r *http.Request) Metadata {
+	remoteAddr := r.Header.Get(HeaderRealIP)
+	if remoteAddr == "" {
+		remoteAddr = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			remoteAddr = host
+		}
+	}
+	requestID := chimiddleware.GetReqID(r.Context())
+	if requestID == "" {
+		requestID = r.Header.Get(chimiddleware.RequestIDHeader)
+	}
+	return Metadata{
+		RemoteAddr: remoteAddr,
+		UserAgent:  r.UserAgent(),
+		RequestID:  requestID,
+		URL:        r.URL.Path,
+		Method:     r.Method,
+	}
+}
+
+// Middleware adds the metadata of the request to its context
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), FromRequest(r))))
+	})
+}
+
+// Map returns the metadata as a map of its keys to the values which are set
+func (md Metadata) Map() map[string]string {
+	m := make(map[string]string, 5)
+	for k, v := range map[string]string{
+		KeyRemoteAddr: md.RemoteAddr,
+		KeyUserAgent:  md.UserAgent,
+		KeyRequestID:  md.RequestID,
+		KeyURL:        md.URL,
+		KeyMethod:     md.Method,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// FromMap returns the metadata contained in a map, e.g. the metadata of an event
+func FromMap(m map[string]string) Metadata {
+	return Metadata{
+		RemoteAddr: m[KeyRemoteAddr],
+		UserAgent:  m[KeyUserAgent],
+		RequestID:  m[KeyRequestID],
+		URL:        m[KeyURL],
+		Method:     m[KeyMethod],
+	}
+}
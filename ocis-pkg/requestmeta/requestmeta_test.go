@@ -0,0 +1,42 @@
+package requestmeta
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMetadataTravelsThroughGRPC(t *testing.T) {
+	md := Metadata{
+		RemoteAddr: "192.0.2.10",
+		UserAgent:  "Mozilla/5.0",
+		RequestID:  "req-123",
+		URL:        "/graph/v1.0/users",
+		Method:     "POST",
+	}
+	ctx := NewContext(context.Background(), md)
+	if got := FromContext(ctx); got != md {
+		t.Errorf("expected %+v got %+v", md, got)
+	}
+
+	// the receiving service finds the metadata in the incoming gRPC metadata
+	out, _ := metadata.FromOutgoingContext(ctx)
+	in := metadata.NewIncomingContext(context.Background(), out)
+	if got := FromContext(in); got != md {
+		t.Errorf("expected %+v got %+v", md, got)
+	}
+}
+
+func TestMetadataMapOmitsEmptyValues(t *testing.T) {
+	m := Metadata{RemoteAddr: "192.0.2.10"}.Map()
+	if len(m) != 1 || m[KeyRemoteAddr] != "192.0.2.10" {
+		t.Errorf("unexpected map %v", m)
+	}
+	if got := FromMap(m); got.RemoteAddr != "192.0.2.10" || got.UserAgent != "" {
+		t.Errorf("unexpected metadata %+v", got)
+	}
+	if _, ok := metadata.FromOutgoingContext(NewContext(context.Background(), Metadata{})); ok {
+		t.Error("empty metadata must not be added to the outgoing gRPC metadata")
+	}
+}
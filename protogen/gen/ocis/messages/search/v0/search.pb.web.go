@@ -118,6 +118,42 @@ func (m *Entity) UnmarshalJSON(b []byte) error {
 
 var _ json.Unmarshaler = (*Entity)(nil)
 
+// SpaceJSONMarshaler describes the default jsonpb.Marshaler used by all
+// instances of Space. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var SpaceJSONMarshaler = new(jsonpb.Marshaler)
+
+// MarshalJSON satisfies the encoding/json Marshaler interface. This method
+// uses the more correct jsonpb package to correctly marshal the message.
+func (m *Space) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return json.Marshal(nil)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := SpaceJSONMarshaler.Marshal(buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var _ json.Marshaler = (*Space)(nil)
+
+// SpaceJSONUnmarshaler describes the default jsonpb.Unmarshaler used by all
+// instances of Space. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var SpaceJSONUnmarshaler = new(jsonpb.Unmarshaler)
+
+// UnmarshalJSON satisfies the encoding/json Unmarshaler interface. This method
+// uses the more correct jsonpb package to correctly unmarshal the message.
+func (m *Space) UnmarshalJSON(b []byte) error {
+	return SpaceJSONUnmarshaler.Unmarshal(bytes.NewReader(b), m)
+}
+
+var _ json.Unmarshaler = (*Space)(nil)
+
 // MatchJSONMarshaler describes the default jsonpb.Marshaler used by all
 // instances of Match. This struct is safe to replace or modify but
 // should not be done so concurrently.
@@ -153,3 +189,111 @@ func (m *Match) UnmarshalJSON(b []byte) error {
 }
 
 var _ json.Unmarshaler = (*Match)(nil)
+
+// FacetTermJSONMarshaler describes the default jsonpb.Marshaler used by all
+// instances of FacetTerm. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var FacetTermJSONMarshaler = new(jsonpb.Marshaler)
+
+// MarshalJSON satisfies the encoding/json Marshaler interface. This method
+// uses the more correct jsonpb package to correctly marshal the message.
+func (m *FacetTerm) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return json.Marshal(nil)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := FacetTermJSONMarshaler.Marshal(buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var _ json.Marshaler = (*FacetTerm)(nil)
+
+// FacetTermJSONUnmarshaler describes the default jsonpb.Unmarshaler used by all
+// instances of FacetTerm. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var FacetTermJSONUnmarshaler = new(jsonpb.Unmarshaler)
+
+// UnmarshalJSON satisfies the encoding/json Unmarshaler interface. This method
+// uses the more correct jsonpb package to correctly unmarshal the message.
+func (m *FacetTerm) UnmarshalJSON(b []byte) error {
+	return FacetTermJSONUnmarshaler.Unmarshal(bytes.NewReader(b), m)
+}
+
+var _ json.Unmarshaler = (*FacetTerm)(nil)
+
+// FacetJSONMarshaler describes the default jsonpb.Marshaler used by all
+// instances of Facet. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var FacetJSONMarshaler = new(jsonpb.Marshaler)
+
+// MarshalJSON satisfies the encoding/json Marshaler interface. This method
+// uses the more correct jsonpb package to correctly marshal the message.
+func (m *Facet) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return json.Marshal(nil)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := FacetJSONMarshaler.Marshal(buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var _ json.Marshaler = (*Facet)(nil)
+
+// FacetJSONUnmarshaler describes the default jsonpb.Unmarshaler used by all
+// instances of Facet. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var FacetJSONUnmarshaler = new(jsonpb.Unmarshaler)
+
+// UnmarshalJSON satisfies the encoding/json Unmarshaler interface. This method
+// uses the more correct jsonpb package to correctly unmarshal the message.
+func (m *Facet) UnmarshalJSON(b []byte) error {
+	return FacetJSONUnmarshaler.Unmarshal(bytes.NewReader(b), m)
+}
+
+var _ json.Unmarshaler = (*Facet)(nil)
+
+// IndexDiscrepancyJSONMarshaler describes the default jsonpb.Marshaler used by all
+// instances of IndexDiscrepancy. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var IndexDiscrepancyJSONMarshaler = new(jsonpb.Marshaler)
+
+// MarshalJSON satisfies the encoding/json Marshaler interface. This method
+// uses the more correct jsonpb package to correctly marshal the message.
+func (m *IndexDiscrepancy) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return json.Marshal(nil)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := IndexDiscrepancyJSONMarshaler.Marshal(buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var _ json.Marshaler = (*IndexDiscrepancy)(nil)
+
+// IndexDiscrepancyJSONUnmarshaler describes the default jsonpb.Unmarshaler used by all
+// instances of IndexDiscrepancy. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var IndexDiscrepancyJSONUnmarshaler = new(jsonpb.Unmarshaler)
+
+// UnmarshalJSON satisfies the encoding/json Unmarshaler interface. This method
+// uses the more correct jsonpb package to correctly unmarshal the message.
+func (m *IndexDiscrepancy) UnmarshalJSON(b []byte) error {
+	return IndexDiscrepancyJSONUnmarshaler.Unmarshal(bytes.NewReader(b), m)
+}
+
+var _ json.Unmarshaler = (*IndexDiscrepancy)(nil)
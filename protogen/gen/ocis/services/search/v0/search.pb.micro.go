@@ -10,6 +10,7 @@ import (
 	_ "google.golang.org/genproto/googleapis/api/annotations"
 	proto "google.golang.org/protobuf/proto"
 	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
 	math "math"
 )
 
@@ -47,6 +48,12 @@ func NewSearchProviderEndpoints() []*api.Endpoint {
 			Method:  []string{"POST"},
 			Handler: "rpc",
 		},
+		{
+			Name:    "SearchProvider.CheckIndex",
+			Path:    []string{"/api/v0/search/check-index"},
+			Method:  []string{"POST"},
+			Handler: "rpc",
+		},
 	}
 }
 
@@ -55,6 +62,8 @@ func NewSearchProviderEndpoints() []*api.Endpoint {
 type SearchProviderService interface {
 	Search(ctx context.Context, in *SearchRequest, opts ...client.CallOption) (*SearchResponse, error)
 	IndexSpace(ctx context.Context, in *IndexSpaceRequest, opts ...client.CallOption) (*IndexSpaceResponse, error)
+	IndexSpaces(ctx context.Context, in *IndexSpacesRequest, opts ...client.CallOption) (SearchProvider_IndexSpacesService, error)
+	CheckIndex(ctx context.Context, in *CheckIndexRequest, opts ...client.CallOption) (*CheckIndexResponse, error)
 }
 
 type searchProviderService struct {
@@ -89,17 +98,85 @@ func (c *searchProviderService) IndexSpace(ctx context.Context, in *IndexSpaceRe
 	return out, nil
 }
 
+func (c *searchProviderService) IndexSpaces(ctx context.Context, in *IndexSpacesRequest, opts ...client.CallOption) (SearchProvider_IndexSpacesService, error) {
+	req := c.c.NewRequest(c.name, "SearchProvider.IndexSpaces", &IndexSpacesRequest{})
+	stream, err := c.c.Stream(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(in); err != nil {
+		return nil, err
+	}
+	return &searchProviderServiceIndexSpaces{stream}, nil
+}
+
+type SearchProvider_IndexSpacesService interface {
+	Context() context.Context
+	SendMsg(interface{}) error
+	RecvMsg(interface{}) error
+	CloseSend() error
+	Close() error
+	Recv() (*IndexSpacesResponse, error)
+}
+
+type searchProviderServiceIndexSpaces struct {
+	stream client.Stream
+}
+
+func (x *searchProviderServiceIndexSpaces) CloseSend() error {
+	return x.stream.CloseSend()
+}
+
+func (x *searchProviderServiceIndexSpaces) Close() error {
+	return x.stream.Close()
+}
+
+func (x *searchProviderServiceIndexSpaces) Context() context.Context {
+	return x.stream.Context()
+}
+
+func (x *searchProviderServiceIndexSpaces) SendMsg(m interface{}) error {
+	return x.stream.Send(m)
+}
+
+func (x *searchProviderServiceIndexSpaces) RecvMsg(m interface{}) error {
+	return x.stream.Recv(m)
+}
+
+func (x *searchProviderServiceIndexSpaces) Recv() (*IndexSpacesResponse, error) {
+	m := new(IndexSpacesResponse)
+	err := x.stream.Recv(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *searchProviderService) CheckIndex(ctx context.Context, in *CheckIndexRequest, opts ...client.CallOption) (*CheckIndexResponse, error) {
+	req := c.c.NewRequest(c.name, "SearchProvider.CheckIndex", in)
+	out := new(CheckIndexResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for SearchProvider service
 
 type SearchProviderHandler interface {
 	Search(context.Context, *SearchRequest, *SearchResponse) error
 	IndexSpace(context.Context, *IndexSpaceRequest, *IndexSpaceResponse) error
+	IndexSpaces(context.Context, *IndexSpacesRequest, SearchProvider_IndexSpacesStream) error
+	CheckIndex(context.Context, *CheckIndexRequest, *CheckIndexResponse) error
 }
 
 func RegisterSearchProviderHandler(s server.Server, hdlr SearchProviderHandler, opts ...server.HandlerOption) error {
 	type searchProvider interface {
 		Search(ctx context.Context, in *SearchRequest, out *SearchResponse) error
 		IndexSpace(ctx context.Context, in *IndexSpaceRequest, out *IndexSpaceResponse) error
+		IndexSpaces(ctx context.Context, stream server.Stream) error
+		CheckIndex(ctx context.Context, in *CheckIndexRequest, out *CheckIndexResponse) error
 	}
 	type SearchProvider struct {
 		searchProvider
@@ -117,6 +194,12 @@ func RegisterSearchProviderHandler(s server.Server, hdlr SearchProviderHandler,
 		Method:  []string{"POST"},
 		Handler: "rpc",
 	}))
+	opts = append(opts, api.WithEndpoint(&api.Endpoint{
+		Name:    "SearchProvider.CheckIndex",
+		Path:    []string{"/api/v0/search/check-index"},
+		Method:  []string{"POST"},
+		Handler: "rpc",
+	}))
 	return s.Handle(s.NewHandler(&SearchProvider{h}, opts...))
 }
 
@@ -132,6 +215,50 @@ func (h *searchProviderHandler) IndexSpace(ctx context.Context, in *IndexSpaceRe
 	return h.SearchProviderHandler.IndexSpace(ctx, in, out)
 }
 
+func (h *searchProviderHandler) IndexSpaces(ctx context.Context, stream server.Stream) error {
+	m := new(IndexSpacesRequest)
+	if err := stream.Recv(m); err != nil {
+		return err
+	}
+	return h.SearchProviderHandler.IndexSpaces(ctx, m, &searchProviderIndexSpacesStream{stream})
+}
+
+type SearchProvider_IndexSpacesStream interface {
+	Context() context.Context
+	SendMsg(interface{}) error
+	RecvMsg(interface{}) error
+	Close() error
+	Send(*IndexSpacesResponse) error
+}
+
+type searchProviderIndexSpacesStream struct {
+	stream server.Stream
+}
+
+func (x *searchProviderIndexSpacesStream) Close() error {
+	return x.stream.Close()
+}
+
+func (x *searchProviderIndexSpacesStream) Context() context.Context {
+	return x.stream.Context()
+}
+
+func (x *searchProviderIndexSpacesStream) SendMsg(m interface{}) error {
+	return x.stream.Send(m)
+}
+
+func (x *searchProviderIndexSpacesStream) RecvMsg(m interface{}) error {
+	return x.stream.Recv(m)
+}
+
+func (x *searchProviderIndexSpacesStream) Send(m *IndexSpacesResponse) error {
+	return x.stream.Send(m)
+}
+
+func (h *searchProviderHandler) CheckIndex(ctx context.Context, in *CheckIndexRequest, out *CheckIndexResponse) error {
+	return h.SearchProviderHandler.CheckIndex(ctx, in, out)
+}
+
 // Api Endpoints for IndexProvider service
 
 func NewIndexProviderEndpoints() []*api.Endpoint {
@@ -75,6 +75,32 @@ func (h *webSearchProviderHandler) IndexSpace(w http.ResponseWriter, r *http.Req
 	render.JSON(w, r, resp)
 }
 
+func (h *webSearchProviderHandler) CheckIndex(w http.ResponseWriter, r *http.Request) {
+	req := &CheckIndexRequest{}
+	resp := &CheckIndexResponse{}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+
+	if err := h.h.CheckIndex(
+		r.Context(),
+		req,
+		resp,
+	); err != nil {
+		if merr, ok := merrors.As(err); ok && merr.Code == http.StatusNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, resp)
+}
+
 func RegisterSearchProviderWeb(r chi.Router, i SearchProviderHandler, middlewares ...func(http.Handler) http.Handler) {
 	handler := &webSearchProviderHandler{
 		r: r,
@@ -83,6 +109,7 @@ func RegisterSearchProviderWeb(r chi.Router, i SearchProviderHandler, middleware
 
 	r.MethodFunc("POST", "/api/v0/search/search", handler.Search)
 	r.MethodFunc("POST", "/api/v0/search/index-space", handler.IndexSpace)
+	r.MethodFunc("POST", "/api/v0/search/check-index", handler.CheckIndex)
 }
 
 type webIndexProviderHandler struct {
@@ -344,3 +371,147 @@ func (m *IndexSpaceResponse) UnmarshalJSON(b []byte) error {
 }
 
 var _ json.Unmarshaler = (*IndexSpaceResponse)(nil)
+
+// IndexSpacesRequestJSONMarshaler describes the default jsonpb.Marshaler used by all
+// instances of IndexSpacesRequest. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var IndexSpacesRequestJSONMarshaler = new(jsonpb.Marshaler)
+
+// MarshalJSON satisfies the encoding/json Marshaler interface. This method
+// uses the more correct jsonpb package to correctly marshal the message.
+func (m *IndexSpacesRequest) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return json.Marshal(nil)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := IndexSpacesRequestJSONMarshaler.Marshal(buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var _ json.Marshaler = (*IndexSpacesRequest)(nil)
+
+// IndexSpacesRequestJSONUnmarshaler describes the default jsonpb.Unmarshaler used by all
+// instances of IndexSpacesRequest. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var IndexSpacesRequestJSONUnmarshaler = new(jsonpb.Unmarshaler)
+
+// UnmarshalJSON satisfies the encoding/json Unmarshaler interface. This method
+// uses the more correct jsonpb package to correctly unmarshal the message.
+func (m *IndexSpacesRequest) UnmarshalJSON(b []byte) error {
+	return IndexSpacesRequestJSONUnmarshaler.Unmarshal(bytes.NewReader(b), m)
+}
+
+var _ json.Unmarshaler = (*IndexSpacesRequest)(nil)
+
+// IndexSpacesResponseJSONMarshaler describes the default jsonpb.Marshaler used by all
+// instances of IndexSpacesResponse. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var IndexSpacesResponseJSONMarshaler = new(jsonpb.Marshaler)
+
+// MarshalJSON satisfies the encoding/json Marshaler interface. This method
+// uses the more correct jsonpb package to correctly marshal the message.
+func (m *IndexSpacesResponse) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return json.Marshal(nil)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := IndexSpacesResponseJSONMarshaler.Marshal(buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var _ json.Marshaler = (*IndexSpacesResponse)(nil)
+
+// IndexSpacesResponseJSONUnmarshaler describes the default jsonpb.Unmarshaler used by all
+// instances of IndexSpacesResponse. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var IndexSpacesResponseJSONUnmarshaler = new(jsonpb.Unmarshaler)
+
+// UnmarshalJSON satisfies the encoding/json Unmarshaler interface. This method
+// uses the more correct jsonpb package to correctly unmarshal the message.
+func (m *IndexSpacesResponse) UnmarshalJSON(b []byte) error {
+	return IndexSpacesResponseJSONUnmarshaler.Unmarshal(bytes.NewReader(b), m)
+}
+
+var _ json.Unmarshaler = (*IndexSpacesResponse)(nil)
+
+// CheckIndexRequestJSONMarshaler describes the default jsonpb.Marshaler used by all
+// instances of CheckIndexRequest. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var CheckIndexRequestJSONMarshaler = new(jsonpb.Marshaler)
+
+// MarshalJSON satisfies the encoding/json Marshaler interface. This method
+// uses the more correct jsonpb package to correctly marshal the message.
+func (m *CheckIndexRequest) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return json.Marshal(nil)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := CheckIndexRequestJSONMarshaler.Marshal(buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var _ json.Marshaler = (*CheckIndexRequest)(nil)
+
+// CheckIndexRequestJSONUnmarshaler describes the default jsonpb.Unmarshaler used by all
+// instances of CheckIndexRequest. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var CheckIndexRequestJSONUnmarshaler = new(jsonpb.Unmarshaler)
+
+// UnmarshalJSON satisfies the encoding/json Unmarshaler interface. This method
+// uses the more correct jsonpb package to correctly unmarshal the message.
+func (m *CheckIndexRequest) UnmarshalJSON(b []byte) error {
+	return CheckIndexRequestJSONUnmarshaler.Unmarshal(bytes.NewReader(b), m)
+}
+
+var _ json.Unmarshaler = (*CheckIndexRequest)(nil)
+
+// CheckIndexResponseJSONMarshaler describes the default jsonpb.Marshaler used by all
+// instances of CheckIndexResponse. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var CheckIndexResponseJSONMarshaler = new(jsonpb.Marshaler)
+
+// MarshalJSON satisfies the encoding/json Marshaler interface. This method
+// uses the more correct jsonpb package to correctly marshal the message.
+func (m *CheckIndexResponse) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return json.Marshal(nil)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := CheckIndexResponseJSONMarshaler.Marshal(buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var _ json.Marshaler = (*CheckIndexResponse)(nil)
+
+// CheckIndexResponseJSONUnmarshaler describes the default jsonpb.Unmarshaler used by all
+// instances of CheckIndexResponse. This struct is safe to replace or modify but
+// should not be done so concurrently.
+var CheckIndexResponseJSONUnmarshaler = new(jsonpb.Unmarshaler)
+
+// UnmarshalJSON satisfies the encoding/json Unmarshaler interface. This method
+// uses the more correct jsonpb package to correctly unmarshal the message.
+func (m *CheckIndexResponse) UnmarshalJSON(b []byte) error {
+	return CheckIndexResponseJSONUnmarshaler.Unmarshal(bytes.NewReader(b), m)
+}
+
+var _ json.Unmarshaler = (*CheckIndexResponse)(nil)
@@ -18,6 +18,8 @@ func GetCommands(cfg *config.Config) cli.Commands {
 		Server(cfg),
 
 		// interaction with this service
+		Verify(cfg),
+		Search(cfg),
 
 		// infos about this service
 		Health(cfg),
@@ -0,0 +1,199 @@
+package command
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tw "github.com/olekukonko/tablewriter"
+	"github.com/owncloud/ocis/v2/ocis-pkg/config/configlog"
+	"github.com/owncloud/ocis/v2/services/audit/pkg/config"
+	"github.com/owncloud/ocis/v2/services/audit/pkg/config/parser"
+	svc "github.com/owncloud/ocis/v2/services/audit/pkg/service"
+	"github.com/urfave/cli/v2"
+)
+
+var _searchColumns = []string{"Time", "Action", "User", "Remote Address", "File ID", "Path", "Message"}
+
+// Search is the entrypoint for the search command.
+func Search(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:     "search",
+		Usage:    "find audit events in the audit log and its rotated logfiles",
+		Category: "audit log",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "file",
+				Aliases: []string{"f"},
+				Usage:   "the audit log to search, defaults to the configured logfile. Only logfiles written in the json format can be searched",
+			},
+			&cli.StringFlag{
+				Name:    "user",
+				Aliases: []string{"u"},
+				Usage:   "the id of the user who performed the action or whose item, share or account was affected",
+			},
+			&cli.StringSliceFlag{
+				Name:    "action",
+				Aliases: []string{"a"},
+				Usage:   "the action, e.g. 'file_delete', or category of actions, e.g. 'sharing'. Can be given multiple times",
+			},
+			&cli.StringFlag{
+				Name:  "since",
+				Usage: "only events at or after this time, given as RFC3339 timestamp, date like 2022-11-24 or duration ago like 12h or 7d",
+			},
+			&cli.StringFlag{
+				Name:  "until",
+				Usage: "only events at or before this time, given like --since",
+			},
+			&cli.StringFlag{
+				Name:  "file-id",
+				Usage: "the id of the file or folder, the items referenced relative to it are found as well",
+			},
+			&cli.StringFlag{
+				Name:  "path",
+				Usage: "the path of the file or folder as logged, events of the items below it are found as well. The paths are relative to the item the event references the file by, usually but not always the root of its space, use --file-id to find all events of an item",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Value:   "table",
+				Usage:   "the output format, either 'table', 'json' for one event per line or 'csv'",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return configlog.ReturnFatal(parser.ParseConfig(cfg))
+		},
+		Action: func(c *cli.Context) error {
+			if err := search(c, cfg); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			return nil
+		},
+	}
+}
+
+func search(c *cli.Context, cfg *config.Config) error {
+	path := cfg.Auditlog.FilePath
+	if c.IsSet("file") {
+		path = c.String("file")
+	}
+	if path == "" {
+		return fmt.Errorf("the audit log to search must be given with --file")
+	}
+
+	q, err := searchQuery(c, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var (
+		emit  func(svc.SearchResult) error
+		flush func() error
+	)
+	switch output := c.String("output"); output {
+	case "table":
+		table := tw.NewWriter(os.Stdout)
+		table.SetHeader(_searchColumns)
+		table.SetAutoFormatHeaders(false)
+		emit = func(res svc.SearchResult) error {
+			table.Append(searchRow(res))
+			return nil
+		}
+		flush = func() error {
+			table.Render()
+			return nil
+		}
+	case "json":
+		emit = func(res svc.SearchResult) error {
+			_, err := fmt.Fprintln(os.Stdout, string(res.Event))
+			return err
+		}
+		flush = func() error { return nil }
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		if err := w.Write(_searchColumns); err != nil {
+			return err
+		}
+		emit = func(res svc.SearchResult) error {
+			return w.Write(searchRow(res))
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	default:
+		return fmt.Errorf("unknown output format '%s'", output)
+	}
+
+	stats, err := svc.Search(path, q, emit)
+	if err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if stats.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d lines which are no json audit events\n", stats.Skipped)
+	}
+	if c.String("output") == "table" {
+		fmt.Printf("%d of %d events match\n", stats.Matched, stats.Searched)
+	}
+	return nil
+}
+
+func searchQuery(c *cli.Context, now time.Time) (svc.SearchQuery, error) {
+	var (
+		q   svc.SearchQuery
+		err error
+	)
+	q.User = c.String("user")
+	q.FileID = c.String("file-id")
+	q.PathPrefix = c.String("path")
+	if q.Actions, err = svc.NewFilter(c.StringSlice("action"), nil); err != nil {
+		return q, err
+	}
+	if c.IsSet("since") {
+		if q.Since, err = parseSearchTime(c.String("since"), now); err != nil {
+			return q, err
+		}
+	}
+	if c.IsSet("until") {
+		if q.Until, err = parseSearchTime(c.String("until"), now); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// parseSearchTime parses an RFC3339 timestamp, a date or a duration before now. Besides the units of
+// time.ParseDuration the duration may be given in days, e.g. 7d.
+func parseSearchTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.UTC); err == nil {
+		return t, nil
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.Add(-time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("can't parse the time '%s', use an RFC3339 timestamp, a date like 2022-11-24 or a duration like 12h or 7d", s)
+}
+
+// searchRow returns the columns of the result. The user is the one who performed the action, the user
+// of the file events is the owner of the file though.
+func searchRow(res svc.SearchResult) []string {
+	user := res.Executant
+	if user == "" {
+		user = res.User
+	}
+	return []string{res.Time, res.Action, user, res.RemoteAddr, res.FileID, res.Path, res.Message}
+}
@@ -6,12 +6,14 @@ import (
 	"crypto/x509"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/cs3org/reva/v2/pkg/events"
 	"github.com/cs3org/reva/v2/pkg/events/server"
 	"github.com/go-micro/plugins/v4/events/natsjs"
 	"github.com/owncloud/ocis/v2/ocis-pkg/config/configlog"
 	ociscrypto "github.com/owncloud/ocis/v2/ocis-pkg/crypto"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 	"github.com/owncloud/ocis/v2/services/audit/pkg/config"
 	"github.com/owncloud/ocis/v2/services/audit/pkg/config/parser"
 	"github.com/owncloud/ocis/v2/services/audit/pkg/logging"
@@ -36,7 +38,9 @@ func Server(cfg *config.Config) *cli.Command {
 			if ctx == nil {
 				ctx = context.Background()
 			}
-			ctx, cancel := context.WithCancel(ctx)
+			// the audit logger writes the buffered events when it is stopped, which needs the context to
+			// be cancelled instead of the process being killed
+			ctx, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 			defer cancel()
 
 			evtsCfg := cfg.Events
@@ -71,13 +75,12 @@ func Server(cfg *config.Config) *cli.Command {
 			if err != nil {
 				return err
 			}
-			evts, err := events.Consume(client, evtsCfg.ConsumerGroup, types.RegisteredEvents()...)
+			evts, err := ocisevents.Consume(client, evtsCfg.ConsumerGroup, types.RegisteredEvents()...)
 			if err != nil {
 				return err
 			}
 
-			svc.AuditLoggerFromConfig(ctx, cfg.Auditlog, evts, logger)
-			return nil
+			return svc.AuditLoggerFromConfig(ctx, cfg.Auditlog, evts, logger)
 		},
 	}
 }
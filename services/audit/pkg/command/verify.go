@@ -0,0 +1,73 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/config/configlog"
+	"github.com/owncloud/ocis/v2/services/audit/pkg/config"
+	"github.com/owncloud/ocis/v2/services/audit/pkg/config/parser"
+	svc "github.com/owncloud/ocis/v2/services/audit/pkg/service"
+	"github.com/urfave/cli/v2"
+)
+
+// Verify is the entrypoint for the verify command.
+func Verify(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:     "verify",
+		Usage:    "verify the hash chain of an audit log and report the first broken link",
+		Category: "audit log",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "file",
+				Aliases: []string{"f"},
+				Usage:   "the hash-chained audit log to verify, defaults to the configured logfile. Compressed logfiles are decompressed",
+			},
+			&cli.Uint64Flag{
+				Name:  "from-seq",
+				Usage: "the sequence number of the first record of the audit log. Defaults to 1, the start of the chain. Use the last sequence number reported for the previous logfile plus one to verify a rotated logfile on its own",
+			},
+			&cli.StringFlag{
+				Name:  "prev",
+				Usage: "the hash of the record before the first record of the audit log, as reported for the previous logfile. Mandatory with --from-seq",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "the key the records have been signed with, defaults to the configured HMAC key",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return configlog.ReturnFatal(parser.ParseConfig(cfg))
+		},
+		Action: func(c *cli.Context) error {
+			path := cfg.Auditlog.FilePath
+			if c.IsSet("file") {
+				path = c.String("file")
+			}
+			if path == "" {
+				return fmt.Errorf("the audit log to verify must be given with --file")
+			}
+			key := cfg.Auditlog.FileHMACKey
+			if c.IsSet("key") {
+				key = c.String("key")
+			}
+
+			f, err := svc.OpenLogfile(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			res, err := svc.VerifyChain(f, []byte(key), svc.ChainAnchor{Seq: c.Uint64("from-seq"), Prev: c.String("prev")})
+			if err != nil {
+				return err
+			}
+			if res.Broken != nil {
+				fmt.Printf("%d records verified, the last intact record is seq %d with hash %s\n", res.Records, res.LastSeq, res.LastHash)
+				return cli.Exit(fmt.Sprintf("the hash chain of '%s' is broken at %s", path, res.Broken), 1)
+			}
+			fmt.Printf("the hash chain of '%s' is intact: %d records, seq %d to %d\n", path, res.Records, res.FirstSeq, res.LastSeq)
+			fmt.Printf("last record: seq %d, hash %s\n", res.LastSeq, res.LastHash)
+			return nil
+		},
+	}
+}
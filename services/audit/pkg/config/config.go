@@ -36,5 +36,45 @@ type Auditlog struct {
 	LogToConsole bool   `yaml:"log_to_console" env:"AUDIT_LOG_TO_CONSOLE" desc:"Logs to Stdout if true. Independent of the log to file option."`
 	LogToFile    bool   `yaml:"log_to_file" env:"AUDIT_LOG_TO_FILE" desc:"Logs to file if true. Independent of the log to Stdout file option."`
 	FilePath     string `yaml:"filepath" env:"AUDIT_FILEPATH" desc:"Filepath to the logfile. Mandatory if LogToFile is true."`
-	Format       string `yaml:"format" env:"AUDIT_FORMAT" desc:"Log format. Using json is advised."`
+	Format       string `yaml:"format" env:"AUDIT_FORMAT" desc:"Log format. Supported values are 'json', 'minimal', 'cef' for the ArcSight Common Event Format and 'leef' for the QRadar Log Event Extended Format. Using json is advised unless the events are ingested by a SIEM."`
+
+	ConsoleIncludeActions []string `yaml:"console_include_actions" env:"AUDIT_CONSOLE_INCLUDE_ACTIONS" desc:"A comma-separated list of the actions, e.g. 'file_read', or categories of actions logged to Stdout. Supported categories are 'sharing', 'files', 'spaces', 'users', 'groups' and 'authentication'. All actions are logged if empty."`
+	ConsoleExcludeActions []string `yaml:"console_exclude_actions" env:"AUDIT_CONSOLE_EXCLUDE_ACTIONS" desc:"A comma-separated list of the actions or categories of actions which are not logged to Stdout, even if they are included."`
+	FileIncludeActions    []string `yaml:"file_include_actions" env:"AUDIT_FILE_INCLUDE_ACTIONS" desc:"A comma-separated list of the actions, e.g. 'file_read', or categories of actions logged to the logfile. Supported categories are 'sharing', 'files', 'spaces', 'users', 'groups' and 'authentication'. All actions are logged if empty."`
+	FileExcludeActions    []string `yaml:"file_exclude_actions" env:"AUDIT_FILE_EXCLUDE_ACTIONS" desc:"A comma-separated list of the actions or categories of actions which are not logged to the logfile, even if they are included."`
+
+	FileRotateSize     int  `yaml:"file_rotate_size" env:"AUDIT_FILE_ROTATE_SIZE" desc:"The size in MB after which the logfile is rotated. 0 disables size based rotation."`
+	FileRotateInterval int  `yaml:"file_rotate_interval" env:"AUDIT_FILE_ROTATE_INTERVAL" desc:"The interval in hours in which the logfile is rotated, e.g. 24 rotates it daily at midnight UTC. 0 disables time based rotation."`
+	FileMaxBackups     int  `yaml:"file_max_backups" env:"AUDIT_FILE_MAX_BACKUPS" desc:"The number of rotated logfiles which are kept. 0 keeps all of them."`
+	FileMaxBackupAge   int  `yaml:"file_max_backup_age" env:"AUDIT_FILE_MAX_BACKUP_AGE" desc:"The age in days after which rotated logfiles are removed. 0 keeps them forever."`
+	FileCompress       bool `yaml:"file_compress" env:"AUDIT_FILE_COMPRESS" desc:"Compresses the rotated logfiles with gzip if true."`
+	FileFlushInterval  int  `yaml:"file_flush_interval" env:"AUDIT_FILE_FLUSH_INTERVAL" desc:"The interval in seconds in which buffered audit events are written to the logfile. 0 writes every event immediately. Buffered events are written when the service is stopped but lost when the process is killed. The logfile is reopened when it has been moved, so that it can be rotated by external tools like logrotate. A standalone audit service also reopens it on SIGHUP, the oCIS runtime stops on SIGHUP though."`
+
+	FileHashChain bool   `yaml:"file_hash_chain" env:"AUDIT_FILE_HASH_CHAIN" desc:"Writes each audit event to the logfile as a record with a sequence number and a hash chained to the previous record, so that edits can be detected with 'ocis audit verify'."`
+	FileHMACKey   string `yaml:"file_hmac_key" env:"AUDIT_FILE_HMAC_KEY" desc:"Key used to sign the records of the hash-chained logfile with HMAC-SHA-256. The records are only hashed with SHA-256 if no key is set, which lets anyone who can write the logfile rewrite the whole chain."`
+
+	LogToSyslog                bool   `yaml:"log_to_syslog" env:"AUDIT_LOG_TO_SYSLOG" desc:"Sends the audit events to a syslog server if true. Independent of the log to Stdout and file options."`
+	SyslogNetwork              string `yaml:"syslog_network" env:"AUDIT_SYSLOG_NETWORK" desc:"The network used to reach the syslog server. Supported values are 'udp', 'tcp' and 'tls'."`
+	SyslogAddress              string `yaml:"syslog_address" env:"AUDIT_SYSLOG_ADDRESS" desc:"The address of the syslog server as host:port. Mandatory if LogToSyslog is true."`
+	SyslogFacility             string `yaml:"syslog_facility" env:"AUDIT_SYSLOG_FACILITY" desc:"The syslog facility the audit events are sent with, e.g. 'auth', 'authpriv', 'audit' or 'local0' to 'local7'."`
+	SyslogAppName              string `yaml:"syslog_app_name" env:"AUDIT_SYSLOG_APP_NAME" desc:"The app-name the audit events are sent with."`
+	SyslogBufferSize           int    `yaml:"syslog_buffer_size" env:"AUDIT_SYSLOG_BUFFER_SIZE" desc:"The number of audit events kept while the syslog server can't be reached. The oldest events are dropped once the buffer is full."`
+	SyslogTLSInsecure          bool   `yaml:"syslog_tls_insecure" env:"OCIS_INSECURE;AUDIT_SYSLOG_TLS_INSECURE" desc:"Whether to skip the verification of the syslog server's TLS certificate."`
+	SyslogTLSRootCACertificate string `yaml:"syslog_tls_root_ca_certificate" env:"AUDIT_SYSLOG_TLS_ROOT_CA_CERTIFICATE" desc:"The root CA certificate used to validate the syslog server's TLS certificate. If provided AUDIT_SYSLOG_TLS_INSECURE will be seen as false."`
+
+	SyslogIncludeActions []string `yaml:"syslog_include_actions" env:"AUDIT_SYSLOG_INCLUDE_ACTIONS" desc:"A comma-separated list of the actions, e.g. 'file_read', or categories of actions sent to the syslog server. Supported categories are 'sharing', 'files', 'spaces', 'users', 'groups' and 'authentication'. All actions are sent if empty."`
+	SyslogExcludeActions []string `yaml:"syslog_exclude_actions" env:"AUDIT_SYSLOG_EXCLUDE_ACTIONS" desc:"A comma-separated list of the actions or categories of actions which are not sent to the syslog server, even if they are included."`
+
+	LogToWebhook                bool   `yaml:"log_to_webhook" env:"AUDIT_LOG_TO_WEBHOOK" desc:"Posts the audit events in batches to an https endpoint if true. Independent of the other log options."`
+	WebhookURL                  string `yaml:"webhook_url" env:"AUDIT_WEBHOOK_URL" desc:"The https URL the batches of audit events are posted to. The events of a batch are separated by newlines. Mandatory if LogToWebhook is true."`
+	WebhookSecret               string `yaml:"webhook_secret" env:"AUDIT_WEBHOOK_SECRET" desc:"Key used to sign the requests with HMAC-SHA-256. The id of the batch from the X-OCIS-Batch-Id header, the time of the request in seconds since the epoch from the X-OCIS-Timestamp header and the body are joined by dots and signed. The signature is sent hex encoded in the X-OCIS-Signature header, prefixed with 'sha256='. Mandatory if LogToWebhook is true."`
+	WebhookBatchSize            int    `yaml:"webhook_batch_size" env:"AUDIT_WEBHOOK_BATCH_SIZE" desc:"The number of audit events after which a batch is sent."`
+	WebhookBatchInterval        int    `yaml:"webhook_batch_interval" env:"AUDIT_WEBHOOK_BATCH_INTERVAL" desc:"The interval in seconds in which a batch is sent even if it isn't full. 0 only sends full batches."`
+	WebhookSpoolDir             string `yaml:"webhook_spool_dir" env:"AUDIT_WEBHOOK_SPOOL_DIR" desc:"The directory the batches are kept in until the endpoint acknowledged them with a 2xx status. Failed requests are retried with an exponential backoff and the spooled batches are sent again after a restart. Batches rejected with a 4xx status other than 401, 403, 404, 408 and 429 are not retried, they are moved to the failed subdirectory."`
+	WebhookSpoolMaxSize         int    `yaml:"webhook_spool_max_size" env:"AUDIT_WEBHOOK_SPOOL_MAX_SIZE" desc:"The size in MB of the spooled batches after which the oldest ones are dropped. 0 keeps all of them. The batches in the failed subdirectory are limited to the same size on their own."`
+	WebhookTLSInsecure          bool   `yaml:"webhook_tls_insecure" env:"OCIS_INSECURE;AUDIT_WEBHOOK_TLS_INSECURE" desc:"Whether to skip the verification of the endpoint's TLS certificate."`
+	WebhookTLSRootCACertificate string `yaml:"webhook_tls_root_ca_certificate" env:"AUDIT_WEBHOOK_TLS_ROOT_CA_CERTIFICATE" desc:"The root CA certificate used to validate the endpoint's TLS certificate. If provided AUDIT_WEBHOOK_TLS_INSECURE will be seen as false."`
+
+	WebhookIncludeActions []string `yaml:"webhook_include_actions" env:"AUDIT_WEBHOOK_INCLUDE_ACTIONS" desc:"A comma-separated list of the actions, e.g. 'file_read', or categories of actions posted to the webhook. Supported categories are 'sharing', 'files', 'spaces', 'users', 'groups' and 'authentication'. All actions are posted if empty."`
+	WebhookExcludeActions []string `yaml:"webhook_exclude_actions" env:"AUDIT_WEBHOOK_EXCLUDE_ACTIONS" desc:"A comma-separated list of the actions or categories of actions which are not posted to the webhook, even if they are included."`
 }
@@ -1,6 +1,9 @@
 package defaults
 
 import (
+	"path/filepath"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/config/defaults"
 	"github.com/owncloud/ocis/v2/services/audit/pkg/config"
 )
 
@@ -28,8 +31,18 @@ func DefaultConfig() *config.Config {
 			EnableTLS:     false,
 		},
 		Auditlog: config.Auditlog{
-			LogToConsole: true,
-			Format:       "json",
+			LogToConsole:         true,
+			Format:               "json",
+			FileFlushInterval:    0,
+			SyslogNetwork:        "udp",
+			SyslogAddress:        "127.0.0.1:514",
+			SyslogFacility:       "local0",
+			SyslogAppName:        "ocis-audit",
+			SyslogBufferSize:     1000,
+			WebhookBatchSize:     100,
+			WebhookBatchInterval: 10,
+			WebhookSpoolDir:      filepath.Join(defaults.BaseDataPath(), "audit", "webhook"),
+			WebhookSpoolMaxSize:  512,
 		},
 	}
 }
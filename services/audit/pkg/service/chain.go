@@ -0,0 +1,317 @@
+package svc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+)
+
+const (
+	// ChainAlgSHA256 marks records hashed with SHA-256
+	ChainAlgSHA256 = "sha256"
+	// ChainAlgHMACSHA256 marks records signed with HMAC-SHA-256
+	ChainAlgHMACSHA256 = "hmac-sha256"
+
+	// maxRecordSize is the maximum size of a record read from an audit log
+	maxRecordSize = 16 * 1024 * 1024
+)
+
+// genesisHash is the previous hash of the first record of a chain
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+// ChainRecord is a line of a hash-chained audit log. The hash covers the sequence number, the hash of
+// the previous record, the algorithm and the event, so that every record vouches for all records
+// before it.
+type ChainRecord struct {
+	Seq   uint64          `json:"seq"`
+	Prev  string          `json:"prev"`
+	Alg   string          `json:"alg"`
+	Event json.RawMessage `json:"event"`
+	Hash  string          `json:"hash"`
+}
+
+// Chain creates the records of a hash-chained audit log
+type Chain struct {
+	key  []byte
+	seq  uint64
+	prev string
+
+	mutex sync.Mutex
+}
+
+// NewChain returns a new Chain. The records are signed with HMAC-SHA-256 if a key is given and hashed
+// with SHA-256 otherwise.
+func NewChain(key []byte) *Chain {
+	return &Chain{
+		key:  key,
+		prev: genesisHash,
+	}
+}
+
+// Resume continues the chain after the last record of the audit log at the given path. If the file is
+// missing or empty the chain is continued after the last record of the newest rotated logfile, a new
+// chain is started if there is none.
+func (c *Chain) Resume(path string) error {
+	last, err := lastRecord(path)
+	if err != nil {
+		return err
+	}
+	if last == nil {
+		backups, err := backupsOf(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if len(backups) > 0 {
+			path = backups[0].path
+			if last, err = lastRecord(path); err != nil {
+				return err
+			}
+		}
+	}
+	if last == nil {
+		return nil
+	}
+
+	rec := ChainRecord{}
+	if err := json.Unmarshal(last, &rec); err != nil || rec.Hash == "" {
+		return fmt.Errorf("the last record of the audit log '%s' is invalid, check the log with 'ocis audit verify'", path)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.seq = rec.Seq
+	c.prev = rec.Hash
+	return nil
+}
+
+// Next returns the record for the given content as a line, without the line break, to append to the
+// audit log. Content which is no valid JSON is recorded as a string.
+func (c *Chain) Next(content []byte) ([]byte, error) {
+	event, err := eventJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	rec := ChainRecord{
+		Seq:   c.seq + 1,
+		Prev:  c.prev,
+		Alg:   c.alg(),
+		Event: event,
+	}
+	rec.Hash = recordHash(c.key, rec)
+
+	// the event has to be written as it has been hashed
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(rec); err != nil {
+		return nil, err
+	}
+	c.seq = rec.Seq
+	c.prev = rec.Hash
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+func (c *Chain) alg() string {
+	if len(c.key) > 0 {
+		return ChainAlgHMACSHA256
+	}
+	return ChainAlgSHA256
+}
+
+// WriteChained returns a Log function appending hash-chained records to the logfile of the writer. The
+// chain is continued after the last record of the existing logfile.
+func WriteChained(w *FileWriter, key []byte, log log.Logger) (Log, error) {
+	chain := NewChain(key)
+	if err := chain.Resume(w.path); err != nil {
+		return nil, err
+	}
+	return func(content []byte) {
+		line, err := chain.Next(content)
+		if err != nil {
+			log.Error().Err(err).Msg("error creating the audit log record")
+			return
+		}
+		w.Write(line)
+	}, nil
+}
+
+// ChainAnchor is the position in the chain a verified audit log has to start at. The zero value expects
+// the log to start the chain.
+type ChainAnchor struct {
+	// Seq is the sequence number of the first record of the log
+	Seq uint64
+	// Prev is the hash of the record before the first record of the log. It is needed unless the log
+	// starts the chain.
+	Prev string
+}
+
+// ChainVerification is the result of verifying a hash-chained audit log
+type ChainVerification struct {
+	// Records is the number of records which have been verified successfully
+	Records int
+	// FirstSeq and LastSeq are the sequence numbers of the first and the last verified record
+	FirstSeq, LastSeq uint64
+	// LastHash is the hash of the last verified record, which anchors the verification of the next
+	// rotated logfile
+	LastHash string
+	// Broken is the first broken link of the chain, nil if the chain is intact
+	Broken *BrokenLink
+}
+
+// BrokenLink describes a record which doesn't fit into the chain
+type BrokenLink struct {
+	// Line is the line number of the record in the audit log
+	Line int
+	// Seq is the sequence number of the record, 0 if it couldn't be read
+	Seq uint64
+	// Reason describes what is wrong with the record
+	Reason string
+}
+
+func (b BrokenLink) String() string {
+	return fmt.Sprintf("line %d (seq %d): %s", b.Line, b.Seq, b.Reason)
+}
+
+// VerifyChain checks the hash-chained audit log read from r and reports the first broken link. Edited
+// records fail the hash check, deleted and inserted records break the sequence or the link to the
+// previous record. The first record has to continue the chain at the given anchor, so that records
+// removed from the beginning of the log are detected as well. Rotated logs are verified on their own
+// with the last sequence number and hash of the previous logfile as anchor. The key has to be given
+// if the records are signed.
+func VerifyChain(r io.Reader, key []byte, from ChainAnchor) (*ChainVerification, error) {
+	if from.Seq <= 1 && from.Prev == "" {
+		from = ChainAnchor{Seq: 1, Prev: genesisHash}
+	}
+	if from.Prev == "" {
+		return nil, fmt.Errorf("the hash of the record before seq %d is needed to verify the chain", from.Seq)
+	}
+
+	res := &ChainVerification{
+		LastSeq:  from.Seq - 1,
+		LastHash: from.Prev,
+	}
+	var line int
+	scanner := newRecordScanner(r)
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		rec := ChainRecord{}
+		if err := json.Unmarshal(raw, &rec); err != nil || rec.Hash == "" {
+			res.Broken = &BrokenLink{Line: line, Reason: "not a record of a hash-chained audit log"}
+			return res, nil
+		}
+		broken := func(reason string, args ...interface{}) (*ChainVerification, error) {
+			res.Broken = &BrokenLink{Line: line, Seq: rec.Seq, Reason: fmt.Sprintf(reason, args...)}
+			return res, nil
+		}
+
+		switch {
+		case rec.Alg == ChainAlgHMACSHA256 && len(key) == 0:
+			return broken("the record is signed, the key is needed to verify it")
+		case rec.Alg == ChainAlgSHA256 && len(key) > 0:
+			return broken("the record is not signed")
+		case rec.Alg != ChainAlgSHA256 && rec.Alg != ChainAlgHMACSHA256:
+			return broken("unknown algorithm '%s'", rec.Alg)
+		}
+		if !hmac.Equal([]byte(recordHash(key, rec)), []byte(rec.Hash)) {
+			return broken("the record has been modified")
+		}
+		expected := res.LastSeq + 1
+		switch {
+		case rec.Seq > expected:
+			return broken("%d record(s) missing after seq %d", rec.Seq-expected, res.LastSeq)
+		case rec.Seq < expected:
+			return broken("unexpected record, expected seq %d", expected)
+		case rec.Prev != res.LastHash:
+			return broken("the record does not link to the previous record")
+		}
+		if res.Records == 0 {
+			res.FirstSeq = rec.Seq
+		}
+
+		res.Records++
+		res.LastSeq = rec.Seq
+		res.LastHash = rec.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// recordHash returns the hex encoded hash of the record
+func recordHash(key []byte, rec ChainRecord) string {
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write([]byte(strconv.FormatUint(rec.Seq, 10)))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(rec.Prev))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(rec.Alg))
+	h.Write([]byte{'\n'})
+	h.Write(rec.Event)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// eventJSON returns the content as compact JSON, which is how it appears in the record
+func eventJSON(content []byte) (json.RawMessage, error) {
+	if json.Valid(content) {
+		buf := &bytes.Buffer{}
+		if err := json.Compact(buf, content); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return json.Marshal(string(content))
+}
+
+// lastRecord returns the last non-empty line of the logfile at the given path, nil if the file is
+// missing or empty
+func lastRecord(path string) ([]byte, error) {
+	f, err := OpenLogfile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var last []byte
+	scanner := newRecordScanner(f)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	return last, scanner.Err()
+}
+
+func newRecordScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	return scanner
+}
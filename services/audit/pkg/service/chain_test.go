@@ -0,0 +1,167 @@
+package svc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/test-go/testify/require"
+)
+
+// writeChain writes n events to a hash-chained audit log and returns its lines
+func writeChain(t *testing.T, path string, key string, n int) []string {
+	w, err := NewFileWriter(path, FileWriterOptions{}, log.NewLogger())
+	require.NoError(t, err)
+	l, err := WriteChained(w, []byte(key), log.NewLogger())
+	require.NoError(t, err)
+	for i := 0; i < n; i++ {
+		l([]byte(fmt.Sprintf(`{"Action":"file_shared","Message":"user <%d> shared a file"}`, i)))
+	}
+	require.NoError(t, w.Close())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func verify(t *testing.T, lines []string, key string) *ChainVerification {
+	return verifyFrom(t, lines, key, ChainAnchor{})
+}
+
+func verifyFrom(t *testing.T, lines []string, key string, from ChainAnchor) *ChainVerification {
+	res, err := VerifyChain(strings.NewReader(strings.Join(lines, "\n")), []byte(key), from)
+	require.NoError(t, err)
+	return res
+}
+
+func TestChainIsIntact(t *testing.T) {
+	lines := writeChain(t, filepath.Join(t.TempDir(), "audit.log"), "", 5)
+	require.Len(t, lines, 5)
+
+	res := verify(t, lines, "")
+	require.Nil(t, res.Broken)
+	require.Equal(t, 5, res.Records)
+	require.Equal(t, uint64(1), res.FirstSeq)
+	require.Equal(t, uint64(5), res.LastSeq)
+}
+
+func TestChainDetectsEdits(t *testing.T) {
+	lines := writeChain(t, filepath.Join(t.TempDir(), "audit.log"), "", 5)
+	lines[2] = strings.Replace(lines[2], "file_shared", "file_deleted", 1)
+
+	res := verify(t, lines, "")
+	require.NotNil(t, res.Broken)
+	require.Equal(t, 3, res.Broken.Line)
+	require.Equal(t, uint64(3), res.Broken.Seq)
+	require.Contains(t, res.Broken.Reason, "modified")
+	require.Equal(t, 2, res.Records)
+}
+
+func TestChainDetectsDeletions(t *testing.T) {
+	lines := writeChain(t, filepath.Join(t.TempDir(), "audit.log"), "", 5)
+	lines = append(lines[:1], lines[3:]...)
+
+	res := verify(t, lines, "")
+	require.NotNil(t, res.Broken)
+	require.Equal(t, 2, res.Broken.Line)
+	require.Equal(t, uint64(4), res.Broken.Seq)
+	require.Contains(t, res.Broken.Reason, "2 record(s) missing after seq 1")
+}
+
+func TestChainDetectsTruncation(t *testing.T) {
+	lines := writeChain(t, filepath.Join(t.TempDir(), "audit.log"), "", 5)
+
+	res := verify(t, lines[2:], "")
+	require.NotNil(t, res.Broken)
+	require.Equal(t, 1, res.Broken.Line)
+	require.Contains(t, res.Broken.Reason, "2 record(s) missing after seq 0")
+}
+
+func TestChainVerifiesFromAnAnchor(t *testing.T) {
+	lines := writeChain(t, filepath.Join(t.TempDir(), "audit.log"), "", 5)
+	first := verify(t, lines[:2], "")
+	require.Nil(t, first.Broken)
+	require.Equal(t, uint64(2), first.LastSeq)
+
+	res := verifyFrom(t, lines[2:], "", ChainAnchor{Seq: first.LastSeq + 1, Prev: first.LastHash})
+	require.Nil(t, res.Broken)
+	require.Equal(t, uint64(3), res.FirstSeq)
+	require.Equal(t, uint64(5), res.LastSeq)
+
+	res = verifyFrom(t, lines[2:], "", ChainAnchor{Seq: 3, Prev: strings.Repeat("1", 64)})
+	require.NotNil(t, res.Broken)
+	require.Contains(t, res.Broken.Reason, "does not link")
+
+	_, err := VerifyChain(strings.NewReader(strings.Join(lines[2:], "\n")), nil, ChainAnchor{Seq: 3})
+	require.Error(t, err)
+}
+
+func TestChainDetectsInsertions(t *testing.T) {
+	lines := writeChain(t, filepath.Join(t.TempDir(), "audit.log"), "", 3)
+	lines = append(lines[:2], lines[1:]...)
+
+	res := verify(t, lines, "")
+	require.NotNil(t, res.Broken)
+	require.Equal(t, 3, res.Broken.Line)
+	require.Contains(t, res.Broken.Reason, "unexpected record")
+
+	// a record of another chain doesn't link to the previous record even if the sequence fits
+	other := NewChain(nil)
+	_, err := other.Next([]byte(`{"Action":"file_deleted"}`))
+	require.NoError(t, err)
+	forged, err := other.Next([]byte(`{"Action":"file_deleted"}`))
+	require.NoError(t, err)
+	lines = writeChain(t, filepath.Join(t.TempDir(), "audit.log"), "", 3)
+	lines[1] = string(forged)
+	res = verify(t, lines, "")
+	require.NotNil(t, res.Broken)
+	require.Equal(t, 2, res.Broken.Line)
+}
+
+func TestChainDetectsForeignLines(t *testing.T) {
+	lines := writeChain(t, filepath.Join(t.TempDir(), "audit.log"), "", 3)
+	lines = append(lines, `{"Action":"file_shared"}`)
+
+	res := verify(t, lines, "")
+	require.NotNil(t, res.Broken)
+	require.Equal(t, 4, res.Broken.Line)
+	require.Equal(t, 3, res.Records)
+}
+
+func TestChainSignsWithHMAC(t *testing.T) {
+	lines := writeChain(t, filepath.Join(t.TempDir(), "audit.log"), "secret", 3)
+	require.Contains(t, lines[0], `"alg":"hmac-sha256"`)
+
+	require.Nil(t, verify(t, lines, "secret").Broken)
+	require.Contains(t, verify(t, lines, "wrong").Broken.Reason, "modified")
+	require.Contains(t, verify(t, lines, "").Broken.Reason, "key is needed")
+
+	// without the key a forger can't recompute a valid hash
+	unsigned := writeChain(t, filepath.Join(t.TempDir(), "unsigned.log"), "", 1)
+	require.Contains(t, verify(t, unsigned, "secret").Broken.Reason, "not signed")
+}
+
+func TestChainResumesAfterTheLastRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeChain(t, path, "", 2)
+	lines := writeChain(t, path, "", 2)
+	require.Len(t, lines, 4)
+
+	res := verify(t, lines, "")
+	require.Nil(t, res.Broken)
+	require.Equal(t, uint64(4), res.LastSeq)
+
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\ngarbage\n"), 0600))
+	require.Error(t, NewChain(nil).Resume(path))
+}
+
+func TestChainRecordsPlainContentAsString(t *testing.T) {
+	line, err := NewChain(nil).Next([]byte("file_shared)\n   user shared a file"))
+	require.NoError(t, err)
+	require.Contains(t, string(line), `"event":"file_shared)\n   user shared a file"`)
+	require.False(t, bytes.Contains(line, []byte("\n")))
+	require.Nil(t, verify(t, []string{string(line)}, "").Broken)
+}
@@ -0,0 +1,397 @@
+package svc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+)
+
+const (
+	// backupTimeFormat is the timestamp appended to the name of rotated logfiles
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	// compressedSuffix is appended to the name of compressed logfiles
+	compressedSuffix = ".gz"
+
+	// fileCheckInterval is the interval in which the logfile is checked for rotation if the
+	// events are flushed immediately
+	fileCheckInterval = time.Second
+)
+
+// FileWriterOptions configures the rotation and retention of the logfile
+type FileWriterOptions struct {
+	// MaxSize is the size in bytes after which the logfile is rotated, 0 disables size based rotation
+	MaxSize int64
+	// RotationInterval is the interval in which the logfile is rotated, 0 disables time based rotation
+	RotationInterval time.Duration
+	// MaxBackups is the number of rotated logfiles which are kept, 0 keeps all
+	MaxBackups int
+	// MaxBackupAge is the age after which rotated logfiles are removed, 0 keeps them forever
+	MaxBackupAge time.Duration
+	// Compress gzips the rotated logfiles
+	Compress bool
+	// FlushInterval is the interval in which buffered events are written to the logfile, 0 writes every
+	// event immediately
+	FlushInterval time.Duration
+}
+
+// FileWriter appends the audit events to a logfile which is kept open. The logfile is rotated when it
+// exceeds the maximum size or when the rotation interval has passed. Rotated logfiles are renamed to
+// the path with a timestamp appended, compressed in the background and removed according to the
+// retention settings. When the logfile has been moved away, e.g. by logrotate, it is reopened with the
+// next check. A SIGHUP reopens it immediately, which only reaches the writer when the audit service
+// runs on its own, as the oCIS runtime stops on SIGHUP.
+type FileWriter struct {
+	path string
+	opts FileWriterOptions
+	log  log.Logger
+	now  func() time.Time
+
+	mutex    sync.Mutex
+	file     *os.File
+	buf      *bufio.Writer
+	size     int64
+	openedAt time.Time
+
+	cleanupMutex sync.Mutex
+	cleanups     sync.WaitGroup
+}
+
+// NewFileWriter opens the logfile at the given path. Start has to be called to flush and rotate the
+// logfile in the background.
+func NewFileWriter(path string, opts FileWriterOptions, log log.Logger) (*FileWriter, error) {
+	w := &FileWriter{
+		path: path,
+		opts: opts,
+		log:  log,
+		now:  time.Now,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Start flushes and rotates the logfile in the background until the context is done. Close has to be
+// called afterwards to write the buffered events, it isn't done in the background as the process may
+// exit before.
+func (w *FileWriter) Start(ctx context.Context) {
+	interval := w.opts.FlushInterval
+	if interval <= 0 {
+		interval = fileCheckInterval
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := w.Reopen(); err != nil {
+					w.log.Error().Err(err).Msgf("error reopening file '%s'", w.path)
+				}
+			case <-ticker.C:
+				w.check()
+			}
+		}
+	}()
+}
+
+// Write appends the content as a line to the logfile. It implements Log.
+func (w *FileWriter) Write(content []byte) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		w.log.Error().Msgf("error writing to file '%s': the file is closed", w.path)
+		return
+	}
+
+	n := int64(len(content) + 1)
+	if w.size > 0 && (w.opts.MaxSize > 0 && w.size+n > w.opts.MaxSize || w.intervalPassed()) {
+		if err := w.rotate(); err != nil {
+			w.log.Error().Err(err).Msgf("error rotating file '%s'", w.path)
+			if w.file == nil {
+				return
+			}
+		}
+	}
+
+	w.buf.Write(content)
+	w.buf.WriteByte('\n')
+	w.size += n
+	if w.opts.FlushInterval <= 0 {
+		if err := w.buf.Flush(); err != nil {
+			w.log.Error().Err(err).Msgf("error writing to file '%s'", w.path)
+		}
+	}
+}
+
+// Rotate renames the logfile and continues with a new one
+func (w *FileWriter) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.rotate()
+}
+
+// Reopen closes the logfile and opens the file at the path again, which is needed when the logfile
+// has been renamed by an external tool
+func (w *FileWriter) Reopen() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := w.close(); err != nil {
+		w.log.Error().Err(err).Msgf("error closing file '%s'", w.path)
+	}
+	return w.open()
+}
+
+// Close flushes and closes the logfile and waits until the rotated logfiles have been compressed
+func (w *FileWriter) Close() error {
+	w.mutex.Lock()
+	err := w.close()
+	w.mutex.Unlock()
+	w.cleanups.Wait()
+	return err
+}
+
+// check flushes the buffered events, rotates the logfile when the rotation interval has passed and
+// reopens it when it has been moved away
+func (w *FileWriter) check() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.log.Error().Err(err).Msgf("error writing to file '%s'", w.path)
+	}
+
+	if w.size > 0 && w.intervalPassed() {
+		if err := w.rotate(); err != nil {
+			w.log.Error().Err(err).Msgf("error rotating file '%s'", w.path)
+		}
+		return
+	}
+
+	current, err := os.Stat(w.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	opened, err2 := w.file.Stat()
+	if err2 != nil || current != nil && os.SameFile(current, opened) {
+		return
+	}
+	w.log.Info().Msgf("file '%s' has been moved, reopening it", w.path)
+	if err := w.close(); err != nil {
+		w.log.Error().Err(err).Msgf("error closing file '%s'", w.path)
+	}
+	if err := w.open(); err != nil {
+		w.log.Error().Err(err).Msgf("error reopening file '%s'", w.path)
+	}
+}
+
+// intervalPassed returns true if the logfile has been opened in a previous rotation interval. The
+// intervals are aligned to the zero time, so that e.g. daily logfiles are rotated at midnight UTC.
+func (w *FileWriter) intervalPassed() bool {
+	if w.opts.RotationInterval <= 0 {
+		return false
+	}
+	return !w.now().Truncate(w.opts.RotationInterval).Equal(w.openedAt.Truncate(w.opts.RotationInterval))
+}
+
+func (w *FileWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.buf = bufio.NewWriter(file)
+	w.size = info.Size()
+	w.openedAt = w.now()
+	if w.size > 0 {
+		// an existing logfile belongs to the interval it has last been written in
+		w.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (w *FileWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.buf.Flush()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	w.buf = nil
+	return err
+}
+
+func (w *FileWriter) rotate() error {
+	if err := w.close(); err != nil {
+		w.log.Error().Err(err).Msgf("error closing file '%s'", w.path)
+	}
+	backup := w.path + "." + w.now().UTC().Format(backupTimeFormat)
+	renameErr := os.Rename(w.path, backup)
+	if err := w.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	w.cleanups.Add(1)
+	go func() {
+		defer w.cleanups.Done()
+		w.cleanup()
+	}()
+	return nil
+}
+
+// cleanup compresses the rotated logfiles and removes the ones which exceed the retention
+func (w *FileWriter) cleanup() {
+	w.cleanupMutex.Lock()
+	defer w.cleanupMutex.Unlock()
+
+	backups, err := backupsOf(w.path)
+	if err != nil {
+		w.log.Error().Err(err).Msgf("error listing the rotated files of '%s'", w.path)
+		return
+	}
+	for i, b := range backups {
+		switch {
+		case w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups,
+			w.opts.MaxBackupAge > 0 && w.now().Sub(b.rotatedAt) > w.opts.MaxBackupAge:
+			if err := os.Remove(b.path); err != nil {
+				w.log.Error().Err(err).Msgf("error removing file '%s'", b.path)
+			}
+		case w.opts.Compress && !b.compressed:
+			if err := compressFile(b.path); err != nil {
+				w.log.Error().Err(err).Msgf("error compressing file '%s'", b.path)
+			}
+		}
+	}
+}
+
+// backup is a rotated logfile
+type backup struct {
+	path       string
+	rotatedAt  time.Time
+	compressed bool
+}
+
+// backupsOf returns the rotated logfiles of the logfile at the given path, the newest first
+func backupsOf(path string) ([]backup, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(path) + "."
+
+	var backups []backup
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		b := backup{path: filepath.Join(filepath.Dir(path), e.Name())}
+		timestamp := strings.TrimPrefix(e.Name(), prefix)
+		if strings.HasSuffix(timestamp, compressedSuffix) {
+			timestamp = strings.TrimSuffix(timestamp, compressedSuffix)
+			b.compressed = true
+		}
+		if b.rotatedAt, err = time.Parse(backupTimeFormat, timestamp); err != nil {
+			// not one of our files, e.g. a file which is being compressed
+			continue
+		}
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].rotatedAt.After(backups[j].rotatedAt)
+	})
+	return backups, nil
+}
+
+// compressFile gzips the file and removes the uncompressed one
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + compressedSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+compressedSuffix); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// OpenLogfile opens a logfile for reading, rotated logfiles which have been compressed are
+// decompressed transparently
+func OpenLogfile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, compressedSuffix) {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return gzipFile{Reader: zr, file: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g gzipFile) Close() error {
+	err := g.Reader.Close()
+	if cerr := g.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,205 @@
+package svc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/test-go/testify/require"
+)
+
+// clock is a time source for the tests which only moves when it is told to
+type clock struct {
+	mutex sync.Mutex
+	t     time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.t
+}
+
+func (c *clock) add(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestFileWriter(t *testing.T, path string, opts FileWriterOptions) (*FileWriter, *clock) {
+	c := &clock{t: time.Date(2022, 11, 14, 10, 0, 0, 0, time.UTC)}
+	w, err := NewFileWriter(path, opts, log.NewLogger())
+	require.NoError(t, err)
+	w.now = c.now
+	w.openedAt = c.now()
+	t.Cleanup(func() { w.Close() })
+	return w, c
+}
+
+func readLogfile(t *testing.T, path string) string {
+	f, err := OpenLogfile(path)
+	require.NoError(t, err)
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func backupPaths(t *testing.T, path string) []string {
+	backups, err := backupsOf(path)
+	require.NoError(t, err)
+	paths := make([]string, 0, len(backups))
+	for _, b := range backups {
+		paths = append(paths, b.path)
+	}
+	return paths
+}
+
+func TestFileWriterRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, c := newTestFileWriter(t, path, FileWriterOptions{MaxSize: 20})
+
+	for i := 0; i < 5; i++ {
+		w.Write([]byte(fmt.Sprintf("event %d", i)))
+		c.add(time.Second)
+	}
+	require.NoError(t, w.Close())
+
+	backups := backupPaths(t, path)
+	require.Equal(t, []string{path + ".2022-11-14T10-00-04.000", path + ".2022-11-14T10-00-02.000"}, backups)
+	require.Equal(t, "event 0\nevent 1\n", readLogfile(t, backups[1]))
+	require.Equal(t, "event 2\nevent 3\n", readLogfile(t, backups[0]))
+	require.Equal(t, "event 4\n", readLogfile(t, path))
+}
+
+func TestFileWriterRotatesByInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, c := newTestFileWriter(t, path, FileWriterOptions{RotationInterval: 24 * time.Hour})
+
+	w.Write([]byte("monday"))
+	c.add(13 * time.Hour)
+	w.Write([]byte("still monday"))
+	w.check()
+	require.Empty(t, backupPaths(t, path))
+
+	// the logfile is rotated at midnight even if nothing is written
+	c.add(time.Hour)
+	w.check()
+	require.Equal(t, []string{path + ".2022-11-15T00-00-00.000"}, backupPaths(t, path))
+	require.Equal(t, "monday\nstill monday\n", readLogfile(t, path+".2022-11-15T00-00-00.000"))
+
+	// an empty logfile isn't rotated
+	c.add(24 * time.Hour)
+	w.check()
+	require.Len(t, backupPaths(t, path), 1)
+}
+
+func TestFileWriterCompressesAndPrunesBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, c := newTestFileWriter(t, path, FileWriterOptions{MaxBackups: 2, Compress: true})
+
+	for i := 0; i < 4; i++ {
+		w.Write([]byte(fmt.Sprintf("event %d", i)))
+		require.NoError(t, w.Rotate())
+		c.add(time.Minute)
+	}
+	require.NoError(t, w.Close())
+
+	backups := backupPaths(t, path)
+	require.Equal(t, []string{path + ".2022-11-14T10-03-00.000.gz", path + ".2022-11-14T10-02-00.000.gz"}, backups)
+	require.Equal(t, "event 3\n", readLogfile(t, backups[0]))
+	require.Equal(t, "event 2\n", readLogfile(t, backups[1]))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+}
+
+func TestFileWriterRemovesOldBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, c := newTestFileWriter(t, path, FileWriterOptions{MaxBackupAge: 7 * 24 * time.Hour})
+
+	w.Write([]byte("old"))
+	require.NoError(t, w.Rotate())
+	w.cleanups.Wait()
+	c.add(6 * 24 * time.Hour)
+	w.Write([]byte("recent"))
+	require.NoError(t, w.Rotate())
+	w.cleanups.Wait()
+	require.Len(t, backupPaths(t, path), 2)
+
+	c.add(2 * 24 * time.Hour)
+	w.Write([]byte("new"))
+	require.NoError(t, w.Rotate())
+	w.cleanups.Wait()
+	backups := backupPaths(t, path)
+	require.Len(t, backups, 2)
+	require.Equal(t, "recent\n", readLogfile(t, backups[1]))
+}
+
+func TestFileWriterBuffersEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, _ := newTestFileWriter(t, path, FileWriterOptions{FlushInterval: time.Minute})
+
+	w.Write([]byte("event"))
+	require.Empty(t, readLogfile(t, path))
+	w.check()
+	require.Equal(t, "event\n", readLogfile(t, path))
+}
+
+func TestFileWriterReopensMovedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, _ := newTestFileWriter(t, path, FileWriterOptions{})
+
+	w.Write([]byte("before"))
+	require.NoError(t, os.Rename(path, path+".1"))
+	w.Write([]byte("still old file"))
+
+	// e.g. logrotate without a postrotate script
+	w.check()
+	w.Write([]byte("after check"))
+	require.Equal(t, "before\nstill old file\n", readLogfile(t, path+".1"))
+	require.Equal(t, "after check\n", readLogfile(t, path))
+
+	// e.g. logrotate sending SIGHUP
+	require.NoError(t, os.Rename(path, path+".2"))
+	require.NoError(t, w.Reopen())
+	w.Write([]byte("after reopen"))
+	require.Equal(t, "after check\n", readLogfile(t, path+".2"))
+	require.Equal(t, "after reopen\n", readLogfile(t, path))
+}
+
+func TestChainContinuesAcrossRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, _ := newTestFileWriter(t, path, FileWriterOptions{Compress: true})
+	l, err := WriteChained(w, nil, log.NewLogger())
+	require.NoError(t, err)
+	l([]byte(`{"Action":"file_shared"}`))
+	l([]byte(`{"Action":"file_deleted"}`))
+	require.NoError(t, w.Rotate())
+	require.NoError(t, w.Close())
+
+	// the new logfile is empty, the chain is continued after the last record of the rotated one
+	w, _ = newTestFileWriter(t, path, FileWriterOptions{})
+	l, err = WriteChained(w, nil, log.NewLogger())
+	require.NoError(t, err)
+	l([]byte(`{"Action":"file_restored"}`))
+	require.NoError(t, w.Close())
+
+	backups := backupPaths(t, path)
+	require.Len(t, backups, 1)
+	require.True(t, strings.HasSuffix(backups[0], ".gz"))
+	rotated, err := VerifyChain(strings.NewReader(readLogfile(t, backups[0])), nil, ChainAnchor{})
+	require.NoError(t, err)
+	require.Nil(t, rotated.Broken)
+	res, err := VerifyChain(strings.NewReader(readLogfile(t, path)), nil, ChainAnchor{Seq: rotated.LastSeq + 1, Prev: rotated.LastHash})
+	require.NoError(t, err)
+	require.Nil(t, res.Broken)
+	require.Equal(t, uint64(3), res.LastSeq)
+}
@@ -0,0 +1,55 @@
+package svc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/owncloud/ocis/v2/services/audit/pkg/types"
+)
+
+// Filter decides which audit events are written to a sink. Its rules name actions, e.g. file_read,
+// or categories of actions, e.g. sharing. The zero value passes all audit events.
+type Filter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// NewFilter returns a Filter passing the included actions and categories, or all of them if nothing is
+// included. Excluded actions and categories never pass, even if they are included.
+func NewFilter(include, exclude []string) (Filter, error) {
+	var (
+		f   Filter
+		err error
+	)
+	if f.include, err = filterRules(include); err != nil {
+		return Filter{}, err
+	}
+	if f.exclude, err = filterRules(exclude); err != nil {
+		return Filter{}, err
+	}
+	return f, nil
+}
+
+// Match returns true if audit events with the given action pass the filter
+func (f Filter) Match(action string) bool {
+	category := types.Category(action)
+	if f.exclude[action] || f.exclude[category] {
+		return false
+	}
+	return len(f.include) == 0 || f.include[action] || f.include[category]
+}
+
+func filterRules(names []string) (map[string]bool, error) {
+	rules := make(map[string]bool, len(names))
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		if types.Category(n) == "" && !types.IsCategory(n) {
+			return nil, fmt.Errorf("unknown audit action or category '%s'", n)
+		}
+		rules[n] = true
+	}
+	return rules, nil
+}
@@ -0,0 +1,69 @@
+package svc
+
+import (
+	"testing"
+
+	"github.com/test-go/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		passes  []string
+		blocks  []string
+	}{
+		{
+			name:   "everything passes by default",
+			passes: []string{"file_read", "file_shared", "user_authenticated"},
+		}, {
+			name:    "exclude an action",
+			exclude: []string{"file_read"},
+			passes:  []string{"file_create", "file_shared"},
+			blocks:  []string{"file_read"},
+		}, {
+			name:    "include categories",
+			include: []string{"sharing", " users "},
+			passes:  []string{"file_shared", "public_link_accessed", "user_created"},
+			blocks:  []string{"file_read", "group_created", "user_authenticated"},
+		}, {
+			name:    "include an action",
+			include: []string{"space_deleted"},
+			passes:  []string{"space_deleted"},
+			blocks:  []string{"space_created"},
+		}, {
+			name:    "exclusion wins",
+			include: []string{"files"},
+			exclude: []string{"file_read"},
+			passes:  []string{"file_create", "file_delete"},
+			blocks:  []string{"file_read", "user_created"},
+		}, {
+			name:    "exclude a category",
+			exclude: []string{"authentication"},
+			passes:  []string{"file_read"},
+			blocks:  []string{"user_authenticated"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.include, tt.exclude)
+			require.NoError(t, err)
+			for _, a := range tt.passes {
+				require.True(t, f.Match(a), a)
+			}
+			for _, a := range tt.blocks {
+				require.False(t, f.Match(a), a)
+			}
+		})
+	}
+}
+
+func TestFilterRejectsUnknownActions(t *testing.T) {
+	_, err := NewFilter([]string{"file_downloaded"}, nil)
+	require.Error(t, err)
+
+	_, err = NewFilter(nil, []string{"sharing", "nonsense"})
+	require.Error(t, err)
+}
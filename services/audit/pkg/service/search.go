@@ -0,0 +1,183 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/owncloud/ocis/v2/services/audit/pkg/types"
+)
+
+// SearchQuery describes the audit events to find in an audit log. Empty fields match all events.
+type SearchQuery struct {
+	// User matches the user who performed the action or whose item, share or account was affected
+	User string
+	// Actions matches the actions and categories of actions
+	Actions Filter
+	// Since and Until limit the time of the events. Events without a time don't match a time range.
+	Since time.Time
+	Until time.Time
+	// FileID matches the id of the file or folder. As the events may reference the items relative to
+	// another one, e.g. the root of their space, the items referenced relative to it match as well.
+	FileID string
+	// PathPrefix matches the path of the file or folder and everything below it. The paths are compared as
+	// logged, i.e. relative to the item the events reference the files by, which isn't always the root of
+	// their space. Events referencing the file relative to another item don't match.
+	PathPrefix string
+}
+
+// SearchResult is an audit event found in an audit log
+type SearchResult struct {
+	types.AuditEvent
+
+	FileID    string
+	Path      string
+	OldPath   string
+	Owner     string
+	Executant string
+
+	UserID     string
+	ShareWith  string
+	ShareOwner string
+	Login      string
+
+	// Event is the audit event as it was logged
+	Event json.RawMessage `json:"-"`
+}
+
+// SearchStats reports how many records have been searched
+type SearchStats struct {
+	Matched  int
+	Searched int
+	// Skipped counts the lines which couldn't be searched because they don't contain a json audit event,
+	// e.g. because the audit log has been written in another format
+	Skipped int
+}
+
+// Search calls fn with the audit events of the logfile at the given path and of its rotated logfiles
+// which match the query, the oldest first. Compressed and hash-chained logfiles are searched as well.
+func Search(logfile string, q SearchQuery, fn func(SearchResult) error) (SearchStats, error) {
+	var stats SearchStats
+
+	backups, err := backupsOf(logfile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return stats, err
+	}
+	files := make([]string, 0, len(backups)+1)
+	for i := len(backups) - 1; i >= 0; i-- {
+		if !q.Since.IsZero() && backups[i].rotatedAt.Before(q.Since) {
+			// the logfile was rotated before the first event we are looking for
+			continue
+		}
+		files = append(files, backups[i].path)
+	}
+	files = append(files, logfile)
+
+	for _, p := range files {
+		f, err := OpenLogfile(p)
+		if errors.Is(err, os.ErrNotExist) && p == logfile && len(backups) > 0 {
+			// the logfile has just been rotated
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+		err = SearchLogfile(f, q, &stats, fn)
+		f.Close()
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// SearchLogfile calls fn with the audit events read from r which match the query
+func SearchLogfile(r io.Reader, q SearchQuery, stats *SearchStats, fn func(SearchResult) error) error {
+	scanner := newRecordScanner(r)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		res, ok := parseSearchResult(line)
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		stats.Searched++
+		if !q.Match(res) {
+			continue
+		}
+		stats.Matched++
+		if err := fn(res); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Match returns true if the audit event matches the query
+func (q SearchQuery) Match(res SearchResult) bool {
+	if q.User != "" && !res.involves(q.User) {
+		return false
+	}
+	if !q.Actions.Match(res.Action) {
+		return false
+	}
+	if !q.Since.IsZero() || !q.Until.IsZero() {
+		t, err := time.Parse(time.RFC3339, res.Time)
+		if err != nil {
+			return false
+		}
+		if (!q.Since.IsZero() && t.Before(q.Since)) || (!q.Until.IsZero() && t.After(q.Until)) {
+			return false
+		}
+	}
+	if q.FileID != "" && res.FileID != q.FileID && !strings.HasPrefix(res.FileID, q.FileID+"/") {
+		return false
+	}
+	if q.PathPrefix != "" && !hasPathPrefix(res.Path, q.PathPrefix) && !hasPathPrefix(res.OldPath, q.PathPrefix) {
+		return false
+	}
+	return true
+}
+
+func (res SearchResult) involves(user string) bool {
+	for _, u := range []string{res.User, res.Executant, res.Owner, res.UserID, res.ShareWith, res.ShareOwner, res.Login} {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSearchResult reads the audit event from a line of a logfile written in the json format. The
+// line may be a record of a hash-chained logfile.
+func parseSearchResult(line []byte) (SearchResult, bool) {
+	var rec ChainRecord
+	if err := json.Unmarshal(line, &rec); err == nil && rec.Hash != "" && len(rec.Event) > 0 {
+		line = rec.Event
+	}
+
+	var res SearchResult
+	if err := json.Unmarshal(line, &res); err != nil || res.Action == "" {
+		return SearchResult{}, false
+	}
+	res.Event = append(json.RawMessage(nil), line...)
+	return res, true
+}
+
+// hasPathPrefix returns true if p is the given path or below it. Paths are compared relative to the
+// root of their space, so that "./Photos", "/Photos" and "Photos" are the same.
+func hasPathPrefix(p, prefix string) bool {
+	if p == "" {
+		return false
+	}
+	p, prefix = path.Clean("/"+p), path.Clean("/"+prefix)
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
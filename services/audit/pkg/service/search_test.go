@@ -0,0 +1,166 @@
+package svc
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/v2/pkg/events"
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/owncloud/ocis/v2/services/audit/pkg/types"
+	"github.com/test-go/testify/require"
+)
+
+var _searchTestEvents = []struct {
+	time  time.Time
+	event interface{}
+}{
+	{
+		time:  time.Date(2022, 11, 7, 9, 0, 0, 0, time.UTC),
+		event: types.ContainerCreated(events.ContainerCreated{Executant: userID("uid-123"), Owner: userID("uid-123"), Ref: reference("pro-1", "sto-123", "iid-1", "./Projects")}),
+	}, {
+		time:  time.Date(2022, 11, 8, 9, 0, 0, 0, time.UTC),
+		event: types.FileUploaded(events.FileUploaded{Executant: userID("uid-123"), Owner: userID("uid-123"), Ref: reference("pro-1", "sto-123", "iid-2", "./Projects/plan.md")}),
+	}, {
+		time:  time.Date(2022, 11, 9, 9, 0, 0, 0, time.UTC),
+		event: types.UserCreated(events.UserCreated{Executant: userID("admin"), UserID: "uid-456"}),
+	}, {
+		time:  time.Date(2022, 11, 10, 9, 0, 0, 0, time.UTC),
+		event: types.ItemTrashed(events.ItemTrashed{Executant: userID("uid-456"), Owner: userID("uid-123"), Ref: reference("pro-1", "sto-123", "iid-1", "./Projects")}),
+	}, {
+		time:  time.Date(2022, 11, 11, 9, 0, 0, 0, time.UTC),
+		event: types.ItemTrashed(events.ItemTrashed{Executant: userID("uid-123"), Owner: userID("uid-123"), Ref: reference("pro-1", "sto-123", "iid-3", "./Projects2")}),
+	},
+}
+
+// writeSearchTestLog writes the test events to a logfile and rotates it before the second and the
+// fourth event
+func writeSearchTestLog(t *testing.T, chained bool) string {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	w, c := newTestFileWriter(t, path, FileWriterOptions{Compress: true})
+	l := w.Write
+	if chained {
+		var err error
+		l, err = WriteChained(w, nil, log.NewLogger())
+		require.NoError(t, err)
+	}
+	for i, e := range _searchTestEvents {
+		c.t = e.time
+		if i == 1 || i == 3 {
+			require.NoError(t, w.Rotate())
+		}
+		b, err := json.Marshal(types.WithTime(e.event, e.time))
+		require.NoError(t, err)
+		l(b)
+	}
+	require.NoError(t, w.Close())
+	require.Len(t, backupPaths(t, path), 2)
+	return path
+}
+
+func searchActions(t *testing.T, path string, q SearchQuery) ([]string, SearchStats) {
+	var found []string
+	stats, err := Search(path, q, func(res SearchResult) error {
+		found = append(found, res.Time+" "+res.Action)
+		return nil
+	})
+	require.NoError(t, err)
+	return found, stats
+}
+
+func TestSearch(t *testing.T) {
+	filesFilter, err := NewFilter([]string{"files"}, nil)
+	require.NoError(t, err)
+	deleteFilter, err := NewFilter([]string{"file_delete"}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		query    SearchQuery
+		expected []string
+	}{
+		{
+			name: "everything, the oldest first",
+			expected: []string{
+				"2022-11-07T09:00:00Z container_create",
+				"2022-11-08T09:00:00Z file_create",
+				"2022-11-09T09:00:00Z user_created",
+				"2022-11-10T09:00:00Z file_delete",
+				"2022-11-11T09:00:00Z file_delete",
+			},
+		}, {
+			name:     "who deleted the folder last week",
+			query:    SearchQuery{Actions: deleteFilter, PathPrefix: "/Projects", Since: time.Date(2022, 11, 7, 0, 0, 0, 0, time.UTC)},
+			expected: []string{"2022-11-10T09:00:00Z file_delete"},
+		}, {
+			name:     "path prefix includes the items below",
+			query:    SearchQuery{PathPrefix: "Projects"},
+			expected: []string{"2022-11-07T09:00:00Z container_create", "2022-11-08T09:00:00Z file_create", "2022-11-10T09:00:00Z file_delete"},
+		}, {
+			name:     "category",
+			query:    SearchQuery{Actions: filesFilter, Until: time.Date(2022, 11, 8, 9, 0, 0, 0, time.UTC)},
+			expected: []string{"2022-11-07T09:00:00Z container_create", "2022-11-08T09:00:00Z file_create"},
+		}, {
+			name:     "file id",
+			query:    SearchQuery{FileID: "pro-1$sto-123!iid-1"},
+			expected: []string{"2022-11-07T09:00:00Z container_create", "2022-11-10T09:00:00Z file_delete"},
+		}, {
+			name:     "affected user and executant",
+			query:    SearchQuery{User: "uid-456"},
+			expected: []string{"2022-11-09T09:00:00Z user_created", "2022-11-10T09:00:00Z file_delete"},
+		}, {
+			name:     "executant of a user event",
+			query:    SearchQuery{User: "admin"},
+			expected: []string{"2022-11-09T09:00:00Z user_created"},
+		}, {
+			name:     "time range",
+			query:    SearchQuery{Since: time.Date(2022, 11, 9, 0, 0, 0, 0, time.UTC), Until: time.Date(2022, 11, 10, 12, 0, 0, 0, time.UTC)},
+			expected: []string{"2022-11-09T09:00:00Z user_created", "2022-11-10T09:00:00Z file_delete"},
+		},
+	}
+
+	for _, chained := range []bool{false, true} {
+		path := writeSearchTestLog(t, chained)
+		for _, tt := range tests {
+			found, stats := searchActions(t, path, tt.query)
+			require.Equal(t, tt.expected, found, "%s, chained: %t", tt.name, chained)
+			require.Equal(t, len(tt.expected), stats.Matched)
+			require.Equal(t, 0, stats.Skipped)
+		}
+	}
+}
+
+func TestSearchSkipsOtherFormats(t *testing.T) {
+	logfile := strings.Join([]string{
+		`{"Action":"file_read","Time":"2022-11-10T09:00:00Z","FileID":"pro-1$sto-123!iid-1"}`,
+		`CEF:0|ownCloud|oCIS|2.0.0|file_read|user 'uid-123' read file 'iid-1'|3|act=file_read`,
+		`{"seq":1,"prev":"00","alg":"sha256","event":"file_read)\n   user 'uid-123' read file 'iid-1'","hash":"00"}`,
+		``,
+		`{"unrelated":"json"}`,
+	}, "\n")
+
+	var (
+		stats SearchStats
+		found []SearchResult
+	)
+	err := SearchLogfile(strings.NewReader(logfile), SearchQuery{}, &stats, func(res SearchResult) error {
+		found = append(found, res)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, SearchStats{Matched: 1, Searched: 1, Skipped: 3}, stats)
+	require.Equal(t, "pro-1$sto-123!iid-1", found[0].FileID)
+	require.JSONEq(t, `{"Action":"file_read","Time":"2022-11-10T09:00:00Z","FileID":"pro-1$sto-123!iid-1"}`, string(found[0].Event))
+}
+
+func TestSearchSkipsOldBackups(t *testing.T) {
+	path := writeSearchTestLog(t, false)
+
+	// the first logfile has been rotated before the time range, it isn't read even though it contains
+	// an event which would match
+	found, stats := searchActions(t, path, SearchQuery{Since: time.Date(2022, 11, 8, 12, 0, 0, 0, time.UTC)})
+	require.Equal(t, []string{"2022-11-09T09:00:00Z user_created", "2022-11-10T09:00:00Z file_delete", "2022-11-11T09:00:00Z file_delete"}, found)
+	require.Equal(t, 4, stats.Searched)
+}
@@ -2,12 +2,19 @@ package svc
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/cs3org/reva/v2/pkg/events"
+	ociscrypto "github.com/owncloud/ocis/v2/ocis-pkg/crypto"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/owncloud/ocis/v2/ocis-pkg/requestmeta"
+	"github.com/owncloud/ocis/v2/ocis-pkg/version"
 	"github.com/owncloud/ocis/v2/services/audit/pkg/config"
 	"github.com/owncloud/ocis/v2/services/audit/pkg/types"
 )
@@ -18,29 +25,185 @@ type Log func([]byte)
 // Marshaller is used to marshal events
 type Marshaller func(interface{}) ([]byte, error)
 
+// Sink is a Log receiving the audit events which pass its Filter
+type Sink struct {
+	Log    Log
+	Filter Filter
+}
+
 // AuditLoggerFromConfig will start a new AuditLogger generated from the config
-func AuditLoggerFromConfig(ctx context.Context, cfg config.Auditlog, ch <-chan interface{}, log log.Logger) {
-	var logs []Log
+func AuditLoggerFromConfig(ctx context.Context, cfg config.Auditlog, ch <-chan interface{}, log log.Logger) error {
+	var (
+		sinks   []Sink
+		file    *FileWriter
+		webhook *Webhook
+	)
 
 	if cfg.LogToConsole {
-		logs = append(logs, WriteToStdout())
+		filter, err := NewFilter(cfg.ConsoleIncludeActions, cfg.ConsoleExcludeActions)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, Sink{Log: WriteToStdout(), Filter: filter})
 	}
 
 	if cfg.LogToFile {
-		logs = append(logs, WriteToFile(cfg.FilePath, log))
+		filter, err := NewFilter(cfg.FileIncludeActions, cfg.FileExcludeActions)
+		if err != nil {
+			return err
+		}
+		file, err = FileWriterFromConfig(cfg, log)
+		if err != nil {
+			return err
+		}
+		if cfg.FileHashChain {
+			if cfg.FileHMACKey == "" {
+				log.Warn().Msg("the audit log is hash-chained without a key, anyone who can write the logfile can rewrite the whole chain. Set AUDIT_FILE_HMAC_KEY to sign the records.")
+			}
+			l, err := WriteChained(file, []byte(cfg.FileHMACKey), log)
+			if err != nil {
+				file.Close()
+				return err
+			}
+			sinks = append(sinks, Sink{Log: l, Filter: filter})
+		} else {
+			sinks = append(sinks, Sink{Log: file.Write, Filter: filter})
+		}
+		file.Start(ctx)
+	}
+
+	if cfg.LogToSyslog {
+		filter, err := NewFilter(cfg.SyslogIncludeActions, cfg.SyslogExcludeActions)
+		if err != nil {
+			return err
+		}
+		syslog, err := SyslogFromConfig(cfg, log)
+		if err != nil {
+			return err
+		}
+		syslog.Start(ctx)
+		sinks = append(sinks, Sink{Log: syslog.Write, Filter: filter})
+	}
+
+	if cfg.LogToWebhook {
+		filter, err := NewFilter(cfg.WebhookIncludeActions, cfg.WebhookExcludeActions)
+		if err != nil {
+			return err
+		}
+		webhook, err = WebhookFromConfig(cfg, log)
+		if err != nil {
+			return err
+		}
+		webhook.Start(ctx)
+		sinks = append(sinks, Sink{Log: webhook.Write, Filter: filter})
 	}
 
-	StartAuditLogger(ctx, ch, log, Marshal(cfg.Format, log), logs...)
+	StartAuditLogger(ctx, ch, log, Marshal(cfg.Format, log), sinks...)
 
+	// the logfile is closed and the webhook batch spooled before returning, so that the buffered events
+	// are written before the process exits
+	if webhook != nil {
+		webhook.Flush()
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			log.Error().Err(err).Msgf("error closing file '%s'", cfg.FilePath)
+		}
+	}
+	return nil
+}
+
+// FileWriterFromConfig returns a FileWriter for the logfile configured in cfg
+func FileWriterFromConfig(cfg config.Auditlog, log log.Logger) (*FileWriter, error) {
+	return NewFileWriter(cfg.FilePath, FileWriterOptions{
+		MaxSize:          int64(cfg.FileRotateSize) * 1024 * 1024,
+		RotationInterval: time.Duration(cfg.FileRotateInterval) * time.Hour,
+		MaxBackups:       cfg.FileMaxBackups,
+		MaxBackupAge:     time.Duration(cfg.FileMaxBackupAge) * 24 * time.Hour,
+		Compress:         cfg.FileCompress,
+		FlushInterval:    time.Duration(cfg.FileFlushInterval) * time.Second,
+	}, log)
+}
+
+// SyslogFromConfig returns a Syslog sending the audit events to the server configured in cfg
+func SyslogFromConfig(cfg config.Auditlog, log log.Logger) (*Syslog, error) {
+	opts := SyslogOptions{
+		Network:    cfg.SyslogNetwork,
+		Address:    cfg.SyslogAddress,
+		Facility:   cfg.SyslogFacility,
+		AppName:    cfg.SyslogAppName,
+		BufferSize: cfg.SyslogBufferSize,
+	}
+	if cfg.SyslogNetwork == "tls" {
+		tlsConfig, err := clientTLSConfig(cfg.SyslogTLSInsecure, cfg.SyslogTLSRootCACertificate)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+	return NewSyslog(opts, log)
+}
+
+// WebhookFromConfig returns a Webhook posting the audit events to the endpoint configured in cfg
+func WebhookFromConfig(cfg config.Auditlog, log log.Logger) (*Webhook, error) {
+	tlsConfig, err := clientTLSConfig(cfg.WebhookTLSInsecure, cfg.WebhookTLSRootCACertificate)
+	if err != nil {
+		return nil, err
+	}
+	contentType := "text/plain; charset=utf-8"
+	if cfg.Format == "json" {
+		contentType = "application/x-ndjson"
+	}
+	return NewWebhook(WebhookOptions{
+		URL:           cfg.WebhookURL,
+		Secret:        []byte(cfg.WebhookSecret),
+		ContentType:   contentType,
+		TLSConfig:     tlsConfig,
+		BatchSize:     cfg.WebhookBatchSize,
+		BatchInterval: time.Duration(cfg.WebhookBatchInterval) * time.Second,
+		SpoolDir:      cfg.WebhookSpoolDir,
+		MaxSpoolSize:  int64(cfg.WebhookSpoolMaxSize) * 1024 * 1024,
+	}, log)
+}
+
+// clientTLSConfig returns the TLS configuration used to connect to a server. The server's certificate
+// is validated with the given root CA certificate if there is one.
+func clientTLSConfig(insecure bool, rootCACertificate string) (*tls.Config, error) {
+	var rootCAPool *x509.CertPool
+	if rootCACertificate != "" {
+		rootCrtFile, err := os.Open(rootCACertificate)
+		if err != nil {
+			return nil, err
+		}
+		defer rootCrtFile.Close()
+
+		rootCAPool, err = ociscrypto.NewCertPoolFromPEM(rootCrtFile)
+		if err != nil {
+			return nil, err
+		}
+		insecure = false
+	}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure, //nolint:gosec
+		RootCAs:            rootCAPool,
+	}, nil
 }
 
 // StartAuditLogger will block. run in separate go routine
-func StartAuditLogger(ctx context.Context, ch <-chan interface{}, log log.Logger, marshaller Marshaller, logto ...Log) {
+// The audit events are only marshalled and written to the sinks whose filter they pass.
+func StartAuditLogger(ctx context.Context, ch <-chan interface{}, log log.Logger, marshaller Marshaller, sinks ...Sink) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case i := <-ch:
+			var request *requestmeta.Metadata
+			if e, ok := i.(ocisevents.Event); ok {
+				i, request = e.Event, &e.Request
+			}
+
 			var auditEvent interface{}
 			switch ev := i.(type) {
 			case events.ShareCreated:
@@ -93,6 +256,10 @@ func StartAuditLogger(ctx context.Context, ch <-chan interface{}, log log.Logger
 				auditEvent = types.UserDeleted(ev)
 			case events.UserFeatureChanged:
 				auditEvent = types.UserFeatureChanged(ev)
+			case ocisevents.UserAuthenticated:
+				auditEvent = types.UserAuthenticated(ev)
+			case ocisevents.UserAuthenticationFailed:
+				auditEvent = types.UserAuthenticationFailed(ev)
 			case events.GroupCreated:
 				auditEvent = types.GroupCreated(ev)
 			case events.GroupDeleted:
@@ -106,6 +273,22 @@ func StartAuditLogger(ctx context.Context, ch <-chan interface{}, log log.Logger
 				continue
 
 			}
+			if request != nil {
+				auditEvent = types.WithRequest(auditEvent, *request)
+			}
+			// most events don't carry the time they happened at, the time they are received is close enough
+			auditEvent = types.WithTime(auditEvent, time.Now())
+
+			action := types.Action(auditEvent)
+			logto := make([]Log, 0, len(sinks))
+			for _, s := range sinks {
+				if s.Filter.Match(action) {
+					logto = append(logto, s.Log)
+				}
+			}
+			if len(logto) == 0 {
+				continue
+			}
 
 			b, err := marshaller(auditEvent)
 			if err != nil {
@@ -121,21 +304,6 @@ func StartAuditLogger(ctx context.Context, ch <-chan interface{}, log log.Logger
 
 }
 
-// WriteToFile returns a Log function writing to a file
-func WriteToFile(path string, log log.Logger) Log {
-	return func(content []byte) {
-		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-		if err != nil {
-			log.Error().Err(err).Msgf("error opening file '%s'", path)
-			return
-		}
-		defer file.Close()
-		if _, err := fmt.Fprintln(file, string(content)); err != nil {
-			log.Error().Err(err).Msgf("error writing to file '%s'", path)
-		}
-	}
-}
-
 // WriteToStdout return a Log function writing to Stdout
 func WriteToStdout() Log {
 	return func(content []byte) {
@@ -151,6 +319,10 @@ func Marshal(format string, log log.Logger) Marshaller {
 		return nil
 	case "json":
 		return json.Marshal
+	case "cef":
+		return MarshalCEF(version.GetString())
+	case "leef":
+		return MarshalLEEF(version.GetString())
 	case "minimal":
 		return func(ev interface{}) ([]byte, error) {
 			b, err := json.Marshal(ev)
@@ -3,10 +3,15 @@ package svc
 import (
 	"context"
 	"encoding/json"
+	"path/filepath"
 	"testing"
+	gotime "time"
 
 	"github.com/cs3org/reva/v2/pkg/events"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/owncloud/ocis/v2/ocis-pkg/requestmeta"
+	"github.com/owncloud/ocis/v2/services/audit/pkg/config"
 	"github.com/owncloud/ocis/v2/services/audit/pkg/types"
 	"github.com/test-go/testify/require"
 
@@ -511,6 +516,48 @@ var testCases = []struct {
 			// AuditEventSpaces fields
 			checkSpacesAuditEvent(t, ev.AuditEventSpaces, "space-123")
 		},
+	}, {
+		Alias: "User authenticated",
+		SystemEvent: ocisevents.UserAuthenticated{
+			UserID:    userID("uid-123"),
+			Login:     "einstein",
+			Method:    "basic",
+			Timestamp: timestamp(10e8),
+		},
+		CheckAuditEvent: func(t *testing.T, b []byte) {
+			ev := types.AuditEventUserAuthenticated{}
+			require.NoError(t, json.Unmarshal(b, &ev))
+
+			// AuditEvent fields
+			checkBaseAuditEvent(t, ev.AuditEvent, "uid-123", "2001-09-09T01:46:40Z", "user 'einstein' authenticated via 'basic'", "user_authenticated")
+			// AuditEventUserAuthenticated fields
+			require.Equal(t, "uid-123", ev.UserID)
+			require.Equal(t, "einstein", ev.Login)
+			require.Equal(t, "basic", ev.AuthMethod)
+			require.Equal(t, true, ev.Success)
+			require.Equal(t, "", ev.Reason)
+		},
+	}, {
+		Alias: "User authentication failed",
+		SystemEvent: ocisevents.UserAuthenticationFailed{
+			Login:     "einstein",
+			Method:    "signed_url",
+			Reason:    "signature expired",
+			Timestamp: timestamp(10e8),
+		},
+		CheckAuditEvent: func(t *testing.T, b []byte) {
+			ev := types.AuditEventUserAuthenticated{}
+			require.NoError(t, json.Unmarshal(b, &ev))
+
+			// AuditEvent fields
+			checkBaseAuditEvent(t, ev.AuditEvent, "", "2001-09-09T01:46:40Z", "authentication of user 'einstein' via 'signed_url' failed: signature expired", "user_authenticated")
+			// AuditEventUserAuthenticated fields
+			require.Equal(t, "", ev.UserID)
+			require.Equal(t, "einstein", ev.Login)
+			require.Equal(t, "signed_url", ev.AuthMethod)
+			require.Equal(t, false, ev.Success)
+			require.Equal(t, "signature expired", ev.Reason)
+		},
 	},
 }
 
@@ -526,9 +573,9 @@ func TestAuditLogging(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
 
-	go StartAuditLogger(ctx, inch, log, Marshal("json", log), func(b []byte) {
+	go StartAuditLogger(ctx, inch, log, Marshal("json", log), Sink{Log: func(b []byte) {
 		outch <- b
-	})
+	}})
 
 	for i := range testCases {
 		tc := testCases[i]
@@ -539,13 +586,178 @@ func TestAuditLogging(t *testing.T) {
 	}
 }
 
+func TestAuditLoggingWithRequest(t *testing.T) {
+	log := log.NewLogger()
+
+	inch := make(chan interface{})
+	defer close(inch)
+
+	outch := make(chan []byte)
+	defer close(outch)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	go StartAuditLogger(ctx, inch, log, Marshal("json", log), Sink{Log: func(b []byte) {
+		outch <- b
+	}})
+
+	inch <- ocisevents.Event{
+		Event: events.GroupMemberAdded{Executant: userID("uid-123"), GroupID: "gid-123", UserID: "uid-456"},
+		Request: requestmeta.Metadata{
+			RemoteAddr: "192.0.2.10",
+			UserAgent:  "Mozilla/5.0",
+			RequestID:  "req-123",
+			URL:        "/graph/v1.0/groups/gid-123/members/$ref",
+			Method:     "POST",
+		},
+	}
+
+	ev := types.AuditEventGroupMemberAdded{}
+	require.NoError(t, json.Unmarshal(<-outch, &ev))
+	require.Equal(t, "192.0.2.10", ev.RemoteAddr)
+	require.Equal(t, "Mozilla/5.0", ev.UserAgent)
+	require.Equal(t, "req-123", ev.RequestID)
+	require.Equal(t, "/graph/v1.0/groups/gid-123/members/$ref", ev.URL)
+	require.Equal(t, "POST", ev.Method)
+	require.Equal(t, "group_member_added", ev.Action)
+	require.Equal(t, "gid-123", ev.GroupID)
+	require.Equal(t, "uid-456", ev.UserID)
+}
+
+func TestAuditLoggingFilters(t *testing.T) {
+	log := log.NewLogger()
+
+	inch := make(chan interface{})
+	defer close(inch)
+
+	outch := make(chan string)
+	defer close(outch)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	consoleFilter, err := NewFilter(nil, []string{"file_read"})
+	require.NoError(t, err)
+	fileFilter, err := NewFilter([]string{"files", "groups"}, []string{"group_deleted"})
+	require.NoError(t, err)
+
+	go StartAuditLogger(ctx, inch, log, func(ev interface{}) ([]byte, error) {
+		return []byte(types.Action(ev)), nil
+	}, Sink{
+		Log:    func(b []byte) { outch <- "console " + string(b) },
+		Filter: consoleFilter,
+	}, Sink{
+		Log:    func(b []byte) { outch <- "file " + string(b) },
+		Filter: fileFilter,
+	})
+
+	go func() {
+		inch <- events.FileDownloaded{Executant: userID("uid-123"), Ref: reference("pro-1", "sto-123", "iid-123", "./item")}
+		inch <- events.FileUploaded{Executant: userID("uid-123"), Ref: reference("pro-1", "sto-123", "iid-123", "./item")}
+		inch <- events.UserCreated{Executant: userID("uid-123"), UserID: "uid-456"}
+		inch <- events.GroupDeleted{Executant: userID("uid-123"), GroupID: "gid-123"}
+		inch <- events.GroupCreated{Executant: userID("uid-123"), GroupID: "gid-123"}
+	}()
+
+	for _, expected := range []string{
+		"file file_read",
+		"console file_create",
+		"file file_create",
+		"console user_created",
+		"console group_deleted",
+		"console group_created",
+		"file group_created",
+	} {
+		require.Equal(t, expected, <-outch)
+	}
+}
+
+func TestAuditLoggingSkipsFilteredEvents(t *testing.T) {
+	log := log.NewLogger()
+
+	inch := make(chan interface{})
+	defer close(inch)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	filter, err := NewFilter([]string{"authentication"}, nil)
+	require.NoError(t, err)
+
+	marshalled := make(chan string)
+	go StartAuditLogger(ctx, inch, log, func(ev interface{}) ([]byte, error) {
+		marshalled <- types.Action(ev)
+		return nil, nil
+	}, Sink{Log: func([]byte) {}, Filter: filter})
+
+	// events which don't pass any filter are not even marshalled
+	inch <- events.UserCreated{Executant: userID("uid-123"), UserID: "uid-456"}
+	inch <- ocisevents.UserAuthenticated{Login: "einstein", Method: "basic"}
+	require.Equal(t, "user_authenticated", <-marshalled)
+}
+
+func TestAuditLoggerWritesTheBufferedEventsWhenStopped(t *testing.T) {
+	log := log.NewLogger()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	inch := make(chan interface{})
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan error)
+	go func() {
+		done <- AuditLoggerFromConfig(ctx, config.Auditlog{
+			LogToFile:         true,
+			FilePath:          path,
+			FileFlushInterval: 60,
+			Format:            "minimal",
+		}, inch, log)
+	}()
+
+	inch <- events.UserCreated{Executant: userID("uid-123"), UserID: "uid-456"}
+	cancel()
+	require.NoError(t, <-done)
+	require.Equal(t, "user_created)\n   user 'uid-123' created the user 'uid-456'\n", readLogfile(t, path))
+}
+
+func TestAuditLoggerSpoolsTheWebhookBatchWhenStopped(t *testing.T) {
+	log := log.NewLogger()
+	dir := t.TempDir()
+
+	inch := make(chan interface{})
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan error)
+	go func() {
+		done <- AuditLoggerFromConfig(ctx, config.Auditlog{
+			LogToWebhook:     true,
+			WebhookURL:       "https://127.0.0.1:1/audit",
+			WebhookSecret:    "secret",
+			WebhookBatchSize: 100,
+			WebhookSpoolDir:  dir,
+			Format:           "minimal",
+		}, inch, log)
+	}()
+
+	inch <- events.UserCreated{Executant: userID("uid-123"), UserID: "uid-456"}
+	cancel()
+	require.NoError(t, <-done)
+	names, err := batchesIn(dir)
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+}
+
 func checkBaseAuditEvent(t *testing.T, ev types.AuditEvent, user string, time string, message string, action string) {
 	require.Equal(t, "", ev.RemoteAddr) // not implemented atm
 	require.Equal(t, user, ev.User)
 	require.Equal(t, "", ev.URL)       // not implemented atm
 	require.Equal(t, "", ev.Method)    // not implemented atm
 	require.Equal(t, "", ev.UserAgent) // not implemented atm
-	require.Equal(t, time, ev.Time)
+	if time == "" {
+		// events without a time are stamped with the time they have been received
+		_, err := gotime.Parse(gotime.RFC3339, ev.Time)
+		require.NoError(t, err)
+	} else {
+		require.Equal(t, time, ev.Time)
+	}
 	require.Equal(t, "admin_audit", ev.App)
 	require.Equal(t, message, ev.Message)
 	require.Equal(t, action, ev.Action)
@@ -0,0 +1,345 @@
+package svc
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owncloud/ocis/v2/services/audit/pkg/types"
+)
+
+const (
+	siemVendor  = "ownCloud"
+	siemProduct = "oCIS"
+
+	// cefCustomPrefix prefixes the extension keys which are not part of the CEF dictionary
+	cefCustomPrefix = "ocis"
+	// leefTimeFormat describes the format of the event time for LEEF consumers
+	leefTimeFormat = "yyyy-MM-dd'T'HH:mm:ssX"
+
+	siemSeverityDefault     = 3
+	siemSeverityDestructive = 5
+	siemSeverityFailure     = 7
+)
+
+var (
+	cefHeaderEscaper  = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefValueEscaper   = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+	leefHeaderEscaper = cefHeaderEscaper
+	leefValueEscaper  = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r", `\r`, "\n", `\n`)
+
+	// cefKeys maps the fields of the audit events to the keys of the CEF dictionary. All other fields
+	// are written with the custom prefix.
+	cefKeys = map[string]string{
+		"fileId":      "fileId",
+		"filePath":    "filePath",
+		"fileName":    "fname",
+		"oldFilePath": "oldFilePath",
+		"shareWith":   "duser",
+		"userId":      "duser",
+		"reason":      "reason",
+	}
+
+	// destructiveActions are logged with a higher severity
+	destructiveActions = map[string]bool{
+		types.ActionFilePurged:    true,
+		types.ActionSpaceDisabled: true,
+		types.ActionSpaceDeleted:  true,
+		types.ActionUserDeleted:   true,
+		types.ActionGroupDeleted:  true,
+	}
+)
+
+// siemField is an event specific field of a CEF or LEEF event
+type siemField struct {
+	key   string
+	value string
+}
+
+// siemEvent holds the values of an audit event which are mapped to CEF or LEEF
+type siemEvent struct {
+	types.AuditEvent
+	success bool
+	fields  []siemField
+}
+
+// MarshalCEF returns a Marshaller creating ArcSight Common Event Format (CEF) events. The action is
+// used as event class id and the message as name. The fields of the audit events are mapped to the
+// CEF dictionary where possible, e.g. the user who performed the action to suser and the remote
+// address to src, and written as custom extension keys prefixed with "ocis" otherwise.
+func MarshalCEF(productVersion string) Marshaller {
+	return func(ev interface{}) ([]byte, error) {
+		e, err := newSIEMEvent(ev)
+		if err != nil {
+			return nil, err
+		}
+
+		b := &strings.Builder{}
+		fmt.Fprintf(b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+			cefHeaderEscaper.Replace(siemVendor),
+			cefHeaderEscaper.Replace(siemProduct),
+			cefHeaderEscaper.Replace(productVersion),
+			cefHeaderEscaper.Replace(e.Action),
+			cefHeaderEscaper.Replace(e.Message),
+			e.severity(),
+		)
+
+		ext := make([]string, 0, len(e.fields)+8)
+		add := func(key, value string) {
+			if value = strings.TrimSpace(value); value != "" {
+				ext = append(ext, key+"="+cefValueEscaper.Replace(value))
+			}
+		}
+		if t, err := time.Parse(time.RFC3339, e.Time); err == nil {
+			add("rt", strconv.FormatInt(t.UnixMilli(), 10))
+		}
+		add("src", e.RemoteAddr)
+		add("suser", e.User)
+		add("requestMethod", e.Method)
+		add("request", e.URL)
+		add("requestClientApplication", e.UserAgent)
+		add(cefCustomPrefix+"RequestId", e.RequestID)
+		add("act", e.Action)
+		add("outcome", e.outcome())
+		for _, f := range e.fields {
+			key, ok := cefKeys[f.key]
+			if !ok {
+				key = cefCustomPrefix + strings.ToUpper(f.key[:1]) + f.key[1:]
+			}
+			add(key, f.value)
+		}
+		b.WriteString(strings.Join(ext, " "))
+		return []byte(b.String()), nil
+	}
+}
+
+// MarshalLEEF returns a Marshaller creating IBM QRadar Log Event Extended Format (LEEF) 2.0 events. The
+// action is used as event id and the attributes are separated by tabs. The user who performed the
+// action is mapped to usrName and the remote address to src, the event specific fields are written
+// with their own keys.
+func MarshalLEEF(productVersion string) Marshaller {
+	return func(ev interface{}) ([]byte, error) {
+		e, err := newSIEMEvent(ev)
+		if err != nil {
+			return nil, err
+		}
+
+		b := &strings.Builder{}
+		fmt.Fprintf(b, "LEEF:2.0|%s|%s|%s|%s|x09|",
+			leefHeaderEscaper.Replace(siemVendor),
+			leefHeaderEscaper.Replace(siemProduct),
+			leefHeaderEscaper.Replace(productVersion),
+			leefHeaderEscaper.Replace(e.Action),
+		)
+
+		attrs := make([]string, 0, len(e.fields)+12)
+		add := func(key, value string) {
+			if value = strings.TrimSpace(value); value != "" {
+				attrs = append(attrs, key+"="+leefValueEscaper.Replace(value))
+			}
+		}
+		if e.Time != "" {
+			add("devTime", e.Time)
+			add("devTimeFormat", leefTimeFormat)
+		}
+		add("src", e.RemoteAddr)
+		add("usrName", e.User)
+		add("cat", e.Action)
+		add("sev", strconv.Itoa(e.severity()))
+		add("method", e.Method)
+		add("url", e.URL)
+		add("userAgent", e.UserAgent)
+		add("requestId", e.RequestID)
+		add("outcome", e.outcome())
+		add("msg", e.Message)
+		for _, f := range e.fields {
+			add(f.key, f.value)
+		}
+		b.WriteString(strings.Join(attrs, "\t"))
+		return []byte(b.String()), nil
+	}
+}
+
+func (e siemEvent) outcome() string {
+	if e.success {
+		return "success"
+	}
+	return "failure"
+}
+
+func (e siemEvent) severity() int {
+	switch {
+	case !e.success:
+		return siemSeverityFailure
+	case destructiveActions[e.Action]:
+		return siemSeverityDestructive
+	default:
+		return siemSeverityDefault
+	}
+}
+
+// newSIEMEvent collects the fields of the given audit event
+func newSIEMEvent(ev interface{}) (siemEvent, error) {
+	var (
+		base      types.AuditEvent
+		fields    []siemField
+		executant string
+	)
+	success := true
+
+	switch e := ev.(type) {
+	case types.AuditEventShareCreated:
+		base = e.AuditEvent
+		fields = append(sharingFields(e.AuditEventSharing),
+			siemField{"itemType", e.ItemType},
+			siemField{"shareType", e.ShareType},
+			siemField{"shareWith", e.ShareWith},
+			siemField{"shareOwner", e.ShareOwner},
+			siemField{"permissions", e.Permissions},
+			siemField{"expirationDate", e.ExpirationDate},
+			siemField{"passwordProtected", strconv.FormatBool(e.SharePass)},
+			siemField{"shareToken", e.ShareToken},
+		)
+	case types.AuditEventShareUpdated:
+		base = e.AuditEvent
+		fields = append(sharingFields(e.AuditEventSharing),
+			siemField{"itemType", e.ItemType},
+			siemField{"shareType", e.ShareType},
+			siemField{"shareWith", e.ShareWith},
+			siemField{"shareOwner", e.ShareOwner},
+			siemField{"permissions", e.Permissions},
+			siemField{"expirationDate", e.ExpirationDate},
+			siemField{"passwordProtected", strconv.FormatBool(e.SharePass)},
+			siemField{"shareToken", e.ShareToken},
+		)
+	case types.AuditEventShareRemoved:
+		base = e.AuditEvent
+		fields = append(sharingFields(e.AuditEventSharing),
+			siemField{"itemType", e.ItemType},
+			siemField{"shareType", e.ShareType},
+			siemField{"shareWith", e.ShareWith},
+		)
+	case types.AuditEventReceivedShareUpdated:
+		base = e.AuditEvent
+		fields = append(sharingFields(e.AuditEventSharing),
+			siemField{"itemType", e.ItemType},
+			siemField{"shareType", e.ShareType},
+			siemField{"shareWith", e.ShareWith},
+		)
+	case types.AuditEventLinkAccessed:
+		base = e.AuditEvent
+		success = e.Success
+		fields = append(sharingFields(e.AuditEventSharing),
+			siemField{"itemType", e.ItemType},
+			siemField{"shareToken", e.ShareToken},
+		)
+	case types.AuditEventContainerCreated:
+		base, fields, executant = e.AuditEvent, filesFields(e.AuditEventFiles), e.Executant
+	case types.AuditEventFileCreated:
+		base, fields, executant = e.AuditEvent, filesFields(e.AuditEventFiles), e.Executant
+	case types.AuditEventFileRead:
+		base, fields, executant = e.AuditEvent, filesFields(e.AuditEventFiles), e.Executant
+	case types.AuditEventFileUpdated:
+		base, fields, executant = e.AuditEvent, filesFields(e.AuditEventFiles), e.Executant
+	case types.AuditEventFileDeleted:
+		base, fields, executant = e.AuditEvent, filesFields(e.AuditEventFiles), e.Executant
+	case types.AuditEventFileCopied:
+		base, fields, executant = e.AuditEvent, filesFields(e.AuditEventFiles), e.Executant
+	case types.AuditEventFilePurged:
+		base, fields, executant = e.AuditEvent, filesFields(e.AuditEventFiles), e.Executant
+	case types.AuditEventFileVersionDeleted:
+		base, fields, executant = e.AuditEvent, filesFields(e.AuditEventFiles), e.Executant
+	case types.AuditEventFileRenamed:
+		base, executant = e.AuditEvent, e.Executant
+		fields = append(filesFields(e.AuditEventFiles), siemField{"oldFilePath", e.OldPath})
+	case types.AuditEventFileRestored:
+		base, executant = e.AuditEvent, e.Executant
+		fields = append(filesFields(e.AuditEventFiles), siemField{"oldFilePath", e.OldPath})
+	case types.AuditEventFileVersionRestored:
+		base, executant = e.AuditEvent, e.Executant
+		fields = append(filesFields(e.AuditEventFiles), siemField{"versionKey", e.Key})
+	case types.AuditEventSpaceCreated:
+		base, executant = e.AuditEvent, e.Executant
+		fields = []siemField{
+			{"spaceId", e.SpaceID},
+			{"spaceName", e.Name},
+			{"spaceType", e.Type},
+			{"spaceOwner", e.Owner},
+			{"rootId", e.RootItem},
+		}
+	case types.AuditEventSpaceRenamed:
+		base, executant = e.AuditEvent, e.Executant
+		fields = []siemField{{"spaceId", e.SpaceID}, {"spaceName", e.NewName}}
+	case types.AuditEventSpaceDisabled:
+		base, fields, executant = e.AuditEvent, []siemField{{"spaceId", e.SpaceID}}, e.Executant
+	case types.AuditEventSpaceEnabled:
+		base, fields, executant = e.AuditEvent, []siemField{{"spaceId", e.SpaceID}}, e.Executant
+	case types.AuditEventSpaceDeleted:
+		base, fields, executant = e.AuditEvent, []siemField{{"spaceId", e.SpaceID}}, e.Executant
+	case types.AuditEventUserCreated:
+		base, fields, executant = e.AuditEvent, []siemField{{"userId", e.UserID}}, e.Executant
+	case types.AuditEventUserDeleted:
+		base, fields, executant = e.AuditEvent, []siemField{{"userId", e.UserID}}, e.Executant
+	case types.AuditEventUserFeatureChanged:
+		features := make([]string, 0, len(e.Features))
+		for _, f := range e.Features {
+			features = append(features, f.Name+":"+f.Value)
+		}
+		base, executant = e.AuditEvent, e.Executant
+		fields = []siemField{{"userId", e.UserID}, {"features", strings.Join(features, ",")}}
+	case types.AuditEventUserAuthenticated:
+		base = e.AuditEvent
+		success = e.Success
+		fields = []siemField{{"login", e.Login}, {"authMethod", e.AuthMethod}, {"reason", e.Reason}}
+	case types.AuditEventGroupCreated:
+		base, fields, executant = e.AuditEvent, []siemField{{"groupId", e.GroupID}}, e.Executant
+	case types.AuditEventGroupDeleted:
+		base, fields, executant = e.AuditEvent, []siemField{{"groupId", e.GroupID}}, e.Executant
+	case types.AuditEventGroupMemberAdded:
+		base, fields, executant = e.AuditEvent, []siemField{{"groupId", e.GroupID}, {"userId", e.UserID}}, e.Executant
+	case types.AuditEventGroupMemberRemoved:
+		base, fields, executant = e.AuditEvent, []siemField{{"groupId", e.GroupID}, {"userId", e.UserID}}, e.Executant
+	default:
+		return siemEvent{}, fmt.Errorf("can't map audit event of type '%T'", ev)
+	}
+
+	if executant != "" {
+		// the user of the file events is the owner of the file, which is written as a field of its own
+		base.User = executant
+	}
+	return siemEvent{AuditEvent: base, success: success, fields: fields}, nil
+}
+
+func sharingFields(e types.AuditEventSharing) []siemField {
+	return []siemField{
+		{"fileId", e.FileID},
+		{"filePath", e.Path},
+		{"fileName", fileName(e.Path)},
+		{"owner", e.Owner},
+		{"shareId", e.ShareID},
+	}
+}
+
+func filesFields(e types.AuditEventFiles) []siemField {
+	return []siemField{
+		{"fileId", e.FileID},
+		{"filePath", e.Path},
+		{"fileName", fileName(e.Path)},
+		{"owner", e.Owner},
+	}
+}
+
+// fileName returns the last element of the path, the path of the item is relative to its space and
+// may be empty
+func fileName(p string) string {
+	if p == "" {
+		return ""
+	}
+	name := path.Base(p)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
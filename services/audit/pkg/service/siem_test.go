@@ -0,0 +1,346 @@
+package svc
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cs3org/reva/v2/pkg/events"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
+	"github.com/owncloud/ocis/v2/ocis-pkg/requestmeta"
+	"github.com/owncloud/ocis/v2/services/audit/pkg/types"
+	"github.com/test-go/testify/require"
+
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// siemTestCases contains an audit event of every conversion in types/conversion.go
+var siemTestCases = []struct {
+	Alias      string
+	AuditEvent interface{}
+}{
+	{
+		Alias: "ShareCreated",
+		AuditEvent: types.ShareCreated(events.ShareCreated{
+			Sharer:        userID("sharing-userid"),
+			GranteeUserID: userID("beshared-userid"),
+			ItemID:        resourceID("provider-1", "storage-1", "itemid-1"),
+			CTime:         timestamp(10e8),
+		}),
+	}, {
+		Alias: "LinkCreated",
+		AuditEvent: types.LinkCreated(events.LinkCreated{
+			ShareID:           linkID("shareid"),
+			Sharer:            userID("sharing-userid"),
+			ItemID:            resourceID("provider-1", "storage-1", "itemid-1"),
+			Permissions:       linkPermissions("stat"),
+			CTime:             timestamp(10e8),
+			Expiration:        timestamp(10e8 + 10e5),
+			PasswordProtected: true,
+			Token:             "token-123",
+		}),
+	}, {
+		Alias: "ShareUpdated",
+		AuditEvent: types.ShareUpdated(events.ShareUpdated{
+			ShareID:        shareID("shareid"),
+			Sharer:         userID("sharing-userid"),
+			GranteeGroupID: groupID("beshared-groupid"),
+			ItemID:         resourceID("provider-1", "storage-1", "itemid-1"),
+			Permissions:    sharePermissions("stat", "get_quota"),
+			MTime:          timestamp(10e8),
+			Updated:        "permissions",
+		}),
+	}, {
+		Alias: "LinkUpdated",
+		AuditEvent: types.LinkUpdated(events.LinkUpdated{
+			ShareID:           linkID("shareid"),
+			Sharer:            userID("sharing-userid"),
+			ItemID:            resourceID("provider-1", "storage-1", "itemid-1"),
+			Permissions:       linkPermissions("stat"),
+			CTime:             timestamp(10e8),
+			Expiration:        timestamp(10e8 + 10e5),
+			PasswordProtected: true,
+			Token:             "token-123",
+			FieldUpdated:      "TYPE_PASSWORD",
+		}),
+	}, {
+		Alias: "ShareRemoved",
+		AuditEvent: types.ShareRemoved(events.ShareRemoved{
+			ShareID: shareID("shareid"),
+			ShareKey: &collaboration.ShareKey{
+				Owner:      userID("sharing-userid"),
+				ResourceId: resourceID("provider-1", "storage-1", "itemid-1"),
+				Grantee:    &provider.Grantee{Id: &provider.Grantee_UserId{UserId: userID("beshared-userid")}},
+			},
+		}),
+	}, {
+		Alias: "LinkRemoved",
+		AuditEvent: types.LinkRemoved(events.LinkRemoved{
+			Executant:  userID("sharing-userid"),
+			ShareToken: "token-123",
+		}),
+	}, {
+		Alias: "ReceivedShareUpdated",
+		AuditEvent: types.ReceivedShareUpdated(events.ReceivedShareUpdated{
+			ShareID:       shareID("shareid"),
+			ItemID:        resourceID("provider-1", "storage-1", "itemid-1"),
+			GranteeUserID: userID("beshared-userid"),
+			Sharer:        userID("sharing-userid"),
+			MTime:         timestamp(10e8),
+			State:         "SHARE_STATE_ACCEPTED",
+		}),
+	}, {
+		Alias: "LinkAccessed",
+		AuditEvent: types.LinkAccessed(events.LinkAccessed{
+			ShareID: linkID("shareid"),
+			Sharer:  userID("sharing-userid"),
+			ItemID:  resourceID("provider-1", "storage-1", "itemid-1"),
+			CTime:   timestamp(10e8),
+			Token:   "token-123",
+		}),
+	}, {
+		Alias: "LinkAccessFailed",
+		AuditEvent: types.LinkAccessFailed(events.LinkAccessFailed{
+			ShareID: linkID("shareid"),
+			Token:   "token-123",
+			Status:  8,
+			Message: "access denied",
+		}),
+	}, {
+		Alias: "ContainerCreated",
+		AuditEvent: types.ContainerCreated(events.ContainerCreated{
+			Executant: userID("uid-456"),
+			Ref:       reference("pro-1", "sto-123", "iid-123", "./folder"),
+			Owner:     userID("uid-123"),
+		}),
+	}, {
+		Alias: "FileUploaded",
+		AuditEvent: types.FileUploaded(events.FileUploaded{
+			Executant: userID("uid-456"),
+			Ref:       reference("pro-1", "sto-123", "iid-123", "./folder/item.txt"),
+			Owner:     userID("uid-123"),
+		}),
+	}, {
+		Alias: "FileDownloaded",
+		AuditEvent: types.FileDownloaded(events.FileDownloaded{
+			Executant: userID("uid-456"),
+			Ref:       reference("pro-1", "sto-123", "iid-123", "./folder/item.txt"),
+			Owner:     userID("uid-123"),
+		}),
+	}, {
+		Alias: "ItemMoved",
+		AuditEvent: types.ItemMoved(events.ItemMoved{
+			Executant:    userID("uid-456"),
+			Ref:          reference("pro-1", "sto-123", "iid-123", `./reports/q3=final|v2\draft.txt`),
+			OldReference: reference("pro-1", "sto-123", "iid-123", "./reports/q3\ttab.txt"),
+			Owner:        userID("uid-123"),
+		}),
+	}, {
+		Alias: "ItemTrashed",
+		AuditEvent: types.ItemTrashed(events.ItemTrashed{
+			Executant: userID("uid-456"),
+			Ref:       reference("pro-1", "sto-123", "iid-123", "./folder/item.txt"),
+			Owner:     userID("uid-123"),
+		}),
+	}, {
+		Alias: "ItemPurged",
+		AuditEvent: types.ItemPurged(events.ItemPurged{
+			Executant: userID("uid-456"),
+			Ref:       reference("pro-1", "sto-123", "iid-123", "./folder/item.txt"),
+			Owner:     userID("uid-123"),
+		}),
+	}, {
+		Alias: "ItemRestored",
+		AuditEvent: types.ItemRestored(events.ItemRestored{
+			Executant:    userID("uid-456"),
+			Ref:          reference("pro-1", "sto-123", "iid-123", "./folder/item.txt"),
+			OldReference: reference("pro-1", "sto-123", "sto-123!iid-123/item.txt", "./item.txt"),
+			Owner:        userID("uid-123"),
+		}),
+	}, {
+		Alias: "FileVersionRestored",
+		AuditEvent: types.FileVersionRestored(events.FileVersionRestored{
+			Executant: userID("uid-456"),
+			Ref:       reference("pro-1", "sto-123", "iid-123", "./folder/item.txt"),
+			Owner:     userID("uid-123"),
+			Key:       "v1",
+		}),
+	}, {
+		Alias: "SpaceCreated",
+		AuditEvent: types.SpaceCreated(events.SpaceCreated{
+			Executant: userID("uid-456"),
+			ID:        &provider.StorageSpaceId{OpaqueId: "space-123"},
+			Owner:     userID("uid-123"),
+			Root:      resourceID("pro-1", "space-123", "space-123"),
+			Name:      "Sales\nEMEA",
+			Type:      "project",
+			MTime:     timestamp(10e8),
+		}),
+	}, {
+		Alias: "SpaceRenamed",
+		AuditEvent: types.SpaceRenamed(events.SpaceRenamed{
+			Executant: userID("uid-456"),
+			ID:        &provider.StorageSpaceId{OpaqueId: "space-123"},
+			Name:      "Sales APAC",
+		}),
+	}, {
+		Alias: "SpaceDisabled",
+		AuditEvent: types.SpaceDisabled(events.SpaceDisabled{
+			Executant: userID("uid-456"),
+			ID:        &provider.StorageSpaceId{OpaqueId: "space-123"},
+		}),
+	}, {
+		Alias: "SpaceEnabled",
+		AuditEvent: types.SpaceEnabled(events.SpaceEnabled{
+			Executant: userID("uid-456"),
+			ID:        &provider.StorageSpaceId{OpaqueId: "space-123"},
+		}),
+	}, {
+		Alias: "SpaceDeleted",
+		AuditEvent: types.SpaceDeleted(events.SpaceDeleted{
+			Executant: userID("uid-admin"),
+			ID:        &provider.StorageSpaceId{OpaqueId: "space-123"},
+		}),
+	}, {
+		Alias: "UserCreated",
+		AuditEvent: types.UserCreated(events.UserCreated{
+			Executant: userID("uid-admin"),
+			UserID:    "uid-456",
+		}),
+	}, {
+		Alias: "UserDeleted",
+		AuditEvent: types.UserDeleted(events.UserDeleted{
+			Executant: userID("uid-admin"),
+			UserID:    "uid-456",
+		}),
+	}, {
+		Alias: "UserFeatureChanged",
+		AuditEvent: types.UserFeatureChanged(events.UserFeatureChanged{
+			Executant: userID("uid-admin"),
+			UserID:    "uid-456",
+			Features:  []events.UserFeature{{Name: "displayname", Value: "Jane Doe"}, {Name: "quota", Value: "1000"}},
+		}),
+	}, {
+		Alias: "UserCreatedWithRequest",
+		AuditEvent: types.WithRequest(types.UserCreated(events.UserCreated{
+			Executant: userID("uid-admin"),
+			UserID:    "uid-456",
+		}), requestmeta.Metadata{
+			RemoteAddr: "192.0.2.10",
+			UserAgent:  "Mozilla/5.0 (X11; Linux x86_64)",
+			RequestID:  "req-123",
+			URL:        "/graph/v1.0/users",
+			Method:     "POST",
+		}),
+	}, {
+		Alias: "UserAuthenticated",
+		AuditEvent: types.UserAuthenticated(ocisevents.UserAuthenticated{
+			UserID: userID("uid-123"),
+			Login:  "einstein",
+			Method: "oidc",
+		}),
+	}, {
+		Alias: "UserAuthenticationFailed",
+		AuditEvent: types.UserAuthenticationFailed(ocisevents.UserAuthenticationFailed{
+			Login:  "einstein",
+			Method: "basic",
+			Reason: "could not authenticate with username and password user: einstein, got code: 4",
+		}),
+	}, {
+		Alias: "GroupCreated",
+		AuditEvent: types.GroupCreated(events.GroupCreated{
+			Executant: userID("uid-admin"),
+			GroupID:   "gid-123",
+		}),
+	}, {
+		Alias: "GroupDeleted",
+		AuditEvent: types.GroupDeleted(events.GroupDeleted{
+			Executant: userID("uid-admin"),
+			GroupID:   "gid-123",
+		}),
+	}, {
+		Alias: "GroupMemberAdded",
+		AuditEvent: types.GroupMemberAdded(events.GroupMemberAdded{
+			Executant: userID("uid-admin"),
+			GroupID:   "gid-123",
+			UserID:    "uid-456",
+		}),
+	}, {
+		Alias: "GroupMemberRemoved",
+		AuditEvent: types.GroupMemberRemoved(events.GroupMemberRemoved{
+			Executant: userID("uid-admin"),
+			GroupID:   "gid-123",
+			UserID:    "uid-456",
+		}),
+	},
+}
+
+// checkGolden compares the marshalled audit events with the golden file, which contains a line per
+// event prefixed with its alias. Run the tests with -update to rewrite the golden file.
+func checkGolden(t *testing.T, marshaller Marshaller, golden string) {
+	path := filepath.Join("testdata", golden)
+
+	lines := make([]string, 0, len(siemTestCases))
+	for _, tc := range siemTestCases {
+		b, err := marshaller(tc.AuditEvent)
+		require.NoError(t, err, tc.Alias)
+		require.NotContains(t, string(b), "\n", tc.Alias)
+		lines = append(lines, tc.Alias+" "+string(b))
+	}
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	expected := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		alias, line, _ := strings.Cut(scanner.Text(), " ")
+		expected[alias] = line
+	}
+	require.NoError(t, scanner.Err())
+
+	for i, tc := range siemTestCases {
+		_, line, _ := strings.Cut(lines[i], " ")
+		t.Run(tc.Alias, func(t *testing.T) {
+			require.Contains(t, expected, tc.Alias)
+			require.Equal(t, expected[tc.Alias], line)
+		})
+	}
+}
+
+func TestMarshalCEF(t *testing.T) {
+	checkGolden(t, MarshalCEF("2.0.0"), "events.cef")
+}
+
+func TestMarshalLEEF(t *testing.T) {
+	checkGolden(t, MarshalLEEF("2.0.0"), "events.leef")
+}
+
+func TestMarshalSIEMEscaping(t *testing.T) {
+	ev := types.AuditEventFileRenamed{
+		AuditEventFiles: types.AuditEventFiles{
+			AuditEvent: types.AuditEvent{Action: "file|rename", Message: `moved a\b`, User: "a=b"},
+			Path:       "line\nbreak\ttab",
+		},
+	}
+
+	b, err := MarshalCEF(`1|2\3`)(ev)
+	require.NoError(t, err)
+	require.Equal(t, `CEF:0|ownCloud|oCIS|1\|2\\3|file\|rename|moved a\\b|3|suser=a\=b act=file|rename outcome=success filePath=line\nbreak`+"\t"+`tab fname=line\nbreak`+"\t"+`tab`, string(b))
+
+	b, err = MarshalLEEF("1")(ev)
+	require.NoError(t, err)
+	require.Equal(t, "LEEF:2.0|ownCloud|oCIS|1|file\\|rename|x09|usrName=a=b\tcat=file|rename\tsev=3\toutcome=success\tmsg=moved a\\\\b\tfilePath=line\\nbreak\\ttab\tfileName=line\\nbreak\\ttab", string(b))
+
+	_, err = MarshalCEF("1")(struct{}{})
+	require.Error(t, err)
+}
@@ -0,0 +1,297 @@
+package svc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+)
+
+const (
+	// syslogSeverity is the severity of the audit events, "informational"
+	syslogSeverity = 6
+	// syslogTimeFormat is the RFC 5424 timestamp with microsecond precision
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+	// syslogMaxAppName is the maximum length of the app-name field
+	syslogMaxAppName = 48
+
+	syslogDialTimeout      = 10 * time.Second
+	syslogWriteTimeout     = 10 * time.Second
+	syslogRetryInterval    = time.Second
+	syslogMaxRetryInterval = time.Minute
+	// syslogMaxAttempts is the number of times sending a message over an established connection is tried
+	// before the message is dropped
+	syslogMaxAttempts = 5
+)
+
+// syslogFacilities maps the names of the syslog facilities to their codes
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"ntp":      12,
+	"audit":    13,
+	"alert":    14,
+	"clock":    15,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// SyslogOptions configures the connection to the syslog server
+type SyslogOptions struct {
+	// Network is one of "udp", "tcp" or "tls"
+	Network string
+	// Address is the host and port of the syslog server
+	Address string
+	// TLSConfig is used to connect to the server if the network is "tls"
+	TLSConfig *tls.Config
+	// Facility is the name of the facility the messages are sent with, e.g. "local0"
+	Facility string
+	// AppName identifies the sender of the messages
+	AppName string
+	// BufferSize is the number of messages kept while the server can't be reached
+	BufferSize int
+}
+
+// Syslog sends the audit events as RFC 5424 messages to a syslog server. Stream connections use octet
+// counting as described in RFC 6587 and RFC 5425. The messages are buffered while the server can't be
+// reached and sent once the connection has been re-established. When the buffer is full the oldest
+// messages are dropped. Messages which can't be sent although the server can be reached, e.g. because
+// they exceed the maximum size of a datagram, are dropped as well.
+type Syslog struct {
+	opts          SyslogOptions
+	priority      int
+	hostname      string
+	pid           int
+	log           log.Logger
+	retryInterval time.Duration
+
+	mutex   sync.Mutex
+	queue   [][]byte
+	pending chan struct{}
+}
+
+// NewSyslog returns a new Syslog instance. Start has to be called to send the messages.
+func NewSyslog(opts SyslogOptions, log log.Logger) (*Syslog, error) {
+	switch opts.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unknown syslog network '%s'", opts.Network)
+	}
+	if opts.Address == "" {
+		return nil, fmt.Errorf("missing syslog address")
+	}
+	facility, ok := syslogFacilities[opts.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility '%s'", opts.Facility)
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1
+	}
+	opts.AppName = syslogField(opts.AppName, syslogMaxAppName)
+
+	hostname, _ := os.Hostname()
+	return &Syslog{
+		opts:          opts,
+		priority:      facility*8 + syslogSeverity,
+		hostname:      syslogField(hostname, 255),
+		pid:           os.Getpid(),
+		log:           log,
+		retryInterval: syslogRetryInterval,
+		pending:       make(chan struct{}, 1),
+	}, nil
+}
+
+// Start sends the messages in the background until the context is done
+func (s *Syslog) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+// Write queues the content to be sent to the syslog server. It implements Log.
+func (s *Syslog) Write(content []byte) {
+	msg := s.format(content, time.Now())
+
+	s.mutex.Lock()
+	if len(s.queue) >= s.opts.BufferSize {
+		s.queue = s.queue[1:]
+		s.log.Error().Str("address", s.opts.Address).Msg("syslog buffer is full, dropping the oldest audit event")
+	}
+	s.queue = append(s.queue, msg)
+	s.mutex.Unlock()
+
+	select {
+	case s.pending <- struct{}{}:
+	default:
+	}
+}
+
+// format returns the RFC 5424 message for the given content
+func (s *Syslog) format(content []byte, t time.Time) []byte {
+	header := fmt.Sprintf("<%d>1 %s %s %s %d - - ", s.priority, t.UTC().Format(syslogTimeFormat), s.hostname, s.opts.AppName, s.pid)
+	return append([]byte(header), content...)
+}
+
+func (s *Syslog) dequeue() []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.queue) == 0 {
+		return nil
+	}
+	msg := s.queue[0]
+	s.queue = s.queue[1:]
+	return msg
+}
+
+func (s *Syslog) run(ctx context.Context) {
+	var (
+		conn     net.Conn
+		closed   <-chan struct{}
+		msg      []byte
+		attempts int
+		retry    = s.retryInterval
+		failing  = false
+	)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		if msg == nil {
+			if msg = s.dequeue(); msg == nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-s.pending:
+					continue
+				}
+			}
+		}
+
+		if conn != nil {
+			select {
+			case <-closed:
+				// the server closed the connection, writing to it would silently lose the message
+				conn.Close()
+				conn = nil
+			default:
+			}
+		}
+		var err error
+		if conn == nil {
+			conn, closed, err = s.dial()
+		}
+		if err == nil {
+			if err = s.send(conn, msg); err != nil {
+				conn.Close()
+				conn = nil
+				attempts++
+				if errors.Is(err, syscall.EMSGSIZE) || attempts >= syslogMaxAttempts {
+					s.log.Error().Err(err).Str("address", s.opts.Address).Int("attempts", attempts).Msg("error sending an audit event to syslog, dropping it")
+					msg, attempts = nil, 0
+					continue
+				}
+			}
+		}
+		if err != nil {
+			if !failing {
+				s.log.Error().Err(err).Str("address", s.opts.Address).Msg("error sending audit events to syslog, buffering them until the server can be reached")
+				failing = true
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retry):
+			}
+			if retry *= 2; retry > syslogMaxRetryInterval {
+				retry = syslogMaxRetryInterval
+			}
+			continue
+		}
+
+		if failing {
+			s.log.Info().Str("address", s.opts.Address).Msg("reconnected to syslog")
+			failing = false
+		}
+		retry = s.retryInterval
+		msg, attempts = nil, 0
+	}
+}
+
+// dial connects to the syslog server. The returned channel is closed when the server closes a
+// stream connection.
+func (s *Syslog) dial() (net.Conn, <-chan struct{}, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	if s.opts.Network == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.opts.Address, s.opts.TLSConfig)
+	} else {
+		conn, err = dialer.Dial(s.opts.Network, s.opts.Address)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closed := make(chan struct{})
+	if s.opts.Network != "udp" {
+		// syslog servers don't send anything, reading only returns once the connection is gone
+		go func() {
+			_, _ = io.Copy(io.Discard, conn)
+			close(closed)
+		}()
+	}
+	return conn, closed, nil
+}
+
+func (s *Syslog) send(conn net.Conn, msg []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout)); err != nil {
+		return err
+	}
+	if s.opts.Network == "udp" {
+		_, err := conn.Write(msg)
+		return err
+	}
+	_, err := fmt.Fprintf(conn, "%d %s", len(msg), msg)
+	return err
+}
+
+// syslogField returns the value as a header field, which consists of printable ASCII characters only
+func syslogField(value string, maxLen int) string {
+	field := make([]byte, 0, len(value))
+	for i := 0; i < len(value) && len(field) < maxLen; i++ {
+		if value[i] > 32 && value[i] < 127 {
+			field = append(field, value[i])
+		}
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	return string(field)
+}
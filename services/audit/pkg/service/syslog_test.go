@@ -0,0 +1,207 @@
+package svc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	ociscrypto "github.com/owncloud/ocis/v2/ocis-pkg/crypto"
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/test-go/testify/require"
+)
+
+var syslogHeader = regexp.MustCompile(`^<134>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}Z \S+ ocis-audit \d+ - - `)
+
+func newTestSyslog(t *testing.T, network, address string) *Syslog {
+	s, err := NewSyslog(SyslogOptions{
+		Network:    network,
+		Address:    address,
+		TLSConfig:  &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+		Facility:   "local0",
+		AppName:    "ocis-audit",
+		BufferSize: 10,
+	}, log.NewLogger())
+	require.NoError(t, err)
+	s.retryInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s.Start(ctx)
+	return s
+}
+
+// readFrame reads an octet counted message from the stream
+func readFrame(t *testing.T, conn net.Conn, r *bufio.Reader) string {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	length, err := r.ReadString(' ')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(length[:len(length)-1])
+	require.NoError(t, err)
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	require.NoError(t, err)
+	return string(msg)
+}
+
+func TestSyslogFormat(t *testing.T) {
+	s, err := NewSyslog(SyslogOptions{Network: "udp", Address: "127.0.0.1:514", Facility: "authpriv", AppName: "ocis audit"}, log.NewLogger())
+	require.NoError(t, err)
+	s.hostname = "host"
+
+	msg := s.format([]byte(`{"Action":"file_shared"}`), time.Date(2022, 11, 14, 10, 5, 3, 123456789, time.FixedZone("CET", 3600)))
+	require.Equal(t, fmt.Sprintf(`<86>1 2022-11-14T09:05:03.123456Z host ocisaudit %d - - {"Action":"file_shared"}`, os.Getpid()), string(msg))
+}
+
+func TestSyslogRejectsInvalidOptions(t *testing.T) {
+	_, err := NewSyslog(SyslogOptions{Network: "http", Address: "127.0.0.1:514", Facility: "local0"}, log.NewLogger())
+	require.Error(t, err)
+	_, err = NewSyslog(SyslogOptions{Network: "udp", Address: "127.0.0.1:514", Facility: "local8"}, log.NewLogger())
+	require.Error(t, err)
+	_, err = NewSyslog(SyslogOptions{Network: "udp", Facility: "local0"}, log.NewLogger())
+	require.Error(t, err)
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s := newTestSyslog(t, "udp", conn.LocalAddr().String())
+	s.Write([]byte("first event"))
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Regexp(t, syslogHeader, string(buf[:n]))
+	require.Equal(t, "first event", syslogHeader.ReplaceAllString(string(buf[:n]), ""))
+}
+
+func TestSyslogDropsOversizedMessages(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s := newTestSyslog(t, "udp", conn.LocalAddr().String())
+	s.Write(make([]byte, 70000))
+	s.Write([]byte("second event"))
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Regexp(t, syslogHeader, string(buf[:n]))
+	require.Contains(t, string(buf[:n]), "second event")
+}
+
+func TestSyslogTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	s := newTestSyslog(t, "tcp", l.Addr().String())
+	s.Write([]byte("first event"))
+	s.Write([]byte("second\nevent"))
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	require.Equal(t, "first event", syslogHeader.ReplaceAllString(readFrame(t, conn, r), ""))
+	require.Equal(t, "second\nevent", syslogHeader.ReplaceAllString(readFrame(t, conn, r), ""))
+}
+
+func TestSyslogTLS(t *testing.T) {
+	cert, err := ociscrypto.GenTempCertForAddr("127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	require.NoError(t, err)
+	defer l.Close()
+
+	s := newTestSyslog(t, "tls", l.Addr().String())
+	s.Write([]byte("first event"))
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, "first event", syslogHeader.ReplaceAllString(readFrame(t, conn, bufio.NewReader(conn)), ""))
+}
+
+func TestSyslogBuffersWhileDisconnected(t *testing.T) {
+	// reserve a port nobody listens on yet
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	s := newTestSyslog(t, "tcp", address)
+	for i := 0; i < 3; i++ {
+		s.Write([]byte(fmt.Sprintf("event %d", i)))
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	l, err = net.Listen("tcp", address)
+	require.NoError(t, err)
+	defer l.Close()
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for i := 0; i < 3; i++ {
+		require.Equal(t, fmt.Sprintf("event %d", i), syslogHeader.ReplaceAllString(readFrame(t, conn, r), ""))
+	}
+}
+
+func TestSyslogReconnects(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	s := newTestSyslog(t, "tcp", l.Addr().String())
+	s.Write([]byte("before"))
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	require.Equal(t, "before", syslogHeader.ReplaceAllString(readFrame(t, conn, bufio.NewReader(conn)), ""))
+	require.NoError(t, conn.Close())
+
+	// the messages are sent over a new connection once the closed one has been noticed
+	accepted := make(chan net.Conn)
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	deadline := time.After(5 * time.Second)
+	for {
+		s.Write([]byte("after"))
+		select {
+		case conn := <-accepted:
+			defer conn.Close()
+			require.Equal(t, "after", syslogHeader.ReplaceAllString(readFrame(t, conn, bufio.NewReader(conn)), ""))
+			return
+		case <-deadline:
+			t.Fatal("no reconnect")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func TestSyslogDropsTheOldestMessages(t *testing.T) {
+	s, err := NewSyslog(SyslogOptions{Network: "udp", Address: "127.0.0.1:514", Facility: "local0", BufferSize: 2}, log.NewLogger())
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		s.Write([]byte(fmt.Sprintf("event %d", i)))
+	}
+	require.Len(t, s.queue, 2)
+	require.Contains(t, string(s.dequeue()), "event 1")
+	require.Contains(t, string(s.dequeue()), "event 2")
+}
@@ -0,0 +1,383 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+)
+
+const (
+	// WebhookSignatureHeader carries the signature of the request, see WebhookSignature
+	WebhookSignatureHeader = "X-OCIS-Signature"
+	// WebhookBatchHeader carries the id of the batch. A batch is sent again with the same id if the
+	// receiver didn't acknowledge it, so that the receiver can drop duplicates.
+	WebhookBatchHeader = "X-OCIS-Batch-Id"
+	// WebhookTimestampHeader carries the time the request has been sent at in seconds since the epoch. It is
+	// part of the signature, so that receivers can reject replayed requests.
+	WebhookTimestampHeader = "X-OCIS-Timestamp"
+
+	webhookSpoolExt         = ".batch"
+	webhookDeadLetterDir    = "failed"
+	webhookTimeout          = 30 * time.Second
+	webhookRetryInterval    = time.Second
+	webhookMaxRetryInterval = 5 * time.Minute
+)
+
+// WebhookOptions configures the endpoint the audit events are posted to
+type WebhookOptions struct {
+	// URL is the https endpoint the batches are posted to
+	URL string
+	// Secret is the key used to sign the batches
+	Secret []byte
+	// ContentType is the content type of the batches
+	ContentType string
+	// TLSConfig is used to connect to the endpoint
+	TLSConfig *tls.Config
+	// BatchSize is the number of audit events after which a batch is sent
+	BatchSize int
+	// BatchInterval is the interval in which a batch is sent even if it isn't full. 0 only sends full batches.
+	BatchInterval time.Duration
+	// SpoolDir is the directory the batches are kept in until the endpoint acknowledged them
+	SpoolDir string
+	// MaxSpoolSize is the size in bytes of the spooled batches after which the oldest ones are dropped.
+	// 0 keeps all of them. The failed batches are limited to the same size on their own.
+	MaxSpoolSize int64
+}
+
+// Webhook posts the audit events in batches to an https endpoint. The events of a batch are separated
+// by newlines and the request is signed with HMAC-SHA-256 in the WebhookSignatureHeader. Every batch is
+// written to the spool directory before it is sent and only removed once the endpoint responded with a
+// 2xx status, failed requests are retried with an exponential backoff. Batches the endpoint rejected with
+// a 4xx status are not retried and moved to the failed directory in the spool directory, unless the
+// status may change without changing the batch, like an authentication error or a rate limit. Batches
+// spooled by a previous run are sent on start, the oldest first.
+type Webhook struct {
+	opts          WebhookOptions
+	client        *http.Client
+	log           log.Logger
+	retryInterval time.Duration
+
+	mutex   sync.Mutex
+	batch   bytes.Buffer
+	count   int
+	seq     int
+	pending chan struct{}
+}
+
+// NewWebhook returns a new Webhook instance. Start has to be called to send the batches.
+func NewWebhook(opts WebhookOptions, log log.Logger) (*Webhook, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook url: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("the webhook url '%s' must be an https url", opts.URL)
+	}
+	if len(opts.Secret) == 0 {
+		return nil, fmt.Errorf("missing webhook secret")
+	}
+	if opts.SpoolDir == "" {
+		return nil, fmt.Errorf("missing webhook spool directory")
+	}
+	if err := os.MkdirAll(opts.SpoolDir, 0700); err != nil {
+		return nil, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "text/plain; charset=utf-8"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = opts.TLSConfig
+	return &Webhook{
+		opts: opts,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   webhookTimeout,
+		},
+		log:           log,
+		retryInterval: webhookRetryInterval,
+		pending:       make(chan struct{}, 1),
+	}, nil
+}
+
+// Start sends the batches in the background until the context is done. The spooled batches which
+// haven't been sent by then are sent on the next start. Flush has to be called after the context is
+// done to spool the current batch, it isn't done in the background as the process may exit before.
+func (w *Webhook) Start(ctx context.Context) {
+	go w.run(ctx)
+	go func() {
+		var tick <-chan time.Time
+		if w.opts.BatchInterval > 0 {
+			ticker := time.NewTicker(w.opts.BatchInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+				w.Flush()
+			}
+		}
+	}()
+}
+
+// Write adds the content to the current batch, which is spooled once it is full. It implements Log.
+func (w *Webhook) Write(content []byte) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.batch.Write(content)
+	w.batch.WriteByte('\n')
+	w.count++
+	if w.count >= w.opts.BatchSize {
+		w.spool()
+	}
+}
+
+// Flush spools the current batch, so that it is sent even if it isn't full
+func (w *Webhook) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.spool()
+}
+
+// spool writes the current batch to the spool directory and wakes up the sender. The mutex must be held.
+func (w *Webhook) spool() {
+	if w.count == 0 {
+		return
+	}
+
+	w.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), w.seq%1000000, webhookSpoolExt)
+	path := filepath.Join(w.opts.SpoolDir, name)
+	if err := writeFileAtomic(path, w.batch.Bytes()); err != nil {
+		// keep the batch, spooling it is tried again with the next event or interval
+		w.log.Error().Err(err).Str("path", path).Msg("error spooling audit events for the webhook")
+		return
+	}
+	w.batch.Reset()
+	w.count = 0
+	w.trimSpool()
+
+	select {
+	case w.pending <- struct{}{}:
+	default:
+	}
+}
+
+// trimSpool drops the oldest batches while the spooled batches exceed the maximum size
+func (w *Webhook) trimSpool() {
+	w.trim(w.opts.SpoolDir)
+}
+
+// trim drops the oldest batches in the directory while they exceed the maximum size of the spool
+func (w *Webhook) trim(dir string) {
+	if w.opts.MaxSpoolSize <= 0 {
+		return
+	}
+	names, err := batchesIn(dir)
+	if err != nil {
+		w.log.Error().Err(err).Str("dir", dir).Msg("error reading the webhook spool")
+		return
+	}
+
+	var (
+		sizes = make([]int64, len(names))
+		total int64
+	)
+	for i, name := range names {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	// the newest batch is always kept
+	for i := 0; i < len(names)-1 && total > w.opts.MaxSpoolSize; i++ {
+		if err := os.Remove(filepath.Join(dir, names[i])); err != nil && !os.IsNotExist(err) {
+			w.log.Error().Err(err).Str("batch", names[i]).Msg("error removing audit events from the webhook spool")
+			continue
+		}
+		total -= sizes[i]
+		w.log.Error().Str("url", w.opts.URL).Str("batch", filepath.Join(dir, names[i])).Msg("webhook spool is full, dropping the oldest audit events")
+	}
+}
+
+// spooled returns the names of the spooled batches, the oldest first
+func (w *Webhook) spooled() ([]string, error) {
+	return batchesIn(w.opts.SpoolDir)
+}
+
+// batchesIn returns the names of the batches in the directory, the oldest first
+func batchesIn(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), webhookSpoolExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (w *Webhook) run(ctx context.Context) {
+	var (
+		retry   = w.retryInterval
+		failing = false
+	)
+
+	for {
+		names, err := w.spooled()
+		if err == nil && len(names) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.pending:
+				continue
+			}
+		}
+		if err == nil {
+			err = w.send(ctx, names[0])
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if !failing {
+				w.log.Error().Err(err).Str("url", w.opts.URL).Msg("error sending audit events to the webhook, spooling them until the endpoint can be reached")
+				failing = true
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retry):
+			}
+			if retry *= 2; retry > webhookMaxRetryInterval {
+				retry = webhookMaxRetryInterval
+			}
+			continue
+		}
+
+		if failing {
+			w.log.Info().Str("url", w.opts.URL).Msg("sent the spooled audit events to the webhook")
+			failing = false
+		}
+		retry = w.retryInterval
+	}
+}
+
+// send posts the spooled batch and removes it once the endpoint acknowledged it
+func (w *Webhook) send(ctx context.Context, name string) error {
+	path := filepath.Join(w.opts.SpoolDir, name)
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// the batch has been dropped because the spool is full
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	batchID := strings.TrimSuffix(name, webhookSpoolExt)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", w.opts.ContentType)
+	req.Header.Set(WebhookBatchHeader, batchID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(w.opts.Secret, batchID, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if isPermanentWebhookFailure(resp.StatusCode) {
+		// sending the batch again won't help, it is set aside so that the following batches are sent
+		return w.deadLetter(name, resp.Status)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// deadLetter moves the spooled batch the endpoint rejected to the failed directory of the spool
+func (w *Webhook) deadLetter(name, status string) error {
+	dir := filepath.Join(w.opts.SpoolDir, webhookDeadLetterDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(w.opts.SpoolDir, name), filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	w.log.Error().
+		Str("url", w.opts.URL).
+		Str("status", status).
+		Str("batch", filepath.Join(dir, name)).
+		Msg("the webhook rejected the audit events, moved them to the failed batches")
+	w.trim(dir)
+	return nil
+}
+
+// isPermanentWebhookFailure returns true for the client errors which aren't resolved by sending the batch
+// again. Authentication errors and a missing endpoint are usually resolved by fixing the configuration of
+// the receiver, so they are retried like timeouts and rate limits.
+func isPermanentWebhookFailure(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 400 && status <= 499
+}
+
+// WebhookSignature returns the value of the WebhookSignatureHeader, the hex encoded HMAC-SHA-256 of the
+// batch id, the timestamp and the body joined by dots, prefixed with "sha256="
+func WebhookSignature(secret []byte, batchID, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(batchID + "." + timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// writeFileAtomic writes the file under a temporary name and renames it, so that it is either complete
+// or not there at all
+func writeFileAtomic(path string, content []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
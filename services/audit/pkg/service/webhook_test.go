@@ -0,0 +1,215 @@
+package svc
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/test-go/testify/require"
+)
+
+type webhookRequest struct {
+	body      string
+	batch     string
+	timestamp string
+	signature string
+}
+
+// newTestEndpoint returns an https endpoint which responds with the given statuses, the last one for
+// all further requests, and reports the acknowledged requests on the channel
+func newTestEndpoint(t *testing.T, statuses ...int) (string, <-chan webhookRequest) {
+	ch := make(chan webhookRequest, 10)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		status := statuses[0]
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			ch <- webhookRequest{
+				body:      string(body),
+				batch:     r.Header.Get(WebhookBatchHeader),
+				timestamp: r.Header.Get(WebhookTimestampHeader),
+				signature: r.Header.Get(WebhookSignatureHeader),
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, ch
+}
+
+func newTestWebhook(t *testing.T, opts WebhookOptions) *Webhook {
+	opts.Secret = []byte("secret")
+	opts.TLSConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	if opts.SpoolDir == "" {
+		opts.SpoolDir = t.TempDir()
+	}
+	w, err := NewWebhook(opts, log.NewLogger())
+	require.NoError(t, err)
+	w.retryInterval = 10 * time.Millisecond
+	return w
+}
+
+func startTestWebhook(t *testing.T, w *Webhook) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	w.Start(ctx)
+}
+
+func receiveRequest(t *testing.T, ch <-chan webhookRequest) webhookRequest {
+	select {
+	case req := <-ch:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("the webhook didn't receive a request")
+		return webhookRequest{}
+	}
+}
+
+func TestNewWebhook(t *testing.T) {
+	for _, opts := range []WebhookOptions{
+		{URL: "http://example.com/audit", Secret: []byte("secret"), SpoolDir: t.TempDir()},
+		{URL: "example.com", Secret: []byte("secret"), SpoolDir: t.TempDir()},
+		{URL: "https://example.com/audit", SpoolDir: t.TempDir()},
+		{URL: "https://example.com/audit", Secret: []byte("secret")},
+	} {
+		_, err := NewWebhook(opts, log.NewLogger())
+		require.Error(t, err, opts.URL)
+	}
+}
+
+func TestWebhookBatchSize(t *testing.T) {
+	url, ch := newTestEndpoint(t, http.StatusOK)
+	w := newTestWebhook(t, WebhookOptions{URL: url, BatchSize: 2})
+	startTestWebhook(t, w)
+
+	w.Write([]byte(`{"Action":"file_read"}`))
+	w.Write([]byte(`{"Action":"file_delete"}`))
+	w.Write([]byte(`{"Action":"file_create"}`))
+
+	req := receiveRequest(t, ch)
+	require.Equal(t, "{\"Action\":\"file_read\"}\n{\"Action\":\"file_delete\"}\n", req.body)
+	require.NotEmpty(t, req.batch)
+	require.NotEmpty(t, req.timestamp)
+	require.Equal(t, WebhookSignature([]byte("secret"), req.batch, req.timestamp, []byte(req.body)), req.signature)
+
+	select {
+	case req := <-ch:
+		t.Fatalf("unexpected batch %q before it is full", req.body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhookBatchInterval(t *testing.T) {
+	url, ch := newTestEndpoint(t, http.StatusOK)
+	w := newTestWebhook(t, WebhookOptions{URL: url, BatchSize: 100, BatchInterval: 10 * time.Millisecond})
+	startTestWebhook(t, w)
+
+	w.Write([]byte(`{"Action":"file_read"}`))
+	require.Equal(t, "{\"Action\":\"file_read\"}\n", receiveRequest(t, ch).body)
+}
+
+func TestWebhookSignature(t *testing.T) {
+	require.Equal(t, "sha256=d17d97481e2bfd439f2221961dc8375f1435dc8f8f0ec088a8b37081cac8d378", WebhookSignature([]byte("secret"), "batch-1", "1668000000", []byte("a\n")))
+}
+
+func TestWebhookRetries(t *testing.T) {
+	url, ch := newTestEndpoint(t, http.StatusServiceUnavailable, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusOK)
+	w := newTestWebhook(t, WebhookOptions{URL: url, BatchSize: 1})
+	startTestWebhook(t, w)
+
+	w.Write([]byte("first"))
+	w.Write([]byte("second"))
+
+	require.Equal(t, "first\n", receiveRequest(t, ch).body)
+	require.Equal(t, "second\n", receiveRequest(t, ch).body)
+
+	// the acknowledged batches are removed from the spool
+	for i := 0; i < 100; i++ {
+		if entries, _ := os.ReadDir(w.opts.SpoolDir); len(entries) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("the spool hasn't been emptied")
+}
+
+func TestWebhookSetsRejectedBatchesAside(t *testing.T) {
+	url, ch := newTestEndpoint(t, http.StatusBadRequest, http.StatusOK)
+	w := newTestWebhook(t, WebhookOptions{URL: url, BatchSize: 1})
+	startTestWebhook(t, w)
+
+	w.Write([]byte("first"))
+	w.Write([]byte("second"))
+
+	require.Equal(t, "second\n", receiveRequest(t, ch).body)
+
+	failed, err := os.ReadDir(filepath.Join(w.opts.SpoolDir, webhookDeadLetterDir))
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	content, err := os.ReadFile(filepath.Join(w.opts.SpoolDir, webhookDeadLetterDir, failed[0].Name()))
+	require.NoError(t, err)
+	require.Equal(t, "first\n", string(content))
+}
+
+func TestWebhookLimitsTheRejectedBatches(t *testing.T) {
+	url, _ := newTestEndpoint(t, http.StatusBadRequest)
+	w := newTestWebhook(t, WebhookOptions{URL: url, BatchSize: 1, MaxSpoolSize: 10})
+	w.Write([]byte("first"))
+	w.Write([]byte("second"))
+	w.Write([]byte("third"))
+	startTestWebhook(t, w)
+
+	dir := filepath.Join(w.opts.SpoolDir, webhookDeadLetterDir)
+	for i := 0; i < 500; i++ {
+		if names, _ := w.spooled(); len(names) == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	names, err := batchesIn(dir)
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+	content, err := os.ReadFile(filepath.Join(dir, names[0]))
+	require.NoError(t, err)
+	require.Equal(t, "third\n", string(content))
+}
+
+func TestWebhookSendsSpoolOnStart(t *testing.T) {
+	dir := t.TempDir()
+
+	// the first webhook isn't started, as if the endpoint has been down until the service stopped
+	w := newTestWebhook(t, WebhookOptions{URL: "https://127.0.0.1:1/audit", BatchSize: 2, SpoolDir: dir})
+	w.Write([]byte("first"))
+	w.Write([]byte("second"))
+	w.Write([]byte("third"))
+	w.Flush()
+
+	url, ch := newTestEndpoint(t, http.StatusOK)
+	startTestWebhook(t, newTestWebhook(t, WebhookOptions{URL: url, BatchSize: 2, SpoolDir: dir}))
+
+	require.Equal(t, "first\nsecond\n", receiveRequest(t, ch).body)
+	require.Equal(t, "third\n", receiveRequest(t, ch).body)
+}
+
+func TestWebhookMaxSpoolSize(t *testing.T) {
+	w := newTestWebhook(t, WebhookOptions{URL: "https://127.0.0.1:1/audit", BatchSize: 1, MaxSpoolSize: 10})
+	w.Write([]byte("first"))
+	w.Write([]byte("second"))
+	w.Write([]byte("third"))
+
+	names, err := w.spooled()
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+	content, err := os.ReadFile(filepath.Join(w.opts.SpoolDir, names[0]))
+	require.NoError(t, err)
+	require.Equal(t, "third\n", string(content))
+}
@@ -47,8 +47,74 @@ const (
 	ActionGroupDeleted       = "group_deleted"
 	ActionGroupMemberAdded   = "group_member_added"
 	ActionGroupMemberRemoved = "group_member_removed"
+
+	// Authentication
+	ActionUserAuthenticated = "user_authenticated"
+)
+
+// categories of the audit actions, they can be used instead of the actions to filter the audit events
+const (
+	CategorySharing        = "sharing"
+	CategoryFiles          = "files"
+	CategorySpaces         = "spaces"
+	CategoryUsers          = "users"
+	CategoryGroups         = "groups"
+	CategoryAuthentication = "authentication"
 )
 
+var _actionCategories = map[string]string{
+	ActionShareCreated:            CategorySharing,
+	ActionSharePermissionUpdated:  CategorySharing,
+	ActionShareDisplayNameUpdated: CategorySharing,
+	ActionSharePasswordUpdated:    CategorySharing,
+	ActionShareExpirationUpdated:  CategorySharing,
+	ActionShareRemoved:            CategorySharing,
+	ActionShareAccepted:           CategorySharing,
+	ActionShareDeclined:           CategorySharing,
+	ActionLinkAccessed:            CategorySharing,
+
+	ActionContainerCreated:    CategoryFiles,
+	ActionFileCreated:         CategoryFiles,
+	ActionFileRead:            CategoryFiles,
+	ActionFileTrashed:         CategoryFiles,
+	ActionFileRenamed:         CategoryFiles,
+	ActionFilePurged:          CategoryFiles,
+	ActionFileRestored:        CategoryFiles,
+	ActionFileVersionRestored: CategoryFiles,
+
+	ActionSpaceCreated:  CategorySpaces,
+	ActionSpaceRenamed:  CategorySpaces,
+	ActionSpaceDisabled: CategorySpaces,
+	ActionSpaceEnabled:  CategorySpaces,
+	ActionSpaceDeleted:  CategorySpaces,
+
+	ActionUserCreated:        CategoryUsers,
+	ActionUserDeleted:        CategoryUsers,
+	ActionUserFeatureChanged: CategoryUsers,
+
+	ActionGroupCreated:       CategoryGroups,
+	ActionGroupDeleted:       CategoryGroups,
+	ActionGroupMemberAdded:   CategoryGroups,
+	ActionGroupMemberRemoved: CategoryGroups,
+
+	ActionUserAuthenticated: CategoryAuthentication,
+}
+
+// Category returns the category of the given action or an empty string for unknown actions
+func Category(action string) string {
+	return _actionCategories[action]
+}
+
+// IsCategory returns true if name is one of the categories of the audit actions
+func IsCategory(name string) bool {
+	for _, category := range _actionCategories {
+		if category == name {
+			return true
+		}
+	}
+	return false
+}
+
 // MessageShareCreated returns the human readable string that describes the action
 func MessageShareCreated(sharer, item, grantee string) string {
 	return fmt.Sprintf("user '%s' shared file '%s' with '%s'", sharer, item, grantee)
@@ -187,6 +253,16 @@ func MessageUserFeatureChanged(executant, userID string, features []events.UserF
 	return sb.String()
 }
 
+// MessageUserAuthenticated returns the human readable string that describes the action
+func MessageUserAuthenticated(login, method string) string {
+	return fmt.Sprintf("user '%s' authenticated via '%s'", login, method)
+}
+
+// MessageUserAuthenticationFailed returns the human readable string that describes the action
+func MessageUserAuthenticationFailed(login, method, reason string) string {
+	return fmt.Sprintf("authentication of user '%s' via '%s' failed: %s", login, method, reason)
+}
+
 // MessageGroupCreated returns the human readable string that describes the action
 func MessageGroupCreated(executant, groupID string) string {
 	return fmt.Sprintf("user '%s' created group '%s'", executant, groupID)
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/test-go/testify/require"
+)
+
+func TestEveryActionHasACategory(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "constants.go", nil, 0)
+	require.NoError(t, err)
+
+	actions := 0
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if !strings.HasPrefix(name.Name, "Action") {
+					continue
+				}
+				action, err := strconv.Unquote(vs.Values[i].(*ast.BasicLit).Value)
+				require.NoError(t, err)
+				require.NotEmpty(t, Category(action), "action %s has no category", name.Name)
+				require.True(t, IsCategory(Category(action)))
+				actions++
+			}
+		}
+	}
+	require.NotZero(t, actions)
+}
+
+func TestIsCategory(t *testing.T) {
+	require.True(t, IsCategory(CategoryAuthentication))
+	require.False(t, IsCategory(ActionFileRead))
+	require.False(t, IsCategory(""))
+}
@@ -2,10 +2,13 @@ package types
 
 import (
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/cs3org/reva/v2/pkg/events"
 	"github.com/cs3org/reva/v2/pkg/storagespace"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
+	"github.com/owncloud/ocis/v2/ocis-pkg/requestmeta"
 
 	group "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
 	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
@@ -25,13 +28,65 @@ func BasicAuditEvent(uid string, ctime string, msg string, action string) AuditE
 		Action:  action,
 		Level:   1,
 
-		// NOTE: those values are not in the events and can therefore not be filled at the moment
+		// NOTE: the request is set by WithRequest if the event carries its metadata
 		RemoteAddr: "",
 		URL:        "",
 		Method:     "",
 		UserAgent:  "",
-		CLI:        false,
+		RequestID:  "",
+
+		// NOTE: this value is not in the events and can therefore not be filled at the moment
+		CLI: false,
+	}
+}
+
+// WithRequest returns a copy of the given audit event with the fields describing the request set from
+// the request metadata. The audit event must embed an AuditEvent.
+func WithRequest(ev interface{}, md requestmeta.Metadata) interface{} {
+	return withBase(ev, func(base *AuditEvent) {
+		base.RemoteAddr = md.RemoteAddr
+		base.UserAgent = md.UserAgent
+		base.RequestID = md.RequestID
+		base.URL = md.URL
+		base.Method = md.Method
+	})
+}
+
+// WithTime returns a copy of the given audit event with the time set to t if the event didn't carry
+// the time it happened at. The audit event must embed an AuditEvent.
+func WithTime(ev interface{}, t time.Time) interface{} {
+	return withBase(ev, func(base *AuditEvent) {
+		if base.Time == "" {
+			base.Time = t.UTC().Format(time.RFC3339)
+		}
+	})
+}
+
+// withBase returns a copy of the given audit event with the embedded AuditEvent changed by fn
+func withBase(ev interface{}, fn func(*AuditEvent)) interface{} {
+	v := reflect.New(reflect.TypeOf(ev)).Elem()
+	v.Set(reflect.ValueOf(ev))
+	f := v.FieldByName("AuditEvent")
+	if !f.IsValid() || f.Type() != reflect.TypeOf(AuditEvent{}) {
+		return ev
 	}
+	base := f.Interface().(AuditEvent)
+	fn(&base)
+	f.Set(reflect.ValueOf(base))
+	return v.Interface()
+}
+
+// Action returns the action of the given audit event. The audit event must embed an AuditEvent.
+func Action(ev interface{}) string {
+	v := reflect.ValueOf(ev)
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	f := v.FieldByName("AuditEvent")
+	if !f.IsValid() || f.Type() != reflect.TypeOf(AuditEvent{}) {
+		return ""
+	}
+	return f.Interface().(AuditEvent).Action
 }
 
 // SharingAuditEvent creates an AuditEventSharing from given values
@@ -225,12 +280,13 @@ func LinkAccessFailed(ev events.LinkAccessFailed) AuditEventLinkAccessed {
 }
 
 // FilesAuditEvent creates an AuditEventFiles from the given values
-func FilesAuditEvent(base AuditEvent, itemid, owner, path string) AuditEventFiles {
+func FilesAuditEvent(base AuditEvent, itemid, owner, path, executant string) AuditEventFiles {
 	return AuditEventFiles{
 		AuditEvent: base,
 		FileID:     itemid,
 		Owner:      owner,
 		Path:       path,
+		Executant:  executant,
 	}
 }
 
@@ -239,7 +295,7 @@ func ContainerCreated(ev events.ContainerCreated) AuditEventContainerCreated {
 	iid, path, uid := extractFileDetails(ev.Ref, ev.Owner)
 	base := BasicAuditEvent(uid, "", MessageContainerCreated(ev.Executant.GetOpaqueId(), iid), ActionContainerCreated)
 	return AuditEventContainerCreated{
-		AuditEventFiles: FilesAuditEvent(base, iid, uid, path),
+		AuditEventFiles: FilesAuditEvent(base, iid, uid, path, ev.Executant.GetOpaqueId()),
 	}
 }
 
@@ -248,7 +304,7 @@ func FileUploaded(ev events.FileUploaded) AuditEventFileCreated {
 	iid, path, uid := extractFileDetails(ev.Ref, ev.Owner)
 	base := BasicAuditEvent(uid, "", MessageFileCreated(ev.Executant.GetOpaqueId(), iid), ActionFileCreated)
 	return AuditEventFileCreated{
-		AuditEventFiles: FilesAuditEvent(base, iid, uid, path),
+		AuditEventFiles: FilesAuditEvent(base, iid, uid, path, ev.Executant.GetOpaqueId()),
 	}
 }
 
@@ -257,7 +313,7 @@ func FileDownloaded(ev events.FileDownloaded) AuditEventFileRead {
 	iid, path, uid := extractFileDetails(ev.Ref, ev.Owner)
 	base := BasicAuditEvent(uid, "", MessageFileRead(ev.Executant.GetOpaqueId(), iid), ActionFileRead)
 	return AuditEventFileRead{
-		AuditEventFiles: FilesAuditEvent(base, iid, uid, path),
+		AuditEventFiles: FilesAuditEvent(base, iid, uid, path, ev.Executant.GetOpaqueId()),
 	}
 }
 
@@ -272,7 +328,7 @@ func ItemMoved(ev events.ItemMoved) AuditEventFileRenamed {
 
 	base := BasicAuditEvent(uid, "", MessageFileRenamed(ev.Executant.GetOpaqueId(), iid, oldpath, path), ActionFileRenamed)
 	return AuditEventFileRenamed{
-		AuditEventFiles: FilesAuditEvent(base, iid, uid, path),
+		AuditEventFiles: FilesAuditEvent(base, iid, uid, path, ev.Executant.GetOpaqueId()),
 		OldPath:         oldpath,
 	}
 }
@@ -282,7 +338,7 @@ func ItemTrashed(ev events.ItemTrashed) AuditEventFileDeleted {
 	iid, path, uid := extractFileDetails(ev.Ref, ev.Owner)
 	base := BasicAuditEvent(uid, "", MessageFileTrashed(ev.Executant.GetOpaqueId(), iid), ActionFileTrashed)
 	return AuditEventFileDeleted{
-		AuditEventFiles: FilesAuditEvent(base, iid, uid, path),
+		AuditEventFiles: FilesAuditEvent(base, iid, uid, path, ev.Executant.GetOpaqueId()),
 	}
 }
 
@@ -291,7 +347,7 @@ func ItemPurged(ev events.ItemPurged) AuditEventFilePurged {
 	iid, path, uid := extractFileDetails(ev.Ref, ev.Owner)
 	base := BasicAuditEvent(uid, "", MessageFilePurged(ev.Executant.GetOpaqueId(), iid), ActionFilePurged)
 	return AuditEventFilePurged{
-		AuditEventFiles: FilesAuditEvent(base, iid, uid, path),
+		AuditEventFiles: FilesAuditEvent(base, iid, uid, path, ev.Executant.GetOpaqueId()),
 	}
 }
 
@@ -306,7 +362,7 @@ func ItemRestored(ev events.ItemRestored) AuditEventFileRestored {
 
 	base := BasicAuditEvent(uid, "", MessageFileRestored(ev.Executant.GetOpaqueId(), iid, path), ActionFileRestored)
 	return AuditEventFileRestored{
-		AuditEventFiles: FilesAuditEvent(base, iid, uid, path),
+		AuditEventFiles: FilesAuditEvent(base, iid, uid, path, ev.Executant.GetOpaqueId()),
 		OldPath:         oldpath,
 	}
 }
@@ -316,16 +372,17 @@ func FileVersionRestored(ev events.FileVersionRestored) AuditEventFileVersionRes
 	iid, path, uid := extractFileDetails(ev.Ref, ev.Owner)
 	base := BasicAuditEvent(uid, "", MessageFileVersionRestored(ev.Executant.GetOpaqueId(), iid, ev.Key), ActionFileVersionRestored)
 	return AuditEventFileVersionRestored{
-		AuditEventFiles: FilesAuditEvent(base, iid, uid, path),
+		AuditEventFiles: FilesAuditEvent(base, iid, uid, path, ev.Executant.GetOpaqueId()),
 		Key:             ev.Key,
 	}
 }
 
 // SpacesAuditEvent creates an AuditEventSpaces from the given values
-func SpacesAuditEvent(base AuditEvent, spaceID string) AuditEventSpaces {
+func SpacesAuditEvent(base AuditEvent, spaceID, executant string) AuditEventSpaces {
 	return AuditEventSpaces{
 		AuditEvent: base,
 		SpaceID:    spaceID,
+		Executant:  executant,
 	}
 }
 
@@ -335,7 +392,7 @@ func SpaceCreated(ev events.SpaceCreated) AuditEventSpaceCreated {
 	iid, _, owner := extractFileDetails(&provider.Reference{ResourceId: ev.Root}, ev.Owner)
 	base := BasicAuditEvent("", formatTime(ev.MTime), MessageSpaceCreated(ev.Executant.GetOpaqueId(), sid, ev.Name), ActionSpaceCreated)
 	return AuditEventSpaceCreated{
-		AuditEventSpaces: SpacesAuditEvent(base, sid),
+		AuditEventSpaces: SpacesAuditEvent(base, sid, ev.Executant.GetOpaqueId()),
 		Owner:            owner,
 		RootItem:         iid,
 		Name:             ev.Name,
@@ -348,7 +405,7 @@ func SpaceRenamed(ev events.SpaceRenamed) AuditEventSpaceRenamed {
 	sid := ev.ID.GetOpaqueId()
 	base := BasicAuditEvent("", "", MessageSpaceRenamed(ev.Executant.GetOpaqueId(), sid, ev.Name), ActionSpaceRenamed)
 	return AuditEventSpaceRenamed{
-		AuditEventSpaces: SpacesAuditEvent(base, sid),
+		AuditEventSpaces: SpacesAuditEvent(base, sid, ev.Executant.GetOpaqueId()),
 		NewName:          ev.Name,
 	}
 }
@@ -358,7 +415,7 @@ func SpaceDisabled(ev events.SpaceDisabled) AuditEventSpaceDisabled {
 	sid := ev.ID.GetOpaqueId()
 	base := BasicAuditEvent("", "", MessageSpaceDisabled(ev.Executant.GetOpaqueId(), sid), ActionSpaceDisabled)
 	return AuditEventSpaceDisabled{
-		AuditEventSpaces: SpacesAuditEvent(base, sid),
+		AuditEventSpaces: SpacesAuditEvent(base, sid, ev.Executant.GetOpaqueId()),
 	}
 }
 
@@ -367,7 +424,7 @@ func SpaceEnabled(ev events.SpaceEnabled) AuditEventSpaceEnabled {
 	sid := ev.ID.GetOpaqueId()
 	base := BasicAuditEvent("", "", MessageSpaceEnabled(ev.Executant.GetOpaqueId(), sid), ActionSpaceEnabled)
 	return AuditEventSpaceEnabled{
-		AuditEventSpaces: SpacesAuditEvent(base, sid),
+		AuditEventSpaces: SpacesAuditEvent(base, sid, ev.Executant.GetOpaqueId()),
 	}
 }
 
@@ -376,7 +433,7 @@ func SpaceDeleted(ev events.SpaceDeleted) AuditEventSpaceDeleted {
 	sid := ev.ID.GetOpaqueId()
 	base := BasicAuditEvent("", "", MessageSpaceDeleted(ev.Executant.GetOpaqueId(), sid), ActionSpaceDeleted)
 	return AuditEventSpaceDeleted{
-		AuditEventSpaces: SpacesAuditEvent(base, sid),
+		AuditEventSpaces: SpacesAuditEvent(base, sid, ev.Executant.GetOpaqueId()),
 	}
 }
 
@@ -386,6 +443,7 @@ func UserCreated(ev events.UserCreated) AuditEventUserCreated {
 	return AuditEventUserCreated{
 		AuditEvent: base,
 		UserID:     ev.UserID,
+		Executant:  ev.Executant.GetOpaqueId(),
 	}
 }
 
@@ -395,6 +453,7 @@ func UserDeleted(ev events.UserDeleted) AuditEventUserDeleted {
 	return AuditEventUserDeleted{
 		AuditEvent: base,
 		UserID:     ev.UserID,
+		Executant:  ev.Executant.GetOpaqueId(),
 	}
 }
 
@@ -406,6 +465,32 @@ func UserFeatureChanged(ev events.UserFeatureChanged) AuditEventUserFeatureChang
 		AuditEvent: base,
 		UserID:     ev.UserID,
 		Features:   ev.Features,
+		Executant:  ev.Executant.GetOpaqueId(),
+	}
+}
+
+// UserAuthenticated converts a UserAuthenticated event to an AuditEventUserAuthenticated
+func UserAuthenticated(ev ocisevents.UserAuthenticated) AuditEventUserAuthenticated {
+	uid := ev.UserID.GetOpaqueId()
+	base := BasicAuditEvent(uid, formatTime(ev.Timestamp), MessageUserAuthenticated(ev.Login, ev.Method), ActionUserAuthenticated)
+	return AuditEventUserAuthenticated{
+		AuditEvent: base,
+		UserID:     uid,
+		Login:      ev.Login,
+		AuthMethod: ev.Method,
+		Success:    true,
+	}
+}
+
+// UserAuthenticationFailed converts a UserAuthenticationFailed event to an AuditEventUserAuthenticated
+func UserAuthenticationFailed(ev ocisevents.UserAuthenticationFailed) AuditEventUserAuthenticated {
+	base := BasicAuditEvent("", formatTime(ev.Timestamp), MessageUserAuthenticationFailed(ev.Login, ev.Method, ev.Reason), ActionUserAuthenticated)
+	return AuditEventUserAuthenticated{
+		AuditEvent: base,
+		Login:      ev.Login,
+		AuthMethod: ev.Method,
+		Success:    false,
+		Reason:     ev.Reason,
 	}
 }
 
@@ -415,6 +500,7 @@ func GroupCreated(ev events.GroupCreated) AuditEventGroupCreated {
 	return AuditEventGroupCreated{
 		AuditEvent: base,
 		GroupID:    ev.GroupID,
+		Executant:  ev.Executant.GetOpaqueId(),
 	}
 }
 
@@ -424,6 +510,7 @@ func GroupDeleted(ev events.GroupDeleted) AuditEventGroupDeleted {
 	return AuditEventGroupDeleted{
 		AuditEvent: base,
 		GroupID:    ev.GroupID,
+		Executant:  ev.Executant.GetOpaqueId(),
 	}
 }
 
@@ -435,6 +522,7 @@ func GroupMemberAdded(ev events.GroupMemberAdded) AuditEventGroupMemberAdded {
 		AuditEvent: base,
 		GroupID:    ev.GroupID,
 		UserID:     ev.UserID,
+		Executant:  ev.Executant.GetOpaqueId(),
 	}
 }
 
@@ -446,6 +534,7 @@ func GroupMemberRemoved(ev events.GroupMemberRemoved) AuditEventGroupMemberRemov
 		AuditEvent: base,
 		GroupID:    ev.GroupID,
 		UserID:     ev.UserID,
+		Executant:  ev.Executant.GetOpaqueId(),
 	}
 }
 
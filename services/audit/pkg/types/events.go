@@ -2,6 +2,7 @@ package types
 
 import (
 	"github.com/cs3org/reva/v2/pkg/events"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 )
 
 // RegisteredEvents returns the events the service is registered for
@@ -33,6 +34,8 @@ func RegisteredEvents() []events.Unmarshaller {
 		events.UserCreated{},
 		events.UserDeleted{},
 		events.UserFeatureChanged{},
+		ocisevents.UserAuthenticated{},
+		ocisevents.UserAuthenticationFailed{},
 		events.GroupCreated{},
 		events.GroupDeleted{},
 		events.GroupMemberAdded{},
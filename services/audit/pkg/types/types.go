@@ -2,13 +2,15 @@ package types
 
 import "github.com/cs3org/reva/v2/pkg/events"
 
-// AuditEvent is the basic audit event
+// AuditEvent is the basic audit event. The fields describing the request are only set for the events
+// published by the oCIS services, the events published by reva don't carry the request metadata.
 type AuditEvent struct {
 	RemoteAddr string // the remote client IP
 	User       string // the UID of the user performing the action. Or "IP x.x.x.x.", "cron", "CLI", "unknown"
 	URL        string // the process request URI
 	Method     string // the HTTP request method
 	UserAgent  string // the HTTP request user agent
+	RequestID  string // the id of the HTTP request which caused the action
 	Time       string // the time of the event eg: 2018-05-08T08:26:00+00:00
 	App        string // always 'admin_audit'
 	Message    string // sentence explaining the action
@@ -91,9 +93,10 @@ type AuditEventLinkAccessed struct {
 type AuditEventFiles struct {
 	AuditEvent
 
-	Path   string // The full path to the create file.
-	Owner  string // The UID of the owner of the file.
-	FileID string // The newly created files identifier.
+	Path      string // The full path to the create file.
+	Owner     string // The UID of the owner of the file.
+	FileID    string // The newly created files identifier.
+	Executant string // The UID of the user who performed the action.
 }
 
 // AuditEventContainerCreated is the event logged when a container is created
@@ -170,7 +173,8 @@ type AuditEventFileVersionDeleted struct {
 type AuditEventSpaces struct {
 	AuditEvent
 
-	SpaceID string
+	SpaceID   string
+	Executant string // The UID of the user who performed the action.
 }
 
 // AuditEventSpaceCreated is the event logged when a space is created
@@ -208,44 +212,61 @@ type AuditEventSpaceDeleted struct {
 // AuditEventUserCreated is the event logged when a user is created
 type AuditEventUserCreated struct {
 	AuditEvent
-	UserID string
+	UserID    string
+	Executant string // The UID of the user who performed the action.
 }
 
 // AuditEventUserDeleted is the event logged when a user is deleted
 type AuditEventUserDeleted struct {
 	AuditEvent
-	UserID string
+	UserID    string
+	Executant string // The UID of the user who performed the action.
 }
 
 // AuditEventUserFeatureChanged is the event logged when a user feature is changed
 type AuditEventUserFeatureChanged struct {
 	AuditEvent
-	UserID   string
-	Features []events.UserFeature
+	UserID    string
+	Features  []events.UserFeature
+	Executant string // The UID of the user who performed the action.
+}
+
+// AuditEventUserAuthenticated is the event logged when a user authenticates or fails to authenticate
+type AuditEventUserAuthenticated struct {
+	AuditEvent
+	UserID     string // The UID of the authenticated user, empty for failures and public links.
+	Login      string // What the client identified itself with, e.g. the username or a hash of the public link token.
+	AuthMethod string // basic, oidc, signed_url or public_share
+	Success    bool   // If the authentication was successful.
+	Reason     string // Why the authentication failed.
 }
 
 // AuditEventGroupCreated is the event logged when a group is created
 type AuditEventGroupCreated struct {
 	AuditEvent
-	GroupID string
+	GroupID   string
+	Executant string // The UID of the user who performed the action.
 }
 
 // AuditEventGroupDeleted is the event logged when a group is deleted
 type AuditEventGroupDeleted struct {
 	AuditEvent
-	GroupID string
+	GroupID   string
+	Executant string // The UID of the user who performed the action.
 }
 
 // AuditEventGroupMemberAdded is the event logged when a group member is added
 type AuditEventGroupMemberAdded struct {
 	AuditEvent
-	GroupID string
-	UserID  string
+	GroupID   string
+	UserID    string
+	Executant string // The UID of the user who performed the action.
 }
 
 // AuditEventGroupMemberRemoved is the event logged when a group member is removed
 type AuditEventGroupMemberRemoved struct {
 	AuditEvent
-	GroupID string
-	UserID  string
+	GroupID   string
+	UserID    string
+	Executant string // The UID of the user who performed the action.
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/owncloud/ocis/v2/ocis-pkg/account"
 	ociscrypto "github.com/owncloud/ocis/v2/ocis-pkg/crypto"
 	"github.com/owncloud/ocis/v2/ocis-pkg/middleware"
+	"github.com/owncloud/ocis/v2/ocis-pkg/requestmeta"
 	"github.com/owncloud/ocis/v2/ocis-pkg/service/http"
 	"github.com/owncloud/ocis/v2/ocis-pkg/version"
 	graphMiddleware "github.com/owncloud/ocis/v2/services/graph/pkg/middleware"
@@ -88,6 +89,7 @@ func Server(opts ...Option) (http.Service, error) {
 		svc.Middleware(
 			middleware.TraceContext,
 			chimiddleware.RequestID,
+			requestmeta.Middleware,
 			middleware.Version(
 				"graph",
 				version.GetString(),
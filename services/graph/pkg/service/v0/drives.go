@@ -26,11 +26,13 @@ import (
 	libregraph "github.com/owncloud/libre-graph-api-go"
 	"github.com/owncloud/ocis/v2/ocis-pkg/service/grpc"
 	v0 "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/settings/v0"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
 	settingssvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/settings/v0"
 	"github.com/owncloud/ocis/v2/services/graph/pkg/service/v0/errorcode"
 	settingsServiceExt "github.com/owncloud/ocis/v2/services/settings/pkg/service/v0"
 	"github.com/pkg/errors"
 	merrors "go-micro.dev/v4/errors"
+	gmmetadata "go-micro.dev/v4/metadata"
 )
 
 var (
@@ -104,6 +106,20 @@ func (g Graph) getDrives(w http.ResponseWriter, r *http.Request, unrestricted bo
 		return
 	}
 
+	storageSpaces := res.StorageSpaces
+	if odataReq.Query.Search != nil {
+		storageSpaces, err = g.searchSpaces(ctx, strings.Trim(odataReq.Query.Search.RawValue, `"`), storageSpaces)
+		if err != nil {
+			logger.Error().Err(err).Msg("could not get drives: error searching the spaces")
+			if e := merrors.FromError(err); e.Code == http.StatusBadRequest {
+				errorcode.InvalidRequest.Render(w, r, http.StatusBadRequest, e.Detail)
+				return
+			}
+			errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	webDavBaseURL, err := g.getWebDavBaseURL()
 	if err != nil {
 		logger.Error().Err(err).Str("url", webDavBaseURL.String()).Msg("could not get drives: error parsing url")
@@ -111,7 +127,7 @@ func (g Graph) getDrives(w http.ResponseWriter, r *http.Request, unrestricted bo
 		return
 	}
 
-	spaces, err := g.formatDrives(ctx, webDavBaseURL, res.StorageSpaces)
+	spaces, err := g.formatDrives(ctx, webDavBaseURL, storageSpaces)
 	if err != nil {
 		logger.Debug().Err(err).Msg("could not get drives: error parsing grpc response")
 		errorcode.GeneralException.Render(w, r, http.StatusInternalServerError, err.Error())
@@ -129,6 +145,52 @@ func (g Graph) getDrives(w http.ResponseWriter, r *http.Request, unrestricted bo
 	render.JSON(w, r, &listResponse{Value: spaces})
 }
 
+// searchSpaces returns the given spaces which match the query by their name, description or readme.
+// The spaces are returned in the order of their relevance.
+func (g Graph) searchSpaces(ctx context.Context, query string, spaces []*storageprovider.StorageSpace) ([]*storageprovider.StorageSpace, error) {
+	if len(spaces) == 0 {
+		return spaces, nil
+	}
+	// the search service authenticates the request with the token of the user
+	if t, ok := ctxpkg.ContextGetToken(ctx); ok {
+		ctx = gmmetadata.Set(ctx, ctxpkg.TokenHeader, t)
+	}
+	// the matches identify the spaces by the storage and space id of their root
+	byID := make(map[string]*storageprovider.StorageSpace, len(spaces))
+	for _, space := range spaces {
+		byID[storagespace.FormatStorageID(space.GetRoot().GetStorageId(), space.GetRoot().GetSpaceId())] = space
+	}
+
+	// the search also matches the spaces which haven't been listed, e.g. because of a filter, so the
+	// pages are requested until all listed spaces have been found or there are no more matches
+	found := make([]*storageprovider.StorageSpace, 0, len(spaces))
+	pageToken := ""
+	for {
+		res, err := g.searchService.Search(ctx, &searchsvc.SearchRequest{
+			Query:     query,
+			Scope:     "spaces",
+			PageSize:  int32(len(spaces)),
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range res.Matches {
+			if match.GetSpace() == nil {
+				continue
+			}
+			if space, ok := byID[match.Space.Id]; ok {
+				found = append(found, space)
+				delete(byID, match.Space.Id)
+			}
+		}
+		if len(byID) == 0 || res.NextPageToken == "" || len(res.Matches) == 0 {
+			return found, nil
+		}
+		pageToken = res.NextPageToken
+	}
+}
+
 // GetSingleDrive does a lookup of a single space by spaceId
 func (g Graph) GetSingleDrive(w http.ResponseWriter, r *http.Request) {
 	logger := g.logger.SubloggerWithRequestID(r.Context())
@@ -11,7 +11,9 @@ import (
 	"github.com/cs3org/reva/v2/pkg/events"
 	"github.com/go-chi/chi/v5"
 	"github.com/jellydator/ttlcache/v2"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
 	settingssvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/settings/v0"
 	"github.com/owncloud/ocis/v2/services/graph/pkg/config"
 	"github.com/owncloud/ocis/v2/services/graph/pkg/identity"
@@ -69,6 +71,11 @@ type Permissions interface {
 	ListPermissionsByResource(ctx context.Context, in *settingssvc.ListPermissionsByResourceRequest, opts ...client.CallOption) (*settingssvc.ListPermissionsByResourceResponse, error)
 }
 
+// SearchProvider is the interface used to access the search service
+type SearchProvider interface {
+	Search(ctx context.Context, in *searchsvc.SearchRequest, opts ...client.CallOption) (*searchsvc.SearchResponse, error)
+}
+
 // HTTPClient is the subset of the http.Client that is being used to interact with the download gateway
 type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
@@ -86,6 +93,7 @@ type Graph struct {
 	gatewayClient        GatewayClient
 	roleService          settingssvc.RoleService
 	permissionsService   Permissions
+	searchService        SearchProvider
 	spacePropertiesCache *ttlcache.Cache
 	eventsPublisher      events.Publisher
 }
@@ -100,9 +108,9 @@ func (g Graph) GetGatewayClient() GatewayClient {
 	return g.gatewayClient
 }
 
-func (g Graph) publishEvent(ev interface{}) {
+func (g Graph) publishEvent(ctx context.Context, ev interface{}) {
 	if g.eventsPublisher != nil {
-		if err := events.Publish(g.eventsPublisher, ev); err != nil {
+		if err := ocisevents.Publish(ctx, g.eventsPublisher, ev); err != nil {
 			g.logger.Error().
 				Err(err).
 				Msg("could not publish user created event")
@@ -21,7 +21,9 @@ import (
 	libregraph "github.com/owncloud/libre-graph-api-go"
 	ogrpc "github.com/owncloud/ocis/v2/ocis-pkg/service/grpc"
 	"github.com/owncloud/ocis/v2/ocis-pkg/shared"
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
 	v0 "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/settings/v0"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
 	settingssvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/settings/v0"
 	"github.com/owncloud/ocis/v2/services/graph/mocks"
 	"github.com/owncloud/ocis/v2/services/graph/pkg/config"
@@ -38,6 +40,7 @@ var _ = Describe("Graph", func() {
 		gatewayClient     *mocks.GatewayClient
 		eventsPublisher   mocks.Publisher
 		permissionService mocks.Permissions
+		searchProvider    mocks.SearchProvider
 		ctx               context.Context
 		cfg               *config.Config
 	)
@@ -54,11 +57,13 @@ var _ = Describe("Graph", func() {
 		gatewayClient = &mocks.GatewayClient{}
 		eventsPublisher = mocks.Publisher{}
 		permissionService = mocks.Permissions{}
+		searchProvider = mocks.SearchProvider{}
 		svc = service.NewService(
 			service.Config(cfg),
 			service.WithGatewayClient(gatewayClient),
 			service.EventsPublisher(&eventsPublisher),
 			service.PermissionService(&permissionService),
+			service.SearchService(&searchProvider),
 		)
 	})
 
@@ -222,6 +227,106 @@ var _ = Describe("Graph", func() {
 			}
 			`))
 		})
+		It("can search spaces", func() {
+			gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
+				Status: status.NewOK(ctx),
+				StorageSpaces: []*provider.StorageSpace{
+					{
+						Id:        &provider.StorageSpaceId{OpaqueId: "pro-1$asameID!asameID"},
+						SpaceType: "project",
+						Root:      &provider.ResourceId{StorageId: "pro-1", SpaceId: "asameID", OpaqueId: "asameID"},
+						Name:      "aspacename",
+					},
+					{
+						Id:        &provider.StorageSpaceId{OpaqueId: "pro-1$bsameID!bsameID"},
+						SpaceType: "project",
+						Root:      &provider.ResourceId{StorageId: "pro-1", SpaceId: "bsameID", OpaqueId: "bsameID"},
+						Name:      "bspacename",
+					},
+					{
+						Id:        &provider.StorageSpaceId{OpaqueId: "pro-1$csameID!csameID"},
+						SpaceType: "project",
+						Root:      &provider.ResourceId{StorageId: "pro-1", SpaceId: "csameID", OpaqueId: "csameID"},
+						Name:      "cspacename",
+					},
+				},
+			}, nil)
+			gatewayClient.On("InitiateFileDownload", mock.Anything, mock.Anything).Return(&gateway.InitiateFileDownloadResponse{
+				Status: status.NewNotFound(ctx, "not found"),
+			}, nil)
+			gatewayClient.On("GetQuota", mock.Anything, mock.Anything).Return(&provider.GetQuotaResponse{
+				Status: status.NewUnimplemented(ctx, fmt.Errorf("not supported"), "not supported"),
+			}, nil)
+			searchProvider.On("Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchRequest) bool {
+				return req.Query == "budget" && req.Scope == "spaces"
+			})).Return(&searchsvc.SearchResponse{
+				Matches: []*searchmsg.Match{
+					{Entity: &searchmsg.Entity{Name: "cspacename"}, Space: &searchmsg.Space{Id: "pro-1$csameID"}},
+					{Entity: &searchmsg.Entity{Name: "aspacename"}, Space: &searchmsg.Space{Id: "pro-1$asameID"}},
+					{Entity: &searchmsg.Entity{Name: "unknown"}, Space: &searchmsg.Space{Id: "pro-1$unknownID"}},
+				},
+			}, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/me/drives?$search=%22budget%22", nil)
+			rr := httptest.NewRecorder()
+			svc.GetDrives(rr, r)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			body, _ := io.ReadAll(rr.Body)
+			var response map[string][]libregraph.Drive
+			err := json.Unmarshal(body, &response)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response["value"]).To(HaveLen(2))
+			Expect(*response["value"][0].Name).To(Equal("cspacename"))
+			Expect(*response["value"][1].Name).To(Equal("aspacename"))
+		})
+		It("finds the spaces on the following pages of the search results", func() {
+			gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
+				Status: status.NewOK(ctx),
+				StorageSpaces: []*provider.StorageSpace{
+					{
+						Id:        &provider.StorageSpaceId{OpaqueId: "pro-1$asameID!asameID"},
+						SpaceType: "project",
+						Root:      &provider.ResourceId{StorageId: "pro-1", SpaceId: "asameID", OpaqueId: "asameID"},
+						Name:      "aspacename",
+					},
+				},
+			}, nil)
+			gatewayClient.On("InitiateFileDownload", mock.Anything, mock.Anything).Return(&gateway.InitiateFileDownloadResponse{
+				Status: status.NewNotFound(ctx, "not found"),
+			}, nil)
+			gatewayClient.On("GetQuota", mock.Anything, mock.Anything).Return(&provider.GetQuotaResponse{
+				Status: status.NewUnimplemented(ctx, fmt.Errorf("not supported"), "not supported"),
+			}, nil)
+			// spaces which haven't been listed rank higher and fill the first page
+			searchProvider.On("Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchRequest) bool {
+				return req.PageToken == ""
+			})).Return(&searchsvc.SearchResponse{
+				Matches: []*searchmsg.Match{
+					{Entity: &searchmsg.Entity{Name: "unknown"}, Space: &searchmsg.Space{Id: "pro-1$unknownID"}},
+				},
+				NextPageToken: "page-2",
+			}, nil)
+			searchProvider.On("Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchRequest) bool {
+				return req.PageToken == "page-2"
+			})).Return(&searchsvc.SearchResponse{
+				Matches: []*searchmsg.Match{
+					{Entity: &searchmsg.Entity{Name: "aspacename"}, Space: &searchmsg.Space{Id: "pro-1$asameID"}},
+				},
+			}, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/graph/v1.0/me/drives?$search=%22budget%22", nil)
+			rr := httptest.NewRecorder()
+			svc.GetDrives(rr, r)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			body, _ := io.ReadAll(rr.Body)
+			var response map[string][]libregraph.Drive
+			err := json.Unmarshal(body, &response)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response["value"]).To(HaveLen(1))
+			Expect(*response["value"][0].Name).To(Equal("aspacename"))
+		})
 		It("can list a spaces type mountpoint", func() {
 			gatewayClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&provider.ListStorageSpacesResponse{
 				Status: status.NewOK(ctx),
@@ -89,7 +89,7 @@ func (g Graph) PostGroup(w http.ResponseWriter, r *http.Request) {
 
 	if grp != nil && grp.Id != nil {
 		currentUser := ctxpkg.ContextMustGetUser(r.Context())
-		g.publishEvent(events.GroupCreated{Executant: currentUser.Id, GroupID: *grp.Id})
+		g.publishEvent(r.Context(), events.GroupCreated{Executant: currentUser.Id, GroupID: *grp.Id})
 	}
 	render.Status(r, http.StatusOK)
 	render.JSON(w, r, grp)
@@ -239,7 +239,7 @@ func (g Graph) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	currentUser := ctxpkg.ContextMustGetUser(r.Context())
-	g.publishEvent(events.GroupDeleted{Executant: currentUser.Id, GroupID: groupID})
+	g.publishEvent(r.Context(), events.GroupDeleted{Executant: currentUser.Id, GroupID: groupID})
 	render.Status(r, http.StatusNoContent)
 	render.NoContent(w, r)
 }
@@ -344,7 +344,7 @@ func (g Graph) PostGroupMember(w http.ResponseWriter, r *http.Request) {
 	}
 
 	currentUser := ctxpkg.ContextMustGetUser(r.Context())
-	g.publishEvent(events.GroupMemberAdded{Executant: currentUser.Id, GroupID: groupID, UserID: id})
+	g.publishEvent(r.Context(), events.GroupMemberAdded{Executant: currentUser.Id, GroupID: groupID, UserID: id})
 	render.Status(r, http.StatusNoContent)
 	render.NoContent(w, r)
 }
@@ -395,7 +395,7 @@ func (g Graph) DeleteGroupMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	currentUser := ctxpkg.ContextMustGetUser(r.Context())
-	g.publishEvent(events.GroupMemberRemoved{Executant: currentUser.Id, GroupID: groupID, UserID: memberID})
+	g.publishEvent(r.Context(), events.GroupMemberRemoved{Executant: currentUser.Id, GroupID: groupID, UserID: memberID})
 	render.Status(r, http.StatusNoContent)
 	render.NoContent(w, r)
 }
@@ -25,6 +25,7 @@ type Options struct {
 	PermissionService Permissions
 	RoleManager       *roles.Manager
 	EventsPublisher   events.Publisher
+	SearchService     SearchProvider
 }
 
 // newOptions initializes the available default options.
@@ -100,3 +101,10 @@ func EventsPublisher(val events.Publisher) Option {
 		o.EventsPublisher = val
 	}
 }
+
+// SearchService provides a function to set the SearchService option.
+func SearchService(val SearchProvider) Option {
+	return func(o *Options) {
+		o.SearchService = val
+	}
+}
@@ -92,7 +92,7 @@ func (g Graph) ChangeOwnPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	currentUser := ctxpkg.ContextMustGetUser(r.Context())
-	g.publishEvent(
+	g.publishEvent(r.Context(),
 		events.UserFeatureChanged{
 			Executant: currentUser.Id,
 			UserID:    u.Id.OpaqueId,
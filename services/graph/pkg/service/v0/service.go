@@ -16,6 +16,7 @@ import (
 	"github.com/owncloud/ocis/v2/ocis-pkg/roles"
 	"github.com/owncloud/ocis/v2/ocis-pkg/service/grpc"
 	"github.com/owncloud/ocis/v2/ocis-pkg/store"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
 	settingssvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/settings/v0"
 	"github.com/owncloud/ocis/v2/services/graph/pkg/identity"
 	"github.com/owncloud/ocis/v2/services/graph/pkg/identity/ldap"
@@ -153,6 +154,12 @@ func NewService(opts ...Option) Service {
 		svc.permissionsService = options.PermissionService
 	}
 
+	if options.SearchService == nil {
+		svc.searchService = searchsvc.NewSearchProviderService("com.owncloud.api.search", grpc.DefaultClient())
+	} else {
+		svc.searchService = options.SearchService
+	}
+
 	roleManager := options.RoleManager
 	if roleManager == nil {
 		storeOptions := store.OcisStoreOptions{
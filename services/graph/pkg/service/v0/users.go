@@ -178,7 +178,7 @@ func (g Graph) PostUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	currentUser := ctxpkg.ContextMustGetUser(r.Context())
-	g.publishEvent(events.UserCreated{Executant: currentUser.Id, UserID: *u.Id})
+	g.publishEvent(r.Context(), events.UserCreated{Executant: currentUser.Id, UserID: *u.Id})
 
 	render.Status(r, http.StatusOK)
 	render.JSON(w, r, u)
@@ -382,7 +382,7 @@ func (g Graph) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	g.publishEvent(events.UserDeleted{Executant: currentUser.Id, UserID: user.GetId()})
+	g.publishEvent(r.Context(), events.UserDeleted{Executant: currentUser.Id, UserID: user.GetId()})
 
 	render.Status(r, http.StatusNoContent)
 	render.NoContent(w, r)
@@ -444,7 +444,7 @@ func (g Graph) PatchUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	currentUser := ctxpkg.ContextMustGetUser(r.Context())
-	g.publishEvent(
+	g.publishEvent(r.Context(),
 		events.UserFeatureChanged{
 			Executant: currentUser.Id,
 			UserID:    nameOrID,
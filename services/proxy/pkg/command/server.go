@@ -3,17 +3,23 @@ package command
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/cs3org/reva/v2/pkg/events"
+	"github.com/cs3org/reva/v2/pkg/events/server"
 	"github.com/cs3org/reva/v2/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/v2/pkg/token/manager/jwt"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/go-micro/plugins/v4/events/natsjs"
 	"github.com/justinas/alice"
 	"github.com/oklog/run"
 	"github.com/owncloud/ocis/v2/ocis-pkg/config/configlog"
+	ociscrypto "github.com/owncloud/ocis/v2/ocis-pkg/crypto"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
 	pkgmiddleware "github.com/owncloud/ocis/v2/ocis-pkg/middleware"
 	"github.com/owncloud/ocis/v2/ocis-pkg/service/grpc"
@@ -72,6 +78,8 @@ func Server(cfg *config.Config) *cli.Command {
 
 			m.BuildInfo.WithLabelValues(version.GetString()).Set(1)
 
+			publisher := authEventsPublisher(cfg, logger)
+
 			rp, err := proxy.NewMultiHostReverseProxy(
 				proxy.Logger(logger),
 				proxy.Config(cfg),
@@ -87,7 +95,7 @@ func Server(cfg *config.Config) *cli.Command {
 					proxyHTTP.Context(ctx),
 					proxyHTTP.Config(cfg),
 					proxyHTTP.Metrics(metrics.New()),
-					proxyHTTP.Middlewares(loadMiddlewares(ctx, logger, cfg)),
+					proxyHTTP.Middlewares(loadMiddlewares(ctx, logger, cfg, publisher)),
 				)
 
 				if err != nil {
@@ -134,7 +142,51 @@ func Server(cfg *config.Config) *cli.Command {
 	}
 }
 
-func loadMiddlewares(ctx context.Context, logger log.Logger, cfg *config.Config) alice.Chain {
+// authEventsPublisher returns the publisher of the authentication events. It connects to the event bus
+// when the first event is published, so that the proxy doesn't depend on it. It returns nil if no events
+// endpoint is configured.
+func authEventsPublisher(cfg *config.Config, logger log.Logger) *middleware.AuthEventsPublisher {
+	if cfg.Events.Endpoint == "" {
+		return nil
+	}
+	return middleware.NewAuthEventsPublisher(logger, func() (events.Publisher, error) {
+		return eventsStream(cfg)
+	})
+}
+
+// eventsStream connects to the event bus.
+func eventsStream(cfg *config.Config) (events.Stream, error) {
+	var tlsConf *tls.Config
+	if cfg.Events.EnableTLS {
+		var rootCAPool *x509.CertPool
+		if cfg.Events.TLSRootCACertificate != "" {
+			rootCrtFile, err := os.Open(cfg.Events.TLSRootCACertificate)
+			if err != nil {
+				return nil, err
+			}
+			defer rootCrtFile.Close()
+
+			rootCAPool, err = ociscrypto.NewCertPoolFromPEM(rootCrtFile)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Events.TLSInsecure = false
+		}
+
+		tlsConf = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.Events.TLSInsecure, //nolint:gosec
+			RootCAs:            rootCAPool,
+		}
+	}
+	return server.NewNatsStream(
+		natsjs.TLSConfig(tlsConf),
+		natsjs.Address(cfg.Events.Endpoint),
+		natsjs.ClusterID(cfg.Events.Cluster),
+	)
+}
+
+func loadMiddlewares(ctx context.Context, logger log.Logger, cfg *config.Config, publisher *middleware.AuthEventsPublisher) alice.Chain {
 	rolesClient := settingssvc.NewRoleService("com.owncloud.api.settings", grpc.DefaultClient())
 	revaClient, err := pool.GetGatewayServiceClient(cfg.Reva.Address, cfg.Reva.GetRevaOptions()...)
 	var userProvider backend.UserBackend
@@ -175,11 +227,12 @@ func loadMiddlewares(ctx context.Context, logger log.Logger, cfg *config.Config)
 	if cfg.EnableBasicAuth {
 		logger.Warn().Msg("basic auth enabled, use only for testing or development")
 		authenticators = append(authenticators, middleware.BasicAuthenticator{
-			Logger:       logger,
-			UserProvider: userProvider,
+			Logger:          logger,
+			UserProvider:    userProvider,
+			EventsPublisher: publisher,
 		})
 	}
-	authenticators = append(authenticators, middleware.NewOIDCAuthenticator(
+	oidcAuthenticator := middleware.NewOIDCAuthenticator(
 		logger,
 		cfg.OIDC.UserinfoCache.TTL,
 		oidcHTTPClient,
@@ -195,10 +248,13 @@ func loadMiddlewares(ctx context.Context, logger log.Logger, cfg *config.Config)
 		},
 		cfg.OIDC.JWKS,
 		cfg.OIDC.AccessTokenVerifyMethod,
-	))
+	)
+	oidcAuthenticator.EventsPublisher = publisher
+	authenticators = append(authenticators, oidcAuthenticator)
 	authenticators = append(authenticators, middleware.PublicShareAuthenticator{
 		Logger:            logger,
 		RevaGatewayClient: revaClient,
+		EventsPublisher:   publisher,
 	})
 
 	authenticators = append(authenticators, middleware.SignedURLAuthenticator{
@@ -206,13 +262,14 @@ func loadMiddlewares(ctx context.Context, logger log.Logger, cfg *config.Config)
 		PreSignedURLConfig: cfg.PreSignedURL,
 		UserProvider:       userProvider,
 		Store:              storeClient,
+		EventsPublisher:    publisher,
 	})
 
 	return alice.New(
 		// first make sure we log all requests and redirect to https if necessary
 		pkgmiddleware.TraceContext,
-		chimiddleware.RealIP,
 		chimiddleware.RequestID,
+		middleware.RequestMetadata(logger, cfg.TrustedProxies),
 		middleware.AccessLog(logger),
 		middleware.HTTPSRedirect,
 		middleware.OIDCWellKnownRewrite(
@@ -35,10 +35,21 @@ type Config struct {
 	InsecureBackends      bool            `yaml:"insecure_backends" env:"PROXY_INSECURE_BACKENDS" desc:"Disable TLS certificate validation for all HTTP backend connections."`
 	BackendHTTPSCACert    string          `yaml:"backend_https_cacert" env:"PROXY_HTTPS_CACERT" desc:"The root CA certificate used to validate TLS server certificates of https enabled backend services."`
 	AuthMiddleware        AuthMiddleware  `yaml:"auth_middleware"`
+	TrustedProxies        []string        `yaml:"trusted_proxies" env:"PROXY_TRUSTED_PROXIES" desc:"A comma-separated list of IP addresses or CIDR ranges of reverse proxies in front of the proxy service. The client IP is only taken from the X-Forwarded-For header of requests coming from one of them. If the reverse proxy in front of the proxy service isn't listed, the address of the reverse proxy is logged and passed on as the client IP."`
+	Events                Events          `yaml:"events"`
 
 	Context context.Context `yaml:"-" json:"-"`
 }
 
+// Events combines the configuration options for the event bus.
+type Events struct {
+	Endpoint             string `yaml:"endpoint" env:"PROXY_EVENTS_ENDPOINT" desc:"The address of the event system. The event system is the message queuing service. It is used as message broker for the microservice architecture. The proxy publishes successful and failed authentications to it, connecting when the first one is published and retrying if the event system is unavailable. Set to a empty string to disable emitting events."`
+	Cluster              string `yaml:"cluster" env:"PROXY_EVENTS_CLUSTER" desc:"The clusterID of the event system. The event system is the message queuing service. It is used as message broker for the microservice architecture."`
+	TLSInsecure          bool   `yaml:"tls_insecure" env:"OCIS_INSECURE;PROXY_EVENTS_TLS_INSECURE" desc:"Whether to verify the server TLS certificates."`
+	TLSRootCACertificate string `yaml:"tls_root_ca_certificate" env:"PROXY_EVENTS_TLS_ROOT_CA_CERTIFICATE" desc:"The root CA certificate used to validate the server's TLS certificate. If provided PROXY_EVENTS_TLS_INSECURE will be seen as false."`
+	EnableTLS            bool   `yaml:"enable_tls" env:"OCIS_EVENTS_ENABLE_TLS;PROXY_EVENTS_ENABLE_TLS" desc:"Enable TLS for the connection to the events broker. The events broker is the ocis service which receives and delivers events between the services."`
+}
+
 // Policy enables us to use multiple directors.
 type Policy struct {
 	Name   string  `yaml:"name"`
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/cs3org/reva/v2/pkg/events"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/owncloud/ocis/v2/ocis-pkg/requestmeta"
+	osync "github.com/owncloud/ocis/v2/ocis-pkg/sync"
+)
+
+const (
+	// authEventsQueueSize is the number of events waiting to be published, further events are dropped
+	authEventsQueueSize = 1024
+	// authEventsDedupeCapacity is the number of credentials remembered to publish their use only once
+	authEventsDedupeCapacity = 4096
+	// authEventsDedupeTTL is the time an authentication with the same credentials isn't published again
+	authEventsDedupeTTL = 10 * time.Minute
+	// authEventsRetryInterval is the time to wait before connecting to the event system again
+	authEventsRetryInterval = 30 * time.Second
+)
+
+// AuthEventsPublisher publishes the authentication events of the authenticators. The events are published
+// asynchronously, so that neither a slow nor an unavailable event system delays the requests. The connection
+// to the event system is established with the first event and retried if it fails, events are dropped as
+// long as there is no connection.
+type AuthEventsPublisher struct {
+	logger  log.Logger
+	connect func() (events.Publisher, error)
+	queue   chan authEvent
+	seen    osync.Cache
+}
+
+type authEvent struct {
+	ctx   context.Context
+	event interface{}
+}
+
+// NewAuthEventsPublisher returns an AuthEventsPublisher which publishes to the publisher returned by connect.
+func NewAuthEventsPublisher(logger log.Logger, connect func() (events.Publisher, error)) *AuthEventsPublisher {
+	p := &AuthEventsPublisher{
+		logger:  logger,
+		connect: connect,
+		queue:   make(chan authEvent, authEventsQueueSize),
+		seen:    osync.NewCache(authEventsDedupeCapacity),
+	}
+	go p.run()
+	return p
+}
+
+// Publish queues the event to be published with the metadata of the request. Events of the same type with
+// the same credentials are published only once within authEventsDedupeTTL, the credentials are identified
+// by the key. Publishing on a nil publisher is a no-op.
+func (p *AuthEventsPublisher) Publish(r *http.Request, key string, ev interface{}) {
+	if p == nil {
+		return
+	}
+
+	key = reflect.TypeOf(ev).String() + ":" + hashCredential(key)
+	if p.seen.Load(key) != nil {
+		return
+	}
+	p.seen.Store(key, true, time.Now().Add(authEventsDedupeTTL))
+
+	// the request context is cancelled after the request has been served, only its metadata is kept
+	ctx := requestmeta.NewContext(context.Background(), requestmeta.FromContext(r.Context()))
+	select {
+	case p.queue <- authEvent{ctx: ctx, event: ev}:
+	default:
+		p.logger.Warn().
+			Str("path", r.URL.Path).
+			Msg("authentication events queue is full, dropping event")
+	}
+}
+
+func (p *AuthEventsPublisher) run() {
+	var (
+		publisher events.Publisher
+		retryAt   time.Time
+	)
+	for ev := range p.queue {
+		if publisher == nil {
+			if time.Now().Before(retryAt) {
+				continue
+			}
+			var err error
+			if publisher, err = p.connect(); err != nil {
+				p.logger.Error().
+					Err(err).
+					Dur("retry_in", authEventsRetryInterval).
+					Msg("could not connect to the event system, dropping authentication events")
+				publisher = nil
+				retryAt = time.Now().Add(authEventsRetryInterval)
+				continue
+			}
+		}
+		if err := ocisevents.Publish(ev.ctx, publisher, ev.event); err != nil {
+			p.logger.Error().
+				Err(err).
+				Msg("could not publish authentication event")
+		}
+	}
+}
+
+// hashCredential returns a truncated hash of the credential, which identifies it without revealing it
+func hashCredential(credential string) string {
+	h := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(h[:8])
+}
@@ -3,6 +3,8 @@ package middleware
 import (
 	"net/http"
 
+	"github.com/cs3org/reva/v2/pkg/utils"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
 	"github.com/owncloud/ocis/v2/ocis-pkg/oidc"
 	"github.com/owncloud/ocis/v2/services/proxy/pkg/user/backend"
@@ -14,6 +16,8 @@ type BasicAuthenticator struct {
 	UserProvider  backend.UserBackend
 	UserCS3Claim  string
 	UserOIDCClaim string
+	// EventsPublisher is optional, it receives the successful and failed authentications
+	EventsPublisher *AuthEventsPublisher
 }
 
 // Authenticate implements the authenticator interface to authenticate requests via basic auth.
@@ -37,6 +41,12 @@ func (m BasicAuthenticator) Authenticate(r *http.Request) (*http.Request, bool)
 			Str("authenticator", "basic").
 			Str("path", r.URL.Path).
 			Msg("failed to authenticate request")
+		m.EventsPublisher.Publish(r, login+":"+password, ocisevents.UserAuthenticationFailed{
+			Login:     login,
+			Method:    "basic",
+			Reason:    err.Error(),
+			Timestamp: utils.TSNow(),
+		})
 		return nil, false
 	}
 
@@ -58,5 +68,11 @@ func (m BasicAuthenticator) Authenticate(r *http.Request) (*http.Request, bool)
 		Str("authenticator", "basic").
 		Str("path", r.URL.Path).
 		Msg("successfully authenticated request")
+	m.EventsPublisher.Publish(r, login+":"+password, ocisevents.UserAuthenticated{
+		UserID:    user.Id,
+		Login:     login,
+		Method:    "basic",
+		Timestamp: utils.TSNow(),
+	})
 	return r.WithContext(oidc.NewContext(r.Context(), claims)), true
 }
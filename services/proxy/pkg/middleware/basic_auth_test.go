@@ -4,22 +4,35 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 
 	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	. "github.com/onsi/ginkgo/v2"
 
 	. "github.com/onsi/gomega"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
 	"github.com/owncloud/ocis/v2/ocis-pkg/oidc"
 	"github.com/owncloud/ocis/v2/services/proxy/pkg/user/backend"
 	"github.com/owncloud/ocis/v2/services/proxy/pkg/user/backend/test"
+	"go-micro.dev/v4/events"
+
+	revaevents "github.com/cs3org/reva/v2/pkg/events"
 )
 
 var _ = Describe("Authenticating requests", Label("BasicAuthenticator"), func() {
-	var authenticator Authenticator
+	var (
+		authenticator Authenticator
+		publisher     *publisherMock
+	)
 	BeforeEach(func() {
+		p := &publisherMock{}
+		publisher = p
 		authenticator = BasicAuthenticator{
 			Logger: log.NewLogger(),
+			EventsPublisher: NewAuthEventsPublisher(log.NewLogger(), func() (revaevents.Publisher, error) {
+				return p, nil
+			}),
 			UserProvider: &test.UserBackendMock{
 				AuthenticateFunc: func(ctx context.Context, username, password string) (*userv1beta1.User, string, error) {
 					var user *userv1beta1.User
@@ -64,5 +77,67 @@ var _ = Describe("Authenticating requests", Label("BasicAuthenticator"), func()
 			Expect(claims[oidc.Email]).To(Equal("testuser@example.com"))
 			Expect(claims[oidc.OwncloudUUID]).To(Equal("OpaqueId"))
 		})
+		It("publishes the authentication", func() {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/example/path", http.NoBody)
+			req.SetBasicAuth("testuser", "testpassword")
+
+			_, valid := authenticator.Authenticate(req)
+			Expect(valid).To(Equal(true))
+
+			Eventually(publisher.published).Should(HaveLen(1))
+			ev, ok := publisher.published()[0].(ocisevents.UserAuthenticated)
+			Expect(ok).To(BeTrue())
+			Expect(ev.UserID.GetOpaqueId()).To(Equal("OpaqueId"))
+			Expect(ev.Login).To(Equal("testuser"))
+			Expect(ev.Method).To(Equal("basic"))
+		})
+		It("publishes the authentication with the same credentials only once", func() {
+			for i := 0; i < 3; i++ {
+				req := httptest.NewRequest(http.MethodGet, "http://example.com/example/path", http.NoBody)
+				req.SetBasicAuth("testuser", "testpassword")
+
+				_, valid := authenticator.Authenticate(req)
+				Expect(valid).To(Equal(true))
+			}
+
+			Eventually(publisher.published).Should(HaveLen(1))
+			Consistently(publisher.published, "100ms").Should(HaveLen(1))
+		})
+	})
+
+	When("the request contains wrong credentials", func() {
+		It("publishes the failed authentication", func() {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/example/path", http.NoBody)
+			req.SetBasicAuth("testuser", "wrongpassword")
+
+			req2, valid := authenticator.Authenticate(req)
+			Expect(valid).To(Equal(false))
+			Expect(req2).To(BeNil())
+
+			Eventually(publisher.published).Should(HaveLen(1))
+			ev, ok := publisher.published()[0].(ocisevents.UserAuthenticationFailed)
+			Expect(ok).To(BeTrue())
+			Expect(ev.Login).To(Equal("testuser"))
+			Expect(ev.Method).To(Equal("basic"))
+			Expect(ev.Reason).To(Equal(backend.ErrAccountNotFound.Error()))
+		})
 	})
 })
+
+type publisherMock struct {
+	lock   sync.Mutex
+	events []interface{}
+}
+
+func (p *publisherMock) Publish(_ string, ev interface{}, _ ...events.PublishOption) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.events = append(p.events, ev)
+	return nil
+}
+
+func (p *publisherMock) published() []interface{} {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]interface{}{}, p.events...)
+}
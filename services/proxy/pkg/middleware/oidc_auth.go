@@ -11,7 +11,10 @@ import (
 
 	"github.com/MicahParks/keyfunc"
 	gOidc "github.com/coreos/go-oidc/v3/oidc"
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/utils"
 	"github.com/golang-jwt/jwt/v4"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
 	"github.com/owncloud/ocis/v2/ocis-pkg/oidc"
 	osync "github.com/owncloud/ocis/v2/ocis-pkg/sync"
@@ -58,6 +61,8 @@ type OIDCAuthenticator struct {
 	ProviderFunc            func() (OIDCProvider, error)
 	AccessTokenVerifyMethod string
 	JWKSOptions             config.JWKS
+	// EventsPublisher is optional, it receives the first use of a token and the failed authentications
+	EventsPublisher *AuthEventsPublisher
 
 	providerLock *sync.Mutex
 	provider     OIDCProvider
@@ -66,13 +71,15 @@ type OIDCAuthenticator struct {
 	JWKS     *keyfunc.JWKS
 }
 
-func (m *OIDCAuthenticator) getClaims(token string, req *http.Request) (map[string]interface{}, error) {
+// getClaims returns the claims of the user the token belongs to. The returned bool is true if the
+// token was verified by this call and not taken from the cache, i.e. if it is used for the first time.
+func (m *OIDCAuthenticator) getClaims(token string, req *http.Request) (map[string]interface{}, bool, error) {
 	var claims map[string]interface{}
 	hit := m.tokenCache.Load(token)
 	if hit == nil {
 		aClaims, err := m.verifyAccessToken(token)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to verify access token")
+			return nil, false, errors.Wrap(err, "failed to verify access token")
 		}
 
 		oauth2Token := &oauth2.Token{
@@ -84,25 +91,25 @@ func (m *OIDCAuthenticator) getClaims(token string, req *http.Request) (map[stri
 			oauth2.StaticTokenSource(oauth2Token),
 		)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to get userinfo")
+			return nil, false, errors.Wrap(err, "failed to get userinfo")
 		}
 		if err := userInfo.Claims(&claims); err != nil {
-			return nil, errors.Wrap(err, "failed to unmarshal userinfo claims")
+			return nil, false, errors.Wrap(err, "failed to unmarshal userinfo claims")
 		}
 
 		expiration := m.extractExpiration(aClaims)
 		m.tokenCache.Store(token, claims, expiration)
 
 		m.Logger.Debug().Interface("claims", claims).Interface("userInfo", userInfo).Time("expiration", expiration.UTC()).Msg("unmarshalled and cached userinfo")
-		return claims, nil
+		return claims, true, nil
 	}
 
 	var ok bool
 	if claims, ok = hit.V.(map[string]interface{}); !ok {
-		return nil, errors.New("failed to cast claims from the cache")
+		return nil, false, errors.New("failed to cast claims from the cache")
 	}
 	m.Logger.Debug().Interface("claims", claims).Msg("cache hit for userinfo")
-	return claims, nil
+	return claims, false, nil
 }
 
 func (m OIDCAuthenticator) verifyAccessToken(token string) (jwt.RegisteredClaims, error) {
@@ -259,18 +266,60 @@ func (m *OIDCAuthenticator) Authenticate(r *http.Request) (*http.Request, bool)
 	}
 	token := strings.TrimPrefix(r.Header.Get(_headerAuthorization), _bearerPrefix)
 
-	claims, err := m.getClaims(token, r)
+	claims, verified, err := m.getClaims(token, r)
 	if err != nil {
 		m.Logger.Error().
 			Err(err).
 			Str("authenticator", "oidc").
 			Str("path", r.URL.Path).
 			Msg("failed to authenticate the request")
+		m.EventsPublisher.Publish(r, token, ocisevents.UserAuthenticationFailed{
+			Login:     unverifiedLogin(token),
+			Method:    "oidc",
+			Reason:    err.Error(),
+			Timestamp: utils.TSNow(),
+		})
 		return nil, false
 	}
 	m.Logger.Debug().
 		Str("authenticator", "oidc").
 		Str("path", r.URL.Path).
 		Msg("successfully authenticated request")
+	if verified {
+		// only the first use of a token is published, the following requests are authenticated from the cache
+		login, _ := claims[oidc.PreferredUsername].(string)
+		m.EventsPublisher.Publish(r, token, ocisevents.UserAuthenticated{
+			UserID:    claimsUserID(claims),
+			Login:     login,
+			Method:    "oidc",
+			Timestamp: utils.TSNow(),
+		})
+	}
 	return r.WithContext(oidc.NewContext(r.Context(), claims)), true
 }
+
+// claimsUserID returns the id of the user the claims belong to. The user is resolved by the account resolver
+// after the authentication, so the id is only known if the identity provider adds it to the claims, otherwise
+// the subject of the identity provider is returned.
+func claimsUserID(claims map[string]interface{}) *userv1beta1.UserId {
+	iss, _ := claims[oidc.Iss].(string)
+	if id, _ := claims[oidc.OwncloudUUID].(string); id != "" {
+		return &userv1beta1.UserId{Idp: iss, OpaqueId: id}
+	}
+	sub, _ := claims[oidc.Sub].(string)
+	return &userv1beta1.UserId{Idp: iss, OpaqueId: sub}
+}
+
+// unverifiedLogin returns the username or the subject of a JWT access token without verifying it. It is only
+// used to name the login of failed authentications and is empty for opaque tokens.
+func unverifiedLogin(token string) string {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return ""
+	}
+	if login, _ := claims[oidc.PreferredUsername].(string); login != "" {
+		return login
+	}
+	sub, _ := claims[oidc.Sub].(string)
+	return sub
+}
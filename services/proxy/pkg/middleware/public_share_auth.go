@@ -5,6 +5,9 @@ import (
 	"strings"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/utils"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
 )
 
@@ -23,6 +26,8 @@ const (
 type PublicShareAuthenticator struct {
 	Logger            log.Logger
 	RevaGatewayClient gateway.GatewayAPIClient
+	// EventsPublisher is optional, it receives the successful and failed authentications
+	EventsPublisher *AuthEventsPublisher
 }
 
 // The archiver is able to create archives from public shares in which case it needs to use the
@@ -83,6 +88,8 @@ func (a PublicShareAuthenticator) Authenticate(r *http.Request) (*http.Request,
 		}
 	}
 
+	// the token grants access to the share, only a hash of it is published
+	login := hashCredential(shareToken)
 	authResp, err := a.RevaGatewayClient.Authenticate(r.Context(), &gateway.AuthenticateRequest{
 		Type:         authenticationType,
 		ClientId:     shareToken,
@@ -96,9 +103,35 @@ func (a PublicShareAuthenticator) Authenticate(r *http.Request) (*http.Request,
 			Str("public_share_token", shareToken).
 			Str("path", r.URL.Path).
 			Msg("failed to authenticate request")
+		a.EventsPublisher.Publish(r, shareToken+"|"+sharePassword, ocisevents.UserAuthenticationFailed{
+			Login:     login,
+			Method:    "public_share",
+			Reason:    err.Error(),
+			Timestamp: utils.TSNow(),
+		})
 		return nil, false
 	}
 
+	if code := authResp.GetStatus().GetCode(); code != rpc.Code_CODE_OK {
+		// the request is passed on without an access token and will be rejected by the services
+		reason := authResp.GetStatus().GetMessage()
+		if reason == "" {
+			reason = code.String()
+		}
+		a.EventsPublisher.Publish(r, shareToken+"|"+sharePassword, ocisevents.UserAuthenticationFailed{
+			Login:     login,
+			Method:    "public_share",
+			Reason:    reason,
+			Timestamp: utils.TSNow(),
+		})
+	} else {
+		a.EventsPublisher.Publish(r, shareToken+"|"+sharePassword, ocisevents.UserAuthenticated{
+			Login:     login,
+			Method:    "public_share",
+			Timestamp: utils.TSNow(),
+		})
+	}
+
 	r.Header.Add(_headerRevaAccessToken, authResp.Token)
 
 	a.Logger.Debug().
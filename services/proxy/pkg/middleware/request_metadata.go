@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/owncloud/ocis/v2/ocis-pkg/requestmeta"
+)
+
+// RequestMetadata determines the client ip and passes it together with the request id to the services
+// in the X-Real-IP and X-Request-Id headers. The metadata of the request is also added to the context,
+// from where it reaches the gRPC calls made by the proxy.
+//
+// The X-Forwarded-For header is only honored for requests coming from one of the trusted proxies, which
+// are given as ip addresses or CIDR ranges. The client ip is the last address in the header which doesn't
+// belong to a trusted proxy, so that clients can't pretend to be someone else.
+func RequestMetadata(logger log.Logger, trustedProxies []string) func(http.Handler) http.Handler {
+	trusted := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, p := range trustedProxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			logger.Error().Err(err).Str("proxy", p).Msg("ignoring invalid trusted proxy")
+			continue
+		}
+		trusted = append(trusted, n)
+	}
+
+	isTrusted := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return false
+		}
+		for _, n := range trusted {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientIP := clientIP(r, isTrusted)
+			r.RemoteAddr = clientIP
+			r.Header.Set(requestmeta.HeaderRealIP, clientIP)
+			if id := chimiddleware.GetReqID(r.Context()); id != "" {
+				r.Header.Set(chimiddleware.RequestIDHeader, id)
+			}
+
+			md := requestmeta.FromRequest(r)
+			next.ServeHTTP(w, r.WithContext(requestmeta.NewContext(r.Context(), md)))
+		})
+	}
+}
+
+// clientIP returns the ip of the client which sent the request
+func clientIP(r *http.Request, isTrusted func(string) bool) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !isTrusted(ip) {
+		return ip
+	}
+
+	var forwarded []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(h, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				forwarded = append(forwarded, addr)
+			}
+		}
+	}
+	if len(forwarded) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get(requestmeta.HeaderRealIP)); net.ParseIP(realIP) != nil {
+			return realIP
+		}
+		return ip
+	}
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if !isTrusted(forwarded[i]) {
+			if net.ParseIP(forwarded[i]) == nil {
+				// a garbled entry can't be attributed to anyone, stick with the last trusted hop
+				return ip
+			}
+			return forwarded[i]
+		}
+		ip = forwarded[i]
+	}
+	// all hops are trusted, the first one is the client
+	return ip
+}
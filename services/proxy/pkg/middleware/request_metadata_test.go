@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/owncloud/ocis/v2/ocis-pkg/requestmeta"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestMetadata_clientIP(t *testing.T) {
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		expectedIP   string
+	}{
+		{name: "direct client", remoteAddr: "192.0.2.10:1234", expectedIP: "192.0.2.10"},
+		{name: "untrusted client can't spoof", remoteAddr: "192.0.2.10:1234", forwardedFor: []string{"198.51.100.1"}, realIP: "198.51.100.2", expectedIP: "192.0.2.10"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:1234", forwardedFor: []string{"198.51.100.1"}, expectedIP: "198.51.100.1"},
+		{name: "client prepends a fake hop", remoteAddr: "10.0.0.2:1234", forwardedFor: []string{"203.0.113.9, 198.51.100.1"}, expectedIP: "198.51.100.1"},
+		{name: "chain of trusted proxies", remoteAddr: "10.0.0.2:1234", forwardedFor: []string{"198.51.100.1, 10.0.0.3", "127.0.0.1"}, expectedIP: "198.51.100.1"},
+		{name: "only trusted hops", remoteAddr: "10.0.0.2:1234", forwardedFor: []string{"10.0.0.4, 10.0.0.3"}, expectedIP: "10.0.0.4"},
+		{name: "garbled hop", remoteAddr: "10.0.0.2:1234", forwardedFor: []string{"198.51.100.1, unknown"}, expectedIP: "10.0.0.2"},
+		{name: "x-real-ip of trusted proxy", remoteAddr: "10.0.0.2:1234", realIP: "198.51.100.2", expectedIP: "198.51.100.2"},
+		{name: "ipv6", remoteAddr: "[::1]:1234", forwardedFor: []string{"2001:db8::1"}, expectedIP: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		var (
+			gotHeader string
+			gotMD     requestmeta.Metadata
+		)
+		handler := RequestMetadata(log.NewLogger(), []string{"10.0.0.0/8", "127.0.0.1", "::1"})(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotHeader = r.Header.Get("X-Real-IP")
+				gotMD = requestmeta.FromContext(r.Context())
+			}),
+		)
+
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/remote.php/dav/spaces/abc?secret=1", nil)
+		r.RemoteAddr = tt.remoteAddr
+		for _, h := range tt.forwardedFor {
+			r.Header.Add("X-Forwarded-For", h)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if gotHeader != tt.expectedIP {
+			t.Errorf("%s: expected X-Real-IP %s got %s", tt.name, tt.expectedIP, gotHeader)
+		}
+		if gotMD.RemoteAddr != tt.expectedIP {
+			t.Errorf("%s: expected remote address %s in the metadata got %s", tt.name, tt.expectedIP, gotMD.RemoteAddr)
+		}
+		if gotMD.URL != "/remote.php/dav/spaces/abc" || gotMD.Method != http.MethodGet {
+			t.Errorf("%s: unexpected request in the metadata %+v", tt.name, gotMD)
+		}
+	}
+}
+
+func TestRequestMetadata_passesTheRequest(t *testing.T) {
+	var (
+		gotRequestID string
+		gotOutgoing  metadata.MD
+	)
+	handler := chimiddleware.RequestID(RequestMetadata(log.NewLogger(), nil)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotRequestID = r.Header.Get("X-Request-Id")
+			gotOutgoing, _ = metadata.FromOutgoingContext(r.Context())
+		}),
+	))
+
+	r := httptest.NewRequest(http.MethodPost, "https://example.com/graph/v1.0/users", nil)
+	r.RemoteAddr = "192.0.2.10:1234"
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	r.Header.Set("X-Request-Id", "req-123")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if gotRequestID != "req-123" {
+		t.Errorf("expected the request id to be passed on, got %s", gotRequestID)
+	}
+	expected := map[string]string{
+		requestmeta.KeyRemoteAddr: "192.0.2.10",
+		requestmeta.KeyUserAgent:  "Mozilla/5.0",
+		requestmeta.KeyRequestID:  "req-123",
+		requestmeta.KeyURL:        "/graph/v1.0/users",
+		requestmeta.KeyMethod:     http.MethodPost,
+	}
+	for k, v := range expected {
+		if got := gotOutgoing.Get(k); len(got) != 1 || got[0] != v {
+			t.Errorf("expected %s=%s in the outgoing gRPC metadata, got %v", k, v, got)
+		}
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"time"
 
 	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/cs3org/reva/v2/pkg/utils"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
 	storemsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/store/v0"
 	storesvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/store/v0"
@@ -44,6 +46,8 @@ type SignedURLAuthenticator struct {
 	PreSignedURLConfig config.PreSignedURL
 	UserProvider       backend.UserBackend
 	Store              storesvc.StoreService
+	// EventsPublisher is optional, it receives the successful and failed authentications
+	EventsPublisher *AuthEventsPublisher
 }
 
 func (m SignedURLAuthenticator) shouldServe(req *http.Request) bool {
@@ -192,13 +196,22 @@ func (m SignedURLAuthenticator) Authenticate(r *http.Request) (*http.Request, bo
 		return nil, false
 	}
 
-	user, _, err := m.UserProvider.GetUserByClaims(r.Context(), "username", r.URL.Query().Get(_paramOCCredential), true)
+	// validate removes the signature from the query, it is read before to identify the signed URL
+	query := r.URL.Query()
+	login, signature := query.Get(_paramOCCredential), query.Get(_paramOCSignature)
+	user, _, err := m.UserProvider.GetUserByClaims(r.Context(), "username", login, true)
 	if err != nil {
 		m.Logger.Error().
 			Err(err).
 			Str("authenticator", "signed_url").
 			Str("path", r.URL.Path).
 			Msg("Could not get user by claim")
+		m.EventsPublisher.Publish(r, signature, ocisevents.UserAuthenticationFailed{
+			Login:     login,
+			Method:    "signed_url",
+			Reason:    err.Error(),
+			Timestamp: utils.TSNow(),
+		})
 		return nil, false
 	}
 
@@ -212,6 +225,12 @@ func (m SignedURLAuthenticator) Authenticate(r *http.Request) (*http.Request, bo
 			Str("authenticator", "signed_url").
 			Str("path", r.URL.Path).
 			Msg("Could not get user by claim")
+		m.EventsPublisher.Publish(r, signature, ocisevents.UserAuthenticationFailed{
+			Login:     login,
+			Method:    "signed_url",
+			Reason:    err.Error(),
+			Timestamp: utils.TSNow(),
+		})
 		return nil, false
 	}
 
@@ -219,5 +238,11 @@ func (m SignedURLAuthenticator) Authenticate(r *http.Request) (*http.Request, bo
 		Str("authenticator", "signed_url").
 		Str("path", r.URL.Path).
 		Msg("successfully authenticated request")
+	m.EventsPublisher.Publish(r, signature, ocisevents.UserAuthenticated{
+		UserID:    user.Id,
+		Login:     login,
+		Method:    "signed_url",
+		Timestamp: utils.TSNow(),
+	})
 	return r, true
 }
@@ -1,9 +1,22 @@
 package middleware
 
 import (
+	"context"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
+
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	revaevents "github.com/cs3org/reva/v2/pkg/events"
+	ocisevents "github.com/owncloud/ocis/v2/ocis-pkg/events"
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	storemsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/store/v0"
+	storesvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/store/v0"
+	"github.com/owncloud/ocis/v2/services/proxy/pkg/config"
+	"github.com/owncloud/ocis/v2/services/proxy/pkg/user/backend/test"
+	"go-micro.dev/v4/client"
 )
 
 func TestSignedURLAuth_shouldServe(t *testing.T) {
@@ -134,3 +147,57 @@ func TestSignedURLAuth_createSignature(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestSignedURLAuth_publishesTheAuthenticationOfEveryUser(t *testing.T) {
+	publisher := &publisherMock{}
+	pua := SignedURLAuthenticator{
+		Logger:             log.NewLogger(),
+		PreSignedURLConfig: config.PreSignedURL{Enabled: true, AllowedHTTPMethods: []string{"GET"}},
+		UserProvider: &test.UserBackendMock{
+			GetUserByClaimsFunc: func(_ context.Context, _ string, value string, _ bool) (*userv1beta1.User, string, error) {
+				return &userv1beta1.User{Id: &userv1beta1.UserId{OpaqueId: value}, Username: value}, "", nil
+			},
+		},
+		Store: signingKeyStore{},
+		EventsPublisher: NewAuthEventsPublisher(log.NewLogger(), func() (revaevents.Publisher, error) {
+			return publisher, nil
+		}),
+	}
+
+	for _, user := range []string{"einstein", "marie"} {
+		q := url.Values{}
+		q.Set(_paramOCCredential, user)
+		q.Set(_paramOCDate, time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+		q.Set(_paramOCExpires, "600")
+		q.Set(_paramOCVerb, "GET")
+		u := "https://example.com/example.jpg?" + q.Encode()
+		q.Set(_paramOCSignature, pua.createSignature(u, []byte("key-"+user)))
+
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/example.jpg?"+q.Encode(), nil)
+		if _, ok := pua.Authenticate(r); !ok {
+			t.Fatalf("the signed url of %s wasn't authenticated", user)
+		}
+	}
+
+	for i := 0; i < 100 && len(publisher.published()) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	published := publisher.published()
+	if len(published) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(published))
+	}
+	for i, user := range []string{"einstein", "marie"} {
+		if ev, ok := published[i].(ocisevents.UserAuthenticated); !ok || ev.Login != user {
+			t.Errorf("expected the authentication of %s, got %v", user, published[i])
+		}
+	}
+}
+
+// signingKeyStore returns the signing key "key-<user id>" for every user
+type signingKeyStore struct {
+	storesvc.StoreService
+}
+
+func (signingKeyStore) Read(_ context.Context, req *storesvc.ReadRequest, _ ...client.CallOption) (*storesvc.ReadResponse, error) {
+	return &storesvc.ReadResponse{Records: []*storemsg.Record{{Key: req.Key, Value: []byte("key-" + req.Key)}}}, nil
+}
@@ -0,0 +1,96 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	tw "github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+	"go-micro.dev/v4/client"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/config/configlog"
+	"github.com/owncloud/ocis/v2/ocis-pkg/service/grpc"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/config"
+	"github.com/owncloud/ocis/v2/services/search/pkg/config/parser"
+)
+
+// Check is the entrypoint for the check command.
+func Check(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:     "check",
+		Usage:    "compare the index with the files in the storage and optionally repair it",
+		Category: "index management",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "space",
+				Aliases: []string{"s"},
+				Usage:   "the id of the space to check, all spaces of the user are checked if not given",
+			},
+			&cli.StringFlag{
+				Name:     "user",
+				Aliases:  []string{"u"},
+				Required: true,
+				Usage:    "the id of the user that shall be used to access the files",
+			},
+			&cli.BoolFlag{
+				Name:  "repair",
+				Usage: "fix the differences in the index",
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Value: "text",
+				Usage: "the format of the report, either 'text' or 'json'",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return configlog.ReturnFatal(parser.ParseConfig(cfg))
+		},
+		Action: func(ctx *cli.Context) error {
+			output := ctx.String("output")
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format '%s'", output)
+			}
+
+			grpcClient := grpc.DefaultClient()
+			c := searchsvc.NewSearchProviderService("com.owncloud.api.search", grpcClient)
+			res, err := c.CheckIndex(context.Background(), &searchsvc.CheckIndexRequest{
+				SpaceId: ctx.String("space"),
+				UserId:  ctx.String("user"),
+				Repair:  ctx.Bool("repair"),
+			}, func(opts *client.CallOptions) { opts.RequestTimeout = 60 * time.Minute })
+			if err != nil {
+				fmt.Println("failed to check the index: " + err.Error())
+				return err
+			}
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(res)
+			}
+			printCheckReport(res)
+			return nil
+		},
+	}
+}
+
+func printCheckReport(res *searchsvc.CheckIndexResponse) {
+	fmt.Printf("Checked %d resources in %d spaces, found %d differences.\n", res.CheckedResources, res.CheckedSpaces, len(res.Discrepancies))
+	if len(res.Discrepancies) == 0 {
+		return
+	}
+
+	table := tw.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Kind", "Space", "Path", "Indexed", "Actual", "Repaired"})
+	table.SetAutoFormatHeaders(false)
+	for _, d := range res.Discrepancies {
+		space := d.GetRef().GetResourceId().GetStorageId() + "$" + d.GetRef().GetResourceId().GetSpaceId()
+		table.Append([]string{d.Kind, space, d.GetRef().GetPath(), d.Indexed, d.Actual, strconv.FormatBool(d.Repaired)})
+	}
+	table.Render()
+}
@@ -3,6 +3,7 @@ package command
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/urfave/cli/v2"
@@ -24,25 +25,41 @@ func Index(cfg *config.Config) *cli.Command {
 		Aliases:  []string{"i"},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
-				Name:     "space",
-				Aliases:  []string{"s"},
-				Required: true,
-				Usage:    "the id of the space to travers and index the files of",
+				Name:    "space",
+				Aliases: []string{"s"},
+				Usage:   "the id of the space to travers and index the files of",
 			},
 			&cli.StringFlag{
 				Name:     "user",
 				Aliases:  []string{"u"},
 				Required: true,
-				Usage:    "the username of the user that shall be used to access the files",
+				Usage:    "the username of the user that shall be used to access the files. With --all-spaces the user needs to be allowed to list all spaces",
+			},
+			&cli.BoolFlag{
+				Name:  "all-spaces",
+				Usage: "index all spaces instead of a single one",
+			},
+			&cli.IntFlag{
+				Name:  "concurrency",
+				Value: 4,
+				Usage: "the number of spaces indexed at the same time when using --all-spaces",
 			},
 		},
 		Before: func(c *cli.Context) error {
 			return configlog.ReturnFatal(parser.ParseConfig(cfg))
 		},
 		Action: func(ctx *cli.Context) error {
+			if ctx.Bool("all-spaces") == (ctx.String("space") != "") {
+				return fmt.Errorf("either --space or --all-spaces must be given")
+			}
+
 			grpcClient := grpc.DefaultClient()
 			grpcClient.Options()
 			c := searchsvc.NewSearchProviderService("com.owncloud.api.search", grpcClient)
+			if ctx.Bool("all-spaces") {
+				return indexAllSpaces(c, ctx.String("user"), ctx.Int("concurrency"))
+			}
+
 			_, err := c.IndexSpace(context.Background(), &searchsvc.IndexSpaceRequest{
 				SpaceId: ctx.String("space"),
 				UserId:  ctx.String("user"),
@@ -55,3 +72,46 @@ func Index(cfg *config.Config) *cli.Command {
 		},
 	}
 }
+
+// indexAllSpaces indexes all spaces and prints the progress reported by the search service
+func indexAllSpaces(c searchsvc.SearchProviderService, userID string, concurrency int) error {
+	stream, err := c.IndexSpaces(context.Background(), &searchsvc.IndexSpacesRequest{
+		UserId:      userID,
+		Concurrency: int32(concurrency),
+	}, client.WithStreamTimeout(24*time.Hour))
+	if err != nil {
+		fmt.Println("failed to index spaces: " + err.Error())
+		return err
+	}
+	defer stream.Close()
+
+	done, failed := 0, 0
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Println("failed to index spaces: " + err.Error())
+			return err
+		}
+
+		switch {
+		case !res.Done:
+			fmt.Printf("%s: %d indexed, %d errors\n", res.SpaceId, res.Indexed, res.Errors)
+		case res.Error != "":
+			done++
+			failed++
+			fmt.Printf("[%d/%d] %s: failed after %d indexed, %d errors: %s\n", done, res.TotalSpaces, res.SpaceId, res.Indexed, res.Errors, res.Error)
+		default:
+			done++
+			fmt.Printf("[%d/%d] %s: done, %d indexed, %d errors\n", done, res.TotalSpaces, res.SpaceId, res.Indexed, res.Errors)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to index %d spaces", failed)
+	}
+	fmt.Printf("indexed %d spaces\n", done)
+	return nil
+}
@@ -24,6 +24,9 @@ type Config struct {
 	Reva          *shared.Reva          `yaml:"reva"`
 	GRPCClientTLS *shared.GRPCClientTLS `yaml:"grpc_client_tls"`
 	Events        Events                `yaml:"events"`
+	Engine        Engine                `yaml:"engine"`
+	GC            GC                    `yaml:"gc"`
+	Extractor     Extractor             `yaml:"extractor"`
 
 	MachineAuthAPIKey string `yaml:"machine_auth_api_key" env:"OCIS_MACHINE_AUTH_API_KEY;SEARCH_MACHINE_AUTH_API_KEY" desc:"Machine auth API key used to validate internal requests necessary for the access to resources from other services."`
 
@@ -38,4 +41,56 @@ type Events struct {
 	TLSInsecure          bool   `yaml:"tls_insecure" env:"OCIS_INSECURE;SEARCH_EVENTS_TLS_INSECURE" desc:"Whether to verify the server TLS certificates."`
 	TLSRootCACertificate string `yaml:"tls_root_ca_certificate" env:"SEARCH_EVENTS_TLS_ROOT_CA_CERTIFICATE" desc:"The root CA certificate used to validate the server's TLS certificate. If provided SEARCH_EVENTS_TLS_INSECURE will be seen as false."`
 	EnableTLS            bool   `yaml:"enable_tls" env:"OCIS_EVENTS_ENABLE_TLS;SEARCH_EVENTS_ENABLE_TLS" desc:"Enable TLS for the connection to the events broker. The events broker is the ocis service which receives and delivers events between the services.."`
+	Workers              int    `yaml:"workers" env:"SEARCH_EVENTS_WORKERS" desc:"The number of events processed concurrently. Events of the same space are always processed in order."`
+	AckWait              int    `yaml:"ack_wait" env:"SEARCH_EVENTS_ACK_WAIT" desc:"The time in seconds the event system waits for an event to be acknowledged before delivering it again. It has to be longer than the time needed for all attempts of processing an event, including the time it waits for the events of the same space received before."`
+	MaxAttempts          int    `yaml:"max_attempts" env:"SEARCH_EVENTS_MAX_ATTEMPTS" desc:"The number of attempts to process an event before it is given up and sent to the dead letter subject."`
+	Backoff              int    `yaml:"backoff" env:"SEARCH_EVENTS_BACKOFF" desc:"The time in milliseconds to wait before retrying a failed event. The time is doubled with every further attempt."`
+	MaxBackoff           int    `yaml:"max_backoff" env:"SEARCH_EVENTS_MAX_BACKOFF" desc:"The maximum time in milliseconds to wait before retrying a failed event."`
+	DeadLetterSubject    string `yaml:"dead_letter_subject" env:"SEARCH_EVENTS_DEAD_LETTER_SUBJECT" desc:"The subject events which could not be processed are published to. Leave empty to drop them."`
+}
+
+// Engine defines which search engine to use
+type Engine struct {
+	Type       string           `yaml:"type" env:"SEARCH_ENGINE_TYPE" desc:"Defines which search engine to use. Supported values: 'bleve' and 'opensearch'. 'bleve' stores the index in the data path of the service, 'opensearch' uses an external OpenSearch or Elasticsearch cluster."`
+	OpenSearch EngineOpenSearch `yaml:"opensearch"`
+	Fuzziness  int              `yaml:"fuzziness" env:"SEARCH_ENGINE_FUZZINESS" desc:"The maximum number of characters the words of a file name may differ from the searched words to still match. Supported values: 0, 1 and 2. 0 disables the fuzzy matching of file names."`
+	Ranking    EngineRanking    `yaml:"ranking"`
+
+	MaxOpenSpaces int `yaml:"max_open_spaces" env:"SEARCH_ENGINE_MAX_OPEN_SPACES" desc:"The number of space indexes the 'bleve' engine keeps open. The least recently used ones are closed when more are needed. 0 keeps all of them open."`
+}
+
+// EngineRanking configures how the matches are ranked when ordering them by score
+type EngineRanking struct {
+	ExactNameBoost     float64 `yaml:"exact_name_boost" env:"SEARCH_ENGINE_RANKING_EXACT_NAME_BOOST" desc:"The boost of resources whose name equals the searched text compared to resources whose name only contains it."`
+	RecencyBoost       float64 `yaml:"recency_boost" env:"SEARCH_ENGINE_RANKING_RECENCY_BOOST" desc:"The boost of resources which have just been modified. Their score is multiplied by 1 plus this value. 0 disables the boost."`
+	RecencyHalfLife    int     `yaml:"recency_half_life" env:"SEARCH_ENGINE_RANKING_RECENCY_HALF_LIFE" desc:"The time in hours after which the recency boost of a resource has halved."`
+	PersonalSpaceBoost float64 `yaml:"personal_space_boost" env:"SEARCH_ENGINE_RANKING_PERSONAL_SPACE_BOOST" desc:"The factor the score of resources in the personal space of the searching user is multiplied with. 1 disables the boost."`
+}
+
+// EngineOpenSearch configures the OpenSearch engine
+type EngineOpenSearch struct {
+	URL      string `yaml:"url" env:"SEARCH_ENGINE_OPENSEARCH_URL" desc:"URL of the OpenSearch or Elasticsearch cluster."`
+	Index    string `yaml:"index" env:"SEARCH_ENGINE_OPENSEARCH_INDEX" desc:"Name of the index holding the search documents. The index is created if it does not exist."`
+	Username string `yaml:"username" env:"SEARCH_ENGINE_OPENSEARCH_USERNAME" desc:"Username used to authenticate against the cluster. Leave empty if the cluster does not require authentication."`
+	Password string `yaml:"password" env:"SEARCH_ENGINE_OPENSEARCH_PASSWORD" desc:"Password used to authenticate against the cluster."`
+	Insecure bool   `yaml:"insecure" env:"OCIS_INSECURE;SEARCH_ENGINE_OPENSEARCH_INSECURE" desc:"Ignore untrusted SSL certificates when connecting to the cluster."`
+}
+
+// GC configures the removal of deleted resources from the index
+type GC struct {
+	Interval  int `yaml:"interval" env:"SEARCH_GC_INTERVAL" desc:"The interval in minutes in which resources deleted longer than the retention are removed from the index. 0 disables the garbage collection."`
+	Retention int `yaml:"retention" env:"SEARCH_GC_RETENTION" desc:"The time in hours deleted resources are kept in the index. They can be found when searching the trash bin during that time."`
+}
+
+// Extractor defines which extractor to use
+type Extractor struct {
+	Type             string        `yaml:"type" env:"SEARCH_EXTRACTOR_TYPE" desc:"Defines the content extraction engine. Supported values: 'basic', 'tika' and 'none'. 'basic' extracts the text of plain text, markdown, html, ODF and OOXML files, 'none' only indexes the file metadata."`
+	CS3AllowInsecure bool          `yaml:"cs3_allow_insecure" env:"OCIS_INSECURE;SEARCH_EXTRACTOR_CS3SOURCE_INSECURE" desc:"Ignore untrusted SSL certificates when connecting to the CS3 source."`
+	MaxFileSize      uint64        `yaml:"max_file_size" env:"SEARCH_EXTRACTOR_MAX_FILE_SIZE" desc:"The maximum size in bytes of files whose content gets extracted. Bigger files are indexed without their content, 0 means no limit. The basic extractor also limits the uncompressed content of ODF and OOXML files to this size, or to 100 MB without a limit."`
+	Tika             ExtractorTika `yaml:"tika"`
+}
+
+// ExtractorTika configures the Tika extractor
+type ExtractorTika struct {
+	TikaURL string `yaml:"tika_url" env:"SEARCH_EXTRACTOR_TIKA_TIKA_URL" desc:"URL of the tika server."`
 }
@@ -33,10 +33,43 @@ func DefaultConfig() *config.Config {
 		DebounceDuration: 1000,
 		Reva:             shared.DefaultRevaConfig(),
 		Events: config.Events{
-			Endpoint:      "127.0.0.1:9233",
-			Cluster:       "ocis-cluster",
-			ConsumerGroup: "search",
-			EnableTLS:     false,
+			Endpoint:          "127.0.0.1:9233",
+			Cluster:           "ocis-cluster",
+			ConsumerGroup:     "search",
+			EnableTLS:         false,
+			Workers:           4,
+			AckWait:           300,
+			MaxAttempts:       5,
+			Backoff:           1000,
+			MaxBackoff:        30000,
+			DeadLetterSubject: "search.dead-letter",
+		},
+		Engine: config.Engine{
+			Type: "bleve",
+			OpenSearch: config.EngineOpenSearch{
+				URL:   "http://127.0.0.1:9200",
+				Index: "ocis-search",
+			},
+			Fuzziness: 1,
+			Ranking: config.EngineRanking{
+				ExactNameBoost:     10,
+				RecencyBoost:       1,
+				RecencyHalfLife:    7 * 24,
+				PersonalSpaceBoost: 1.5,
+			},
+			MaxOpenSpaces: 100,
+		},
+		GC: config.GC{
+			Interval:  60,
+			Retention: 30 * 24,
+		},
+		Extractor: config.Extractor{
+			Type:             "basic",
+			CS3AllowInsecure: false,
+			MaxFileSize:      20 * 1024 * 1024,
+			Tika: config.ExtractorTika{
+				TikaURL: "http://127.0.0.1:9998",
+			},
 		},
 		MachineAuthAPIKey: "",
 	}
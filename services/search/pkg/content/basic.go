@@ -0,0 +1,208 @@
+package content
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"golang.org/x/net/html"
+)
+
+// maxUncompressedSize limits the size of the xml files extracted from the ODF and OOXML archives if the
+// extractor has no maximum size, as a small archive can expand to an arbitrary size
+const maxUncompressedSize = 100 * 1024 * 1024
+
+// extraction funcs by mime type, the limit is the maximum size of the files extracted from archives
+var basicExtractors = map[string]func(data []byte, limit uint64) (string, error){
+	"text/plain":      extractText,
+	"text/markdown":   extractText,
+	"text/x-markdown": extractText,
+	"text/csv":        extractText,
+
+	"text/html":             extractHTML,
+	"application/xhtml+xml": extractHTML,
+
+	"application/vnd.oasis.opendocument.text":         extractODF,
+	"application/vnd.oasis.opendocument.spreadsheet":  extractODF,
+	"application/vnd.oasis.opendocument.presentation": extractODF,
+
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   extractOOXML("word/document.xml"),
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         extractOOXML("xl/sharedStrings.xml"),
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": extractOOXML("ppt/slides/slide*.xml"),
+}
+
+// Basic is an Extractor which extracts the text of common document formats without relying on external tools
+type Basic struct {
+	retriever Retriever
+	maxSize   uint64
+}
+
+// NewBasicExtractor returns a new Basic extractor. Files bigger than maxSize bytes are skipped, 0 means no limit.
+// The xml files extracted from ODF and OOXML files must not be bigger than maxSize in total either.
+func NewBasicExtractor(retriever Retriever, maxSize uint64) *Basic {
+	return &Basic{
+		retriever: retriever,
+		maxSize:   maxSize,
+	}
+}
+
+// Extract returns the plain text content of plain text, markdown, html, ODF and OOXML files
+func (b *Basic) Extract(ctx context.Context, ri *provider.ResourceInfo) (string, error) {
+	if ri.Type != provider.ResourceType_RESOURCE_TYPE_FILE || (b.maxSize > 0 && ri.Size > b.maxSize) {
+		return "", nil
+	}
+	extract, ok := basicExtractors[strings.ToLower(strings.SplitN(ri.MimeType, ";", 2)[0])]
+	if !ok {
+		return "", nil
+	}
+
+	rc, err := b.retriever.Retrieve(ctx, ri.Id)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	r := io.Reader(rc)
+	if b.maxSize > 0 {
+		r = io.LimitReader(rc, int64(b.maxSize))
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	limit := b.maxSize
+	if limit == 0 {
+		limit = maxUncompressedSize
+	}
+	content, err := extract(data, limit)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(content), nil
+}
+
+func extractText(data []byte, _ uint64) (string, error) {
+	return strings.ToValidUTF8(string(data), ""), nil
+}
+
+func extractHTML(data []byte, _ uint64) (string, error) {
+	var (
+		b    strings.Builder
+		skip bool
+	)
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return b.String(), nil
+			}
+			return "", z.Err()
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			skip = string(name) == "script" || string(name) == "style"
+		case html.EndTagToken:
+			skip = false
+			b.WriteString(" ")
+		case html.TextToken:
+			if !skip {
+				b.Write(z.Text())
+			}
+		}
+	}
+}
+
+func extractODF(data []byte, limit uint64) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	return extractZippedXML(zr, "content.xml", limit)
+}
+
+func extractOOXML(pattern string) func(data []byte, limit uint64) (string, error) {
+	return func(data []byte, limit uint64) (string, error) {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return "", err
+		}
+		return extractZippedXML(zr, pattern, limit)
+	}
+}
+
+// extractZippedXML returns the text of all xml files in the archive matching the given pattern. Archives
+// whose matching files are bigger than limit bytes in total are rejected.
+func extractZippedXML(zr *zip.Reader, pattern string, limit uint64) (string, error) {
+	var (
+		files = []*zip.File{}
+		size  uint64
+	)
+	for _, f := range zr.File {
+		if ok, _ := path.Match(pattern, f.Name); ok {
+			files = append(files, f)
+			size += f.UncompressedSize64
+		}
+	}
+	if size > limit {
+		return "", fmt.Errorf("the uncompressed content of the archive exceeds %d bytes", limit)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		// make sure slide10.xml is sorted after slide9.xml
+		if len(files[i].Name) != len(files[j].Name) {
+			return len(files[i].Name) < len(files[j].Name)
+		}
+		return files[i].Name < files[j].Name
+	})
+
+	var b strings.Builder
+	for _, f := range files {
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		// the reader fails if a file is bigger than its header claims, the limit is only a safeguard
+		err = extractXMLText(io.LimitReader(rc, int64(limit)), &b)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// extractXMLText writes the character data of the given xml document to the builder. The
+// paragraph elements of the ODF and OOXML formats are separated by a line break.
+func extractXMLText(r io.Reader, b *strings.Builder) error {
+	d := xml.NewDecoder(r)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch e := t.(type) {
+		case xml.CharData:
+			b.Write(e)
+		case xml.StartElement:
+			switch e.Name.Local {
+			case "tab", "br", "s":
+				b.WriteString(" ")
+			}
+		case xml.EndElement:
+			switch e.Name.Local {
+			case "p", "h", "si", "table-cell":
+				b.WriteString("\n")
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package content_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/owncloud/ocis/v2/services/search/pkg/content"
+	"github.com/owncloud/ocis/v2/services/search/pkg/content/mocks"
+)
+
+var _ = Describe("Basic", func() {
+	var (
+		extractor *content.Basic
+		retriever *mocks.Retriever
+		ctx       context.Context
+
+		ri *sprovider.ResourceInfo
+
+		zipped = func(files map[string]string) []byte {
+			buf := &bytes.Buffer{}
+			w := zip.NewWriter(buf)
+			for name, body := range files {
+				f, err := w.Create(name)
+				ExpectWithOffset(1, err).ToNot(HaveOccurred())
+				_, err = f.Write([]byte(body))
+				ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			}
+			ExpectWithOffset(1, w.Close()).To(Succeed())
+			return buf.Bytes()
+		}
+		returnsContent = func(data []byte) {
+			ri.Size = uint64(len(data))
+			retriever.On("Retrieve", mock.Anything, mock.Anything).Return(func(context.Context, *sprovider.ResourceId) io.ReadCloser {
+				return io.NopCloser(bytes.NewReader(data))
+			}, nil)
+		}
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		retriever = &mocks.Retriever{}
+		extractor = content.NewBasicExtractor(retriever, 1024)
+		ri = &sprovider.ResourceInfo{
+			Id:   &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "opaqueid"},
+			Type: sprovider.ResourceType_RESOURCE_TYPE_FILE,
+		}
+	})
+
+	It("extracts plain text and markdown files", func() {
+		returnsContent([]byte("# Quarterly report\n\nThe budget was exceeded."))
+		for _, mimeType := range []string{"text/plain", "text/markdown", "text/plain; charset=utf-8"} {
+			ri.MimeType = mimeType
+			c, err := extractor.Extract(ctx, ri)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c).To(ContainSubstring("The budget was exceeded."))
+		}
+	})
+
+	It("strips the markup from html files", func() {
+		ri.MimeType = "text/html"
+		returnsContent([]byte(`<html><head><style>p { color: red }</style></head><body><p>Hello <b>world</b></p><script>alert(1)</script></body></html>`))
+
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(ContainSubstring("Hello world"))
+		Expect(c).ToNot(ContainSubstring("color"))
+		Expect(c).ToNot(ContainSubstring("alert"))
+	})
+
+	It("extracts the text of ODF documents", func() {
+		ri.MimeType = "application/vnd.oasis.opendocument.text"
+		returnsContent(zipped(map[string]string{
+			"mimetype":    "application/vnd.oasis.opendocument.text",
+			"content.xml": `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"><office:body><office:text><text:h>Minutes</text:h><text:p>Hello <text:span>world</text:span></text:p></office:text></office:body></office:document-content>`,
+		}))
+
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(Equal("Minutes\nHello world"))
+	})
+
+	It("extracts the text of OOXML documents", func() {
+		ri.MimeType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		returnsContent(zipped(map[string]string{
+			"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>Hel</w:t></w:r><w:r><w:t>lo</w:t></w:r></w:p><w:p><w:r><w:t>world</w:t></w:r></w:p></w:body></w:document>`,
+		}))
+
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(Equal("Hello\nworld"))
+	})
+
+	It("extracts the slides of OOXML presentations in order", func() {
+		ri.MimeType = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+		slide := func(text string) string {
+			return `<p:sld xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:p><a:r><a:t>` + text + `</a:t></a:r></a:p></p:sld>`
+		}
+		returnsContent(zipped(map[string]string{
+			"ppt/slides/slide10.xml": slide("last"),
+			"ppt/slides/slide2.xml":  slide("second"),
+			"ppt/slides/slide1.xml":  slide("first"),
+		}))
+
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(Equal("first\nsecond\nlast"))
+	})
+
+	It("rejects archives expanding beyond the maximum size", func() {
+		ri.MimeType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		data := zipped(map[string]string{
+			"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>` + strings.Repeat("a", 4096) + `</w:t></w:r></w:p></w:body></w:document>`,
+		})
+		Expect(len(data)).To(BeNumerically("<", 1024))
+		returnsContent(data)
+
+		_, err := extractor.Extract(ctx, ri)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("ignores unsupported mime types", func() {
+		ri.MimeType = "image/png"
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(BeEmpty())
+		retriever.AssertNotCalled(GinkgoT(), "Retrieve", mock.Anything, mock.Anything)
+	})
+
+	It("ignores containers", func() {
+		ri.Type = sprovider.ResourceType_RESOURCE_TYPE_CONTAINER
+		ri.MimeType = "httpd/unix-directory"
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(BeEmpty())
+		retriever.AssertNotCalled(GinkgoT(), "Retrieve", mock.Anything, mock.Anything)
+	})
+
+	It("skips files exceeding the maximum size", func() {
+		ri.MimeType = "text/plain"
+		ri.Size = 2048
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(BeEmpty())
+		retriever.AssertNotCalled(GinkgoT(), "Retrieve", mock.Anything, mock.Anything)
+	})
+
+	It("returns retrieval errors", func() {
+		ri.MimeType = "text/plain"
+		retriever.On("Retrieve", mock.Anything, mock.Anything).Return(nil, errors.New("download failed"))
+		_, err := extractor.Extract(ctx, ri)
+		Expect(err).To(HaveOccurred())
+	})
+})
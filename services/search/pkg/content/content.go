@@ -0,0 +1,25 @@
+package content
+
+import (
+	"context"
+	"io"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+)
+
+//go:generate mockery --name=Retriever
+//go:generate mockery --name=Extractor
+
+// Retriever is the interface to the source the file contents are read from
+type Retriever interface {
+	// Retrieve returns the contents of the given resource.
+	// The caller MUST make sure to close the returned ReadCloser
+	Retrieve(ctx context.Context, id *provider.ResourceId) (io.ReadCloser, error)
+}
+
+// Extractor is the interface to the plain text extraction of file contents
+type Extractor interface {
+	// Extract returns the plain text content of the given resource. Resources
+	// which are not supported by the extractor result in an empty string.
+	Extract(ctx context.Context, ri *provider.ResourceInfo) (string, error)
+}
@@ -0,0 +1,95 @@
+package content
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	revactx "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/cs3org/reva/v2/pkg/rhttp"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// "github.com/cs3org/reva/v2/internal/http/services/datagateway" is internal so we redeclare it here
+	// TokenTransportHeader holds the header key for the reva transfer token
+	TokenTransportHeader = "X-Reva-Transfer"
+)
+
+// CS3 downloads the file contents from the cs3 data gateway
+type CS3 struct {
+	gwClient gateway.GatewayAPIClient
+	client   *http.Client
+}
+
+// NewCS3Retriever returns a new Retriever which downloads the files using the given gateway client
+func NewCS3Retriever(gwClient gateway.GatewayAPIClient, insecure bool) CS3 {
+	return CS3{
+		gwClient: gwClient,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					MinVersion:         tls.VersionTLS12,
+					InsecureSkipVerify: insecure, //nolint:gosec
+				},
+			},
+		},
+	}
+}
+
+// Retrieve downloads the file from the cs3 data gateway. The context has to carry the
+// reva token in its outgoing metadata.
+// The caller MUST make sure to close the returned ReadCloser
+func (s CS3) Retrieve(ctx context.Context, id *provider.ResourceId) (io.ReadCloser, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	tokens := md.Get(revactx.TokenHeader)
+	if len(tokens) == 0 {
+		return nil, errors.New("cs3retriever: token missing")
+	}
+
+	rsp, err := s.gwClient.InitiateFileDownload(ctx, &provider.InitiateFileDownloadRequest{
+		Ref: &provider.Reference{ResourceId: id, Path: "."},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Status.Code != rpc.Code_CODE_OK {
+		return nil, fmt.Errorf("could not initiate the download: %s", rsp.Status.Message)
+	}
+
+	var ep, tk string
+	for _, p := range rsp.Protocols {
+		if p.Protocol == "spaces" {
+			ep, tk = p.DownloadEndpoint, p.Token
+			break
+		}
+	}
+	if (ep == "" || tk == "") && len(rsp.Protocols) > 0 {
+		ep, tk = rsp.Protocols[0].DownloadEndpoint, rsp.Protocols[0].Token
+	}
+
+	httpReq, err := rhttp.NewRequest(ctx, http.MethodGet, ep, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set(revactx.TokenHeader, tokens[0])
+	httpReq.Header.Set(TokenTransportHeader, tk)
+
+	resp, err := s.client.Do(httpReq) // nolint:bodyclose
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("could not download the file. Request returned with statuscode %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,78 @@
+package content
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+)
+
+// tikaTimeout is the time tika has to extract the text of a file
+const tikaTimeout = 5 * time.Minute
+
+// Tika is an Extractor which uses an Apache Tika server to extract the text of the files
+type Tika struct {
+	retriever Retriever
+	url       string
+	maxSize   uint64
+	client    *http.Client
+}
+
+// NewTikaExtractor returns a new Tika extractor sending the files to the tika server at the given url.
+// Files bigger than maxSize bytes are skipped, 0 means no limit.
+func NewTikaExtractor(retriever Retriever, url string, maxSize uint64) *Tika {
+	return &Tika{
+		retriever: retriever,
+		url:       strings.TrimSuffix(url, "/"),
+		maxSize:   maxSize,
+		client:    &http.Client{Timeout: tikaTimeout},
+	}
+}
+
+// Extract sends the file to the tika server and returns the plain text content it responds with
+func (t *Tika) Extract(ctx context.Context, ri *provider.ResourceInfo) (string, error) {
+	if ri.Type != provider.ResourceType_RESOURCE_TYPE_FILE || ri.Size == 0 || (t.maxSize > 0 && ri.Size > t.maxSize) {
+		return "", nil
+	}
+
+	rc, err := t.retriever.Retrieve(ctx, ri.Id)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.url+"/tika", rc)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(ri.Size)
+	req.Header.Set("Accept", "text/plain")
+	if ri.MimeType != "" {
+		req.Header.Set("Content-Type", ri.MimeType)
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+		// tika could not find any text or does not know the file type
+		return "", nil
+	default:
+		return "", fmt.Errorf("tika returned with statuscode %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(string(data), "")), nil
+}
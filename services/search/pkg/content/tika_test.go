@@ -0,0 +1,90 @@
+package content_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/owncloud/ocis/v2/services/search/pkg/content"
+	"github.com/owncloud/ocis/v2/services/search/pkg/content/mocks"
+)
+
+var _ = Describe("Tika", func() {
+	var (
+		extractor *content.Tika
+		retriever *mocks.Retriever
+		tika      *httptest.Server
+		ctx       context.Context
+
+		ri *sprovider.ResourceInfo
+
+		received []byte
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		received = nil
+		tika = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPut || r.URL.Path != "/tika" || r.Header.Get("Accept") != "text/plain" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			received, _ = io.ReadAll(r.Body)
+			if r.Header.Get("Content-Type") == "application/octet-stream" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			_, _ = w.Write([]byte("\nextracted text\n"))
+		}))
+
+		retriever = &mocks.Retriever{}
+		retriever.On("Retrieve", mock.Anything, mock.Anything).Return(io.NopCloser(bytes.NewReader([]byte("%PDF-1.4"))), nil)
+		extractor = content.NewTikaExtractor(retriever, tika.URL+"/", 0)
+
+		ri = &sprovider.ResourceInfo{
+			Id:       &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "opaqueid"},
+			Type:     sprovider.ResourceType_RESOURCE_TYPE_FILE,
+			MimeType: "application/pdf",
+			Size:     8,
+		}
+	})
+
+	AfterEach(func() {
+		tika.Close()
+	})
+
+	It("sends the file to tika and returns the extracted text", func() {
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(Equal("extracted text"))
+		Expect(string(received)).To(Equal("%PDF-1.4"))
+	})
+
+	It("returns an empty string for files tika can not process", func() {
+		ri.MimeType = "application/octet-stream"
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(BeEmpty())
+	})
+
+	It("ignores containers", func() {
+		ri.Type = sprovider.ResourceType_RESOURCE_TYPE_CONTAINER
+		c, err := extractor.Extract(ctx, ri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c).To(BeEmpty())
+		Expect(received).To(BeNil())
+	})
+
+	It("fails when tika is not reachable", func() {
+		tika.Close()
+		_, err := extractor.Extract(ctx, ri)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -12,8 +12,11 @@ var (
 
 // Metrics defines the available metrics of this service.
 type Metrics struct {
-	// Counter  *prometheus.CounterVec
-	BuildInfo *prometheus.GaugeVec
+	BuildInfo          *prometheus.GaugeVec
+	EventLag           prometheus.Gauge
+	EventsProcessed    *prometheus.CounterVec
+	EventFailures      *prometheus.CounterVec
+	EventsDeadLettered *prometheus.CounterVec
 }
 
 // New initializes the available metrics.
@@ -25,9 +28,51 @@ func New() *Metrics {
 			Name:      "build_info",
 			Help:      "Build information",
 		}, []string{"version"}),
+		EventLag: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "event_lag_seconds",
+			Help:      "Time in seconds between the publication of the last received event and the start of its processing",
+		}),
+		EventsProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "events_processed_total",
+			Help:      "How many events have been processed successfully",
+		}, []string{"type"}),
+		EventFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "event_failures_total",
+			Help:      "How many attempts of processing an event failed",
+		}, []string{"type"}),
+		EventsDeadLettered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "events_dead_lettered_total",
+			Help:      "How many events have been given up after all attempts failed",
+		}, []string{"type"}),
 	}
 
-	_ = prometheus.Register(m.BuildInfo)
-	// TODO: implement metrics
+	_ = prometheus.Register(
+		m.BuildInfo,
+	)
+
+	_ = prometheus.Register(
+		m.EventLag,
+	)
+
+	_ = prometheus.Register(
+		m.EventsProcessed,
+	)
+
+	_ = prometheus.Register(
+		m.EventFailures,
+	)
+
+	_ = prometheus.Register(
+		m.EventsDeadLettered,
+	)
+
 	return m
 }
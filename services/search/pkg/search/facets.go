@@ -0,0 +1,56 @@
+package search
+
+import (
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+)
+
+// The facets which can be requested along with the search results
+const (
+	// FacetMimeType counts the matches per group of mime types, e.g. document or image
+	FacetMimeType = "mimetype"
+	// FacetSize counts the matches per size bucket
+	FacetSize = "size"
+	// FacetMtime counts the matches per modification time range, e.g. today
+	FacetMtime = "mtime"
+	// FacetSpace counts the matches per space
+	FacetSpace = "space"
+)
+
+// IsValidFacet returns true if the given facet is supported
+func IsValidFacet(name string) bool {
+	switch name {
+	case FacetMimeType, FacetSize, FacetMtime, FacetSpace:
+		return true
+	}
+	return false
+}
+
+// MergeFacets adds the counts of the src facets to the dst facets and returns the result.
+// The order of the facets and their terms is kept, unknown terms are appended.
+func MergeFacets(dst, src []*searchmsg.Facet) []*searchmsg.Facet {
+	for _, sf := range src {
+		var df *searchmsg.Facet
+		for _, f := range dst {
+			if f.Name == sf.Name {
+				df = f
+				break
+			}
+		}
+		if df == nil {
+			df = &searchmsg.Facet{Name: sf.Name}
+			dst = append(dst, df)
+		}
+
+	TERMS:
+		for _, st := range sf.Terms {
+			for _, dt := range df.Terms {
+				if dt.Term == st.Term {
+					dt.Count += st.Count
+					continue TERMS
+				}
+			}
+			df.Terms = append(df.Terms, &searchmsg.FacetTerm{Term: st.Term, Count: st.Count})
+		}
+	}
+	return dst
+}
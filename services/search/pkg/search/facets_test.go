@@ -0,0 +1,50 @@
+package search_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
+)
+
+var _ = Describe("Facets", func() {
+	var (
+		facet = func(name string, terms ...interface{}) *searchmsg.Facet {
+			f := &searchmsg.Facet{Name: name}
+			for i := 0; i < len(terms); i += 2 {
+				f.Terms = append(f.Terms, &searchmsg.FacetTerm{Term: terms[i].(string), Count: int32(terms[i+1].(int))})
+			}
+			return f
+		}
+	)
+
+	It("validates the facet names", func() {
+		Expect(search.IsValidFacet(search.FacetMimeType)).To(BeTrue())
+		Expect(search.IsValidFacet(search.FacetSpace)).To(BeTrue())
+		Expect(search.IsValidFacet("color")).To(BeFalse())
+	})
+
+	It("sums up the counts of the same terms", func() {
+		merged := search.MergeFacets(nil, []*searchmsg.Facet{facet("size", "small", 1, "large", 2)})
+		merged = search.MergeFacets(merged, []*searchmsg.Facet{facet("size", "small", 3, "large", 0)})
+		Expect(merged).To(HaveLen(1))
+		Expect(merged[0].Terms).To(HaveLen(2))
+		Expect(merged[0].Terms[0].Term).To(Equal("small"))
+		Expect(merged[0].Terms[0].Count).To(Equal(int32(4)))
+		Expect(merged[0].Terms[1].Term).To(Equal("large"))
+		Expect(merged[0].Terms[1].Count).To(Equal(int32(2)))
+	})
+
+	It("appends unknown facets and terms", func() {
+		merged := search.MergeFacets([]*searchmsg.Facet{facet("size", "small", 1)}, []*searchmsg.Facet{
+			facet("size", "large", 2),
+			facet("mimetype", "pdf", 3),
+		})
+		Expect(merged).To(HaveLen(2))
+		Expect(merged[0].Terms).To(HaveLen(2))
+		Expect(merged[0].Terms[1].Term).To(Equal("large"))
+		Expect(merged[1].Name).To(Equal("mimetype"))
+		Expect(merged[1].Terms[0].Count).To(Equal(int32(3)))
+	})
+})
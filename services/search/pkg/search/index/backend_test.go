@@ -0,0 +1,113 @@
+package index_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search/index"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// backendSpecs describes the behaviour shared by all index backends
+func backendSpecs(newBackend func() search.IndexClient) {
+	var (
+		backend search.IndexClient
+
+		rootID    = &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: "spaceid"}
+		parentRef = &sprovider.Reference{ResourceId: rootID, Path: "./docs"}
+		parentRi  = &sprovider.ResourceInfo{
+			Id:       &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: "parentid"},
+			ParentId: rootID,
+			Name:     "docs",
+			Type:     sprovider.ResourceType_RESOURCE_TYPE_CONTAINER,
+			Mtime:    &typesv1beta1.Timestamp{Seconds: 4000},
+		}
+		childRef = &sprovider.Reference{ResourceId: rootID, Path: "./docs/child.pdf"}
+		childRi  = &sprovider.ResourceInfo{
+			Id:       &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: "childid"},
+			ParentId: parentRi.Id,
+			Name:     "child.pdf",
+			Type:     sprovider.ResourceType_RESOURCE_TYPE_FILE,
+			Mtime:    &typesv1beta1.Timestamp{Seconds: 4000},
+		}
+
+		isDeleted = func(id *sprovider.ResourceId) bool {
+			e, err := backend.Get(id)
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			return e.Deleted
+		}
+	)
+
+	BeforeEach(func() {
+		backend = newBackend()
+		Expect(backend.Add(parentRef, parentRi, "")).To(Succeed())
+		Expect(backend.Add(childRef, childRi, "")).To(Succeed())
+	})
+
+	Describe("Restore", func() {
+		It("restores the children deleted along with the resource", func() {
+			Expect(backend.Delete(parentRi.Id)).To(Succeed())
+			Expect(isDeleted(childRi.Id)).To(BeTrue())
+
+			Expect(backend.Restore(parentRi.Id)).To(Succeed())
+			Expect(isDeleted(parentRi.Id)).To(BeFalse())
+			Expect(isDeleted(childRi.Id)).To(BeFalse())
+		})
+
+		It("does not restore the children deleted on their own", func() {
+			Expect(backend.Delete(childRi.Id)).To(Succeed())
+			Expect(backend.Delete(parentRi.Id)).To(Succeed())
+
+			Expect(backend.Restore(parentRi.Id)).To(Succeed())
+			Expect(isDeleted(parentRi.Id)).To(BeFalse())
+			Expect(isDeleted(childRi.Id)).To(BeTrue())
+		})
+	})
+}
+
+var _ = Describe("Backends", func() {
+	Context("bleve", func() {
+		backendSpecs(func() search.IndexClient {
+			i, err := index.NewMemOnly()
+			Expect(err).ToNot(HaveOccurred())
+			return i
+		})
+	})
+
+	// the OpenSearch backend is only tested against a server given in SEARCH_TEST_OPENSEARCH_URL, the
+	// credentials are taken from SEARCH_TEST_OPENSEARCH_USERNAME and SEARCH_TEST_OPENSEARCH_PASSWORD
+	Context("opensearch", func() {
+		url := os.Getenv("SEARCH_TEST_OPENSEARCH_URL")
+		username := os.Getenv("SEARCH_TEST_OPENSEARCH_USERNAME")
+		password := os.Getenv("SEARCH_TEST_OPENSEARCH_PASSWORD")
+
+		BeforeEach(func() {
+			if url == "" {
+				Skip("SEARCH_TEST_OPENSEARCH_URL is not set")
+			}
+		})
+
+		backendSpecs(func() search.IndexClient {
+			name := fmt.Sprintf("ocis-test-%d", time.Now().UnixNano())
+			o, err := index.NewOpenSearch(url, name, username, password, true)
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(func() {
+				req, err := http.NewRequest(http.MethodDelete, strings.TrimSuffix(url, "/")+"/"+name, nil)
+				Expect(err).ToNot(HaveOccurred())
+				req.SetBasicAuth(username, password)
+				res, err := http.DefaultClient.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				res.Body.Close()
+			})
+			return o
+		})
+	})
+})
@@ -0,0 +1,98 @@
+package index
+
+import (
+	bleve "github.com/blevesearch/bleve/v2"
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+
+	searchpkg "github.com/owncloud/ocis/v2/services/search/pkg/search"
+)
+
+// Batch collects additions and purges and applies them to the bleve indexes of the spaces at once
+type Batch struct {
+	index *Index
+	// batches holds a bleve batch for the index of every space the operations affect. The indexes are
+	// only held while operations are added, so that they can be closed in between.
+	batches    map[string]*bleve.Batch
+	operations int
+	size       int
+}
+
+// NewBatch returns a new Batch which is pushed to the index whenever it holds the given number of operations
+func (i *Index) NewBatch(size int) (searchpkg.BatchOperator, error) {
+	if size <= 0 {
+		size = 1
+	}
+	return &Batch{
+		index:   i,
+		batches: map[string]*bleve.Batch{},
+		size:    size,
+	}, nil
+}
+
+// Add adds a new entity with the given extracted content to the batch
+func (b *Batch) Add(ref *sprovider.Reference, ri *sprovider.ResourceInfo, content string) error {
+	s, err := b.index.createSpace(ri.GetId())
+	if err != nil {
+		return err
+	}
+	entity := toEntity(ref, ri)
+	entity.Content = content
+	err = b.batch(s).Index(entity.ID, entity)
+	b.index.release(s)
+	if err != nil {
+		return err
+	}
+	return b.pushIfFull()
+}
+
+// Purge adds the removal of an entity to the batch
+func (b *Batch) Purge(id *sprovider.ResourceId) error {
+	s, err := b.index.space(id)
+	if err != nil || s == nil {
+		return err
+	}
+	b.batch(s).Delete(idToBleveId(id))
+	b.index.release(s)
+	return b.pushIfFull()
+}
+
+// Push applies the collected operations to the indexes of the spaces. The operations of spaces which
+// have been purged in the meantime are dropped.
+func (b *Batch) Push() error {
+	var err error
+	for sid, batch := range b.batches {
+		if batchErr := b.push(sid, batch); batchErr != nil && err == nil {
+			err = batchErr
+		}
+	}
+	b.batches = map[string]*bleve.Batch{}
+	b.operations = 0
+	return err
+}
+
+func (b *Batch) push(sid string, batch *bleve.Batch) error {
+	s, err := b.index.acquire(sid, false)
+	if err != nil || s == nil {
+		return err
+	}
+	defer b.index.release(s)
+	return s.index.Batch(batch)
+}
+
+// batch returns the bleve batch of the given index
+func (b *Batch) batch(s *spaceIndex) *bleve.Batch {
+	batch, ok := b.batches[s.id]
+	if !ok {
+		batch = s.index.NewBatch()
+		b.batches[s.id] = batch
+	}
+	b.operations++
+	return batch
+}
+
+func (b *Batch) pushIfFull() error {
+	if b.operations < b.size {
+		return nil
+	}
+	return b.Push()
+}
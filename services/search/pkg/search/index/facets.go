@@ -0,0 +1,189 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
+
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	searchpkg "github.com/owncloud/ocis/v2/services/search/pkg/search"
+)
+
+// mimeTypeGroupOther is the group of all mime types not belonging to any of the mimeTypeGroups
+const mimeTypeGroupOther = "other"
+
+// mimeTypeGroups maps the mime types to the groups returned by the mimetype facet. A mime type belongs
+// to the group with the longest matching prefix.
+var mimeTypeGroups = []struct {
+	name     string
+	prefixes []string
+}{
+	{"folder", []string{"httpd/unix-directory"}},
+	{"document", []string{
+		"text/",
+		"application/msword",
+		"application/rtf",
+		"application/vnd.oasis.opendocument.text",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml",
+	}},
+	{"spreadsheet", []string{
+		"text/csv",
+		"application/vnd.ms-excel",
+		"application/vnd.oasis.opendocument.spreadsheet",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml",
+	}},
+	{"presentation", []string{
+		"application/vnd.ms-powerpoint",
+		"application/vnd.oasis.opendocument.presentation",
+		"application/vnd.openxmlformats-officedocument.presentationml",
+	}},
+	{"pdf", []string{"application/pdf"}},
+	{"image", []string{"image/"}},
+	{"video", []string{"video/"}},
+	{"audio", []string{"audio/"}},
+	{"archive", []string{
+		"application/zip",
+		"application/gzip",
+		"application/x-tar",
+		"application/x-7z-compressed",
+		"application/x-rar-compressed",
+	}},
+}
+
+// mimeTypeGroup returns the name of the group the given mime type belongs to
+func mimeTypeGroup(mimeType string) string {
+	group, length := mimeTypeGroupOther, 0
+	for _, g := range mimeTypeGroups {
+		for _, p := range g.prefixes {
+			if len(p) > length && strings.HasPrefix(mimeType, p) {
+				group, length = g.name, len(p)
+			}
+		}
+	}
+	return group
+}
+
+const (
+	kb = 1000
+	mb = 1000 * kb
+	gb = 1000 * mb
+)
+
+// sizeRanges are the buckets of the size facet, min is inclusive, max exclusive
+var sizeRanges = []struct {
+	name     string
+	min, max float64
+}{
+	{"0-100KB", 0, 100 * kb},
+	{"100KB-1MB", 100 * kb, mb},
+	{"1MB-10MB", mb, 10 * mb},
+	{"10MB-100MB", 10 * mb, 100 * mb},
+	{"100MB-1GB", 100 * mb, gb},
+	{"1GB+", gb, 0},
+}
+
+type mtimeRange struct {
+	name       string
+	start, end time.Time
+}
+
+// mtimeRanges returns the ranges of the mtime facet relative to the given point in time. The ranges
+// are overlapping, a zero start or end means the range is unbounded.
+func mtimeRanges(now time.Time) []mtimeRange {
+	year, month, day := now.Date()
+	lastYear := now.AddDate(-1, 0, 0)
+	return []mtimeRange{
+		{"today", time.Date(year, month, day, 0, 0, 0, 0, now.Location()), time.Time{}},
+		{"last 7 days", now.AddDate(0, 0, -7), time.Time{}},
+		{"last 30 days", now.AddDate(0, 0, -30), time.Time{}},
+		{"last year", lastYear, time.Time{}},
+		{"older", time.Time{}, lastYear},
+	}
+}
+
+// maxFacetTerms limits the number of terms bleve counts for the term based facets
+const maxFacetTerms = 1000
+
+// addFacetRequests adds the requests for the given facets to the bleve search request
+func addFacetRequests(bleveReq *bleve.SearchRequest, facets []string, now time.Time) error {
+	for _, name := range facets {
+		switch name {
+		case searchpkg.FacetMimeType:
+			bleveReq.AddFacet(name, bleve.NewFacetRequest("MimeType", maxFacetTerms))
+		case searchpkg.FacetSize:
+			fr := bleve.NewFacetRequest("Size", len(sizeRanges))
+			for _, r := range sizeRanges {
+				min, max := r.min, r.max
+				if max == 0 {
+					fr.AddNumericRange(r.name, &min, nil)
+				} else {
+					fr.AddNumericRange(r.name, &min, &max)
+				}
+			}
+			bleveReq.AddFacet(name, fr)
+		case searchpkg.FacetMtime:
+			ranges := mtimeRanges(now)
+			fr := bleve.NewFacetRequest("Mtime", len(ranges))
+			for _, r := range ranges {
+				fr.AddDateTimeRange(r.name, r.start, r.end)
+			}
+			bleveReq.AddFacet(name, fr)
+		case searchpkg.FacetSpace:
+			bleveReq.AddFacet(name, bleve.NewFacetRequest("RootID", maxFacetTerms))
+		default:
+			return errtypes.BadRequest(fmt.Sprintf("unknown facet '%s'", name))
+		}
+	}
+	return nil
+}
+
+// fromFacetResults converts the bleve facet results of the given facets. The terms of the mimetype,
+// size and mtime facets are always returned in the same order, including the ones without matches, so
+// that the facets of several indexes can be merged.
+func fromFacetResults(facets []string, results search.FacetResults) []*searchmsg.Facet {
+	converted := make([]*searchmsg.Facet, 0, len(facets))
+	for _, name := range facets {
+		res, ok := results[name]
+		if !ok {
+			continue
+		}
+		facet := &searchmsg.Facet{Name: name}
+		switch name {
+		case searchpkg.FacetMimeType:
+			counts := map[string]int{mimeTypeGroupOther: res.Other + res.Missing}
+			for _, t := range res.Terms.Terms() {
+				counts[mimeTypeGroup(t.Term)] += t.Count
+			}
+			for _, g := range mimeTypeGroups {
+				facet.Terms = append(facet.Terms, &searchmsg.FacetTerm{Term: g.name, Count: int32(counts[g.name])})
+			}
+			facet.Terms = append(facet.Terms, &searchmsg.FacetTerm{Term: mimeTypeGroupOther, Count: int32(counts[mimeTypeGroupOther])})
+		case searchpkg.FacetSize:
+			counts := map[string]int{}
+			for _, r := range res.NumericRanges {
+				counts[r.Name] = r.Count
+			}
+			for _, r := range sizeRanges {
+				facet.Terms = append(facet.Terms, &searchmsg.FacetTerm{Term: r.name, Count: int32(counts[r.name])})
+			}
+		case searchpkg.FacetMtime:
+			counts := map[string]int{}
+			for _, r := range res.DateRanges {
+				counts[r.Name] = r.Count
+			}
+			for _, r := range mtimeRanges(time.Time{}) {
+				facet.Terms = append(facet.Terms, &searchmsg.FacetTerm{Term: r.name, Count: int32(counts[r.name])})
+			}
+		default:
+			for _, t := range res.Terms.Terms() {
+				facet.Terms = append(facet.Terms, &searchmsg.FacetTerm{Term: t.Term, Count: int32(t.Count)})
+			}
+		}
+		converted = append(converted, facet)
+	}
+	return converted
+}
@@ -19,21 +19,32 @@
 package index
 
 import (
+	"container/list"
 	"context"
-	"errors"
+	"fmt"
 	"math"
+	"net/url"
+	"os"
 	"path"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	bleve "github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/v2/analysis/token/edgengram"
 	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	regexptokenizer "github.com/blevesearch/bleve/v2/analysis/tokenizer/regexp"
 	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
 	"github.com/blevesearch/bleve/v2/mapping"
 	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
 	"github.com/cs3org/reva/v2/pkg/storagespace"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
@@ -41,8 +52,22 @@ import (
 	"github.com/cs3org/reva/v2/pkg/utils"
 	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
 	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	searchpkg "github.com/owncloud/ocis/v2/services/search/pkg/search"
 )
 
+// MappingVersion is the version of the mapping built by BuildMapping. It has to be increased whenever
+// the mapping changes, so that the indexes built with a former mapping are rebuilt by Upgrade.
+const MappingVersion = 1
+
+// mappingVersionFile holds the version of the mapping the persisted indexes have been built with
+const mappingVersionFile = "mapping-version"
+
+// listPageSize is the number of documents read at once when listing the documents of a space
+const listPageSize = 1000
+
+// entityFields are the fields needed to build the entities returned by the index
+var entityFields = []string{"RootID", "Path", "ID", "ParentID", "Name", "Size", "Mtime", "MimeType", "Type", "Etag", "Deleted", "TrashKey", "Tags", "Space", "SpaceType", "Description"}
+
 type indexDocument struct {
 	RootID   string
 	Path     string
@@ -54,175 +79,815 @@ type indexDocument struct {
 	Mtime    string
 	MimeType string
 	Type     uint64
+	Etag     string
+	Content  string
+	Tags     []string
+
+	// Favorites holds the ids of the users who marked the resource as favorite
+	Favorites []string
+
+	Deleted   bool
+	DeletedAt string
+	TrashKey  string
+	Hidden    bool
 
-	Deleted bool
-	Hidden  bool
+	// Space is true for the documents describing spaces rather than resources. Their content consists
+	// of the description and the readme of the space.
+	Space       bool
+	SpaceType   string
+	Description string
 }
 
-// Index represents a bleve based search index
+// Index represents a bleve based search index. The resources of every space are kept in a bleve index
+// of their own, searches across spaces use an alias of the indexes of the spaces. That way searching a
+// space only scans the resources of the space and purging a space drops its index as a whole.
 type Index struct {
-	bleveIndex bleve.Index
+	// path is the directory holding the indexes of the spaces, they are kept in memory if it is empty
+	path    string
+	mapping mapping.IndexMapping
+	options Options
+
+	lock sync.Mutex
+	// released is signalled whenever an index has been released or dropped
+	released *sync.Cond
+	spaces   map[string]*spaceIndex
+	// recent holds the open indexes, the most recently used one first
+	recent *list.List
 }
 
-// NewPersisted returns a new instance of Index with the data being persisted in the given directory
-func NewPersisted(path string) (*Index, error) {
-	mapping, err := BuildMapping()
+// spaceIndex is the index of a single space. Persisted indexes are opened when they are used and
+// closed again when there are more open indexes than configured. An index is neither closed nor
+// dropped while it is in use.
+type spaceIndex struct {
+	id string
+	// index is nil while the index is closed
+	index bleve.Index
+	// refs is the number of users of the index
+	refs int
+	// purged is set once the space has been purged, the index is dropped as soon as it isn't used anymore
+	purged bool
+	// elem is the position of the open index in the list of recently used indexes
+	elem *list.Element
+}
+
+// NewPersisted returns a new instance of Index with the index of every space being persisted in a
+// subdirectory of the given directory. The indexes of the spaces are opened when they are used.
+func NewPersisted(path string, opts ...Option) (*Index, error) {
+	i, err := newIndex(path, opts...)
 	if err != nil {
 		return nil, err
 	}
-	bi, err := bleve.New(path, mapping)
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(path)
 	if err != nil {
 		return nil, err
 	}
-	return New(bi)
+	for _, e := range entries {
+		// indexes being rebuilt are kept in hidden directories
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		spaceID, err := url.PathUnescape(e.Name())
+		if err != nil {
+			continue
+		}
+		i.spaces[spaceID] = &spaceIndex{id: spaceID}
+	}
+	return i, nil
 }
 
-// New returns a new instance of Index using the given bleve Index as the backend
-func New(bleveIndex bleve.Index) (*Index, error) {
-	return &Index{
-		bleveIndex: bleveIndex,
-	}, nil
+// NewMemOnly returns a new instance of Index keeping the indexes of the spaces in memory
+func NewMemOnly(opts ...Option) (*Index, error) {
+	return newIndex("", opts...)
 }
 
-// DocCount returns the number of elemenst in the index
-func (i *Index) DocCount() (uint64, error) {
-	return i.bleveIndex.DocCount()
+func newIndex(path string, opts ...Option) (*Index, error) {
+	mapping, err := BuildMapping()
+	if err != nil {
+		return nil, err
+	}
+	i := &Index{
+		path:    path,
+		mapping: mapping,
+		options: newOptions(opts...),
+		spaces:  map[string]*spaceIndex{},
+		recent:  list.New(),
+	}
+	i.released = sync.NewCond(&i.lock)
+	return i, nil
 }
 
-// Add adds a new entity to the Index
-func (i *Index) Add(ref *sprovider.Reference, ri *sprovider.ResourceInfo) error {
-	entity := toEntity(ref, ri)
-	return i.bleveIndex.Index(idToBleveId(ri.Id), entity)
+// spaceID returns the id of the space the given resource belongs to
+func spaceID(id *sprovider.ResourceId) string {
+	return storagespace.FormatStorageID(id.GetStorageId(), id.GetSpaceId())
 }
 
-// Delete marks an entity from the index as deleten (still keeping it around)
-func (i *Index) Delete(id *sprovider.ResourceId) error {
-	return i.markAsDeleted(idToBleveId(id), true)
+// space returns the index of the space the given resource belongs to, nil if the space hasn't been
+// indexed. The index has to be released once it isn't used anymore.
+func (i *Index) space(id *sprovider.ResourceId) (*spaceIndex, error) {
+	return i.acquire(spaceID(id), false)
 }
 
-// Restore marks an entity from the index as not being deleted
-func (i *Index) Restore(id *sprovider.ResourceId) error {
-	return i.markAsDeleted(idToBleveId(id), false)
+// createSpace returns the index of the space the given resource belongs to. The index is created if
+// the space hasn't been indexed yet. The index has to be released once it isn't used anymore.
+func (i *Index) createSpace(id *sprovider.ResourceId) (*spaceIndex, error) {
+	return i.acquire(spaceID(id), true)
+}
+
+// allSpaces returns the indexes of all spaces. They have to be released once they aren't used anymore.
+func (i *Index) allSpaces() ([]*spaceIndex, error) {
+	i.lock.Lock()
+	ids := make([]string, 0, len(i.spaces))
+	for sid := range i.spaces {
+		ids = append(ids, sid)
+	}
+	i.lock.Unlock()
+
+	spaces := make([]*spaceIndex, 0, len(ids))
+	for _, sid := range ids {
+		s, err := i.acquire(sid, false)
+		if err != nil {
+			i.release(spaces...)
+			return nil, err
+		}
+		if s != nil {
+			spaces = append(spaces, s)
+		}
+	}
+	return spaces, nil
+}
+
+// acquire returns the index of the space with the given id and opens it if necessary. It returns nil
+// if the space hasn't been indexed and the index isn't supposed to be created.
+func (i *Index) acquire(sid string, create bool) (*spaceIndex, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	s, ok := i.spaces[sid]
+	for ok && s.purged && create {
+		// the index of the purged space has to be dropped before the space can be indexed again
+		i.released.Wait()
+		s, ok = i.spaces[sid]
+	}
+	if ok && s.purged || !ok && !create {
+		return nil, nil
+	}
+	if !ok {
+		s = &spaceIndex{id: sid}
+	}
+
+	if s.index == nil {
+		bleveIndex, err := i.open(sid)
+		if err != nil {
+			return nil, fmt.Errorf("could not open the index of space %s: %w", sid, err)
+		}
+		s.index = bleveIndex
+		s.elem = i.recent.PushFront(s)
+		i.spaces[sid] = s
+	} else {
+		i.recent.MoveToFront(s.elem)
+	}
+	s.refs++
+	i.evict()
+	return s, nil
 }
 
-func (i *Index) markAsDeleted(id string, deleted bool) error {
-	doc, err := i.updateEntity(id, func(doc *indexDocument) {
-		doc.Deleted = deleted
+// open opens the index of the space with the given id, it is created if it doesn't exist yet
+func (i *Index) open(sid string) (bleve.Index, error) {
+	if i.path == "" {
+		return bleve.NewMemOnly(i.mapping)
+	}
+	dir := filepath.Join(i.path, url.PathEscape(sid))
+	bleveIndex, err := bleve.Open(dir)
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		return bleve.New(dir, i.mapping)
+	}
+	return bleveIndex, err
+}
+
+// release releases the given indexes after they have been used
+func (i *Index) release(spaces ...*spaceIndex) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for _, s := range spaces {
+		s.refs--
+		if s.refs == 0 && s.purged {
+			// there is no one left to report the error to, the directory is reused when the space is
+			// indexed again
+			_ = i.drop(s)
+		}
+	}
+	i.evict()
+	i.released.Broadcast()
+}
+
+// evict closes the least recently used persisted indexes which aren't in use while there are more open
+// indexes than configured. The lock has to be held.
+func (i *Index) evict() {
+	if i.path == "" || i.options.MaxOpenSpaces <= 0 {
+		return
+	}
+	for e := i.recent.Back(); e != nil && i.recent.Len() > i.options.MaxOpenSpaces; {
+		s := e.Value.(*spaceIndex)
+		e = e.Prev()
+		if s.refs == 0 {
+			// a failure to close the index shows up when it is opened again
+			_ = i.close(s)
+		}
+	}
+}
+
+// close closes the index of the given space. The lock has to be held.
+func (i *Index) close(s *spaceIndex) error {
+	if s.index == nil {
+		return nil
+	}
+	i.recent.Remove(s.elem)
+	err := s.index.Close()
+	s.index, s.elem = nil, nil
+	return err
+}
+
+// drop closes the index of the given space and removes it. The lock has to be held.
+func (i *Index) drop(s *spaceIndex) error {
+	defer i.released.Broadcast()
+	if i.spaces[s.id] == s {
+		delete(i.spaces, s.id)
+	}
+	if err := i.close(s); err != nil {
+		return err
+	}
+	if i.path == "" {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(i.path, url.PathEscape(s.id)))
+}
+
+// Close closes the indexes of all spaces
+func (i *Index) Close() error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	var errs []string
+	for sid, s := range i.spaces {
+		if err := i.close(s); err != nil {
+			errs = append(errs, sid+": "+err.Error())
+		}
+		delete(i.spaces, sid)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not close the indexes of the spaces: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Migrate copies the documents of the index at the given path, which held the documents of all spaces
+// before every space got an index of its own, to the indexes of their spaces. Documents which have been
+// indexed in the index of their space already are kept. It returns the number of copied documents.
+func (i *Index) Migrate(legacyPath string) (int, error) {
+	legacy, err := bleve.Open(legacyPath)
+	if err != nil {
+		return 0, err
+	}
+	defer legacy.Close()
+
+	migrated := 0
+	err = readDocuments(legacy, func(page []*indexDocument) error {
+		docs := map[string][]*indexDocument{}
+		for _, doc := range page {
+			id, err := storagespace.ParseID(doc.ID)
+			if err != nil || doc.ID == "" {
+				continue
+			}
+			docs[spaceID(&id)] = append(docs[spaceID(&id)], doc)
+		}
+		for sid, spaceDocs := range docs {
+			n, err := i.migrateSpace(sid, spaceDocs)
+			migrated += n
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	return migrated, err
+}
+
+// Upgrade rebuilds the indexes of the spaces which have been built with a former version of the
+// mapping, see MappingVersion. The documents are rebuilt from their stored fields. It has to be called
+// before the index is used and returns the number of rebuilt indexes.
+func (i *Index) Upgrade() (int, error) {
+	if i.path == "" {
+		return 0, nil
+	}
+	versionFile := filepath.Join(i.path, mappingVersionFile)
+	if version, err := os.ReadFile(versionFile); err == nil && strings.TrimSpace(string(version)) == strconv.Itoa(MappingVersion) {
+		return 0, nil
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	rebuilt := 0
+	for sid, s := range i.spaces {
+		if err := i.close(s); err != nil {
+			return rebuilt, err
+		}
+		if err := i.rebuild(sid); err != nil {
+			return rebuilt, fmt.Errorf("could not rebuild the index of space %s: %w", sid, err)
+		}
+		rebuilt++
+	}
+	return rebuilt, os.WriteFile(versionFile, []byte(strconv.Itoa(MappingVersion)), 0600)
+}
+
+// rebuild replaces the index of the space with the given id by an index built with the current mapping
+func (i *Index) rebuild(sid string) error {
+	dir := filepath.Join(i.path, url.PathEscape(sid))
+	// the directory is ignored when opening the indexes, in case the rebuild is interrupted
+	tmpDir := filepath.Join(i.path, "."+url.PathEscape(sid))
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return err
+	}
+	outdated, err := bleve.Open(dir)
 	if err != nil {
 		return err
 	}
+	rebuilt, err := bleve.New(tmpDir, i.mapping)
+	if err != nil {
+		outdated.Close()
+		return err
+	}
 
-	if doc.Type == uint64(sprovider.ResourceType_RESOURCE_TYPE_CONTAINER) {
-		query := bleve.NewConjunctionQuery(
-			bleve.NewQueryStringQuery("RootID:"+doc.RootID),
-			bleve.NewQueryStringQuery("Path:"+queryEscape(doc.Path+"/*")),
-		)
-		bleveReq := bleve.NewSearchRequest(query)
-		bleveReq.Size = math.MaxInt
-		bleveReq.Fields = []string{"*"}
-		res, err := i.bleveIndex.Search(bleveReq)
+	err = readDocuments(outdated, func(docs []*indexDocument) error {
+		batch := rebuilt.NewBatch()
+		for _, doc := range docs {
+			if err := batch.Index(doc.ID, doc); err != nil {
+				return err
+			}
+		}
+		return rebuilt.Batch(batch)
+	})
+	if closeErr := outdated.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := rebuilt.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(tmpDir, dir)
+}
+
+// readDocuments calls fn with all documents of the given index, page by page
+func readDocuments(bleveIndex bleve.Index, fn func([]*indexDocument) error) error {
+	var searchAfter []string
+	for {
+		req := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+		req.Size = listPageSize
+		req.Fields = []string{"*"}
+		req.SortBy([]string{"_id"})
+		if searchAfter != nil {
+			req.SetSearchAfter(searchAfter)
+		}
+		res, err := bleveIndex.Search(req)
 		if err != nil {
 			return err
 		}
 
+		docs := make([]*indexDocument, 0, len(res.Hits))
 		for _, h := range res.Hits {
-			_, err := i.updateEntity(h.ID, func(doc *indexDocument) {
-				doc.Deleted = deleted
-			})
-			if err != nil {
-				return err
-			}
+			docs = append(docs, fieldsToEntity(h.Fields))
+		}
+		if err := fn(docs); err != nil {
+			return err
+		}
+		if len(res.Hits) < listPageSize {
+			return nil
+		}
+		searchAfter = []string{res.Hits[len(res.Hits)-1].ID}
+	}
+}
+
+// migrateSpace adds the given documents to the index of the space with the given id unless they have
+// been indexed already
+func (i *Index) migrateSpace(sid string, docs []*indexDocument) (int, error) {
+	s, err := i.acquire(sid, true)
+	if err != nil {
+		return 0, err
+	}
+	defer i.release(s)
+
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	req := bleve.NewSearchRequest(bleve.NewDocIDQuery(ids))
+	req.Size = len(ids)
+	res, err := s.index.Search(req)
+	if err != nil {
+		return 0, err
+	}
+	indexed := make(map[string]struct{}, len(res.Hits))
+	for _, h := range res.Hits {
+		indexed[h.ID] = struct{}{}
+	}
+
+	batch := s.index.NewBatch()
+	for _, doc := range docs {
+		if _, ok := indexed[doc.ID]; ok {
+			continue
+		}
+		if err := batch.Index(doc.ID, doc); err != nil {
+			return 0, err
 		}
 	}
+	if err := s.index.Batch(batch); err != nil {
+		return 0, err
+	}
+	return batch.Size(), nil
+}
+
+// DocCount returns the number of elements in the indexes of all spaces
+func (i *Index) DocCount() (uint64, error) {
+	spaces, err := i.allSpaces()
+	if err != nil {
+		return 0, err
+	}
+	defer i.release(spaces...)
+
+	var count uint64
+	for _, s := range spaces {
+		c, err := s.index.DocCount()
+		if err != nil {
+			return 0, err
+		}
+		count += c
+	}
+	return count, nil
+}
+
+// Add adds a new entity with the given extracted content to the Index
+func (i *Index) Add(ref *sprovider.Reference, ri *sprovider.ResourceInfo, content string) error {
+	s, err := i.createSpace(ri.GetId())
+	if err != nil {
+		return err
+	}
+	defer i.release(s)
+	entity := toEntity(ref, ri)
+	entity.Content = content
+	return s.index.Index(entity.ID, entity)
+}
+
+// AddSpace adds the document describing the given space with the given readme content to the Index
+func (i *Index) AddSpace(space *sprovider.StorageSpace, readme string) error {
+	s, err := i.createSpace(space.GetRoot())
+	if err != nil {
+		return err
+	}
+	defer i.release(s)
+	doc := toSpaceDocument(space, readme)
+	return s.index.Index(doc.ID, doc)
+}
 
+// Delete marks an entity and its children as deleted (still keeping them around). The entities
+// remember their key in the trash bin so that they can be found when searching the trash.
+func (i *Index) Delete(id *sprovider.ResourceId) error {
+	deletedAt := time.Now().UTC().Format(time.RFC3339Nano)
+	s, doc, err := i.updateEntity(id, func(doc *indexDocument) {
+		doc.Deleted = true
+		doc.DeletedAt = deletedAt
+		doc.TrashKey = id.GetOpaqueId()
+	})
+	if err != nil {
+		return err
+	}
+	defer i.release(s)
+
+	children, err := children(s.index, doc)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if child.Deleted {
+			// the child has been deleted before and lives in the trash bin on its own
+			continue
+		}
+		child.Deleted = true
+		child.DeletedAt = deletedAt
+		child.TrashKey = id.GetOpaqueId() + strings.TrimPrefix(child.Path, doc.Path)
+		if err := s.index.Index(child.ID, child); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (i *Index) updateEntity(id string, mutateFunc func(doc *indexDocument)) (*indexDocument, error) {
-	doc, err := i.getEntity(id)
+// Restore marks an entity and the children which have been deleted along with it as not being deleted
+func (i *Index) Restore(id *sprovider.ResourceId) error {
+	s, doc, err := i.updateEntity(id, func(doc *indexDocument) {
+		doc.Deleted = false
+		doc.DeletedAt = ""
+		doc.TrashKey = ""
+	})
+	if err != nil {
+		return err
+	}
+	defer i.release(s)
+
+	children, err := children(s.index, doc)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		// documents deleted before trash keys were recorded don't have one
+		if child.TrashKey != "" && !strings.HasPrefix(child.TrashKey, id.GetOpaqueId()+"/") {
+			continue
+		}
+		child.Deleted = false
+		child.DeletedAt = ""
+		child.TrashKey = ""
+		if err := s.index.Index(child.ID, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// children returns all documents below the given one
+func children(bleveIndex bleve.Index, doc *indexDocument) ([]*indexDocument, error) {
+	if doc.Type != uint64(sprovider.ResourceType_RESOURCE_TYPE_CONTAINER) {
+		return nil, nil
+	}
+	query := bleve.NewConjunctionQuery(
+		bleve.NewQueryStringQuery("RootID:"+doc.RootID),
+		bleve.NewQueryStringQuery("Path:"+queryEscape(doc.Path+"/*")),
+	)
+	bleveReq := bleve.NewSearchRequest(query)
+	bleveReq.Size = math.MaxInt
+	bleveReq.Fields = []string{"*"}
+	res, err := bleveIndex.Search(bleveReq)
 	if err != nil {
 		return nil, err
 	}
+
+	children := make([]*indexDocument, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		children = append(children, fieldsToEntity(h.Fields))
+	}
+	return children, nil
+}
+
+func (i *Index) updateEntity(id *sprovider.ResourceId, mutateFunc func(doc *indexDocument)) (*spaceIndex, *indexDocument, error) {
+	s, doc, err := i.getEntity(id)
+	if err != nil {
+		return nil, nil, err
+	}
 	mutateFunc(doc)
-	err = i.bleveIndex.Index(doc.ID, doc)
+	err = s.index.Index(doc.ID, doc)
 	if err != nil {
-		return nil, err
+		i.release(s)
+		return nil, nil, err
 	}
 
-	return doc, nil
+	return s, doc, nil
 }
 
-func (i *Index) getEntity(id string) (*indexDocument, error) {
-	req := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{id}))
+// getEntity returns the document with the given id along with the index of its space, which has to be
+// released once it isn't used anymore
+func (i *Index) getEntity(id *sprovider.ResourceId) (*spaceIndex, *indexDocument, error) {
+	s, err := i.space(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s == nil {
+		return nil, nil, errtypes.NotFound(idToBleveId(id))
+	}
+	req := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{idToBleveId(id)}))
 	req.Fields = []string{"*"}
-	res, err := i.bleveIndex.Search(req)
+	res, err := s.index.Search(req)
+	if err == nil && res.Hits.Len() == 0 {
+		err = errtypes.NotFound(idToBleveId(id))
+	}
+	if err != nil {
+		i.release(s)
+		return nil, nil, err
+	}
+	return s, fieldsToEntity(res.Hits[0].Fields), nil
+}
+
+// Get returns the entity with the given id. Entities marked as deleted are returned as well.
+func (i *Index) Get(id *sprovider.ResourceId) (*searchmsg.Entity, error) {
+	s, err := i.space(id)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, errtypes.NotFound(idToBleveId(id))
+	}
+	defer i.release(s)
+	req := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{idToBleveId(id)}))
+	req.Fields = entityFields
+	res, err := s.index.Search(req)
 	if err != nil {
 		return nil, err
 	}
 	if res.Hits.Len() == 0 {
-		return nil, errors.New("entity not found")
+		return nil, errtypes.NotFound(idToBleveId(id))
+	}
+	match, err := fromDocumentMatch(res.Hits[0])
+	if err != nil {
+		return nil, err
+	}
+	return match.Entity, nil
+}
+
+// ListSpace calls fn with all entities of the space with the given root, including the ones marked as
+// deleted. The document describing the space itself is left out. The entities are read page by page, so
+// that large spaces don't have to be held in memory.
+func (i *Index) ListSpace(rootID *sprovider.ResourceId, fn func(*searchmsg.Entity) error) error {
+	s, err := i.space(rootID)
+	if err != nil || s == nil {
+		return err
+	}
+	defer i.release(s)
+	rootQuery := bleve.NewTermQuery(idToBleveId(rootID))
+	rootQuery.SetField("RootID")
+	query := bleve.NewBooleanQuery()
+	query.AddMust(rootQuery)
+	query.AddMustNot(spaceQuery())
+
+	var searchAfter []string
+	for {
+		req := bleve.NewSearchRequest(query)
+		req.Size = listPageSize
+		req.Fields = entityFields
+		req.SortBy([]string{"_id"})
+		if searchAfter != nil {
+			req.SetSearchAfter(searchAfter)
+		}
+		res, err := s.index.Search(req)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range res.Hits {
+			match, err := fromDocumentMatch(h)
+			if err != nil {
+				return err
+			}
+			if err := fn(match.Entity); err != nil {
+				return err
+			}
+		}
+		if len(res.Hits) < listPageSize {
+			return nil
+		}
+		searchAfter = []string{res.Hits[len(res.Hits)-1].ID}
 	}
-	return fieldsToEntity(res.Hits[0].Fields), nil
 }
 
 // Purge removes an entity from the index
 func (i *Index) Purge(id *sprovider.ResourceId) error {
-	return i.bleveIndex.Delete(idToBleveId(id))
+	s, err := i.space(id)
+	if err != nil || s == nil {
+		return err
+	}
+	defer i.release(s)
+	return s.index.Delete(idToBleveId(id))
+}
+
+// PurgeSpace removes all entities of the space with the given root from the index by dropping the
+// index of the space. If the index is in use it is dropped as soon as the last user released it.
+func (i *Index) PurgeSpace(rootID *sprovider.ResourceId) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	s, ok := i.spaces[spaceID(rootID)]
+	if !ok || s.purged {
+		return nil
+	}
+	s.purged = true
+	if s.refs > 0 {
+		return nil
+	}
+	return i.drop(s)
+}
+
+// PurgeDeleted removes the entities which have been marked as deleted before the given point in time
+// from the index. Entities marked as deleted without a deletion time, e.g. because they have been
+// deleted before the deletion time was recorded, are removed as well. It returns the number of purged
+// entities.
+func (i *Index) PurgeDeleted(deletedBefore time.Time) (int, error) {
+	deletedQuery := bleve.NewBoolFieldQuery(true)
+	deletedQuery.SetField("Deleted")
+	inclusive := true
+	keptQuery := bleve.NewDateRangeInclusiveQuery(deletedBefore, time.Time{}, &inclusive, nil)
+	keptQuery.SetField("DeletedAt")
+	query := bleve.NewBooleanQuery()
+	query.AddMust(deletedQuery)
+	query.AddMustNot(keptQuery)
+
+	spaces, err := i.allSpaces()
+	if err != nil {
+		return 0, err
+	}
+	defer i.release(spaces...)
+
+	purged := 0
+	for _, s := range spaces {
+		n, err := purgeMatching(s.index, query)
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+	}
+	return purged, nil
+}
+
+func purgeMatching(bleveIndex bleve.Index, q query.Query) (int, error) {
+	req := bleve.NewSearchRequest(q)
+	req.Size = math.MaxInt
+	res, err := bleveIndex.Search(req)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := bleveIndex.NewBatch()
+	for _, h := range res.Hits {
+		batch.Delete(h.ID)
+	}
+	if err := bleveIndex.Batch(batch); err != nil {
+		return 0, err
+	}
+	return len(res.Hits), nil
 }
 
 // Move update the path of an entry and all its children
 func (i *Index) Move(id, newParentID *sprovider.ResourceId, fullPath string) error {
-	bleveId := idToBleveId(id)
-	doc, err := i.getEntity(bleveId)
+	s, doc, err := i.getEntity(id)
+	if err != nil {
+		return err
+	}
+	defer i.release(s)
+	children, err := children(s.index, doc)
 	if err != nil {
 		return err
 	}
 	oldName := doc.Path
 	newName := utils.MakeRelativePath(fullPath)
 
-	doc, err = i.updateEntity(bleveId, func(doc *indexDocument) {
-		doc.Path = newName
-		doc.Name = path.Base(newName)
-		doc.ParentID = idToBleveId(newParentID)
-	})
-	if err != nil {
+	doc.Path = newName
+	doc.Name = path.Base(newName)
+	doc.ParentID = idToBleveId(newParentID)
+	if err := s.index.Index(doc.ID, doc); err != nil {
 		return err
 	}
 
-	if doc.Type == uint64(sprovider.ResourceType_RESOURCE_TYPE_CONTAINER) {
-		query := bleve.NewConjunctionQuery(
-			bleve.NewQueryStringQuery("RootID:"+doc.RootID),
-			bleve.NewQueryStringQuery("Path:"+queryEscape(oldName+"/*")),
-		)
-		bleveReq := bleve.NewSearchRequest(query)
-		bleveReq.Size = math.MaxInt
-		bleveReq.Fields = []string{"*"}
-		res, err := i.bleveIndex.Search(bleveReq)
-		if err != nil {
+	for _, child := range children {
+		child.Path = strings.Replace(child.Path, oldName, newName, 1)
+		if err := s.index.Index(child.ID, child); err != nil {
 			return err
 		}
-
-		for _, h := range res.Hits {
-			_, err := i.updateEntity(h.ID, func(doc *indexDocument) {
-				doc.Path = strings.Replace(doc.Path, oldName, newName, 1)
-			})
-			if err != nil {
-				return err
-			}
-		}
 	}
 
 	return nil
 }
 
+// searchSpaces returns the indexes to run the given search request against. Requests limited to a space
+// only search the index of the space, other requests search the indexes of all spaces. The indexes have
+// to be released once the search is done.
+func (i *Index) searchSpaces(req *searchsvc.SearchIndexRequest) ([]*spaceIndex, error) {
+	if req.Ref == nil {
+		return i.allSpaces()
+	}
+	s, err := i.space(&sprovider.ResourceId{
+		StorageId: req.Ref.GetResourceId().GetStorageId(),
+		SpaceId:   req.Ref.GetResourceId().GetSpaceId(),
+	})
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return []*spaceIndex{s}, nil
+}
+
 // Search searches the index according to the criteria specified in the given SearchIndexRequest
 func (i *Index) Search(ctx context.Context, req *searchsvc.SearchIndexRequest) (*searchsvc.SearchIndexResponse, error) {
-	deletedQuery := bleve.NewBoolFieldQuery(false)
-	deletedQuery.SetField("Deleted")
+	order, err := newSearchOrder(req, i.options)
+	if err != nil {
+		return nil, err
+	}
+	userQuery, err := buildQuery(req.Query, queryOptions{fuzziness: i.options.Fuzziness, userID: req.UserId, exactNameBoost: i.options.ExactNameBoost})
+	if err != nil {
+		return nil, errtypes.BadRequest("invalid query: " + err.Error())
+	}
 	query := bleve.NewConjunctionQuery(
-		bleve.NewQueryStringQuery(req.Query),
-		deletedQuery, // Skip documents that have been marked as deleted
+		userQuery,
+		scopeQuery(req.Scope), // Only search the documents in the requested scope
 	)
 	if req.Ref != nil {
 		query = bleve.NewConjunctionQuery(
@@ -235,13 +900,37 @@ func (i *Index) Search(ctx context.Context, req *searchsvc.SearchIndexRequest) (
 			bleve.NewQueryStringQuery("Path:"+queryEscape(utils.MakeRelativePath(path.Join(req.Ref.Path, "/"))+"*")), // Limit search to this directory in the space
 		)
 	}
-	bleveReq := bleve.NewSearchRequest(query)
-	bleveReq.Size = 200
+	size := 200
 	if req.PageSize > 0 {
-		bleveReq.Size = int(req.PageSize)
+		size = int(req.PageSize)
 	}
-	bleveReq.Fields = []string{"*"}
-	res, err := i.bleveIndex.Search(bleveReq)
+	bleveReq := bleve.NewSearchRequest(query)
+	bleveReq.Size = size + 1 // fetch one more hit to find out if there is another page
+	bleveReq.Fields = entityFields
+	sortOrder, searchAfter := order.bleveSort()
+	bleveReq.SortByCustom(sortOrder)
+	if searchAfter != nil {
+		bleveReq.SetSearchAfter(searchAfter)
+	}
+	if err := addFacetRequests(bleveReq, req.Facets, time.Now()); err != nil {
+		return nil, err
+	}
+	spaces, err := i.searchSpaces(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(spaces) == 0 {
+		return &searchsvc.SearchIndexResponse{
+			Matches: []*searchmsg.Match{},
+			Facets:  fromFacetResults(req.Facets, nil),
+		}, nil
+	}
+	defer i.release(spaces...)
+	indexes := make([]bleve.Index, 0, len(spaces))
+	for _, s := range spaces {
+		indexes = append(indexes, s.index)
+	}
+	res, err := bleve.NewIndexAlias(indexes...).Search(bleveReq)
 	if err != nil {
 		return nil, err
 	}
@@ -255,19 +944,81 @@ func (i *Index) Search(ctx context.Context, req *searchsvc.SearchIndexRequest) (
 		matches = append(matches, match)
 	}
 
+	nextPageToken := ""
+	if len(matches) > size {
+		matches = matches[:size]
+		nextPageToken = searchpkg.EncodePageToken(order.order, order.ranking.at, matches[size-1])
+	}
+
 	return &searchsvc.SearchIndexResponse{
-		Matches:      matches,
-		TotalMatches: int32(res.Total),
+		Matches:       matches,
+		TotalMatches:  int32(res.Total),
+		NextPageToken: nextPageToken,
+		Facets:        fromFacetResults(req.Facets, res.Facets),
 	}, nil
 }
 
+// scopeQuery returns the query matching the documents of the given scope. The trash consists of the
+// documents which have been marked as deleted, the spaces scope of the documents describing spaces.
+func scopeQuery(scope string) query.Query {
+	deletedQuery := bleve.NewBoolFieldQuery(scope == searchpkg.ScopeTrash)
+	deletedQuery.SetField("Deleted")
+	q := bleve.NewBooleanQuery()
+	q.AddMust(deletedQuery)
+	if scope == searchpkg.ScopeSpaces {
+		q.AddMust(spaceQuery())
+	} else {
+		q.AddMustNot(spaceQuery())
+	}
+	return q
+}
+
+// spaceQuery matches the documents describing spaces
+func spaceQuery() query.Query {
+	q := bleve.NewBoolFieldQuery(true)
+	q.SetField("Space")
+	return q
+}
+
+// nameWordsPattern matches the words of file names. Words are separated by anything which is neither
+// a letter nor a digit, by changes between letters and digits and by camel case.
+const nameWordsPattern = `\p{Lu}?\p{Ll}+|\p{Lu}+|\p{L}+|\p{N}+`
+
 // BuildMapping builds a bleve index mapping which can be used for indexing
 func BuildMapping() (mapping.IndexMapping, error) {
 	nameMapping := bleve.NewTextFieldMapping()
 	nameMapping.Analyzer = "lowercaseKeyword"
 
+	// the name is additionally indexed word by word and as prefixes of its words to find
+	// resources by parts of their names
+	nameWordsMapping := bleve.NewTextFieldMapping()
+	nameWordsMapping.Name = "NameWords"
+	nameWordsMapping.Analyzer = "nameWords"
+	nameWordsMapping.Store = false
+	nameWordsMapping.IncludeInAll = false
+
+	namePrefixesMapping := bleve.NewTextFieldMapping()
+	namePrefixesMapping.Name = "NamePrefixes"
+	namePrefixesMapping.Analyzer = "namePrefixes"
+	namePrefixesMapping.Store = false
+	namePrefixesMapping.IncludeInAll = false
+
+	contentMapping := bleve.NewTextFieldMapping()
+	contentMapping.Analyzer = standard.Name
+
+	tagsMapping := bleve.NewTextFieldMapping()
+	tagsMapping.Analyzer = "lowercaseKeyword"
+
+	// the description of spaces is searched as part of their content
+	descriptionMapping := bleve.NewTextFieldMapping()
+	descriptionMapping.Index = false
+	descriptionMapping.IncludeInAll = false
+
 	docMapping := bleve.NewDocumentMapping()
-	docMapping.AddFieldMappingsAt("Name", nameMapping)
+	docMapping.AddFieldMappingsAt("Name", nameMapping, nameWordsMapping, namePrefixesMapping)
+	docMapping.AddFieldMappingsAt("Content", contentMapping)
+	docMapping.AddFieldMappingsAt("Tags", tagsMapping)
+	docMapping.AddFieldMappingsAt("Description", descriptionMapping)
 
 	indexMapping := bleve.NewIndexMapping()
 	indexMapping.DefaultAnalyzer = keyword.Name
@@ -283,22 +1034,66 @@ func BuildMapping() (mapping.IndexMapping, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = indexMapping.AddCustomTokenizer("nameWords",
+		map[string]interface{}{
+			"type":   regexptokenizer.Name,
+			"regexp": nameWordsPattern,
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = indexMapping.AddCustomTokenFilter("namePrefixes",
+		map[string]interface{}{
+			"type": edgengram.Name,
+			"back": false,
+			"min":  1.0,
+			"max":  30.0,
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = indexMapping.AddCustomAnalyzer("nameWords",
+		map[string]interface{}{
+			"type":      custom.Name,
+			"tokenizer": "nameWords",
+			"token_filters": []string{
+				lowercase.Name,
+			},
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = indexMapping.AddCustomAnalyzer("namePrefixes",
+		map[string]interface{}{
+			"type":      custom.Name,
+			"tokenizer": "nameWords",
+			"token_filters": []string{
+				lowercase.Name,
+				"namePrefixes",
+			},
+		})
+	if err != nil {
+		return nil, err
+	}
 
 	return indexMapping, nil
 }
 
 func toEntity(ref *sprovider.Reference, ri *sprovider.ResourceInfo) *indexDocument {
 	doc := &indexDocument{
-		RootID:   idToBleveId(ref.ResourceId),
-		Path:     ref.Path,
-		ID:       idToBleveId(ri.Id),
-		ParentID: idToBleveId(ri.ParentId),
-		Name:     ri.Name,
-		Size:     ri.Size,
-		MimeType: ri.MimeType,
-		Type:     uint64(ri.Type),
-		Deleted:  false,
-		Hidden:   strings.HasPrefix(ri.Path, "."),
+		RootID:    idToBleveId(ref.ResourceId),
+		Path:      ref.Path,
+		ID:        idToBleveId(ri.Id),
+		ParentID:  idToBleveId(ri.ParentId),
+		Name:      ri.Name,
+		Size:      ri.Size,
+		MimeType:  ri.MimeType,
+		Type:      uint64(ri.Type),
+		Etag:      ri.Etag,
+		Tags:      searchpkg.Tags(ri),
+		Favorites: searchpkg.Favorites(ri),
+		Deleted:   false,
+		Hidden:    strings.HasPrefix(ri.Path, "."),
 	}
 
 	if ri.Mtime != nil {
@@ -308,23 +1103,91 @@ func toEntity(ref *sprovider.Reference, ri *sprovider.ResourceInfo) *indexDocume
 	return doc
 }
 
-func fieldsToEntity(fields map[string]interface{}) *indexDocument {
+// toSpaceDocument returns the document describing the given space. It is identified by the id of the
+// space, its content consists of the description and the readme of the space.
+func toSpaceDocument(space *sprovider.StorageSpace, readme string) *indexDocument {
+	root := space.GetRoot()
+	description := searchpkg.SpaceDescription(space)
 	doc := &indexDocument{
-		RootID:   fields["RootID"].(string),
-		Path:     fields["Path"].(string),
-		ID:       fields["ID"].(string),
-		ParentID: fields["ParentID"].(string),
-		Name:     fields["Name"].(string),
-		Size:     uint64(fields["Size"].(float64)),
-		Mtime:    fields["Mtime"].(string),
-		MimeType: fields["MimeType"].(string),
-		Type:     uint64(fields["Type"].(float64)),
-		Deleted:  fields["Deleted"].(bool),
-		Hidden:   fields["Hidden"].(bool),
+		RootID:      idToBleveId(root),
+		Path:        ".",
+		ID:          storagespace.FormatStorageID(root.GetStorageId(), root.GetSpaceId()),
+		Name:        space.GetName(),
+		MimeType:    "httpd/unix-directory",
+		Type:        uint64(sprovider.ResourceType_RESOURCE_TYPE_CONTAINER),
+		Content:     strings.TrimSpace(description + "\n\n" + readme),
+		Space:       true,
+		SpaceType:   space.GetSpaceType(),
+		Description: description,
+	}
+	if space.GetMtime() != nil {
+		doc.Mtime = time.Unix(int64(space.Mtime.Seconds), int64(space.Mtime.Nanos)).UTC().Format(time.RFC3339Nano)
+	}
+	return doc
+}
+
+// toSpace returns the space described by the document with the given root, nil for other documents
+func toSpace(isSpace bool, rootID sprovider.ResourceId, name, description, spaceType string) *searchmsg.Space {
+	if !isSpace {
+		return nil
+	}
+	return &searchmsg.Space{
+		Id:          storagespace.FormatStorageID(rootID.StorageId, rootID.SpaceId),
+		RootId:      resourceIDtoSearchID(rootID),
+		Name:        name,
+		Description: description,
+		SpaceType:   spaceType,
+	}
+}
+
+func fieldsToEntity(fields map[string]interface{}) *indexDocument {
+	doc := &indexDocument{}
+	// documents of former versions or indexed without any content or etag don't carry all fields
+	doc.RootID, _ = fields["RootID"].(string)
+	doc.Path, _ = fields["Path"].(string)
+	doc.ID, _ = fields["ID"].(string)
+	doc.ParentID, _ = fields["ParentID"].(string)
+	doc.Name, _ = fields["Name"].(string)
+	if size, ok := fields["Size"].(float64); ok {
+		doc.Size = uint64(size)
+	}
+	doc.Mtime, _ = fields["Mtime"].(string)
+	doc.MimeType, _ = fields["MimeType"].(string)
+	if typ, ok := fields["Type"].(float64); ok {
+		doc.Type = uint64(typ)
 	}
+	doc.Deleted, _ = fields["Deleted"].(bool)
+	doc.Hidden, _ = fields["Hidden"].(bool)
+	doc.Content, _ = fields["Content"].(string)
+	doc.Etag, _ = fields["Etag"].(string)
+	doc.DeletedAt, _ = fields["DeletedAt"].(string)
+	doc.TrashKey, _ = fields["TrashKey"].(string)
+	doc.Tags = stringsField(fields["Tags"])
+	doc.Favorites = stringsField(fields["Favorites"])
+	doc.Space, _ = fields["Space"].(bool)
+	doc.SpaceType, _ = fields["SpaceType"].(string)
+	doc.Description, _ = fields["Description"].(string)
 	return doc
 }
 
+// stringsField returns the values of a stored field holding a list of strings. Bleve returns single
+// values as plain strings and leaves out empty lists.
+func stringsField(field interface{}) []string {
+	switch v := field.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
 func fromDocumentMatch(hit *search.DocumentMatch) (*searchmsg.Match, error) {
 	rootID, err := storagespace.ParseID(hit.Fields["RootID"].(string))
 	if err != nil {
@@ -350,6 +1213,17 @@ func fromDocumentMatch(hit *search.DocumentMatch) (*searchmsg.Match, error) {
 			Deleted:  hit.Fields["Deleted"].(bool),
 		},
 	}
+	if etag, ok := hit.Fields["Etag"].(string); ok {
+		match.Entity.Etag = etag
+	}
+	if trashKey, ok := hit.Fields["TrashKey"].(string); ok {
+		match.Entity.TrashKey = trashKey
+	}
+	match.Entity.Tags = stringsField(hit.Fields["Tags"])
+	isSpace, _ := hit.Fields["Space"].(bool)
+	description, _ := hit.Fields["Description"].(string)
+	spaceType, _ := hit.Fields["SpaceType"].(string)
+	match.Space = toSpace(isSpace, rootID, match.Entity.Name, description, spaceType)
 	if hit.Fields["ParentID"] != nil && hit.Fields["ParentID"] != "" {
 		parentID, err := storagespace.ParseID(hit.Fields["ParentID"].(string))
 		if err != nil {
@@ -2,13 +2,21 @@ package index_test
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/blevesearch/bleve/v2"
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
 	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
 	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
 	"github.com/owncloud/ocis/v2/services/search/pkg/search/index"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -16,9 +24,8 @@ import (
 
 var _ = Describe("Index", func() {
 	var (
-		i          *index.Index
-		bleveIndex bleve.Index
-		ctx        context.Context
+		i   *index.Index
+		ctx context.Context
 
 		rootId = &sprovider.ResourceId{
 			StorageId: "provider-1",
@@ -71,6 +78,15 @@ var _ = Describe("Index", func() {
 			Mtime: &typesv1beta1.Timestamp{Seconds: 4000},
 		}
 
+		listSpace = func(rootId *sprovider.ResourceId) ([]*searchmsg.Entity, error) {
+			entities := []*searchmsg.Entity{}
+			err := i.ListSpace(rootId, func(e *searchmsg.Entity) error {
+				entities = append(entities, e)
+				return nil
+			})
+			return entities, err
+		}
+
 		assertDocCount = func(rootId *sprovider.ResourceId, query string, expectedCount int) []*searchmsg.Match {
 			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{
 				Query: query,
@@ -91,13 +107,8 @@ var _ = Describe("Index", func() {
 	BeforeEach(func() {
 		filename = "Foo.pdf"
 
-		mapping, err := index.BuildMapping()
-		Expect(err).ToNot(HaveOccurred())
-
-		bleveIndex, err = bleve.NewMemOnly(mapping)
-		Expect(err).ToNot(HaveOccurred())
-
-		i, err = index.New(bleveIndex)
+		var err error
+		i, err = index.NewMemOnly()
 		Expect(err).ToNot(HaveOccurred())
 	})
 
@@ -126,9 +137,9 @@ var _ = Describe("Index", func() {
 		}
 	})
 
-	Describe("New", func() {
+	Describe("NewMemOnly", func() {
 		It("returns a new index instance", func() {
-			i, err := index.New(bleveIndex)
+			i, err := index.NewMemOnly()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(i).ToNot(BeNil())
 		})
@@ -136,16 +147,122 @@ var _ = Describe("Index", func() {
 
 	Describe("NewPersisted", func() {
 		It("returns a new index instance", func() {
-			i, err := index.NewPersisted("")
+			i, err := index.NewPersisted(GinkgoT().TempDir())
 			Expect(err).ToNot(HaveOccurred())
 			Expect(i).ToNot(BeNil())
 		})
+
+		It("opens the indexes of the spaces persisted before", func() {
+			dir := GinkgoT().TempDir()
+			i, err := index.NewPersisted(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+			Expect(i.Close()).To(Succeed())
+
+			i, err = index.NewPersisted(dir)
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(i.Close)
+			entity, err := i.Get(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entity.Name).To(Equal("Foo.pdf"))
+		})
+
+		It("closes the least recently used indexes of the spaces", func() {
+			i, err := index.NewPersisted(GinkgoT().TempDir(), index.MaxOpenSpaces(1))
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(i.Close)
+			otherRi := &sprovider.ResourceInfo{
+				Id:   &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "otherspaceid", OpaqueId: "otheropaqueid"},
+				Path: "Foo.pdf",
+				Name: "Foo.pdf",
+				Type: sprovider.ResourceType_RESOURCE_TYPE_FILE,
+			}
+			otherRef := &sprovider.Reference{
+				ResourceId: &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "otherspaceid", OpaqueId: "otherspaceid"},
+				Path:       "./Foo.pdf",
+			}
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+			Expect(i.Add(otherRef, otherRi, "")).To(Succeed())
+
+			_, err = i.Get(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = i.Get(otherRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "Foo.pdf"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Matches).To(HaveLen(2))
+			count, err := i.DocCount()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(uint64(2)))
+		})
+	})
+
+	Describe("Upgrade", func() {
+		It("rebuilds the indexes built with a former mapping", func() {
+			dir := GinkgoT().TempDir()
+			outdated, err := bleve.New(filepath.Join(dir, "provider-1$spaceid"), bleve.NewIndexMapping())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outdated.Index("provider-1$spaceid!opaqueid", map[string]interface{}{
+				"RootID": "provider-1$spaceid!rootopaqueid", "Path": "./2022_Budget_final.xlsx", "ID": "provider-1$spaceid!opaqueid",
+				"ParentID": "provider-1$spaceid!rootopaqueid", "Name": "2022_Budget_final.xlsx", "Size": 1, "Mtime": "1970-01-01T01:06:40Z",
+				"MimeType": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "Type": 1, "Deleted": false, "Hidden": false,
+			})).To(Succeed())
+			Expect(outdated.Close()).To(Succeed())
+
+			i, err = index.NewPersisted(dir)
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(i.Close)
+			rebuilt, err := i.Upgrade()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rebuilt).To(Equal(1))
+			assertDocCount(rootId, "budget", 1)
+
+			rebuilt, err = i.Upgrade()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rebuilt).To(Equal(0))
+		})
+	})
+
+	Describe("Migrate", func() {
+		It("moves the documents of the former index to the indexes of their spaces", func() {
+			legacyDir := filepath.Join(GinkgoT().TempDir(), "index.bleve")
+			legacy, err := bleve.New(legacyDir, bleve.NewIndexMapping())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(legacy.Index("provider-1$spaceid!opaqueid", map[string]interface{}{
+				"RootID": "provider-1$spaceid!rootopaqueid", "Path": "./Foo.pdf", "ID": "provider-1$spaceid!opaqueid",
+				"ParentID": "provider-1$spaceid!rootopaqueid", "Name": "Foo.pdf", "Size": 12345, "Mtime": "1970-01-01T01:06:40Z",
+				"MimeType": "application/pdf", "Type": 1, "Deleted": false, "Hidden": false,
+			})).To(Succeed())
+			Expect(legacy.Index("provider-1$otherspaceid!otheropaqueid", map[string]interface{}{
+				"RootID": "provider-1$otherspaceid!otherspaceid", "Path": "./Bar.pdf", "ID": "provider-1$otherspaceid!otheropaqueid",
+				"ParentID": "provider-1$otherspaceid!otherspaceid", "Name": "Bar.pdf", "Size": 1, "Mtime": "1970-01-01T01:06:40Z",
+				"MimeType": "application/pdf", "Type": 1, "Deleted": false, "Hidden": false,
+			})).To(Succeed())
+			Expect(legacy.Close()).To(Succeed())
+
+			i, err := index.NewPersisted(GinkgoT().TempDir())
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(i.Close)
+			Expect(i.Add(ref, ri, "the content")).To(Succeed())
+
+			migrated, err := i.Migrate(legacyDir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(migrated).To(Equal(1))
+
+			entity, err := i.Get(&sprovider.ResourceId{StorageId: "provider-1", SpaceId: "otherspaceid", OpaqueId: "otheropaqueid"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entity.Name).To(Equal("Bar.pdf"))
+			// the documents indexed in the index of their space are kept
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "content"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Matches).To(HaveLen(1))
+		})
 	})
 
 	Describe("Search", func() {
 		Context("by other fields than filename", func() {
 			JustBeforeEach(func() {
-				err := i.Add(ref, ri)
+				err := i.Add(ref, ri, "")
 				Expect(err).ToNot(HaveOccurred())
 			})
 
@@ -160,11 +277,38 @@ var _ = Describe("Index", func() {
 			})
 		})
 
+		Context("by content", func() {
+			JustBeforeEach(func() {
+				err := i.Add(ref, ri, "The quarterly Budget was exceeded by 20 percent.")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("finds files by a word or phrase in their content", func() {
+				assertDocCount(ref.ResourceId, `Content:budget`, 1)
+				assertDocCount(ref.ResourceId, `Content:"budget was exceeded"`, 1)
+				assertDocCount(ref.ResourceId, `Content:"budget exceeded"`, 0)
+				assertDocCount(ref.ResourceId, `Content:forecast`, 0)
+			})
+
+			It("matches either the name or the content", func() {
+				assertDocCount(ref.ResourceId, `budget`, 1)
+				assertDocCount(ref.ResourceId, `foo`, 1)
+				assertDocCount(ref.ResourceId, `"was exceeded"`, 1)
+				assertDocCount(ref.ResourceId, `bar`, 0)
+			})
+
+			It("keeps the content when the resource is moved", func() {
+				err := i.Move(ri.Id, ri.ParentId, "./bar.pdf")
+				Expect(err).ToNot(HaveOccurred())
+				assertDocCount(ref.ResourceId, `Content:budget`, 1)
+			})
+		})
+
 		Context("by filename", func() {
 			It("finds files with spaces in the filename", func() {
 				ri.Name = "Foo oo.pdf"
 				ref.Path = "./" + ri.Path
-				err := i.Add(ref, ri)
+				err := i.Add(ref, ri, "")
 				Expect(err).ToNot(HaveOccurred())
 
 				assertDocCount(ref.ResourceId, `Name:foo\ o*`, 1)
@@ -173,7 +317,7 @@ var _ = Describe("Index", func() {
 			It("finds files by digits in the filename", func() {
 				ri.Name = "12345.pdf"
 				ref.Path = "./" + ri.Path
-				err := i.Add(ref, ri)
+				err := i.Add(ref, ri, "")
 				Expect(err).ToNot(HaveOccurred())
 
 				assertDocCount(ref.ResourceId, `Name:1234*`, 1)
@@ -183,16 +327,16 @@ var _ = Describe("Index", func() {
 				ri.Path = ".hidden.pdf"
 				ri.Name = ".hidden.pdf"
 				ref.Path = "./" + ri.Path
-				err := i.Add(ref, ri)
+				err := i.Add(ref, ri, "")
 				Expect(err).ToNot(HaveOccurred())
 
-				assertDocCount(ref.ResourceId, `Name:*hidden* +Hidden:T`, 1)
-				assertDocCount(ref.ResourceId, `Name:*hidden* +Hidden:F`, 0)
+				assertDocCount(ref.ResourceId, `name:*hidden* hidden:true`, 1)
+				assertDocCount(ref.ResourceId, `name:*hidden* hidden:false`, 0)
 			})
 
 			Context("with a file in the root of the space", func() {
 				JustBeforeEach(func() {
-					err := i.Add(ref, ri)
+					err := i.Add(ref, ri, "")
 					Expect(err).ToNot(HaveOccurred())
 				})
 
@@ -250,9 +394,10 @@ var _ = Describe("Index", func() {
 					}
 				})
 
-				It("uses a lower-case index", func() {
+				It("matches the name case insensitively", func() {
 					assertDocCount(ref.ResourceId, "Name:foo*", 1)
-					assertDocCount(ref.ResourceId, "Name:Foo*", 0)
+					assertDocCount(ref.ResourceId, "Name:Foo*", 1)
+					assertDocCount(ref.ResourceId, "FOO.PDF", 1)
 				})
 
 				Context("and an additional file in a subdirectory", func() {
@@ -280,7 +425,7 @@ var _ = Describe("Index", func() {
 							Name: "nestedpdf.pdf",
 							Size: 12345,
 						}
-						err := i.Add(nestedRef, nestedRI)
+						err := i.Add(nestedRef, nestedRI, "")
 						Expect(err).ToNot(HaveOccurred())
 					})
 
@@ -312,37 +457,716 @@ var _ = Describe("Index", func() {
 		})
 	})
 
+	Describe("Search by parts of the filename", func() {
+		var (
+			addFile = func(opaqueID, name string) {
+				err := i.Add(&sprovider.Reference{ResourceId: rootId, Path: "./" + name}, &sprovider.ResourceInfo{
+					Id:       &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: opaqueID},
+					ParentId: &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: "rootopaqueid"},
+					Path:     name,
+					Name:     name,
+					Type:     sprovider.ResourceType_RESOURCE_TYPE_FILE,
+				}, "")
+				Expect(err).ToNot(HaveOccurred())
+			}
+			names = func(matches []*searchmsg.Match) []string {
+				found := []string{}
+				for _, m := range matches {
+					found = append(found, m.Entity.Name)
+				}
+				return found
+			}
+		)
+
+		BeforeEach(func() {
+			addFile("budget", "2022_Budget_final.xlsx")
+			addFile("report", "QuarterlyReport-v2.pdf")
+		})
+
+		It("finds files by the words of their name", func() {
+			Expect(names(assertDocCount(rootId, `budget`, 1))).To(ConsistOf("2022_Budget_final.xlsx"))
+			Expect(names(assertDocCount(rootId, `final budget`, 1))).To(ConsistOf("2022_Budget_final.xlsx"))
+			Expect(names(assertDocCount(rootId, `2022`, 1))).To(ConsistOf("2022_Budget_final.xlsx"))
+			Expect(names(assertDocCount(rootId, `report`, 1))).To(ConsistOf("QuarterlyReport-v2.pdf"))
+			Expect(names(assertDocCount(rootId, `quarterly v2`, 1))).To(ConsistOf("QuarterlyReport-v2.pdf"))
+			assertDocCount(rootId, `final report`, 0)
+		})
+
+		It("finds files by prefixes of the words of their name", func() {
+			Expect(names(assertDocCount(rootId, `budg`, 1))).To(ConsistOf("2022_Budget_final.xlsx"))
+			Expect(names(assertDocCount(rootId, `fin bud`, 1))).To(ConsistOf("2022_Budget_final.xlsx"))
+			Expect(names(assertDocCount(rootId, `quart rep`, 1))).To(ConsistOf("QuarterlyReport-v2.pdf"))
+		})
+
+		It("finds files by words with typos", func() {
+			Expect(names(assertDocCount(rootId, `budjet`, 1))).To(ConsistOf("2022_Budget_final.xlsx"))
+			Expect(names(assertDocCount(rootId, `quaterly`, 1))).To(ConsistOf("QuarterlyReport-v2.pdf"))
+			assertDocCount(rootId, `bujjet`, 0)
+		})
+
+		It("uses the configured edit distance", func() {
+			var err error
+			i, err = index.NewMemOnly(index.Fuzziness(2))
+			Expect(err).ToNot(HaveOccurred())
+			addFile("budget", "2022_Budget_final.xlsx")
+			assertDocCount(rootId, `bujjet`, 1)
+
+			i, err = index.NewMemOnly(index.Fuzziness(0))
+			Expect(err).ToNot(HaveOccurred())
+			addFile("budget", "2022_Budget_final.xlsx")
+			assertDocCount(rootId, `budjet`, 0)
+			assertDocCount(rootId, `budget`, 1)
+		})
+
+		It("ranks exact matches above partial ones", func() {
+			addFile("exact", "budget")
+			addFile("prefix", "budgetary.txt")
+			addFile("typo", "budjet.txt")
+
+			Expect(names(assertDocCount(rootId, `budget`, 4))).To(Equal([]string{
+				"budget",
+				"2022_Budget_final.xlsx",
+				"budgetary.txt",
+				"budjet.txt",
+			}))
+		})
+	})
+
+	Describe("Search with the query syntax", func() {
+		JustBeforeEach(func() {
+			for _, f := range []struct {
+				name     string
+				mimeType string
+				size     uint64
+				mtime    string
+				content  string
+			}{
+				{"Report-2022.pdf", "application/pdf", 5000000, "2022-06-01T10:00:00Z", "annual report"},
+				{"report-draft.docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", 20000, "2022-01-01T12:00:00Z", "first draft"},
+				{"report-old.docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", 30000, "2021-12-15T08:00:00Z", ""},
+				{"report-figures.csv", "text/csv", 4000, "2022-02-01T08:00:00Z", ""},
+				{"report-cover.png", "image/png", 3000000, "2022-03-01T08:00:00Z", ""},
+				{"holiday.png", "image/png", 12000000, "2022-08-01T08:00:00Z", ""},
+			} {
+				mtime, err := time.Parse(time.RFC3339, f.mtime)
+				Expect(err).ToNot(HaveOccurred())
+				ri.Id.OpaqueId = "opaqueid-" + f.name
+				ri.Name = f.name
+				ri.MimeType = f.mimeType
+				ri.Size = f.size
+				ri.Mtime = &typesv1beta1.Timestamp{Seconds: uint64(mtime.Unix())}
+				ref.Path = "./" + f.name
+				err = i.Add(ref, ri, f.content)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			ri.Id.OpaqueId = "opaqueid-reports"
+			ri.Name = "reports"
+			ri.MimeType = "httpd/unix-directory"
+			ri.Type = sprovider.ResourceType_RESOURCE_TYPE_CONTAINER
+			ri.Size = 0
+			ref.Path = "./reports"
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+		})
+
+		names := func(matches []*searchmsg.Match) []string {
+			n := []string{}
+			for _, m := range matches {
+				n = append(n, m.Entity.Name)
+			}
+			return n
+		}
+
+		It("supports the documented example", func() {
+			matches := assertDocCount(rootId, `name:report* type:document mtime>2022-01-01 size<10MB AND NOT mediatype:image`, 0)
+			Expect(matches).To(BeEmpty())
+			matches = assertDocCount(rootId, `name:report* type:document mtime>=2022-01-01 size<10MB AND NOT mediatype:image`, 1)
+			Expect(names(matches)).To(ConsistOf("report-draft.docx"))
+		})
+
+		It("restricts the name", func() {
+			Expect(names(assertDocCount(rootId, `name:report*`, 6))).ToNot(ContainElement("holiday.png"))
+			assertDocCount(rootId, `name:report-2022.pdf`, 1)
+			assertDocCount(rootId, `NAME:"Report-2022.pdf"`, 1)
+			assertDocCount(rootId, `name:report`, 0)
+		})
+
+		It("restricts the type", func() {
+			Expect(names(assertDocCount(rootId, `type:document`, 2))).To(ConsistOf("report-draft.docx", "report-old.docx"))
+			Expect(names(assertDocCount(rootId, `type:spreadsheet`, 1))).To(ConsistOf("report-figures.csv"))
+			Expect(names(assertDocCount(rootId, `type:folder`, 1))).To(ConsistOf("reports"))
+			assertDocCount(rootId, `type:file`, 6)
+			assertDocCount(rootId, `type:pdf`, 1)
+		})
+
+		It("restricts the mime type", func() {
+			assertDocCount(rootId, `mediatype:image`, 2)
+			assertDocCount(rootId, `mediatype:image/png`, 2)
+			assertDocCount(rootId, `mediatype:application/*`, 3)
+		})
+
+		It("compares sizes", func() {
+			assertDocCount(rootId, `size>10MB`, 1)
+			assertDocCount(rootId, `size>=3MB size<=5MB`, 2)
+			assertDocCount(rootId, `size:20000`, 1)
+			assertDocCount(rootId, `size<20KB`, 2)
+			assertDocCount(rootId, `size<20KiB`, 3)
+		})
+
+		It("compares modification times", func() {
+			assertDocCount(rootId, `mtime:2022-01-01`, 1)
+			assertDocCount(rootId, `mtime>2022-01-01 type:file`, 4)
+			assertDocCount(rootId, `mtime<2022-01-01`, 1)
+			assertDocCount(rootId, `mtime<=2022-01-01`, 2)
+			assertDocCount(rootId, `mtime>"2022-06-01T09:00:00Z" type:file`, 2)
+		})
+
+		It("combines the restrictions", func() {
+			Expect(names(assertDocCount(rootId, `mediatype:image OR type:pdf`, 3))).To(ConsistOf("Report-2022.pdf", "report-cover.png", "holiday.png"))
+			Expect(names(assertDocCount(rootId, `name:report* AND (type:document OR type:spreadsheet) AND NOT size>25KB`, 2))).To(ConsistOf("report-draft.docx", "report-figures.csv"))
+			Expect(names(assertDocCount(rootId, `report -type:folder -mediatype:image -type:document`, 2))).To(ConsistOf("Report-2022.pdf", "report-figures.csv"))
+			assertDocCount(rootId, `NOT name:report*`, 1)
+		})
+
+		It("matches free text against the name and the content", func() {
+			Expect(names(assertDocCount(rootId, `annual`, 1))).To(ConsistOf("Report-2022.pdf"))
+			Expect(names(assertDocCount(rootId, `"first draft"`, 1))).To(ConsistOf("report-draft.docx"))
+			assertDocCount(rootId, `draft first`, 1)
+			assertDocCount(rootId, `cover`, 1)
+		})
+
+		It("rejects invalid queries", func() {
+			for _, query := range []string{
+				``,
+				`RootID:foo`,
+				`Deleted:true`,
+				`(name:foo`,
+				`name:foo)`,
+				`"foo`,
+				`name:`,
+				`name>foo`,
+				`size>10XB`,
+				`mtime>yesterday`,
+				`type:blob`,
+				`hidden:maybe`,
+				`:foo`,
+				`foo AND`,
+			} {
+				_, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: query})
+				Expect(err).To(HaveOccurred(), "query: "+query)
+				Expect(err).To(BeAssignableToTypeOf(errtypes.BadRequest("")), "query: "+query)
+			}
+		})
+
+		It("explains the errors", func() {
+			_, err := index.BuildQuery(`rootid:foo`)
+			Expect(err).To(MatchError(ContainSubstring("unknown field 'rootid'")))
+			_, err = index.BuildQuery(`(name:foo`)
+			Expect(err).To(MatchError(ContainSubstring("missing ')'")))
+			_, err = index.BuildQuery(`size>10XB`)
+			Expect(err).To(MatchError(ContainSubstring("invalid size '10XB'")))
+		})
+	})
+
+	Describe("Search with pagination", func() {
+		JustBeforeEach(func() {
+			for _, id := range []string{"a", "b", "c", "d", "e"} {
+				ri.Id.OpaqueId = "opaqueid-" + id
+				ri.Name = "page-" + id + ".pdf"
+				ref.Path = "./" + ri.Name
+				err := i.Add(ref, ri, "")
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("pages through all results without duplicates", func() {
+			ids := []string{}
+			pageToken := ""
+			for page := 0; page < 3; page++ {
+				res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{
+					Query:     "Name:page*",
+					PageSize:  2,
+					PageToken: pageToken,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.TotalMatches).To(BeNumerically(">=", len(res.Matches)))
+				for _, m := range res.Matches {
+					ids = append(ids, m.Entity.Id.OpaqueId)
+				}
+				pageToken = res.NextPageToken
+				if page < 2 {
+					Expect(res.Matches).To(HaveLen(2))
+					Expect(pageToken).ToNot(BeEmpty())
+				} else {
+					Expect(res.Matches).To(HaveLen(1))
+					Expect(pageToken).To(BeEmpty())
+				}
+			}
+			Expect(ids).To(ConsistOf("opaqueid-a", "opaqueid-b", "opaqueid-c", "opaqueid-d", "opaqueid-e"))
+		})
+
+		It("does not return a page token when all results fit on the page", func() {
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{
+				Query:    "Name:page*",
+				PageSize: 5,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Matches).To(HaveLen(5))
+			Expect(res.NextPageToken).To(BeEmpty())
+		})
+
+		It("rejects invalid page tokens", func() {
+			_, err := i.Search(ctx, &searchsvc.SearchIndexRequest{
+				Query:     "Name:page*",
+				PageToken: "invalid",
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Search with ordering and ranking", func() {
+		var (
+			now = time.Now().Truncate(time.Second)
+
+			add = func(id, name string, size uint64, mtime time.Time, root *sprovider.ResourceId) {
+				ri.Id = &sprovider.ResourceId{StorageId: root.StorageId, SpaceId: root.SpaceId, OpaqueId: id}
+				ri.Name = name
+				ri.Size = size
+				ri.Mtime = &typesv1beta1.Timestamp{Seconds: uint64(mtime.Unix()), Nanos: uint32(mtime.Nanosecond())}
+				ExpectWithOffset(1, i.Add(&sprovider.Reference{ResourceId: root, Path: "./" + name}, ri, "")).To(Succeed())
+			}
+
+			// searchAll pages through all matches of the query one match per page
+			searchAll = func(req *searchsvc.SearchIndexRequest) []string {
+				ids := []string{}
+				req.PageSize = 1
+				for {
+					res, err := i.Search(ctx, req)
+					ExpectWithOffset(1, err).ToNot(HaveOccurred())
+					for _, m := range res.Matches {
+						ids = append(ids, m.Entity.Id.OpaqueId)
+					}
+					if res.NextPageToken == "" {
+						return ids
+					}
+					req.PageToken = res.NextPageToken
+				}
+			}
+		)
+
+		Context("by field", func() {
+			JustBeforeEach(func() {
+				add("a", "b-report.txt", 300, now.Add(-2*time.Hour), rootId)
+				add("b", "A-report.txt", 100, now.Add(-time.Hour), rootId)
+				add("c", "c-report.txt", 200, now.Add(-3*time.Hour), rootId)
+				add("d", "d-report.txt", 200, now.Add(-3*time.Hour).Add(time.Microsecond), rootId)
+			})
+
+			It("orders by name ignoring the case", func() {
+				Expect(searchAll(&searchsvc.SearchIndexRequest{Query: "report", OrderBy: "name"})).To(Equal([]string{"b", "a", "c", "d"}))
+				Expect(searchAll(&searchsvc.SearchIndexRequest{Query: "report", OrderBy: "name desc"})).To(Equal([]string{"d", "c", "a", "b"}))
+			})
+
+			It("orders by mtime with second precision", func() {
+				Expect(searchAll(&searchsvc.SearchIndexRequest{Query: "report", OrderBy: "mtime"})).To(Equal([]string{"b", "a", "c", "d"}))
+				Expect(searchAll(&searchsvc.SearchIndexRequest{Query: "report", OrderBy: "mtime asc"})).To(Equal([]string{"c", "d", "a", "b"}))
+			})
+
+			It("orders by size", func() {
+				Expect(searchAll(&searchsvc.SearchIndexRequest{Query: "report", OrderBy: "size"})).To(Equal([]string{"a", "c", "d", "b"}))
+				Expect(searchAll(&searchsvc.SearchIndexRequest{Query: "report", OrderBy: "size asc"})).To(Equal([]string{"b", "c", "d", "a"}))
+			})
+
+			It("rejects unknown orders and page tokens of other orders", func() {
+				_, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "report", OrderBy: "path"})
+				Expect(err).To(BeAssignableToTypeOf(errtypes.BadRequest("")))
+
+				res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "report", OrderBy: "name", PageSize: 1})
+				Expect(err).ToNot(HaveOccurred())
+				_, err = i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "report", OrderBy: "size", PageToken: res.NextPageToken})
+				Expect(err).To(BeAssignableToTypeOf(errtypes.BadRequest("")))
+			})
+		})
+
+		Context("by score", func() {
+			var personalRootID = &sprovider.ResourceId{
+				StorageId: "provider-1",
+				SpaceId:   "personalspace",
+				OpaqueId:  "personalspace",
+			}
+
+			It("ranks recently modified resources higher", func() {
+				add("a", "budget-old.txt", 100, now.Add(-365*24*time.Hour), rootId)
+				add("b", "budget-new.txt", 100, now.Add(-time.Hour), rootId)
+
+				res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "budget"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.Matches).To(HaveLen(2))
+				Expect(res.Matches[0].Entity.Id.OpaqueId).To(Equal("b"))
+				Expect(res.Matches[0].Score).To(BeNumerically(">", 1.9*res.Matches[1].Score))
+
+				// the recency is ranked relative to the requested time
+				res, err = i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "budget", RankedAt: timestamppb.New(now.Add(-365 * 24 * time.Hour))})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.Matches[0].Entity.Id.OpaqueId).To(Equal("a"))
+			})
+
+			It("ranks resources in the personal space of the user higher", func() {
+				add("a", "budget.txt", 100, now, rootId)
+				add("b", "budget.txt", 100, now, personalRootID)
+
+				res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{
+					Query: "budget",
+					PersonalRootId: &searchmsg.ResourceID{
+						StorageId: personalRootID.StorageId,
+						SpaceId:   personalRootID.SpaceId,
+						OpaqueId:  personalRootID.OpaqueId,
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.Matches).To(HaveLen(2))
+				Expect(res.Matches[0].Entity.Id.OpaqueId).To(Equal("b"))
+				Expect(res.Matches[0].Score).To(BeNumerically("~", index.DefaultPersonalSpaceBoost*res.Matches[1].Score, 0.0001))
+			})
+
+			It("ranks as configured", func() {
+				var err error
+				i, err = index.NewMemOnly(index.RecencyBoost(0, 0), index.PersonalSpaceBoost(1))
+				Expect(err).ToNot(HaveOccurred())
+				add("b", "budget.txt", 100, now.Add(-365*24*time.Hour), rootId)
+				add("a", "budget.txt", 100, now, personalRootID)
+
+				res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{
+					Query:          "budget",
+					PersonalRootId: &searchmsg.ResourceID{StorageId: "provider-1", SpaceId: "personalspace", OpaqueId: "personalspace"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.Matches).To(HaveLen(2))
+				Expect(res.Matches[0].Score).To(Equal(res.Matches[1].Score))
+				Expect(res.Matches[0].Entity.Id.OpaqueId).To(Equal("a"))
+			})
+
+			It("keeps the ranking stable while paging", func() {
+				for _, id := range []string{"a", "b", "c", "d", "e"} {
+					add(id, "budget-"+id+".txt", 100, now.Add(-time.Duration(len(id))*time.Hour), rootId)
+				}
+				Expect(searchAll(&searchsvc.SearchIndexRequest{Query: "budget"})).To(ConsistOf("a", "b", "c", "d", "e"))
+			})
+		})
+	})
+
+	Describe("Search with facets", func() {
+		JustBeforeEach(func() {
+			now := time.Now()
+			for _, f := range []struct {
+				id       string
+				mimeType string
+				size     uint64
+				mtime    time.Time
+			}{
+				{"a", "application/pdf", 50000, now},
+				{"b", "image/png", 2000000, now.AddDate(0, 0, -3)},
+				{"c", "text/csv", 3000000, now.AddDate(0, -2, 0)},
+				{"d", "application/x-unknown", 5000000000, now.AddDate(-2, 0, 0)},
+			} {
+				ri.Id.OpaqueId = "opaqueid-" + f.id
+				ri.Name = "facet-" + f.id
+				ri.MimeType = f.mimeType
+				ri.Size = f.size
+				ri.Mtime = &typesv1beta1.Timestamp{Seconds: uint64(f.mtime.Unix())}
+				ref.Path = "./" + ri.Name
+				err := i.Add(ref, ri, "")
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		counts := func(facets []*searchmsg.Facet, name string) map[string]int32 {
+			c := map[string]int32{}
+			for _, f := range facets {
+				if f.Name != name {
+					continue
+				}
+				for _, t := range f.Terms {
+					c[t.Term] = t.Count
+				}
+			}
+			return c
+		}
+
+		It("does not return facets unless requested", func() {
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "Name:facet*"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Facets).To(BeEmpty())
+		})
+
+		It("counts the matches per mime type group", func() {
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "Name:facet*", Facets: []string{"mimetype"}})
+			Expect(err).ToNot(HaveOccurred())
+			c := counts(res.Facets, "mimetype")
+			Expect(c["pdf"]).To(Equal(int32(1)))
+			Expect(c["image"]).To(Equal(int32(1)))
+			Expect(c["spreadsheet"]).To(Equal(int32(1)))
+			Expect(c["other"]).To(Equal(int32(1)))
+			Expect(c["document"]).To(Equal(int32(0)))
+		})
+
+		It("counts the matches per size bucket", func() {
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "Name:facet*", Facets: []string{"size"}})
+			Expect(err).ToNot(HaveOccurred())
+			c := counts(res.Facets, "size")
+			Expect(c).To(Equal(map[string]int32{
+				"0-100KB":    1,
+				"100KB-1MB":  0,
+				"1MB-10MB":   2,
+				"10MB-100MB": 0,
+				"100MB-1GB":  0,
+				"1GB+":       1,
+			}))
+		})
+
+		It("counts the matches per modification time range", func() {
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "Name:facet*", Facets: []string{"mtime"}})
+			Expect(err).ToNot(HaveOccurred())
+			c := counts(res.Facets, "mtime")
+			Expect(c["last 7 days"]).To(Equal(int32(2)))
+			Expect(c["last 30 days"]).To(Equal(int32(2)))
+			Expect(c["last year"]).To(Equal(int32(3)))
+			Expect(c["older"]).To(Equal(int32(1)))
+		})
+
+		It("counts the matches per space", func() {
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "Name:facet*", Facets: []string{"space"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(counts(res.Facets, "space")).To(Equal(map[string]int32{"provider-1$spaceid!rootopaqueid": 4}))
+		})
+
+		It("counts all matches regardless of the page", func() {
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "Name:facet*", PageSize: 1, Facets: []string{"space"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Matches).To(HaveLen(1))
+			Expect(counts(res.Facets, "space")["provider-1$spaceid!rootopaqueid"]).To(Equal(int32(4)))
+		})
+
+		It("rejects unknown facets", func() {
+			_, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "Name:facet*", Facets: []string{"color"}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("Add", func() {
 		It("adds a resourceInfo to the index", func() {
-			err := i.Add(ref, ri)
+			err := i.Add(ref, ri, "")
 			Expect(err).ToNot(HaveOccurred())
 
-			count, err := bleveIndex.DocCount()
+			count, err := i.DocCount()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(count).To(Equal(uint64(1)))
 
-			query := bleve.NewMatchQuery("foo.pdf")
-			res, err := bleveIndex.Search(bleve.NewSearchRequest(query))
-			Expect(err).ToNot(HaveOccurred())
-			Expect(res.Hits.Len()).To(Equal(1))
+			assertDocCount(rootId, "foo.pdf", 1)
 		})
 
 		It("updates an existing resource in the index", func() {
-			err := i.Add(ref, ri)
+			err := i.Add(ref, ri, "")
+			Expect(err).ToNot(HaveOccurred())
+			count, _ := i.DocCount()
+			Expect(count).To(Equal(uint64(1)))
+
+			err = i.Add(ref, ri, "")
 			Expect(err).ToNot(HaveOccurred())
-			count, _ := bleveIndex.DocCount()
+			count, _ = i.DocCount()
 			Expect(count).To(Equal(uint64(1)))
+		})
+	})
+
+	Describe("Tags and favorites", func() {
+		var (
+			searchAs = func(userID, query string) []*searchmsg.Match {
+				res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: query, UserId: userID})
+				ExpectWithOffset(1, err).ToNot(HaveOccurred())
+				return res.Matches
+			}
+		)
+
+		JustBeforeEach(func() {
+			ri.ArbitraryMetadata = &sprovider.ArbitraryMetadata{
+				Metadata: map[string]string{"tags": "invoice-2022, Tax,invoice-2022"},
+			}
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+		})
+
+		It("indexes the tags from the arbitrary metadata", func() {
+			entity, err := i.Get(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entity.Tags).To(Equal([]string{"invoice-2022", "Tax"}))
+		})
+
+		It("finds resources by their tags", func() {
+			assertDocCount(rootId, `tag:invoice-2022`, 1)
+			assertDocCount(rootId, `tag:tax`, 1)
+			assertDocCount(rootId, `tag:invoice*`, 1)
+			assertDocCount(rootId, `tag:invoice`, 0)
+		})
+
+		It("replaces the tags when the resource is indexed again", func() {
+			ri.ArbitraryMetadata.Metadata["tags"] = "paid"
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+			assertDocCount(rootId, `tag:paid`, 1)
+			assertDocCount(rootId, `tag:tax`, 0)
+
+			delete(ri.ArbitraryMetadata.Metadata, "tags")
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+			assertDocCount(rootId, `tag:paid`, 0)
+		})
+
+		It("finds the favorites the owner marked", func() {
+			ri.Owner = &userv1beta1.UserId{OpaqueId: "einstein"}
+			ri.ArbitraryMetadata.Metadata[search.FavoriteMetadataKey] = "1"
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+
+			Expect(searchAs("einstein", `favorite:true`)).To(HaveLen(1))
+			Expect(searchAs("richard", `favorite:true`)).To(HaveLen(0))
+			Expect(searchAs("richard", `foo favorite:false`)).To(HaveLen(1))
+
+			ri.ArbitraryMetadata.Metadata[search.FavoriteMetadataKey] = "0"
+			batch, err := i.NewBatch(10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batch.Add(ref, ri, "")).To(Succeed())
+			Expect(batch.Push()).To(Succeed())
+			Expect(searchAs("einstein", `favorite:true`)).To(HaveLen(0))
+		})
+
+		It("keeps the tags and favorites when the resource is moved", func() {
+			ri.Owner = &userv1beta1.UserId{OpaqueId: "einstein"}
+			ri.ArbitraryMetadata.Metadata[search.FavoriteMetadataKey] = "1"
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+			Expect(i.Move(ri.Id, ri.ParentId, "./bar.pdf")).To(Succeed())
+
+			Expect(searchAs("einstein", `favorite:true tag:tax`)).To(HaveLen(1))
+		})
+
+		It("rejects invalid favorite restrictions", func() {
+			_, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: `favorite:maybe`})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Get", func() {
+		It("returns the indexed entity including its etag", func() {
+			ri.Etag = "etag-1"
+			err := i.Add(ref, ri, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			entity, err := i.Get(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entity.Id.OpaqueId).To(Equal(ri.Id.OpaqueId))
+			Expect(entity.Ref.Path).To(Equal(ref.Path))
+			Expect(entity.Etag).To(Equal("etag-1"))
+		})
+
+		It("returns entities marked as deleted", func() {
+			err := i.Add(ref, ri, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Delete(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+
+			entity, err := i.Get(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entity.Deleted).To(BeTrue())
+		})
+
+		It("returns a not found error for unknown resources", func() {
+			_, err := i.Get(ri.Id)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.NotFound("")))
+		})
+	})
+
+	Describe("ListSpace", func() {
+		It("returns all entities of the space", func() {
+			err := i.Add(parentRef, parentRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Add(childRef, childRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Delete(childRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+			otherRef := &sprovider.Reference{
+				ResourceId: &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "otherspaceid", OpaqueId: "otherspaceid"},
+				Path:       "./" + filename,
+			}
+			err = i.Add(otherRef, ri, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			entities, err := listSpace(rootId)
+			Expect(err).ToNot(HaveOccurred())
+			paths := []string{}
+			for _, e := range entities {
+				paths = append(paths, e.Ref.Path)
+			}
+			Expect(paths).To(ConsistOf("./my/sub d!r", "./my/sub d!r/child.pdf"))
+		})
+
+		It("lists spaces with more entities than fit on a page", func() {
+			batch, err := i.NewBatch(500)
+			Expect(err).ToNot(HaveOccurred())
+			for n := 0; n < 2500; n++ {
+				err := batch.Add(
+					&sprovider.Reference{ResourceId: rootId, Path: fmt.Sprintf("./file-%d.txt", n)},
+					&sprovider.ResourceInfo{
+						Id:   &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: fmt.Sprintf("file-%d", n)},
+						Path: fmt.Sprintf("file-%d.txt", n),
+						Name: fmt.Sprintf("file-%d.txt", n),
+						Type: sprovider.ResourceType_RESOURCE_TYPE_FILE,
+					}, "")
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(batch.Push()).To(Succeed())
+
+			seen := map[string]bool{}
+			err = i.ListSpace(rootId, func(e *searchmsg.Entity) error {
+				Expect(seen[e.Id.OpaqueId]).To(BeFalse())
+				seen[e.Id.OpaqueId] = true
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(seen).To(HaveLen(2500))
+		})
+	})
+
+	Describe("NewBatch", func() {
+		It("applies the operations when pushed", func() {
+			err := i.Add(ref, ri, "")
+			Expect(err).ToNot(HaveOccurred())
 
-			err = i.Add(ref, ri)
+			batch, err := i.NewBatch(10)
 			Expect(err).ToNot(HaveOccurred())
-			count, _ = bleveIndex.DocCount()
+			Expect(batch.Add(parentRef, parentRi, "")).To(Succeed())
+			Expect(batch.Add(childRef, childRi, "")).To(Succeed())
+			Expect(batch.Purge(ri.Id)).To(Succeed())
+
+			count, _ := i.DocCount()
 			Expect(count).To(Equal(uint64(1)))
+
+			Expect(batch.Push()).To(Succeed())
+			count, _ = i.DocCount()
+			Expect(count).To(Equal(uint64(2)))
+			_, err = i.Get(ri.Id)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.NotFound("")))
+		})
+
+		It("pushes the operations once the batch is full", func() {
+			batch, err := i.NewBatch(2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batch.Add(parentRef, parentRi, "")).To(Succeed())
+			count, _ := i.DocCount()
+			Expect(count).To(Equal(uint64(0)))
+
+			Expect(batch.Add(childRef, childRi, "")).To(Succeed())
+			count, _ = i.DocCount()
+			Expect(count).To(Equal(uint64(2)))
 		})
 	})
 
 	Describe("Delete", func() {
 		It("marks a resource as deleted", func() {
-			err := i.Add(parentRef, parentRi)
+			err := i.Add(parentRef, parentRi, "")
 			Expect(err).ToNot(HaveOccurred())
 			assertDocCount(rootId, `sub\ d!r`, 1)
 
@@ -353,9 +1177,9 @@ var _ = Describe("Index", func() {
 		})
 
 		It("also marks child resources as deleted", func() {
-			err := i.Add(parentRef, parentRi)
+			err := i.Add(parentRef, parentRi, "")
 			Expect(err).ToNot(HaveOccurred())
-			err = i.Add(childRef, childRi)
+			err = i.Add(childRef, childRi, "")
 			Expect(err).ToNot(HaveOccurred())
 
 			assertDocCount(rootId, `sub\ d\!r`, 1)
@@ -367,13 +1191,77 @@ var _ = Describe("Index", func() {
 			assertDocCount(rootId, `sub\ d\!r`, 0)
 			assertDocCount(rootId, "child.pdf", 0)
 		})
+
+		It("records the trash keys", func() {
+			err := i.Add(parentRef, parentRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Add(childRef, childRi, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = i.Delete(parentRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+
+			parent, err := i.Get(parentRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parent.TrashKey).To(Equal("parentopaqueid"))
+			child, err := i.Get(childRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(child.TrashKey).To(Equal("parentopaqueid/child.pdf"))
+		})
+
+		It("keeps the trash key of children deleted before", func() {
+			err := i.Add(parentRef, parentRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Add(childRef, childRi, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = i.Delete(childRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Delete(parentRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+
+			child, err := i.Get(childRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(child.TrashKey).To(Equal("childopaqueid"))
+		})
+	})
+
+	Describe("Search in the trash", func() {
+		assertTrashCount := func(query string, expectedCount int) []*searchmsg.Match {
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{
+				Query: query,
+				Scope: "trash",
+				Ref: &searchmsg.Reference{
+					ResourceId: &searchmsg.ResourceID{StorageId: "provider-1", SpaceId: rootId.SpaceId, OpaqueId: rootId.OpaqueId},
+				},
+			})
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			ExpectWithOffset(1, res.Matches).To(HaveLen(expectedCount))
+			return res.Matches
+		}
+
+		It("only finds deleted resources", func() {
+			err := i.Add(parentRef, parentRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Add(childRef, childRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			assertTrashCount("child.pdf", 0)
+
+			err = i.Delete(parentRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+
+			matches := assertTrashCount("child.pdf", 1)
+			Expect(matches[0].Entity.Deleted).To(BeTrue())
+			Expect(matches[0].Entity.TrashKey).To(Equal("parentopaqueid/child.pdf"))
+			assertDocCount(rootId, "child.pdf", 0)
+		})
 	})
 
 	Describe("Restore", func() {
 		It("also marks child resources as restored", func() {
-			err := i.Add(parentRef, parentRi)
+			err := i.Add(parentRef, parentRi, "")
 			Expect(err).ToNot(HaveOccurred())
-			err = i.Add(childRef, childRi)
+			err = i.Add(childRef, childRi, "")
 			Expect(err).ToNot(HaveOccurred())
 			err = i.Delete(parentRi.Id)
 			Expect(err).ToNot(HaveOccurred())
@@ -387,13 +1275,272 @@ var _ = Describe("Index", func() {
 			assertDocCount(rootId, `sub\ d!r`, 1)
 			assertDocCount(rootId, "child.pdf", 1)
 		})
+
+		It("does not restore children which have been deleted on their own", func() {
+			err := i.Add(parentRef, parentRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Add(childRef, childRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Delete(childRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Delete(parentRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = i.Restore(parentRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+
+			assertDocCount(rootId, `sub\ d!r`, 1)
+			assertDocCount(rootId, "child.pdf", 0)
+			parent, err := i.Get(parentRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parent.TrashKey).To(BeEmpty())
+		})
+	})
+
+	Describe("PurgeSpace", func() {
+		It("removes all resources of the space", func() {
+			err := i.Add(parentRef, parentRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Add(childRef, childRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			otherRef := &sprovider.Reference{
+				ResourceId: &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "otherspaceid", OpaqueId: "otherspaceid"},
+				Path:       "./" + filename,
+			}
+			ri.Id.SpaceId = "otherspaceid"
+			err = i.Add(otherRef, ri, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = i.PurgeSpace(rootId)
+			Expect(err).ToNot(HaveOccurred())
+
+			count, _ := i.DocCount()
+			Expect(count).To(Equal(uint64(1)))
+			_, err = i.Get(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = i.Get(childRi.Id)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.NotFound("")))
+
+			// the space can be indexed again
+			err = i.Add(childRef, childRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			assertDocCount(rootId, "child.pdf", 1)
+		})
+
+		It("removes the directory of the space", func() {
+			dir := GinkgoT().TempDir()
+			i, err := index.NewPersisted(dir)
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(i.Close)
+			Expect(i.Add(childRef, childRi, "")).To(Succeed())
+			entries, err := os.ReadDir(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+
+			Expect(i.PurgeSpace(rootId)).To(Succeed())
+			entries, err = os.ReadDir(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		It("drops the index of the space once it isn't used anymore", func() {
+			dir := GinkgoT().TempDir()
+			i, err := index.NewPersisted(dir)
+			Expect(err).ToNot(HaveOccurred())
+			DeferCleanup(i.Close)
+			Expect(i.Add(childRef, childRi, "")).To(Succeed())
+
+			done := make(chan error)
+			err = i.ListSpace(rootId, func(*searchmsg.Entity) error {
+				go func() { done <- i.PurgeSpace(rootId) }()
+				Eventually(done).Should(Receive(BeNil()))
+				// the index isn't dropped while the space is listed
+				entries, err := os.ReadDir(dir)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(entries).To(HaveLen(1))
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			entries, err := os.ReadDir(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Describe("Spaces", func() {
+		var otherRootId = &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "otherspaceid", OpaqueId: "otherspaceid"}
+
+		JustBeforeEach(func() {
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+			ri.Id = &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "otherspaceid", OpaqueId: "otheropaqueid"}
+			Expect(i.Add(&sprovider.Reference{ResourceId: otherRootId, Path: "./" + filename}, ri, "")).To(Succeed())
+		})
+
+		It("limits searches in a space to the space", func() {
+			Expect(assertDocCount(rootId, "foo.pdf", 1)[0].Entity.Id.OpaqueId).To(Equal("opaqueid"))
+			Expect(assertDocCount(otherRootId, "foo.pdf", 1)[0].Entity.Id.OpaqueId).To(Equal("otheropaqueid"))
+			assertDocCount(&sprovider.ResourceId{StorageId: "provider-1", SpaceId: "unknown", OpaqueId: "unknown"}, "foo.pdf", 0)
+		})
+
+		It("searches all spaces", func() {
+			res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: "foo.pdf", Facets: []string{"space"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.TotalMatches).To(Equal(int32(2)))
+			Expect(res.Matches).To(HaveLen(2))
+			Expect(res.Facets).To(HaveLen(1))
+			Expect(res.Facets[0].Terms).To(HaveLen(2))
+		})
+
+		It("lists the resources of a space", func() {
+			entities, err := listSpace(otherRootId)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entities).To(HaveLen(1))
+			Expect(entities[0].Id.OpaqueId).To(Equal("otheropaqueid"))
+		})
+	})
+
+	Describe("AddSpace", func() {
+		var (
+			space = &sprovider.StorageSpace{
+				Opaque: &typesv1beta1.Opaque{
+					Map: map[string]*typesv1beta1.OpaqueEntry{
+						"description": {Decoder: "plain", Value: []byte("Quarterly numbers")},
+					},
+				},
+				Id:        &sprovider.StorageSpaceId{OpaqueId: "provider-1$spaceid"},
+				Root:      rootId,
+				Name:      "Finance",
+				SpaceType: "project",
+				Mtime:     &typesv1beta1.Timestamp{Seconds: 4000},
+			}
+			searchSpaces = func(query string) []*searchmsg.Match {
+				res, err := i.Search(ctx, &searchsvc.SearchIndexRequest{Query: query, Scope: "spaces"})
+				ExpectWithOffset(1, err).ToNot(HaveOccurred())
+				return res.Matches
+			}
+		)
+
+		JustBeforeEach(func() {
+			Expect(i.Add(ref, ri, "")).To(Succeed())
+			Expect(i.AddSpace(space, "Budget planning for the next year")).To(Succeed())
+		})
+
+		It("finds spaces by their name, description and readme", func() {
+			Expect(searchSpaces("finance")).To(HaveLen(1))
+			Expect(searchSpaces("content:quarterly")).To(HaveLen(1))
+			Expect(searchSpaces("content:budget")).To(HaveLen(1))
+			Expect(searchSpaces("foo.pdf")).To(BeEmpty())
+		})
+
+		It("returns the space with the match", func() {
+			matches := searchSpaces("finance")
+			Expect(matches).To(HaveLen(1))
+			Expect(matches[0].Space).ToNot(BeNil())
+			Expect(matches[0].Space.Id).To(Equal("provider-1$spaceid"))
+			Expect(matches[0].Space.Name).To(Equal("Finance"))
+			Expect(matches[0].Space.Description).To(Equal("Quarterly numbers"))
+			Expect(matches[0].Space.SpaceType).To(Equal("project"))
+			Expect(matches[0].Space.RootId.OpaqueId).To(Equal(rootId.OpaqueId))
+		})
+
+		It("replaces the space when it is added again", func() {
+			renamed := *space
+			renamed.Name = "Accounting"
+			Expect(i.AddSpace(&renamed, "")).To(Succeed())
+			Expect(searchSpaces("finance")).To(BeEmpty())
+			Expect(searchSpaces("accounting")).To(HaveLen(1))
+			Expect(searchSpaces("content:budget")).To(BeEmpty())
+		})
+
+		It("does not return spaces when searching files", func() {
+			matches := assertDocCount(rootId, "finance", 0)
+			Expect(matches).To(BeEmpty())
+			matches = assertDocCount(rootId, "foo.pdf", 1)
+			Expect(matches[0].Space).To(BeNil())
+		})
+
+		It("does not list the space with its resources", func() {
+			entities, err := listSpace(rootId)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entities).To(HaveLen(1))
+			Expect(entities[0].Id.OpaqueId).To(Equal("opaqueid"))
+		})
+
+		It("removes the space when it is purged", func() {
+			Expect(i.PurgeSpace(rootId)).To(Succeed())
+			Expect(searchSpaces("finance")).To(BeEmpty())
+		})
+	})
+
+	Describe("PurgeDeleted", func() {
+		It("removes the resources deleted before the given time", func() {
+			err := i.Add(parentRef, parentRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Add(childRef, childRi, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Add(ref, ri, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Delete(parentRi.Id)
+			Expect(err).ToNot(HaveOccurred())
+
+			purged, err := i.PurgeDeleted(time.Now().Add(-time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(purged).To(Equal(0))
+
+			purged, err = i.PurgeDeleted(time.Now().Add(time.Second))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(purged).To(Equal(2))
+			count, _ := i.DocCount()
+			Expect(count).To(Equal(uint64(1)))
+		})
+
+		It("does not remove restored resources", func() {
+			err := i.Add(ref, ri, "")
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Delete(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+			err = i.Restore(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+
+			purged, err := i.PurgeDeleted(time.Now().Add(time.Second))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(purged).To(Equal(0))
+		})
+
+		It("removes the resources deleted without a deletion time", func() {
+			dir := GinkgoT().TempDir()
+			persisted, err := index.NewPersisted(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(persisted.Add(ref, ri, "")).To(Succeed())
+			Expect(persisted.Close()).To(Succeed())
+
+			// documents deleted by former versions don't carry a deletion time
+			entries, err := os.ReadDir(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+			bleveIndex, err := bleve.Open(filepath.Join(dir, entries[0].Name()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bleveIndex.Index("provider-1$spaceid!legacy", map[string]interface{}{
+				"RootID": "provider-1$spaceid!rootopaqueid", "ID": "provider-1$spaceid!legacy", "Name": "legacy.pdf", "Deleted": true,
+			})).To(Succeed())
+			Expect(bleveIndex.Close()).To(Succeed())
+
+			persisted, err = index.NewPersisted(dir)
+			Expect(err).ToNot(HaveOccurred())
+			purged, err := persisted.PurgeDeleted(time.Now().Add(-time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(purged).To(Equal(1))
+			count, _ := persisted.DocCount()
+			Expect(count).To(Equal(uint64(1)))
+			Expect(persisted.Close()).To(Succeed())
+		})
 	})
 
 	Describe("Move", func() {
 		It("renames the parent and its child resources", func() {
-			err := i.Add(parentRef, parentRi)
+			err := i.Add(parentRef, parentRi, "")
 			Expect(err).ToNot(HaveOccurred())
-			err = i.Add(childRef, childRi)
+			err = i.Add(childRef, childRi, "")
 			Expect(err).ToNot(HaveOccurred())
 
 			parentRi.Path = "newname"
@@ -408,9 +1555,9 @@ var _ = Describe("Index", func() {
 		})
 
 		It("moves the parent and its child resources", func() {
-			err := i.Add(parentRef, parentRi)
+			err := i.Add(parentRef, parentRi, "")
 			Expect(err).ToNot(HaveOccurred())
-			err = i.Add(childRef, childRi)
+			err = i.Add(childRef, childRi, "")
 			Expect(err).ToNot(HaveOccurred())
 
 			parentRi.Path = " "
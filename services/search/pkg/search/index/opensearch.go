@@ -0,0 +1,731 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
+	"github.com/cs3org/reva/v2/pkg/storagespace"
+	"github.com/cs3org/reva/v2/pkg/utils"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	searchpkg "github.com/owncloud/ocis/v2/services/search/pkg/search"
+)
+
+// openSearchNameMapping is the mapping of the name. Besides the whole name its words and the prefixes
+// of its words are indexed to find resources by parts of their names.
+var openSearchNameMapping = map[string]interface{}{
+	"type":       "keyword",
+	"normalizer": "lowercase",
+	"fields": map[string]interface{}{
+		"words":    map[string]interface{}{"type": "text", "analyzer": "name_words"},
+		"prefixes": map[string]interface{}{"type": "text", "analyzer": "name_prefixes", "search_analyzer": "name_words"},
+	},
+}
+
+// openSearchMapping is the mapping of the index documents used when creating the index
+var openSearchMapping = map[string]interface{}{
+	"settings": map[string]interface{}{
+		"analysis": map[string]interface{}{
+			"normalizer": map[string]interface{}{
+				"lowercase": map[string]interface{}{
+					"type":   "custom",
+					"filter": []string{"lowercase"},
+				},
+			},
+			"tokenizer": map[string]interface{}{
+				"name_words": map[string]interface{}{
+					"type":    "pattern",
+					"pattern": nameWordsPattern,
+					"group":   0,
+				},
+			},
+			"filter": map[string]interface{}{
+				"name_prefixes": map[string]interface{}{
+					"type":     "edge_ngram",
+					"min_gram": 1,
+					"max_gram": 30,
+				},
+			},
+			"analyzer": map[string]interface{}{
+				"name_words": map[string]interface{}{
+					"type":      "custom",
+					"tokenizer": "name_words",
+					"filter":    []string{"lowercase"},
+				},
+				"name_prefixes": map[string]interface{}{
+					"type":      "custom",
+					"tokenizer": "name_words",
+					"filter":    []string{"lowercase", "name_prefixes"},
+				},
+			},
+		},
+	},
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"RootID":      map[string]interface{}{"type": "keyword"},
+			"Path":        map[string]interface{}{"type": "keyword"},
+			"ID":          map[string]interface{}{"type": "keyword"},
+			"ParentID":    map[string]interface{}{"type": "keyword"},
+			"Name":        openSearchNameMapping,
+			"Size":        map[string]interface{}{"type": "long"},
+			"Mtime":       map[string]interface{}{"type": "date"},
+			"MimeType":    map[string]interface{}{"type": "keyword"},
+			"Type":        map[string]interface{}{"type": "long"},
+			"Etag":        map[string]interface{}{"type": "keyword"},
+			"Content":     map[string]interface{}{"type": "text"},
+			"Tags":        map[string]interface{}{"type": "keyword", "normalizer": "lowercase"},
+			"Favorites":   map[string]interface{}{"type": "keyword"},
+			"Deleted":     map[string]interface{}{"type": "boolean"},
+			"TrashKey":    map[string]interface{}{"type": "keyword"},
+			"DeletedAt":   map[string]interface{}{"type": "date"},
+			"Hidden":      map[string]interface{}{"type": "boolean"},
+			"Space":       map[string]interface{}{"type": "boolean"},
+			"SpaceType":   map[string]interface{}{"type": "keyword"},
+			"Description": map[string]interface{}{"type": "text", "index": false},
+		},
+	},
+}
+
+// openSearchPageSize is the number of documents fetched at once when listing a space
+const openSearchPageSize = 1000
+
+// OpenSearch is an index implementation using an OpenSearch or Elasticsearch cluster via its REST API
+type OpenSearch struct {
+	url      string
+	index    string
+	username string
+	password string
+	client   *http.Client
+	options  Options
+}
+
+// NewOpenSearch returns a new OpenSearch index using the given index of the cluster at the given url.
+// The index is created if it doesn't exist yet.
+func NewOpenSearch(baseURL, index, username, password string, insecure bool, opts ...Option) (*OpenSearch, error) {
+	o := &OpenSearch{
+		url:      strings.TrimSuffix(baseURL, "/"),
+		index:    index,
+		username: username,
+		password: password,
+		options:  newOptions(opts...),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					MinVersion:         tls.VersionTLS12,
+					InsecureSkipVerify: insecure, //nolint:gosec
+				},
+			},
+		},
+	}
+	if err := o.ensureIndex(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// openSearchError is returned when the cluster answers with an unexpected status
+type openSearchError struct {
+	status int
+	body   string
+}
+
+func (e *openSearchError) Error() string {
+	return fmt.Sprintf("opensearch responded with status %d: %s", e.status, e.body)
+}
+
+// do sends a request with the given JSON body to the cluster and decodes the response into result.
+// Responses with one of the accepted status codes are not treated as errors. The request is canceled
+// when the context is done.
+func (o *OpenSearch) do(ctx context.Context, method, endpoint string, body interface{}, result interface{}, accepted ...int) (int, error) {
+	var reader io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(b)
+		contentType = "application/x-ndjson"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, o.url+endpoint, reader)
+	if err != nil {
+		return 0, err
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if o.username != "" {
+		req.SetBasicAuth(o.username, o.password)
+	}
+
+	res, err := o.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	ok := res.StatusCode >= 200 && res.StatusCode < 300
+	for _, s := range accepted {
+		ok = ok || res.StatusCode == s
+	}
+	if !ok {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return res.StatusCode, &openSearchError{status: res.StatusCode, body: string(msg)}
+	}
+	if result != nil && res.StatusCode >= 200 && res.StatusCode < 300 {
+		if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+			return res.StatusCode, err
+		}
+	}
+	return res.StatusCode, nil
+}
+
+func (o *OpenSearch) ensureIndex() error {
+	status, err := o.do(context.Background(), http.MethodHead, "/"+url.PathEscape(o.index), nil, nil, http.StatusNotFound)
+	if err != nil || status != http.StatusNotFound {
+		return err
+	}
+	status, err = o.do(context.Background(), http.MethodPut, "/"+url.PathEscape(o.index), openSearchMapping, nil, http.StatusBadRequest)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusBadRequest {
+		// another instance might have created the index in the meantime
+		_, err = o.do(context.Background(), http.MethodHead, "/"+url.PathEscape(o.index), nil, nil)
+	}
+	return err
+}
+
+func (o *OpenSearch) docEndpoint(id string) string {
+	return "/" + url.PathEscape(o.index) + "/_doc/" + url.PathEscape(id)
+}
+
+func (o *OpenSearch) updateEndpoint(id string) string {
+	return "/" + url.PathEscape(o.index) + "/_update/" + url.PathEscape(id)
+}
+
+// DocCount returns the number of elements in the index
+func (o *OpenSearch) DocCount() (uint64, error) {
+	res := struct {
+		Count uint64 `json:"count"`
+	}{}
+	_, err := o.do(context.Background(), http.MethodGet, "/"+url.PathEscape(o.index)+"/_count", nil, &res)
+	return res.Count, err
+}
+
+// Add adds a new entity with the given extracted content to the index. An already indexed entity is
+// replaced, its tags and favorites are taken from the metadata of the resource like all other fields.
+func (o *OpenSearch) Add(ref *sprovider.Reference, ri *sprovider.ResourceInfo, content string) error {
+	doc := toEntity(ref, ri)
+	doc.Content = content
+	_, err := o.do(context.Background(), http.MethodPut, o.docEndpoint(doc.ID)+"?refresh=wait_for", openSearchSource(doc), nil)
+	return err
+}
+
+// AddSpace adds the document describing the given space with the given readme content to the index
+func (o *OpenSearch) AddSpace(space *sprovider.StorageSpace, readme string) error {
+	doc := toSpaceDocument(space, readme)
+	_, err := o.do(context.Background(), http.MethodPut, o.docEndpoint(doc.ID)+"?refresh=wait_for", openSearchSource(doc), nil)
+	return err
+}
+
+// Get returns the entity with the given id. Entities marked as deleted are returned as well.
+func (o *OpenSearch) Get(id *sprovider.ResourceId) (*searchmsg.Entity, error) {
+	doc, err := o.getDocument(idToBleveId(id))
+	if err != nil {
+		return nil, err
+	}
+	return documentToEntity(doc)
+}
+
+func (o *OpenSearch) getDocument(id string) (*indexDocument, error) {
+	res := struct {
+		Found  bool           `json:"found"`
+		Source *indexDocument `json:"_source"`
+	}{}
+	status, err := o.do(context.Background(), http.MethodGet, o.docEndpoint(id), nil, &res, http.StatusNotFound)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound || !res.Found || res.Source == nil {
+		return nil, errtypes.NotFound(id)
+	}
+	return res.Source, nil
+}
+
+// ListSpace calls fn with all entities of the space with the given root, including the ones marked as
+// deleted. The document describing the space itself is left out. The entities are read page by page, so
+// that large spaces don't have to be held in memory.
+func (o *OpenSearch) ListSpace(rootID *sprovider.ResourceId, fn func(*searchmsg.Entity) error) error {
+	var searchAfter []interface{}
+	for {
+		req := map[string]interface{}{
+			"size": openSearchPageSize,
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"filter":   []interface{}{termQuery("RootID", idToBleveId(rootID))},
+					"must_not": []interface{}{termQuery("Space", true)},
+				},
+			},
+			"sort":    []interface{}{map[string]interface{}{"ID": "asc"}},
+			"_source": map[string]interface{}{"excludes": []string{"Content"}},
+		}
+		if searchAfter != nil {
+			req["search_after"] = searchAfter
+		}
+		res := openSearchResponse{}
+		if _, err := o.do(context.Background(), http.MethodPost, "/"+url.PathEscape(o.index)+"/_search", req, &res); err != nil {
+			return err
+		}
+
+		for _, h := range res.Hits.Hits {
+			entity, err := documentToEntity(h.Source)
+			if err != nil {
+				return err
+			}
+			if err := fn(entity); err != nil {
+				return err
+			}
+		}
+		if len(res.Hits.Hits) < openSearchPageSize {
+			return nil
+		}
+		searchAfter = res.Hits.Hits[len(res.Hits.Hits)-1].Sort
+	}
+}
+
+// Delete marks an entity and its children as deleted (still keeping them around). The entities
+// remember their key in the trash bin so that they can be found when searching the trash.
+func (o *OpenSearch) Delete(id *sprovider.ResourceId) error {
+	doc, err := o.getDocument(idToBleveId(id))
+	if err != nil {
+		return err
+	}
+
+	// children which have been deleted before live in the trash bin on their own
+	query := anyOf(termQuery("ID", doc.ID), map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter":   []interface{}{subtreeQuery(doc.RootID, doc.Path)},
+			"must_not": []interface{}{termQuery("Deleted", true)},
+		},
+	})
+	return o.updateByQuery(query,
+		"ctx._source.Deleted = true; ctx._source.DeletedAt = params.now; "+
+			"ctx._source.TrashKey = params.key + ctx._source.Path.substring(params.path.length())",
+		map[string]interface{}{
+			"key":  id.GetOpaqueId(),
+			"path": doc.Path,
+			"now":  time.Now().UTC().Format(time.RFC3339Nano),
+		},
+	)
+}
+
+// Restore marks an entity and the children which have been deleted along with it as not being deleted
+func (o *OpenSearch) Restore(id *sprovider.ResourceId) error {
+	doc, err := o.getDocument(idToBleveId(id))
+	if err != nil {
+		return err
+	}
+
+	query := anyOf(termQuery("ID", doc.ID), map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []interface{}{
+				subtreeQuery(doc.RootID, doc.Path),
+				// documents deleted before trash keys were recorded don't have one
+				anyOf(
+					prefixQuery("TrashKey", id.GetOpaqueId()+"/"),
+					termQuery("TrashKey", ""),
+					missingQuery("TrashKey"),
+				),
+			},
+		},
+	})
+	return o.updateByQuery(query, "ctx._source.Deleted = false; ctx._source.TrashKey = ''; ctx._source.remove('DeletedAt')", nil)
+}
+
+// Purge removes an entity from the index
+func (o *OpenSearch) Purge(id *sprovider.ResourceId) error {
+	_, err := o.do(context.Background(), http.MethodDelete, o.docEndpoint(idToBleveId(id))+"?refresh=wait_for", nil, nil, http.StatusNotFound)
+	return err
+}
+
+// PurgeSpace removes all entities of the space with the given root from the index
+func (o *OpenSearch) PurgeSpace(rootID *sprovider.ResourceId) error {
+	_, err := o.deleteByQuery(termQuery("RootID", idToBleveId(rootID)))
+	return err
+}
+
+// PurgeDeleted removes the entities which have been marked as deleted before the given point in time
+// from the index. Entities marked as deleted without a deletion time are removed as well. It returns the
+// number of purged entities.
+func (o *OpenSearch) PurgeDeleted(deletedBefore time.Time) (int, error) {
+	return o.deleteByQuery(map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []interface{}{
+				termQuery("Deleted", true),
+			},
+			"must_not": []interface{}{
+				rangeQuery("DeletedAt", map[string]interface{}{"gte": deletedBefore.UTC().Format(time.RFC3339Nano)}),
+			},
+		},
+	})
+}
+
+func (o *OpenSearch) deleteByQuery(query map[string]interface{}) (int, error) {
+	res := struct {
+		Deleted int `json:"deleted"`
+	}{}
+	_, err := o.do(context.Background(), http.MethodPost, "/"+url.PathEscape(o.index)+"/_delete_by_query?refresh=true&conflicts=proceed",
+		map[string]interface{}{"query": query}, &res)
+	return res.Deleted, err
+}
+
+// Move update the path of an entry and all its children
+func (o *OpenSearch) Move(id, newParentID *sprovider.ResourceId, fullPath string) error {
+	bleveID := idToBleveId(id)
+	doc, err := o.getDocument(bleveID)
+	if err != nil {
+		return err
+	}
+	oldName := doc.Path
+	newName := utils.MakeRelativePath(fullPath)
+
+	update := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"Path":     newName,
+			"Name":     path.Base(newName),
+			"ParentID": idToBleveId(newParentID),
+		},
+	}
+	if _, err := o.do(context.Background(), http.MethodPost, o.updateEndpoint(bleveID)+"?refresh=wait_for", update, nil); err != nil {
+		return err
+	}
+
+	if doc.Type != uint64(sprovider.ResourceType_RESOURCE_TYPE_CONTAINER) {
+		return nil
+	}
+	return o.updateByQuery(
+		subtreeQuery(doc.RootID, oldName),
+		"ctx._source.Path = params.newPath + ctx._source.Path.substring(params.oldPath.length())",
+		map[string]interface{}{"oldPath": oldName, "newPath": newName},
+	)
+}
+
+func (o *OpenSearch) updateByQuery(query map[string]interface{}, script string, params map[string]interface{}) error {
+	req := map[string]interface{}{
+		"query": query,
+		"script": map[string]interface{}{
+			"lang":   "painless",
+			"source": script,
+			"params": params,
+		},
+	}
+	_, err := o.do(context.Background(), http.MethodPost, "/"+url.PathEscape(o.index)+"/_update_by_query?refresh=true&conflicts=proceed", req, nil)
+	return err
+}
+
+type openSearchHit struct {
+	Score  float64        `json:"_score"`
+	Source *indexDocument `json:"_source"`
+	Sort   []interface{}  `json:"sort"`
+}
+
+type openSearchBucket struct {
+	Key      interface{} `json:"key"`
+	DocCount int         `json:"doc_count"`
+}
+
+type openSearchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []openSearchHit `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]struct {
+		SumOtherDocCount int                `json:"sum_other_doc_count"`
+		Buckets          []openSearchBucket `json:"buckets"`
+	} `json:"aggregations"`
+}
+
+// Search searches the index according to the criteria specified in the given SearchIndexRequest
+func (o *OpenSearch) Search(ctx context.Context, req *searchsvc.SearchIndexRequest) (*searchsvc.SearchIndexResponse, error) {
+	node, err := parseQuery(req.Query)
+	if err != nil {
+		return nil, errtypes.BadRequest("invalid query: " + err.Error())
+	}
+	order, err := newSearchOrder(req, o.options)
+	if err != nil {
+		return nil, err
+	}
+	userQuery, err := compileOpenSearchQuery(node, queryOptions{fuzziness: o.options.Fuzziness, userID: req.UserId, exactNameBoost: o.options.ExactNameBoost})
+	if err != nil {
+		return nil, errtypes.BadRequest("invalid query: " + err.Error())
+	}
+
+	// the trash consists of the documents which have been marked as deleted, the spaces scope of the
+	// documents describing spaces
+	filters := []interface{}{termQuery("Deleted", req.Scope == searchpkg.ScopeTrash)}
+	exclusions := []interface{}{}
+	if req.Scope == searchpkg.ScopeSpaces {
+		filters = append(filters, termQuery("Space", true))
+	} else {
+		exclusions = append(exclusions, termQuery("Space", true))
+	}
+	if req.Ref != nil {
+		filters = append(filters,
+			termQuery("RootID", idToBleveId(&sprovider.ResourceId{
+				StorageId: req.Ref.GetResourceId().GetStorageId(),
+				SpaceId:   req.Ref.GetResourceId().GetSpaceId(),
+				OpaqueId:  req.Ref.GetResourceId().GetOpaqueId(),
+			})), // Limit search to the space
+			prefixQuery("Path", utils.MakeRelativePath(path.Join(req.Ref.Path, "/"))), // Limit search to this directory in the space
+		)
+	}
+
+	size := 200
+	if req.PageSize > 0 {
+		size = int(req.PageSize)
+	}
+	sort, searchAfter := order.openSearchSort()
+	osReq := map[string]interface{}{
+		"query": order.ranking.openSearchQuery(map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":     []interface{}{userQuery},
+				"filter":   filters,
+				"must_not": exclusions,
+			},
+		}),
+		"size":             size + 1, // fetch one more hit to find out if there is another page
+		"track_total_hits": true,
+		"track_scores":     true, // the matches carry their score when ordered by other fields as well
+		"sort":             sort,
+		"_source":          map[string]interface{}{"excludes": []string{"Content"}},
+	}
+	if searchAfter != nil {
+		osReq["search_after"] = searchAfter
+	}
+	aggs, err := openSearchAggregations(req.Facets, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(aggs) > 0 {
+		osReq["aggs"] = aggs
+	}
+
+	res := openSearchResponse{}
+	if _, err := o.do(ctx, http.MethodPost, "/"+url.PathEscape(o.index)+"/_search", osReq, &res); err != nil {
+		return nil, err
+	}
+
+	matches := []*searchmsg.Match{}
+	for _, h := range res.Hits.Hits {
+		entity, err := documentToEntity(h.Source)
+		if err != nil {
+			return nil, err
+		}
+		rootID, _ := storagespace.ParseID(h.Source.RootID)
+		matches = append(matches, &searchmsg.Match{
+			Score:  float32(h.Score),
+			Entity: entity,
+			Space:  toSpace(h.Source.Space, rootID, h.Source.Name, h.Source.Description, h.Source.SpaceType),
+		})
+	}
+
+	nextPageToken := ""
+	if len(matches) > size {
+		matches = matches[:size]
+		nextPageToken = searchpkg.EncodePageToken(order.order, order.ranking.at, matches[size-1])
+	}
+
+	return &searchsvc.SearchIndexResponse{
+		Matches:       matches,
+		TotalMatches:  int32(res.Hits.Total.Value),
+		NextPageToken: nextPageToken,
+		Facets:        fromFacetResults(req.Facets, fromOpenSearchAggregations(res, req.Facets)),
+	}, nil
+}
+
+// NewBatch returns a new batch using the bulk API which is pushed whenever it holds the given number of operations
+func (o *OpenSearch) NewBatch(size int) (searchpkg.BatchOperator, error) {
+	if size <= 0 {
+		size = 1
+	}
+	return &OpenSearchBatch{index: o, size: size}, nil
+}
+
+// OpenSearchBatch collects additions and purges and sends them to the bulk API at once
+type OpenSearchBatch struct {
+	index      *OpenSearch
+	size       int
+	operations int
+	body       bytes.Buffer
+}
+
+// Add adds a new entity with the given extracted content to the batch. An already indexed entity is
+// replaced, its tags and favorites are taken from the metadata of the resource like all other fields.
+func (b *OpenSearchBatch) Add(ref *sprovider.Reference, ri *sprovider.ResourceInfo, content string) error {
+	doc := toEntity(ref, ri)
+	doc.Content = content
+	if err := b.append(map[string]interface{}{"index": map[string]interface{}{"_id": doc.ID}}, openSearchSource(doc)); err != nil {
+		return err
+	}
+	return b.pushIfFull()
+}
+
+// Purge adds the removal of an entity to the batch
+func (b *OpenSearchBatch) Purge(id *sprovider.ResourceId) error {
+	if err := b.append(map[string]interface{}{"delete": map[string]interface{}{"_id": idToBleveId(id)}}); err != nil {
+		return err
+	}
+	return b.pushIfFull()
+}
+
+func (b *OpenSearchBatch) append(lines ...interface{}) error {
+	for _, l := range lines {
+		data, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		b.body.Write(data)
+		b.body.WriteByte('\n')
+	}
+	b.operations++
+	return nil
+}
+
+func (b *OpenSearchBatch) pushIfFull() error {
+	if b.operations < b.size {
+		return nil
+	}
+	return b.Push()
+}
+
+// Push sends the collected operations to the bulk API
+func (b *OpenSearchBatch) Push() error {
+	if b.operations == 0 {
+		return nil
+	}
+	body := make([]byte, b.body.Len())
+	copy(body, b.body.Bytes())
+	b.body.Reset()
+	b.operations = 0
+
+	res := struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}{}
+	if _, err := b.index.do(context.Background(), http.MethodPost, "/"+url.PathEscape(b.index.index)+"/_bulk?refresh=wait_for", body, &res); err != nil {
+		return err
+	}
+	if !res.Errors {
+		return nil
+	}
+	for _, item := range res.Items {
+		for op, r := range item {
+			// purging documents which are already gone is fine
+			if r.Error != nil && !(op == "delete" && r.Status == http.StatusNotFound) {
+				return fmt.Errorf("bulk %s operation failed: %s", op, string(r.Error))
+			}
+		}
+	}
+	return nil
+}
+
+// openSearchSource returns the source of the given document. Empty modification and deletion times
+// are left out as they can't be indexed as dates.
+func openSearchSource(doc *indexDocument) map[string]interface{} {
+	source := map[string]interface{}{
+		"RootID":    doc.RootID,
+		"Path":      doc.Path,
+		"ID":        doc.ID,
+		"ParentID":  doc.ParentID,
+		"Name":      doc.Name,
+		"Size":      doc.Size,
+		"MimeType":  doc.MimeType,
+		"Type":      doc.Type,
+		"Etag":      doc.Etag,
+		"Content":   doc.Content,
+		"Tags":      doc.Tags,
+		"Favorites": doc.Favorites,
+		"Deleted":   doc.Deleted,
+		"TrashKey":  doc.TrashKey,
+		"Hidden":    doc.Hidden,
+	}
+	if doc.Space {
+		source["Space"] = true
+		source["SpaceType"] = doc.SpaceType
+		source["Description"] = doc.Description
+	}
+	if doc.Mtime != "" {
+		source["Mtime"] = doc.Mtime
+	}
+	if doc.DeletedAt != "" {
+		source["DeletedAt"] = doc.DeletedAt
+	}
+	return source
+}
+
+func documentToEntity(doc *indexDocument) (*searchmsg.Entity, error) {
+	if doc == nil {
+		return nil, fmt.Errorf("missing document source")
+	}
+	rootID, err := storagespace.ParseID(doc.RootID)
+	if err != nil {
+		return nil, err
+	}
+	rID, err := storagespace.ParseID(doc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	entity := &searchmsg.Entity{
+		Ref: &searchmsg.Reference{
+			ResourceId: resourceIDtoSearchID(rootID),
+			Path:       doc.Path,
+		},
+		Id:       resourceIDtoSearchID(rID),
+		Name:     doc.Name,
+		Etag:     doc.Etag,
+		Size:     doc.Size,
+		Type:     doc.Type,
+		MimeType: doc.MimeType,
+		Deleted:  doc.Deleted,
+		TrashKey: doc.TrashKey,
+		Tags:     doc.Tags,
+	}
+	if doc.ParentID != "" {
+		parentID, err := storagespace.ParseID(doc.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		entity.ParentId = resourceIDtoSearchID(parentID)
+	}
+	if mtime, err := time.Parse(time.RFC3339Nano, doc.Mtime); err == nil {
+		entity.LastModifiedTime = &timestamppb.Timestamp{Seconds: mtime.Unix(), Nanos: int32(mtime.Nanosecond())}
+	}
+	return entity, nil
+}
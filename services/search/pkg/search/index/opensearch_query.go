@@ -0,0 +1,342 @@
+package index
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search"
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
+
+	searchpkg "github.com/owncloud/ocis/v2/services/search/pkg/search"
+)
+
+// compileOpenSearchQuery translates the parsed query syntax into the OpenSearch query DSL. The
+// semantics match the ones of compileQuery used for the bleve index.
+func compileOpenSearchQuery(node queryNode, opts queryOptions) (map[string]interface{}, error) {
+	switch n := node.(type) {
+	case *andNode:
+		must, mustNot := []interface{}{}, []interface{}{}
+		for _, c := range n.children {
+			if not, ok := c.(*notNode); ok {
+				q, err := compileOpenSearchQuery(not.child, opts)
+				if err != nil {
+					return nil, err
+				}
+				mustNot = append(mustNot, q)
+				continue
+			}
+			q, err := compileOpenSearchQuery(c, opts)
+			if err != nil {
+				return nil, err
+			}
+			must = append(must, q)
+		}
+		return boolQuery(must, mustNot), nil
+	case *orNode:
+		should := []interface{}{}
+		for _, c := range n.children {
+			q, err := compileOpenSearchQuery(c, opts)
+			if err != nil {
+				return nil, err
+			}
+			should = append(should, q)
+		}
+		return anyOf(should...), nil
+	case *notNode:
+		q, err := compileOpenSearchQuery(n.child, opts)
+		if err != nil {
+			return nil, err
+		}
+		return boolQuery(nil, []interface{}{q}), nil
+	case *textNode:
+		return compileOpenSearchText(n, opts), nil
+	case *restrictionNode:
+		return compileOpenSearchRestriction(n, opts)
+	}
+	return nil, fmt.Errorf("unsupported query")
+}
+
+func boolQuery(must, mustNot []interface{}) map[string]interface{} {
+	q := map[string]interface{}{}
+	if len(must) > 0 {
+		q["must"] = must
+	} else {
+		q["must"] = []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}}
+	}
+	if len(mustNot) > 0 {
+		q["must_not"] = mustNot
+	}
+	return map[string]interface{}{"bool": q}
+}
+
+func anyOf(queries ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should":               queries,
+			"minimum_should_match": 1,
+		},
+	}
+}
+
+func termQuery(field string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"term": map[string]interface{}{field: value}}
+}
+
+func prefixQuery(field, value string) map[string]interface{} {
+	return map[string]interface{}{"prefix": map[string]interface{}{field: value}}
+}
+
+// missingQuery matches the documents without the given field
+func missingQuery(field string) map[string]interface{} {
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must_not": []interface{}{map[string]interface{}{"exists": map[string]interface{}{"field": field}}},
+		},
+	}
+}
+
+func wildcardQuery(field, value string) map[string]interface{} {
+	return map[string]interface{}{"wildcard": map[string]interface{}{field: map[string]interface{}{"value": value}}}
+}
+
+func rangeQuery(field string, bounds map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"range": map[string]interface{}{field: bounds}}
+}
+
+// subtreeQuery matches all resources below the given path of a space
+func subtreeQuery(rootID, path string) map[string]interface{} {
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []interface{}{
+				termQuery("RootID", rootID),
+				prefixQuery("Path", path+"/"),
+			},
+		},
+	}
+}
+
+func compileOpenSearchText(n *textNode, opts queryOptions) map[string]interface{} {
+	value := strings.ToLower(n.value)
+	if !n.phrase && strings.ContainsAny(value, "*?") {
+		return wildcardQuery("Name", value)
+	}
+
+	exact := map[string]interface{}{"term": map[string]interface{}{"Name": map[string]interface{}{"value": value, "boost": opts.exactNameBoost}}}
+	name := wildcardQuery("Name", "*"+value+"*")
+	if n.phrase {
+		return anyOf(exact, name, map[string]interface{}{"match_phrase": map[string]interface{}{"Content": n.value}})
+	}
+
+	should := []interface{}{
+		exact,
+		name,
+		nameWordsOpenSearchQuery("Name.words", n.value, 0, nameWordsBoost),
+		nameWordsOpenSearchQuery("Name.prefixes", n.value, 0, namePrefixesBoost),
+	}
+	if opts.fuzziness > 0 {
+		should = append(should, nameWordsOpenSearchQuery("Name.words", n.value, opts.fuzziness, nameFuzzyBoost))
+	}
+	return anyOf(append(should, map[string]interface{}{
+		"match": map[string]interface{}{
+			"Content": map[string]interface{}{"query": n.value, "operator": "and"},
+		},
+	})...)
+}
+
+// nameWordsOpenSearchQuery matches all words of the value against the given sub field of the name
+func nameWordsOpenSearchQuery(field, value string, fuzziness int, boost float64) map[string]interface{} {
+	match := map[string]interface{}{"query": value, "operator": "and", "boost": boost}
+	if fuzziness > 0 {
+		match["fuzziness"] = fuzziness
+	}
+	return map[string]interface{}{"match": map[string]interface{}{field: match}}
+}
+
+func compileOpenSearchRestriction(n *restrictionNode, opts queryOptions) (map[string]interface{}, error) {
+	if err := checkOperator(n); err != nil {
+		return nil, err
+	}
+
+	switch n.field {
+	case "name":
+		return termOrWildcardOpenSearchQuery("Name", strings.ToLower(n.value)), nil
+	case "content":
+		return map[string]interface{}{"match_phrase": map[string]interface{}{"Content": n.value}}, nil
+	case "type":
+		return compileOpenSearchType(strings.ToLower(n.value))
+	case "mediatype":
+		value := strings.ToLower(n.value)
+		if !strings.Contains(value, "/") {
+			return prefixQuery("MimeType", value+"/"), nil
+		}
+		return termOrWildcardOpenSearchQuery("MimeType", value), nil
+	case "size":
+		size, err := parseSize(n.value)
+		if err != nil {
+			return nil, err
+		}
+		return numericOpenSearchQuery("Size", n.operator, size), nil
+	case "mtime":
+		return compileOpenSearchMtime(n.operator, n.value)
+	case "id":
+		return termQuery("ID", n.value), nil
+	case "hidden":
+		hidden, err := strconv.ParseBool(n.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for field 'hidden', expected true or false", n.value)
+		}
+		return termQuery("Hidden", hidden), nil
+	case "tag":
+		return termOrWildcardOpenSearchQuery("Tags", strings.ToLower(n.value)), nil
+	case "favorite":
+		favorite, err := strconv.ParseBool(n.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for field 'favorite', expected true or false", n.value)
+		}
+		q := termQuery("Favorites", opts.userID)
+		if !favorite {
+			return boolQuery(nil, []interface{}{q}), nil
+		}
+		return q, nil
+	}
+	return nil, fmt.Errorf("unknown field '%s'", n.field)
+}
+
+func termOrWildcardOpenSearchQuery(field, value string) map[string]interface{} {
+	if strings.ContainsAny(value, "*?") {
+		return wildcardQuery(field, value)
+	}
+	return termQuery(field, value)
+}
+
+func compileOpenSearchType(value string) (map[string]interface{}, error) {
+	switch value {
+	case "file":
+		return termQuery("Type", uint64(sprovider.ResourceType_RESOURCE_TYPE_FILE)), nil
+	case "folder":
+		return termQuery("Type", uint64(sprovider.ResourceType_RESOURCE_TYPE_CONTAINER)), nil
+	}
+
+	prefixes, exclusions, err := mimeTypeGroupPrefixes(value)
+	if err != nil {
+		return nil, err
+	}
+	should, mustNot := []interface{}{}, []interface{}{}
+	for _, p := range prefixes {
+		should = append(should, prefixQuery("MimeType", p))
+	}
+	for _, p := range exclusions {
+		mustNot = append(mustNot, prefixQuery("MimeType", p))
+	}
+	return boolQuery([]interface{}{anyOf(should...)}, mustNot), nil
+}
+
+func numericOpenSearchQuery(field, operator string, value float64) map[string]interface{} {
+	switch operator {
+	case "<":
+		return rangeQuery(field, map[string]interface{}{"lt": value})
+	case "<=":
+		return rangeQuery(field, map[string]interface{}{"lte": value})
+	case ">":
+		return rangeQuery(field, map[string]interface{}{"gt": value})
+	case ">=":
+		return rangeQuery(field, map[string]interface{}{"gte": value})
+	}
+	return termQuery(field, value)
+}
+
+func compileOpenSearchMtime(operator, value string) (map[string]interface{}, error) {
+	start, end, err := parseTime(value)
+	if err != nil {
+		return nil, err
+	}
+	format := func(t time.Time) string { return t.UTC().Format(time.RFC3339Nano) }
+	switch operator {
+	case "<":
+		return rangeQuery("Mtime", map[string]interface{}{"lt": format(start)}), nil
+	case "<=":
+		return rangeQuery("Mtime", map[string]interface{}{"lte": format(end)}), nil
+	case ">":
+		return rangeQuery("Mtime", map[string]interface{}{"gt": format(end)}), nil
+	case ">=":
+		return rangeQuery("Mtime", map[string]interface{}{"gte": format(start)}), nil
+	}
+	return rangeQuery("Mtime", map[string]interface{}{"gte": format(start), "lte": format(end)}), nil
+}
+
+// openSearchAggregations returns the aggregations computing the given facets
+func openSearchAggregations(facets []string, now time.Time) (map[string]interface{}, error) {
+	aggs := map[string]interface{}{}
+	for _, name := range facets {
+		switch name {
+		case searchpkg.FacetMimeType:
+			aggs[name] = map[string]interface{}{
+				"terms": map[string]interface{}{"field": "MimeType", "size": maxFacetTerms, "missing": ""},
+			}
+		case searchpkg.FacetSize:
+			ranges := []interface{}{}
+			for _, r := range sizeRanges {
+				bucket := map[string]interface{}{"key": r.name, "from": r.min}
+				if r.max != 0 {
+					bucket["to"] = r.max
+				}
+				ranges = append(ranges, bucket)
+			}
+			aggs[name] = map[string]interface{}{
+				"range": map[string]interface{}{"field": "Size", "ranges": ranges},
+			}
+		case searchpkg.FacetMtime:
+			ranges := []interface{}{}
+			for _, r := range mtimeRanges(now) {
+				bucket := map[string]interface{}{"key": r.name}
+				if !r.start.IsZero() {
+					bucket["from"] = r.start.UTC().Format(time.RFC3339Nano)
+				}
+				if !r.end.IsZero() {
+					bucket["to"] = r.end.UTC().Format(time.RFC3339Nano)
+				}
+				ranges = append(ranges, bucket)
+			}
+			aggs[name] = map[string]interface{}{
+				"date_range": map[string]interface{}{"field": "Mtime", "ranges": ranges},
+			}
+		case searchpkg.FacetSpace:
+			aggs[name] = map[string]interface{}{
+				"terms": map[string]interface{}{"field": "RootID", "size": maxFacetTerms},
+			}
+		default:
+			return nil, errtypes.BadRequest(fmt.Sprintf("unknown facet '%s'", name))
+		}
+	}
+	return aggs, nil
+}
+
+// fromOpenSearchAggregations converts the aggregations of the response into bleve facet results so that
+// they can be converted the same way as the results of the bleve index
+func fromOpenSearchAggregations(res openSearchResponse, facets []string) search.FacetResults {
+	results := search.FacetResults{}
+	for _, name := range facets {
+		agg, ok := res.Aggregations[name]
+		if !ok {
+			continue
+		}
+		fr := &search.FacetResult{Field: name, Other: agg.SumOtherDocCount, Terms: &search.TermFacets{}}
+		for _, b := range agg.Buckets {
+			key := fmt.Sprint(b.Key)
+			switch name {
+			case searchpkg.FacetSize:
+				fr.NumericRanges = append(fr.NumericRanges, &search.NumericRangeFacet{Name: key, Count: b.DocCount})
+			case searchpkg.FacetMtime:
+				fr.DateRanges = append(fr.DateRanges, &search.DateRangeFacet{Name: key, Count: b.DocCount})
+			default:
+				fr.Terms.Add(&search.TermFacet{Term: key, Count: b.DocCount})
+			}
+			fr.Total += b.DocCount
+		}
+		results[name] = fr
+	}
+	return results
+}
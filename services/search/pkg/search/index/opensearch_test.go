@@ -0,0 +1,465 @@
+package index_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
+	"github.com/golang/protobuf/proto"
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search/index"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type recordedRequest struct {
+	method string
+	path   string
+	query  string
+	body   string
+}
+
+var _ = Describe("OpenSearch", func() {
+	var (
+		o         *index.OpenSearch
+		server    *httptest.Server
+		requests  []recordedRequest
+		responses map[string]string
+		indexed   bool
+
+		rootID = &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: "spaceid"}
+		ref    = &sprovider.Reference{ResourceId: rootID, Path: "./docs/Foo.pdf"}
+		ri     = &sprovider.ResourceInfo{
+			Id:       &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: "opaqueid"},
+			ParentId: &sprovider.ResourceId{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: "parentid"},
+			Name:     "Foo.pdf",
+			Size:     12345,
+			Type:     sprovider.ResourceType_RESOURCE_TYPE_FILE,
+			MimeType: "application/pdf",
+			Etag:     "etag-1",
+			Mtime:    &typesv1beta1.Timestamp{Seconds: 4000},
+		}
+		source = `{"RootID":"provider-1$spaceid!spaceid","Path":"./docs/Foo.pdf","ID":"provider-1$spaceid!opaqueid",` +
+			`"ParentID":"provider-1$spaceid!parentid","Name":"Foo.pdf","Size":12345,"Mtime":"1970-01-01T01:06:40Z",` +
+			`"MimeType":"application/pdf","Type":1,"Etag":"etag-1","Deleted":false,"Hidden":false}`
+
+		requestBody = func(path string) map[string]interface{} {
+			for _, r := range requests {
+				if r.path == path {
+					body := map[string]interface{}{}
+					ExpectWithOffset(1, json.Unmarshal([]byte(r.body), &body)).To(Succeed())
+					return body
+				}
+			}
+			Fail("no request to " + path)
+			return nil
+		}
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		indexed = true
+		responses = map[string]string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			requests = append(requests, recordedRequest{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, body: string(body)})
+
+			if user, password, _ := r.BasicAuth(); user != "admin" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.Method == http.MethodHead && r.URL.Path == "/ocis" && !indexed {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			res, ok := responses[r.Method+" "+r.URL.Path]
+			if !ok {
+				res = "{}"
+			}
+			if strings.HasPrefix(res, "404 ") {
+				w.WriteHeader(http.StatusNotFound)
+				res = strings.TrimPrefix(res, "404 ")
+			}
+			_, _ = w.Write([]byte(res))
+		}))
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		o, err = index.NewOpenSearch(server.URL+"/", "ocis", "admin", "secret", false)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("NewOpenSearch", func() {
+		It("uses an existing index", func() {
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].method).To(Equal(http.MethodHead))
+			Expect(requests[0].path).To(Equal("/ocis"))
+		})
+
+		Context("when the index does not exist", func() {
+			BeforeEach(func() {
+				indexed = false
+			})
+
+			It("creates the index with the mapping", func() {
+				Expect(requests).To(HaveLen(2))
+				Expect(requests[1].method).To(Equal(http.MethodPut))
+				body := map[string]interface{}{}
+				Expect(json.Unmarshal([]byte(requests[1].body), &body)).To(Succeed())
+				Expect(body).To(HaveKey("mappings"))
+				Expect(requests[1].body).To(ContainSubstring(`"Name":{"fields":{"prefixes":{"analyzer":"name_prefixes","search_analyzer":"name_words","type":"text"},"words":{"analyzer":"name_words","type":"text"}},"normalizer":"lowercase","type":"keyword"}`))
+			})
+		})
+
+		It("fails when the cluster rejects the credentials", func() {
+			_, err := index.NewOpenSearch(server.URL, "ocis", "admin", "wrong", false)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Add", func() {
+		It("indexes the document", func() {
+			Expect(o.Add(ref, ri, "some content")).To(Succeed())
+			req := requests[len(requests)-1]
+			Expect(req.method).To(Equal(http.MethodPut))
+			Expect(req.path).To(Equal("/ocis/_doc/provider-1$spaceid!opaqueid"))
+			Expect(req.query).To(Equal("refresh=wait_for"))
+
+			body := requestBody(req.path)
+			Expect(body).To(HaveKeyWithValue("Name", "Foo.pdf"))
+			Expect(body).To(HaveKeyWithValue("Content", "some content"))
+			Expect(body).To(HaveKeyWithValue("Etag", "etag-1"))
+			Expect(body).To(HaveKeyWithValue("Mtime", "1970-01-01T01:06:40Z"))
+			Expect(body).ToNot(HaveKey("DeletedAt"))
+		})
+
+		It("indexes the tags and the favorite of the owner", func() {
+			tagged := proto.Clone(ri).(*sprovider.ResourceInfo)
+			tagged.Owner = &userv1beta1.UserId{OpaqueId: "einstein"}
+			tagged.ArbitraryMetadata = &sprovider.ArbitraryMetadata{Metadata: map[string]string{
+				"tags":                     "invoice-2022, Tax",
+				search.FavoriteMetadataKey: "1",
+			}}
+			Expect(o.Add(ref, tagged, "")).To(Succeed())
+
+			body := requestBody("/ocis/_doc/provider-1$spaceid!opaqueid")
+			Expect(body).To(HaveKeyWithValue("Tags", ConsistOf("invoice-2022", "Tax")))
+			Expect(body).To(HaveKeyWithValue("Favorites", ConsistOf("einstein")))
+		})
+	})
+
+	Describe("AddSpace", func() {
+		It("indexes the document describing the space", func() {
+			space := &sprovider.StorageSpace{
+				Opaque: &typesv1beta1.Opaque{
+					Map: map[string]*typesv1beta1.OpaqueEntry{
+						"description": {Decoder: "plain", Value: []byte("Quarterly numbers")},
+					},
+				},
+				Id:        &sprovider.StorageSpaceId{OpaqueId: "provider-1$spaceid"},
+				Root:      rootID,
+				Name:      "Finance",
+				SpaceType: "project",
+			}
+			Expect(o.AddSpace(space, "the readme")).To(Succeed())
+			req := requests[len(requests)-1]
+			Expect(req.method).To(Equal(http.MethodPut))
+			Expect(req.path).To(Equal("/ocis/_doc/provider-1$spaceid"))
+
+			body := requestBody(req.path)
+			Expect(body).To(HaveKeyWithValue("Name", "Finance"))
+			Expect(body).To(HaveKeyWithValue("Content", "Quarterly numbers\n\nthe readme"))
+			Expect(body).To(HaveKeyWithValue("Space", true))
+			Expect(body).To(HaveKeyWithValue("SpaceType", "project"))
+			Expect(body).To(HaveKeyWithValue("RootID", "provider-1$spaceid!spaceid"))
+		})
+	})
+
+	Describe("Get", func() {
+		It("returns the entity", func() {
+			responses["GET /ocis/_doc/provider-1$spaceid!opaqueid"] = `{"found":true,"_source":` + source + `}`
+			entity, err := o.Get(ri.Id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entity.Id.OpaqueId).To(Equal("opaqueid"))
+			Expect(entity.ParentId.OpaqueId).To(Equal("parentid"))
+			Expect(entity.Ref.Path).To(Equal("./docs/Foo.pdf"))
+			Expect(entity.Etag).To(Equal("etag-1"))
+			Expect(entity.LastModifiedTime.Seconds).To(Equal(int64(4000)))
+		})
+
+		It("returns a not found error for unknown resources", func() {
+			responses["GET /ocis/_doc/provider-1$spaceid!opaqueid"] = `404 {"found":false}`
+			_, err := o.Get(ri.Id)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.NotFound("")))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("marks the container and its children as deleted", func() {
+			responses["GET /ocis/_doc/provider-1$spaceid!opaqueid"] = `{"found":true,"_source":` +
+				strings.Replace(source, `"Type":1`, `"Type":2`, 1) + `}`
+			Expect(o.Delete(ri.Id)).To(Succeed())
+
+			body := requestBody("/ocis/_update_by_query")
+			params := body["script"].(map[string]interface{})["params"]
+			Expect(params).To(HaveKeyWithValue("key", "opaqueid"))
+			Expect(params).To(HaveKeyWithValue("path", "./docs/Foo.pdf"))
+			Expect(params).To(HaveKey("now"))
+			query, _ := json.Marshal(body["query"])
+			Expect(string(query)).To(ContainSubstring(`{"prefix":{"Path":"./docs/Foo.pdf/"}}`))
+			Expect(string(query)).To(ContainSubstring(`"must_not":[{"term":{"Deleted":true}}]`))
+		})
+	})
+
+	Describe("Restore", func() {
+		It("restores the children deleted along with the resource or without a trash key", func() {
+			responses["GET /ocis/_doc/provider-1$spaceid!opaqueid"] = `{"found":true,"_source":` +
+				strings.Replace(source, `"Type":1`, `"Type":2`, 1) + `}`
+			Expect(o.Restore(ri.Id)).To(Succeed())
+
+			body := requestBody("/ocis/_update_by_query")
+			query, _ := json.Marshal(body["query"])
+			Expect(string(query)).To(ContainSubstring(`{"prefix":{"TrashKey":"opaqueid/"}}`))
+			Expect(string(query)).To(ContainSubstring(`{"term":{"TrashKey":""}}`))
+			Expect(string(query)).To(ContainSubstring(`{"bool":{"must_not":[{"exists":{"field":"TrashKey"}}]}}`))
+		})
+	})
+
+	Describe("Move", func() {
+		It("updates the path of the resource", func() {
+			responses["GET /ocis/_doc/provider-1$spaceid!opaqueid"] = `{"found":true,"_source":` + source + `}`
+			Expect(o.Move(ri.Id, ri.ParentId, "/other/Bar.pdf")).To(Succeed())
+
+			body := requestBody("/ocis/_update/provider-1$spaceid!opaqueid")
+			Expect(body["doc"]).To(HaveKeyWithValue("Path", "./other/Bar.pdf"))
+			Expect(body["doc"]).To(HaveKeyWithValue("Name", "Bar.pdf"))
+			for _, r := range requests {
+				Expect(r.path).ToNot(Equal("/ocis/_update_by_query"), "files don't have children to update")
+			}
+		})
+	})
+
+	Describe("PurgeSpace", func() {
+		It("deletes all documents of the space", func() {
+			Expect(o.PurgeSpace(rootID)).To(Succeed())
+			body := requestBody("/ocis/_delete_by_query")
+			Expect(body["query"]).To(Equal(map[string]interface{}{
+				"term": map[string]interface{}{"RootID": "provider-1$spaceid!spaceid"},
+			}))
+		})
+	})
+
+	Describe("PurgeDeleted", func() {
+		It("deletes the documents deleted before the given time or without a deletion time", func() {
+			responses["POST /ocis/_delete_by_query"] = `{"deleted":3}`
+			purged, err := o.PurgeDeleted(time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(purged).To(Equal(3))
+
+			body := requestBody("/ocis/_delete_by_query")
+			query, _ := json.Marshal(body["query"])
+			Expect(string(query)).To(ContainSubstring(`{"term":{"Deleted":true}}`))
+			Expect(string(query)).To(ContainSubstring(`"must_not":[{"range":{"DeletedAt":{"gte":"2022-11-01T00:00:00Z"}}}]`))
+		})
+	})
+
+	Describe("Search", func() {
+		var req *searchsvc.SearchIndexRequest
+
+		BeforeEach(func() {
+			req = &searchsvc.SearchIndexRequest{
+				Query: "name:foo* type:pdf",
+				Ref: &searchmsg.Reference{
+					ResourceId: &searchmsg.ResourceID{StorageId: "provider-1", SpaceId: "spaceid", OpaqueId: "spaceid"},
+					Path:       "./docs",
+				},
+				PageSize: 1,
+			}
+			responses["POST /ocis/_search"] = `{"hits":{"total":{"value":2},"hits":[` +
+				`{"_score":1.5,"_source":` + source + `,"sort":[1.5,"provider-1$spaceid!opaqueid"]},` +
+				`{"_score":1.0,"_source":` + source + `,"sort":[1.0,"provider-1$spaceid!opaqueid"]}]},` +
+				`"aggregations":{"mimetype":{"sum_other_doc_count":0,"buckets":[{"key":"application/pdf","doc_count":2}]}}}`
+		})
+
+		It("translates the query and restricts it to the space and the directory", func() {
+			_, err := o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+
+			body := requestBody("/ocis/_search")
+			Expect(body["size"]).To(Equal(float64(2)))
+			query, _ := json.Marshal(body["query"])
+			Expect(string(query)).To(ContainSubstring(`{"wildcard":{"Name":{"value":"foo*"}}}`))
+			Expect(string(query)).To(ContainSubstring(`{"prefix":{"MimeType":"application/pdf"}}`))
+			Expect(string(query)).To(ContainSubstring(`{"term":{"Deleted":false}}`))
+			Expect(string(query)).To(ContainSubstring(`{"term":{"RootID":"provider-1$spaceid!spaceid"}}`))
+			Expect(string(query)).To(ContainSubstring(`{"prefix":{"Path":"./docs"}}`))
+		})
+
+		It("matches free text against the words of the name with the configured fuzziness", func() {
+			req.Query = "budget"
+			_, err := o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+
+			body := requestBody("/ocis/_search")
+			query, _ := json.Marshal(body["query"])
+			Expect(string(query)).To(ContainSubstring(`{"term":{"Name":{"boost":10,"value":"budget"}}}`))
+			Expect(string(query)).To(ContainSubstring(`{"match":{"Name.prefixes":{"boost":1.5,"operator":"and","query":"budget"}}}`))
+			Expect(string(query)).To(ContainSubstring(`{"match":{"Name.words":{"boost":0.5,"fuzziness":1,"operator":"and","query":"budget"}}}`))
+		})
+
+		It("matches the tags and the favorites of the user", func() {
+			req.Query = "tag:Invoice favorite:true"
+			req.UserId = "einstein"
+			_, err := o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+
+			body := requestBody("/ocis/_search")
+			query, _ := json.Marshal(body["query"])
+			Expect(string(query)).To(ContainSubstring(`{"term":{"Tags":"invoice"}}`))
+			Expect(string(query)).To(ContainSubstring(`{"term":{"Favorites":"einstein"}}`))
+		})
+
+		It("searches the deleted documents in the trash scope", func() {
+			req.Scope = "trash"
+			_, err := o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+
+			body := requestBody("/ocis/_search")
+			query, _ := json.Marshal(body["query"])
+			Expect(string(query)).To(ContainSubstring(`{"term":{"Deleted":true}}`))
+		})
+
+		It("only searches the spaces themselves in the spaces scope", func() {
+			_, err := o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			query, _ := json.Marshal(requestBody("/ocis/_search")["query"])
+			Expect(string(query)).To(ContainSubstring(`"must_not":[{"term":{"Space":true}}]`))
+
+			requests = nil
+			req.Scope = "spaces"
+			_, err = o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			query, _ = json.Marshal(requestBody("/ocis/_search")["query"])
+			Expect(string(query)).To(ContainSubstring(`{"term":{"Space":true}}`))
+			Expect(string(query)).ToNot(ContainSubstring(`"must_not":[{"term":{"Space":true}}]`))
+		})
+
+		It("returns the matches and a page token", func() {
+			res, err := o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.TotalMatches).To(Equal(int32(2)))
+			Expect(res.Matches).To(HaveLen(1))
+			Expect(res.Matches[0].Score).To(Equal(float32(1.5)))
+			Expect(res.Matches[0].Entity.Name).To(Equal("Foo.pdf"))
+			Expect(res.NextPageToken).ToNot(BeEmpty())
+
+			req.PageToken = res.NextPageToken
+			_, err = o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			body := requestBody("/ocis/_search")
+			Expect(body).ToNot(HaveKey("search_after"))
+			Expect(requests[len(requests)-1].body).To(ContainSubstring(`"search_after":[1.5,"provider-1$spaceid!opaqueid"]`))
+		})
+
+		It("ranks the matches by their recency and their space", func() {
+			req.RankedAt = timestamppb.New(time.Unix(1000, 0))
+			req.PersonalRootId = &searchmsg.ResourceID{StorageId: "provider-1", SpaceId: "personal", OpaqueId: "personal"}
+			_, err := o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+
+			body := requestBody("/ocis/_search")
+			Expect(body["sort"]).To(Equal([]interface{}{
+				map[string]interface{}{"_score": "desc"},
+				map[string]interface{}{"ID": "asc"},
+			}))
+			query, _ := json.Marshal(body["query"])
+			Expect(string(query)).To(ContainSubstring(`{"exp":{"Mtime":{"decay":0.5,"origin":"1000000","scale":"604800000ms"}},"weight":1}`))
+			Expect(string(query)).To(ContainSubstring(`{"filter":{"term":{"RootID":"provider-1$personal!personal"}},"weight":1.5}`))
+		})
+
+		It("orders the matches by the requested field", func() {
+			req.OrderBy = "mtime"
+			res, err := o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+
+			body := requestBody("/ocis/_search")
+			Expect(body["track_scores"]).To(BeTrue())
+			Expect(body["sort"]).To(Equal([]interface{}{
+				map[string]interface{}{"Mtime": "desc"},
+				map[string]interface{}{"ID": "asc"},
+			}))
+
+			req.PageToken = res.NextPageToken
+			_, err = o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(requests[len(requests)-1].body).To(ContainSubstring(`"search_after":[4000000,"provider-1$spaceid!opaqueid"]`))
+
+			req.OrderBy = "name"
+			_, err = o.Search(context.Background(), req)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.BadRequest("")))
+		})
+
+		It("converts the aggregations into facets", func() {
+			req.Facets = []string{"mimetype"}
+			res, err := o.Search(context.Background(), req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Facets).To(HaveLen(1))
+			Expect(res.Facets[0].Terms).To(ContainElement(&searchmsg.FacetTerm{Term: "pdf", Count: 2}))
+		})
+
+		It("rejects invalid queries", func() {
+			req.Query = "size>big"
+			_, err := o.Search(context.Background(), req)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.BadRequest("")))
+		})
+
+		It("stops when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := o.Search(ctx, req)
+			Expect(err).To(MatchError(context.Canceled))
+		})
+	})
+
+	Describe("NewBatch", func() {
+		It("sends the operations to the bulk API", func() {
+			batch, err := o.NewBatch(10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batch.Add(ref, ri, "")).To(Succeed())
+			Expect(batch.Purge(ri.ParentId)).To(Succeed())
+			Expect(requests).To(HaveLen(1))
+
+			Expect(batch.Push()).To(Succeed())
+			req := requests[len(requests)-1]
+			Expect(req.path).To(Equal("/ocis/_bulk"))
+			lines := strings.Split(strings.TrimSpace(req.body), "\n")
+			Expect(lines).To(HaveLen(3))
+			Expect(lines[0]).To(Equal(`{"index":{"_id":"provider-1$spaceid!opaqueid"}}`))
+			Expect(lines[2]).To(Equal(`{"delete":{"_id":"provider-1$spaceid!parentid"}}`))
+		})
+
+		It("reports failed operations", func() {
+			responses["POST /ocis/_bulk"] = `{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`
+			batch, err := o.NewBatch(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(batch.Add(ref, ri, "")).To(MatchError(ContainSubstring("mapper_parsing_exception")))
+		})
+	})
+})
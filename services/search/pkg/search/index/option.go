@@ -0,0 +1,119 @@
+package index
+
+import "time"
+
+// DefaultFuzziness is the edit distance used for fuzzy name matching if not configured otherwise
+const DefaultFuzziness = 1
+
+// maxFuzziness is the largest edit distance supported by the search engines
+const maxFuzziness = 2
+
+// The ranking used if not configured otherwise
+const (
+	DefaultExactNameBoost     = 10.0
+	DefaultRecencyBoost       = 1.0
+	DefaultRecencyHalfLife    = 7 * 24 * time.Hour
+	DefaultPersonalSpaceBoost = 1.5
+)
+
+// DefaultMaxOpenSpaces is the number of persisted space indexes kept open if not configured otherwise
+const DefaultMaxOpenSpaces = 100
+
+// Option defines a single option function.
+type Option func(o *Options)
+
+// Options defines the available options for the search indexes.
+type Options struct {
+	// Fuzziness is the maximum edit distance allowed when matching the words of file names.
+	// 0 disables fuzzy matching.
+	Fuzziness int
+
+	// ExactNameBoost is the boost of matches whose name equals the searched text
+	ExactNameBoost float64
+	// RecencyBoost is the boost of resources which have just been modified. Their score is multiplied by
+	// 1 + RecencyBoost, the boost halves with every RecencyHalfLife passed since the modification.
+	// 0 disables the boost.
+	RecencyBoost    float64
+	RecencyHalfLife time.Duration
+	// PersonalSpaceBoost is the factor the score of the matches in the personal space of the searching
+	// user is multiplied with. 1 disables the boost.
+	PersonalSpaceBoost float64
+
+	// MaxOpenSpaces is the number of persisted space indexes kept open, the least recently used ones are
+	// closed when more are opened. 0 keeps all of them open. Indexes in use are never closed, so searches
+	// across all spaces still open all of them.
+	MaxOpenSpaces int
+}
+
+func newOptions(opts ...Option) Options {
+	opt := Options{
+		Fuzziness:          DefaultFuzziness,
+		ExactNameBoost:     DefaultExactNameBoost,
+		RecencyBoost:       DefaultRecencyBoost,
+		RecencyHalfLife:    DefaultRecencyHalfLife,
+		PersonalSpaceBoost: DefaultPersonalSpaceBoost,
+		MaxOpenSpaces:      DefaultMaxOpenSpaces,
+	}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	if opt.Fuzziness < 0 {
+		opt.Fuzziness = 0
+	}
+	if opt.Fuzziness > maxFuzziness {
+		opt.Fuzziness = maxFuzziness
+	}
+	if opt.ExactNameBoost < 1 {
+		opt.ExactNameBoost = 1
+	}
+	if opt.RecencyBoost < 0 || opt.RecencyHalfLife <= 0 {
+		opt.RecencyBoost = 0
+	}
+	if opt.PersonalSpaceBoost <= 0 {
+		opt.PersonalSpaceBoost = 1
+	}
+	if opt.MaxOpenSpaces < 0 {
+		opt.MaxOpenSpaces = 0
+	}
+	return opt
+}
+
+// Fuzziness provides a function to set the edit distance used for fuzzy name matching.
+func Fuzziness(val int) Option {
+	return func(o *Options) {
+		o.Fuzziness = val
+	}
+}
+
+// ExactNameBoost provides a function to set the boost of matches whose name equals the searched text.
+func ExactNameBoost(val float64) Option {
+	return func(o *Options) {
+		o.ExactNameBoost = val
+	}
+}
+
+// RecencyBoost provides a function to set the boost of recently modified resources and the time after
+// which the boost has halved.
+func RecencyBoost(val float64, halfLife time.Duration) Option {
+	return func(o *Options) {
+		o.RecencyBoost = val
+		o.RecencyHalfLife = halfLife
+	}
+}
+
+// PersonalSpaceBoost provides a function to set the factor the score of matches in the personal space
+// of the searching user is multiplied with.
+func PersonalSpaceBoost(val float64) Option {
+	return func(o *Options) {
+		o.PersonalSpaceBoost = val
+	}
+}
+
+// MaxOpenSpaces provides a function to set the number of persisted space indexes kept open.
+func MaxOpenSpaces(val int) Option {
+	return func(o *Options) {
+		o.MaxOpenSpaces = val
+	}
+}
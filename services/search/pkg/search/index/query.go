@@ -0,0 +1,679 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+)
+
+// The search queries use a small KQL like syntax:
+//
+//	report                      name contains "report" or the content contains the word "report"
+//	"annual report"             name contains "annual report" or the content contains the phrase
+//	name:report*                name starting with "report", name:report.pdf matches the exact name
+//	content:"annual report"     content contains the phrase
+//	type:document               the type of the resource, either file, folder or a mime type group
+//	                            like document, spreadsheet, presentation, pdf, image, video, audio or archive
+//	mediatype:image             the mime type, either a full mime type like image/png or its top level type
+//	size>10MB                   the size, the operators :, =, <, <=, > and >= and the units B, KB, MB, GB, TB,
+//	                            KiB, MiB, GiB and TiB are supported
+//	mtime>=2022-01-01           the modification time, given as date or RFC3339 timestamp
+//	id:"storage$space!opaque"   the resource id
+//	hidden:true                 hidden resources
+//	tag:invoice-2022            resources tagged with "invoice-2022", wildcards are supported
+//	favorite:true               the favorites of the searching user
+//
+// Restrictions and free text are combined with AND, OR and NOT (upper case) and can be grouped with
+// parentheses. Restrictions next to each other are combined with AND, a leading - negates a
+// restriction. Field names are case insensitive, the values of the name, mediatype and tag fields as well.
+
+// queryFields are the fields which can be used in the queries
+var queryFields = []string{"name", "content", "type", "mediatype", "size", "mtime", "id", "hidden", "tag", "favorite"}
+
+// queryOptions are the settings the queries are compiled with
+type queryOptions struct {
+	// fuzziness is the edit distance used for matching the words of file names
+	fuzziness int
+	// userID is the id of the searching user whose favorites are matched
+	userID string
+	// exactNameBoost is the boost of names equal to the searched text
+	exactNameBoost float64
+}
+
+type queryNode interface{}
+
+type andNode struct {
+	children []queryNode
+}
+
+type orNode struct {
+	children []queryNode
+}
+
+type notNode struct {
+	child queryNode
+}
+
+// textNode is a free text term which is matched against the name and the content
+type textNode struct {
+	value  string
+	phrase bool
+}
+
+// restrictionNode restricts a field to a value
+type restrictionNode struct {
+	field    string
+	operator string
+	value    string
+	phrase   bool
+}
+
+// queryParser is a recursive descent parser for the query syntax
+type queryParser struct {
+	input []rune
+	pos   int
+}
+
+// BuildQuery parses the given query and compiles it into a bleve query
+func BuildQuery(q string) (query.Query, error) {
+	return buildQuery(q, queryOptions{fuzziness: DefaultFuzziness, exactNameBoost: DefaultExactNameBoost})
+}
+
+// buildQuery parses the given query and compiles it into a bleve query using the given options
+func buildQuery(q string, opts queryOptions) (query.Query, error) {
+	node, err := parseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	return compileQuery(node, opts)
+}
+
+func parseQuery(q string) (queryNode, error) {
+	p := &queryParser{input: []rune(q)}
+	p.skipSpace()
+	if p.eof() {
+		return nil, fmt.Errorf("empty query")
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eof() {
+		return nil, fmt.Errorf("unexpected '%c' at position %d", p.input[p.pos], p.pos+1)
+	}
+	return node, nil
+}
+
+func (p *queryParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *queryParser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *queryParser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+// isKeyword returns true if the given operator keyword is next in the input
+func (p *queryParser) isKeyword(kw string) bool {
+	p.skipSpace()
+	end := p.pos + len(kw)
+	if end > len(p.input) || string(p.input[p.pos:end]) != kw {
+		return false
+	}
+	return end == len(p.input) || unicode.IsSpace(p.input[end]) || p.input[end] == '(' || p.input[end] == '"'
+}
+
+// keyword consumes the given operator keyword if it is next in the input
+func (p *queryParser) keyword(kw string) bool {
+	if !p.isKeyword(kw) {
+		return false
+	}
+	p.pos += len(kw)
+	return true
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []queryNode{node}
+	for p.keyword("OR") {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &orNode{children: children}, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	children := []queryNode{}
+	for {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+
+		p.skipSpace()
+		if p.eof() || p.peek() == ')' || p.isKeyword("OR") {
+			break
+		}
+		p.keyword("AND")
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &andNode{children: children}, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	p.skipSpace()
+	if p.keyword("NOT") || p.consume('-') {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{child: node}, nil
+	}
+	p.consume('+')
+	return p.parsePrimary()
+}
+
+func (p *queryParser) consume(r rune) bool {
+	if p.peek() == r && !p.eof() {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	p.skipSpace()
+	switch {
+	case p.eof():
+		return nil, fmt.Errorf("unexpected end of query")
+	case p.peek() == '(':
+		start := p.pos
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(')') {
+			return nil, fmt.Errorf("missing ')' for the '(' at position %d", start+1)
+		}
+		return node, nil
+	case p.peek() == ')':
+		return nil, fmt.Errorf("unexpected ')' at position %d", p.pos+1)
+	case p.peek() == '"':
+		value, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return &textNode{value: value, phrase: true}, nil
+	}
+
+	word := p.parseWord(":<>=")
+	if word == "" {
+		return nil, fmt.Errorf("unexpected '%c' at position %d", p.peek(), p.pos+1)
+	}
+	operator := p.parseOperator()
+	if operator == "" {
+		return &textNode{value: word}, nil
+	}
+
+	field := strings.ToLower(word)
+	if !isQueryField(field) {
+		return nil, fmt.Errorf("unknown field '%s', supported fields are %s", word, strings.Join(queryFields, ", "))
+	}
+	node := &restrictionNode{field: field, operator: operator}
+	if p.peek() == '"' {
+		value, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		node.value, node.phrase = value, true
+	} else {
+		node.value = p.parseWord("")
+	}
+	if node.value == "" {
+		return nil, fmt.Errorf("missing value for field '%s'", field)
+	}
+	return node, nil
+}
+
+// parseWord reads an unquoted word. Backslashes escape the next character.
+func (p *queryParser) parseWord(stopChars string) string {
+	var b strings.Builder
+	for !p.eof() {
+		r := p.peek()
+		if unicode.IsSpace(r) || r == '(' || r == ')' || r == '"' || strings.ContainsRune(stopChars, r) {
+			break
+		}
+		p.pos++
+		if r == '\\' && !p.eof() {
+			r = p.peek()
+			p.pos++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// parseQuoted reads a quoted string. Backslashes escape the next character.
+func (p *queryParser) parseQuoted() (string, error) {
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		r := p.peek()
+		p.pos++
+		switch {
+		case r == '"':
+			return b.String(), nil
+		case r == '\\' && !p.eof():
+			b.WriteRune(p.peek())
+			p.pos++
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", fmt.Errorf("missing closing '\"' for the quote at position %d", start+1)
+}
+
+// parseOperator reads the operator of a restriction. For compatibility ":>" etc. are accepted as well.
+func (p *queryParser) parseOperator() string {
+	operator := ""
+	if p.consume(':') {
+		operator = ":"
+	} else if p.consume('=') {
+		operator = "="
+	}
+	switch {
+	case p.consume('<'):
+		operator = "<"
+	case p.consume('>'):
+		operator = ">"
+	default:
+		return operator
+	}
+	if p.consume('=') {
+		operator += "="
+	}
+	return operator
+}
+
+func isQueryField(field string) bool {
+	for _, f := range queryFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func compileQuery(node queryNode, opts queryOptions) (query.Query, error) {
+	switch n := node.(type) {
+	case *andNode:
+		must, mustNot := []query.Query{}, []query.Query{}
+		for _, c := range n.children {
+			if not, ok := c.(*notNode); ok {
+				q, err := compileQuery(not.child, opts)
+				if err != nil {
+					return nil, err
+				}
+				mustNot = append(mustNot, q)
+				continue
+			}
+			q, err := compileQuery(c, opts)
+			if err != nil {
+				return nil, err
+			}
+			must = append(must, q)
+		}
+		return newBooleanQuery(must, mustNot), nil
+	case *orNode:
+		disjuncts := []query.Query{}
+		for _, c := range n.children {
+			q, err := compileQuery(c, opts)
+			if err != nil {
+				return nil, err
+			}
+			disjuncts = append(disjuncts, q)
+		}
+		return bleve.NewDisjunctionQuery(disjuncts...), nil
+	case *notNode:
+		q, err := compileQuery(n.child, opts)
+		if err != nil {
+			return nil, err
+		}
+		return newBooleanQuery(nil, []query.Query{q}), nil
+	case *textNode:
+		return compileText(n, opts), nil
+	case *restrictionNode:
+		return compileRestriction(n, opts)
+	}
+	return nil, fmt.Errorf("unsupported query")
+}
+
+func newBooleanQuery(must, mustNot []query.Query) query.Query {
+	q := bleve.NewBooleanQuery()
+	if len(must) > 0 {
+		q.AddMust(must...)
+	}
+	if len(mustNot) > 0 {
+		q.AddMustNot(mustNot...)
+	}
+	return q
+}
+
+// The boosts of the different ways free text can match the name of a resource. Exact matches of the
+// whole name, boosted as configured, rank above matches of whole words, which rank above matches of
+// word prefixes, parts of words and fuzzy matches.
+const (
+	nameWordsBoost    = 3.0
+	namePrefixesBoost = 1.5
+	nameFuzzyBoost    = 0.5
+)
+
+// compileText matches free text against the name and the content. Unquoted free text containing
+// wildcards only matches the name. Besides containing the text the name matches if the words of the
+// text are prefixes of the words of the name or if they differ in at most opts.fuzziness characters.
+func compileText(n *textNode, opts queryOptions) query.Query {
+	value := strings.ToLower(n.value)
+	if !n.phrase && strings.ContainsAny(value, "*?") {
+		q := bleve.NewWildcardQuery(value)
+		q.SetField("Name")
+		return q
+	}
+
+	exact := bleve.NewTermQuery(value)
+	exact.SetField("Name")
+	exact.SetBoost(opts.exactNameBoost)
+	name := bleve.NewWildcardQuery("*" + value + "*")
+	name.SetField("Name")
+	disjuncts := []query.Query{exact, name}
+	if n.phrase {
+		q := bleve.NewMatchPhraseQuery(n.value)
+		q.SetField("Content")
+		return bleve.NewDisjunctionQuery(append(disjuncts, q)...)
+	}
+
+	disjuncts = append(disjuncts,
+		nameWordsQuery("NameWords", n.value, 0, nameWordsBoost),
+		nameWordsQuery("NamePrefixes", n.value, 0, namePrefixesBoost),
+	)
+	if opts.fuzziness > 0 {
+		disjuncts = append(disjuncts, nameWordsQuery("NameWords", n.value, opts.fuzziness, nameFuzzyBoost))
+	}
+	content := bleve.NewMatchQuery(n.value)
+	content.SetField("Content")
+	content.SetOperator(query.MatchQueryOperatorAnd)
+	return bleve.NewDisjunctionQuery(append(disjuncts, content)...)
+}
+
+// nameWordsQuery matches all words of the value against the given name field. The value is split into
+// words the same way as the names, the prefixes of the words are not taken into account.
+func nameWordsQuery(field, value string, fuzziness int, boost float64) query.Query {
+	q := bleve.NewMatchQuery(value)
+	q.SetField(field)
+	q.Analyzer = "nameWords"
+	q.SetOperator(query.MatchQueryOperatorAnd)
+	q.SetFuzziness(fuzziness)
+	q.SetBoost(boost)
+	return q
+}
+
+// checkOperator makes sure the comparison operators are only used for the fields supporting them
+func checkOperator(n *restrictionNode) error {
+	isEquality := n.operator == ":" || n.operator == "="
+	if !isEquality && n.field != "size" && n.field != "mtime" {
+		return fmt.Errorf("operator '%s' is not supported for field '%s'", n.operator, n.field)
+	}
+	return nil
+}
+
+func compileRestriction(n *restrictionNode, opts queryOptions) (query.Query, error) {
+	if err := checkOperator(n); err != nil {
+		return nil, err
+	}
+
+	switch n.field {
+	case "name":
+		return termOrWildcardQuery("Name", strings.ToLower(n.value)), nil
+	case "content":
+		q := bleve.NewMatchPhraseQuery(n.value)
+		q.SetField("Content")
+		return q, nil
+	case "type":
+		return compileType(strings.ToLower(n.value))
+	case "mediatype":
+		value := strings.ToLower(n.value)
+		if !strings.Contains(value, "/") {
+			q := bleve.NewPrefixQuery(value + "/")
+			q.SetField("MimeType")
+			return q, nil
+		}
+		return termOrWildcardQuery("MimeType", value), nil
+	case "size":
+		size, err := parseSize(n.value)
+		if err != nil {
+			return nil, err
+		}
+		return numericQuery("Size", n.operator, size), nil
+	case "mtime":
+		return compileMtime(n.operator, n.value)
+	case "id":
+		q := bleve.NewTermQuery(n.value)
+		q.SetField("ID")
+		return q, nil
+	case "hidden":
+		hidden, err := strconv.ParseBool(n.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for field 'hidden', expected true or false", n.value)
+		}
+		q := bleve.NewBoolFieldQuery(hidden)
+		q.SetField("Hidden")
+		return q, nil
+	case "tag":
+		return termOrWildcardQuery("Tags", strings.ToLower(n.value)), nil
+	case "favorite":
+		favorite, err := strconv.ParseBool(n.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for field 'favorite', expected true or false", n.value)
+		}
+		q := bleve.NewTermQuery(opts.userID)
+		q.SetField("Favorites")
+		if !favorite {
+			return newBooleanQuery(nil, []query.Query{q}), nil
+		}
+		return q, nil
+	}
+	return nil, fmt.Errorf("unknown field '%s'", n.field)
+}
+
+func termOrWildcardQuery(field, value string) query.Query {
+	if strings.ContainsAny(value, "*?") {
+		q := bleve.NewWildcardQuery(value)
+		q.SetField(field)
+		return q
+	}
+	q := bleve.NewTermQuery(value)
+	q.SetField(field)
+	return q
+}
+
+// compileType matches the resource type or the mime type group
+func compileType(value string) (query.Query, error) {
+	switch value {
+	case "file", "folder":
+		t := float64(sprovider.ResourceType_RESOURCE_TYPE_FILE)
+		if value == "folder" {
+			t = float64(sprovider.ResourceType_RESOURCE_TYPE_CONTAINER)
+		}
+		return numericQuery("Type", "=", t), nil
+	}
+
+	prefixes, exclusions, err := mimeTypeGroupPrefixes(value)
+	if err != nil {
+		return nil, err
+	}
+	must, mustNot := []query.Query{}, []query.Query{}
+	for _, p := range prefixes {
+		q := bleve.NewPrefixQuery(p)
+		q.SetField("MimeType")
+		must = append(must, q)
+	}
+	for _, p := range exclusions {
+		q := bleve.NewPrefixQuery(p)
+		q.SetField("MimeType")
+		mustNot = append(mustNot, q)
+	}
+	return newBooleanQuery([]query.Query{bleve.NewDisjunctionQuery(must...)}, mustNot), nil
+}
+
+// mimeTypeGroupPrefixes returns the mime type prefixes of the given group and the prefixes which have
+// to be excluded because they belong to another group with a longer matching prefix. That way the mime
+// types of a group are matched the same way as for the mimetype facet.
+func mimeTypeGroupPrefixes(group string) ([]string, []string, error) {
+	names := []string{"file", "folder"}
+	for _, g := range mimeTypeGroups {
+		if g.name != group {
+			if g.name != "folder" {
+				names = append(names, g.name)
+			}
+			continue
+		}
+
+		exclusions := []string{}
+		for _, p := range g.prefixes {
+			for _, other := range mimeTypeGroups {
+				if other.name == g.name {
+					continue
+				}
+				for _, op := range other.prefixes {
+					if len(op) > len(p) && strings.HasPrefix(op, p) {
+						exclusions = append(exclusions, op)
+					}
+				}
+			}
+		}
+		return g.prefixes, exclusions, nil
+	}
+	return nil, nil, fmt.Errorf("invalid value '%s' for field 'type', supported types are %s", group, strings.Join(names, ", "))
+}
+
+func numericQuery(field, operator string, value float64) query.Query {
+	var min, max *float64
+	inclusive, exclusive := true, false
+	minInclusive, maxInclusive := &inclusive, &inclusive
+	switch operator {
+	case "<":
+		max, maxInclusive = &value, &exclusive
+	case "<=":
+		max = &value
+	case ">":
+		min, minInclusive = &value, &exclusive
+	case ">=":
+		min = &value
+	default:
+		min, max = &value, &value
+	}
+	q := bleve.NewNumericRangeInclusiveQuery(min, max, minInclusive, maxInclusive)
+	q.SetField(field)
+	return q
+}
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1024,
+	"mib": 1024 * 1024,
+	"gib": 1024 * 1024 * 1024,
+	"tib": 1024 * 1024 * 1024 * 1024,
+}
+
+// parseSize parses sizes like 100, 1.5MB or 10KiB
+func parseSize(s string) (float64, error) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if i == -1 {
+		i = len(s)
+	}
+	number, err := strconv.ParseFloat(s[:i], 64)
+	unit, ok := sizeUnits[strings.ToLower(s[i:])]
+	if err != nil || !ok {
+		return 0, fmt.Errorf("invalid size '%s', expected a number with an optional unit like 10MB", s)
+	}
+	return math.Round(number * unit), nil
+}
+
+// compileMtime matches the modification time. Dates match the whole day, e.g. mtime>2022-01-01 matches
+// resources modified after the first of January.
+func compileMtime(operator, value string) (query.Query, error) {
+	start, end, err := parseTime(value)
+	if err != nil {
+		return nil, err
+	}
+	inclusive, exclusive := true, false
+	switch operator {
+	case "<":
+		q := bleve.NewDateRangeInclusiveQuery(time.Time{}, start, nil, &exclusive)
+		q.SetField("Mtime")
+		return q, nil
+	case "<=":
+		q := bleve.NewDateRangeInclusiveQuery(time.Time{}, end, nil, &inclusive)
+		q.SetField("Mtime")
+		return q, nil
+	case ">":
+		q := bleve.NewDateRangeInclusiveQuery(end, time.Time{}, &exclusive, nil)
+		q.SetField("Mtime")
+		return q, nil
+	case ">=":
+		q := bleve.NewDateRangeInclusiveQuery(start, time.Time{}, &inclusive, nil)
+		q.SetField("Mtime")
+		return q, nil
+	}
+	q := bleve.NewDateRangeInclusiveQuery(start, end, &inclusive, &inclusive)
+	q.SetField("Mtime")
+	return q, nil
+}
+
+// parseTime returns the first and the last instant the given date or timestamp covers
+func parseTime(value string) (time.Time, time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, t, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid time '%s', expected a date like 2022-01-31 or a RFC3339 timestamp", value)
+}
@@ -0,0 +1,287 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/numeric"
+	"github.com/blevesearch/bleve/v2/search"
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	searchpkg "github.com/owncloud/ocis/v2/services/search/pkg/search"
+)
+
+// ranking holds the parameters the matches of a search are ranked with
+type ranking struct {
+	// at is the time the recency of the matches is ranked relative to
+	at                 time.Time
+	recencyBoost       float64
+	recencyHalfLife    time.Duration
+	personalRootID     string
+	personalSpaceBoost float64
+}
+
+// searchOrder holds the order of the matches of a search request along with the position to continue at
+type searchOrder struct {
+	order     searchpkg.Order
+	pageToken *searchpkg.PageToken
+	ranking   ranking
+}
+
+// newSearchOrder returns the order and the ranking of the matches of the given request
+func newSearchOrder(req *searchsvc.SearchIndexRequest, opts Options) (searchOrder, error) {
+	order, err := searchpkg.ParseOrder(req.OrderBy)
+	if err != nil {
+		return searchOrder{}, errtypes.BadRequest(err.Error())
+	}
+	o := searchOrder{
+		order: order,
+		ranking: ranking{
+			at:                 time.Now(),
+			recencyBoost:       opts.RecencyBoost,
+			recencyHalfLife:    opts.RecencyHalfLife,
+			personalSpaceBoost: opts.PersonalSpaceBoost,
+		},
+	}
+	if req.PageToken != "" {
+		token, err := searchpkg.DecodePageTokenFor(req.PageToken, order)
+		if err != nil {
+			return searchOrder{}, errtypes.BadRequest(err.Error())
+		}
+		o.pageToken = &token
+		o.ranking.at = token.RankedAt
+	}
+	if req.RankedAt != nil {
+		o.ranking.at = req.RankedAt.AsTime()
+	}
+	if req.PersonalRootId != nil {
+		o.ranking.personalRootID = idToBleveId(&sprovider.ResourceId{
+			StorageId: req.PersonalRootId.StorageId,
+			SpaceId:   req.PersonalRootId.SpaceId,
+			OpaqueId:  req.PersonalRootId.OpaqueId,
+		})
+	}
+	return o, nil
+}
+
+// rank returns the score of a match boosted according to its modification time and its space
+func (r ranking) rank(score float64, mtime time.Time, rootID string) float32 {
+	if r.recencyBoost > 0 && !mtime.IsZero() {
+		age := r.at.Sub(mtime)
+		if age < 0 {
+			age = -age
+		}
+		score *= 1 + r.recencyBoost*math.Pow(0.5, float64(age)/float64(r.recencyHalfLife))
+	}
+	if r.personalRootID != "" && rootID == r.personalRootID {
+		score *= r.personalSpaceBoost
+	}
+	return float32(score)
+}
+
+// openSearchQuery wraps the given query so that the scores of its matches are ranked like the bleve
+// matches. The recency boost uses an exponential decay function which halves the boost with every
+// half-life passed.
+func (r ranking) openSearchQuery(q map[string]interface{}) map[string]interface{} {
+	if r.recencyBoost > 0 {
+		q = map[string]interface{}{
+			"function_score": map[string]interface{}{
+				"query": q,
+				"functions": []interface{}{
+					map[string]interface{}{"weight": 1},
+					map[string]interface{}{
+						"exp": map[string]interface{}{
+							"Mtime": map[string]interface{}{
+								"origin": strconv.FormatInt(r.at.UnixMilli(), 10),
+								"scale":  fmt.Sprintf("%dms", r.recencyHalfLife.Milliseconds()),
+								"decay":  0.5,
+							},
+						},
+						"weight": r.recencyBoost,
+					},
+				},
+				"score_mode": "sum",
+				"boost_mode": "multiply",
+			},
+		}
+	}
+	if r.personalRootID != "" && r.personalSpaceBoost != 1 {
+		q = map[string]interface{}{
+			"function_score": map[string]interface{}{
+				"query": q,
+				"functions": []interface{}{
+					map[string]interface{}{"filter": termQuery("RootID", r.personalRootID), "weight": r.personalSpaceBoost},
+				},
+				"boost_mode": "multiply",
+			},
+		}
+	}
+	return q
+}
+
+// openSearchSort returns the sort and the search_after parameters of OpenSearch requests
+func (o searchOrder) openSearchSort() ([]interface{}, []interface{}) {
+	field := map[string]string{
+		searchpkg.OrderByName:  "Name",
+		searchpkg.OrderByMtime: "Mtime",
+		searchpkg.OrderBySize:  "Size",
+	}[o.order.Field]
+	if field == "" {
+		field = "_score"
+	}
+	direction := "asc"
+	if o.order.Descending {
+		direction = "desc"
+	}
+	sort := []interface{}{map[string]interface{}{field: direction}, map[string]interface{}{"ID": "asc"}}
+	if o.pageToken == nil {
+		return sort, nil
+	}
+
+	var after interface{}
+	switch o.order.Field {
+	case searchpkg.OrderByName:
+		after = o.pageToken.Name
+	case searchpkg.OrderByMtime:
+		after = o.pageToken.Mtime
+	case searchpkg.OrderBySize:
+		after = o.pageToken.Size
+	default:
+		after = o.pageToken.Score
+	}
+	return sort, []interface{}{after, o.pageToken.ID}
+}
+
+// bleveSort returns the sort order and the search after values of bleve requests
+func (o searchOrder) bleveSort() (search.SortOrder, []string) {
+	sort := search.SortOrder{&sortMatch{order: o.order, ranking: o.ranking}, &search.SortDocID{}}
+	if o.pageToken == nil {
+		return sort, nil
+	}
+
+	var after string
+	switch o.order.Field {
+	case searchpkg.OrderByName:
+		after = o.pageToken.Name
+	case searchpkg.OrderByMtime:
+		after = sortableInt64(o.pageToken.Mtime)
+	case searchpkg.OrderBySize:
+		after = sortableUint64(o.pageToken.Size)
+	default:
+		after = sortableScore(o.pageToken.Score)
+	}
+	return sort, []string{after, o.pageToken.ID}
+}
+
+// sortMatch sorts the hits according to the requested order. The hits are ranked while sorting, their
+// scores are replaced with the ranked scores with float32 precision, which is the precision the scores
+// are returned with. That way page tokens can be built from the returned matches.
+type sortMatch struct {
+	order   searchpkg.Order
+	ranking ranking
+
+	// the values of the hit currently being sorted
+	name   string
+	rootID string
+	mtime  time.Time
+	size   uint64
+}
+
+// UpdateVisitor collects the values of the hit needed for sorting and ranking
+func (s *sortMatch) UpdateVisitor(field string, term []byte) {
+	switch field {
+	case "Name":
+		s.name = string(term)
+	case "RootID":
+		s.rootID = string(term)
+	case "Mtime":
+		if v, ok := prefixCodedInt64(term); ok {
+			s.mtime = time.Unix(0, v)
+		}
+	case "Size":
+		if v, ok := prefixCodedInt64(term); ok {
+			s.size = uint64(numeric.Int64ToFloat64(v))
+		}
+	}
+}
+
+// Value ranks the given hit and returns the sortable representation of its value of the order field.
+// It also resets the collected values for processing the next hit.
+func (s *sortMatch) Value(d *search.DocumentMatch) string {
+	d.Score = float64(s.ranking.rank(d.Score, s.mtime, s.rootID))
+
+	var value string
+	switch s.order.Field {
+	case searchpkg.OrderByName:
+		value = searchpkg.SortableName(s.name)
+	case searchpkg.OrderByMtime:
+		// the stored modification times are returned with second precision only, so the hits are
+		// ordered with that precision as well
+		var millis int64
+		if !s.mtime.IsZero() {
+			millis = s.mtime.Unix() * 1000
+		}
+		value = sortableInt64(millis)
+	case searchpkg.OrderBySize:
+		value = sortableUint64(s.size)
+	default:
+		value = sortableScore(float32(d.Score))
+	}
+
+	s.name, s.rootID, s.mtime, s.size = "", "", time.Time{}, 0
+	return value
+}
+
+// Descending determines the order of the sort
+func (s *sortMatch) Descending() bool { return s.order.Descending }
+
+// RequiresDocID returns false
+func (s *sortMatch) RequiresDocID() bool { return false }
+
+// RequiresScoring returns false. The hits are scored anyway, returning true would make bleve
+// compare the scores with float64 precision.
+func (s *sortMatch) RequiresScoring() bool { return false }
+
+// RequiresFields returns the fields needed for sorting and ranking
+func (s *sortMatch) RequiresFields() []string { return []string{"Name", "RootID", "Mtime", "Size"} }
+
+// Reverse reverses the order of the sort
+func (s *sortMatch) Reverse() { s.order.Descending = !s.order.Descending }
+
+// Copy returns a copy of the sort
+func (s *sortMatch) Copy() search.SearchSort { return &sortMatch{order: s.order, ranking: s.ranking} }
+
+// prefixCodedInt64 decodes the full precision term of numeric and date fields. The terms of
+// lower precision bleve indexes for range queries are skipped.
+func prefixCodedInt64(term []byte) (int64, bool) {
+	shift, err := numeric.PrefixCoded(term).Shift()
+	if err != nil || shift != 0 {
+		return 0, false
+	}
+	v, err := numeric.PrefixCoded(term).Int64()
+	return v, err == nil
+}
+
+// sortableScore returns a representation of the score which sorts lexicographically like the score itself
+func sortableScore(score float32) string {
+	bits := math.Float32bits(score)
+	if bits&(1<<31) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 31
+	}
+	return fmt.Sprintf("%08x", bits)
+}
+
+// sortableInt64 returns a representation of the value which sorts lexicographically like the value itself
+func sortableInt64(v int64) string {
+	return fmt.Sprintf("%016x", uint64(v)^(1<<63))
+}
+
+// sortableUint64 returns a representation of the value which sorts lexicographically like the value itself
+func sortableUint64(v uint64) string {
+	return fmt.Sprintf("%016x", v)
+}
@@ -0,0 +1,89 @@
+package search
+
+import (
+	"strings"
+
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/storagespace"
+	"github.com/cs3org/reva/v2/pkg/utils"
+)
+
+const (
+	// TagsMetadataKey is the arbitrary metadata key holding the comma separated tags of a resource
+	TagsMetadataKey = "tags"
+	// FavoriteMetadataKey is the arbitrary metadata key telling whether the user who requested the
+	// resource info marked the resource as favorite
+	FavoriteMetadataKey = "http://owncloud.org/ns/favorite"
+	// SpaceDescriptionKey is the key of the opaque of a space holding its description
+	SpaceDescriptionKey = "description"
+	// SpaceReadmeKey is the key of the opaque of a space holding the id of its readme file
+	SpaceReadmeKey = "readme"
+)
+
+// Tags returns the tags of the resource. Surrounding whitespace and duplicates are removed.
+func Tags(ri *providerv1beta1.ResourceInfo) []string {
+	return ParseTags(ri.GetArbitraryMetadata().GetMetadata()[TagsMetadataKey])
+}
+
+// ParseTags splits the comma separated list of tags
+func ParseTags(list string) []string {
+	tags := []string{}
+	seen := map[string]struct{}{}
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// IsFavorite returns true if the user who requested the resource info marked the resource as favorite
+func IsFavorite(ri *providerv1beta1.ResourceInfo) bool {
+	return ri.GetArbitraryMetadata().GetMetadata()[FavoriteMetadataKey] == "1"
+}
+
+// Favorites returns the ids of the users who marked the resource as favorite. The favorite mark in the
+// resource info belongs to the user who requested it, it is taken as the mark of the owner. Resource
+// infos requested by other users must not carry the mark, see WithoutForeignFavorite.
+func Favorites(ri *providerv1beta1.ResourceInfo) []string {
+	if !IsFavorite(ri) || ri.GetOwner().GetOpaqueId() == "" {
+		return nil
+	}
+	return []string{ri.Owner.OpaqueId}
+}
+
+// WithoutForeignFavorite removes the favorite mark from the resource info unless it has been requested by
+// the owner of the resource
+func WithoutForeignFavorite(ri *providerv1beta1.ResourceInfo, requester *userv1beta1.UserId) *providerv1beta1.ResourceInfo {
+	if requester.GetOpaqueId() != ri.GetOwner().GetOpaqueId() && ri.GetArbitraryMetadata() != nil {
+		delete(ri.ArbitraryMetadata.Metadata, FavoriteMetadataKey)
+	}
+	return ri
+}
+
+// SpaceDescription returns the description of the space
+func SpaceDescription(space *providerv1beta1.StorageSpace) string {
+	return utils.ReadPlainFromOpaque(space.GetOpaque(), SpaceDescriptionKey)
+}
+
+// SpaceReadmeID returns the id of the readme file of the space, nil if the space has none. The id is
+// stored without the storage id, all resources of a space share the storage id of its root.
+func SpaceReadmeID(space *providerv1beta1.StorageSpace) *providerv1beta1.ResourceId {
+	value := utils.ReadPlainFromOpaque(space.GetOpaque(), SpaceReadmeKey)
+	if value == "" {
+		return nil
+	}
+	id, err := storagespace.ParseID(value)
+	if err != nil || id.OpaqueId == "" {
+		return nil
+	}
+	id.StorageId = space.GetRoot().GetStorageId()
+	return &id
+}
@@ -0,0 +1,86 @@
+package search_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/utils"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
+)
+
+var _ = Describe("Metadata", func() {
+	var (
+		info = func(metadata map[string]string) *providerv1beta1.ResourceInfo {
+			return &providerv1beta1.ResourceInfo{
+				ArbitraryMetadata: &providerv1beta1.ArbitraryMetadata{Metadata: metadata},
+			}
+		}
+	)
+
+	Describe("Tags", func() {
+		It("splits the comma separated list of tags", func() {
+			Expect(search.Tags(info(map[string]string{"tags": " invoice-2022,Tax , ,invoice-2022"}))).To(Equal([]string{"invoice-2022", "Tax"}))
+		})
+
+		It("returns no tags for resources without metadata", func() {
+			Expect(search.Tags(&providerv1beta1.ResourceInfo{})).To(BeEmpty())
+		})
+	})
+
+	Describe("IsFavorite", func() {
+		It("tells whether the resource has been marked as favorite", func() {
+			Expect(search.IsFavorite(info(map[string]string{search.FavoriteMetadataKey: "1"}))).To(BeTrue())
+			Expect(search.IsFavorite(info(map[string]string{search.FavoriteMetadataKey: "0"}))).To(BeFalse())
+			Expect(search.IsFavorite(&providerv1beta1.ResourceInfo{})).To(BeFalse())
+		})
+	})
+
+	Describe("Favorites", func() {
+		var (
+			owner    = &userv1beta1.UserId{OpaqueId: "einstein"}
+			favorite = func() *providerv1beta1.ResourceInfo {
+				ri := info(map[string]string{search.FavoriteMetadataKey: "1"})
+				ri.Owner = owner
+				return ri
+			}
+		)
+
+		It("takes the favorite mark as the one of the owner", func() {
+			Expect(search.Favorites(favorite())).To(Equal([]string{"einstein"}))
+			Expect(search.Favorites(info(map[string]string{search.FavoriteMetadataKey: "1"}))).To(BeEmpty())
+			Expect(search.Favorites(&providerv1beta1.ResourceInfo{Owner: owner})).To(BeEmpty())
+		})
+
+		It("drops the favorite mark of other users", func() {
+			Expect(search.Favorites(search.WithoutForeignFavorite(favorite(), owner))).To(Equal([]string{"einstein"}))
+			Expect(search.Favorites(search.WithoutForeignFavorite(favorite(), &userv1beta1.UserId{OpaqueId: "marie"}))).To(BeEmpty())
+			Expect(search.WithoutForeignFavorite(&providerv1beta1.ResourceInfo{}, owner)).ToNot(BeNil())
+		})
+	})
+
+	Describe("Spaces", func() {
+		var space = func(key, value string) *providerv1beta1.StorageSpace {
+			return &providerv1beta1.StorageSpace{
+				Root:   &providerv1beta1.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "spaceid"},
+				Opaque: utils.AppendPlainToOpaque(nil, key, value),
+			}
+		}
+
+		It("returns the description of the space", func() {
+			Expect(search.SpaceDescription(space(search.SpaceDescriptionKey, "Budget planning"))).To(Equal("Budget planning"))
+			Expect(search.SpaceDescription(&providerv1beta1.StorageSpace{})).To(BeEmpty())
+		})
+
+		It("returns the id of the readme of the space", func() {
+			Expect(search.SpaceReadmeID(space(search.SpaceReadmeKey, "spaceid!readmeid"))).To(Equal(&providerv1beta1.ResourceId{
+				StorageId: "storageid",
+				SpaceId:   "spaceid",
+				OpaqueId:  "readmeid",
+			}))
+			Expect(search.SpaceReadmeID(space(search.SpaceReadmeKey, "spaceid"))).To(BeNil())
+			Expect(search.SpaceReadmeID(&providerv1beta1.StorageSpace{})).To(BeNil())
+		})
+	})
+})
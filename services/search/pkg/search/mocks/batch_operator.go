@@ -0,0 +1,70 @@
+// Code generated by mockery v2.14.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// BatchOperator is an autogenerated mock type for the BatchOperator type
+type BatchOperator struct {
+	mock.Mock
+}
+
+// Add provides a mock function with given fields: ref, ri, content
+func (_m *BatchOperator) Add(ref *providerv1beta1.Reference, ri *providerv1beta1.ResourceInfo, content string) error {
+	ret := _m.Called(ref, ri, content)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*providerv1beta1.Reference, *providerv1beta1.ResourceInfo, string) error); ok {
+		r0 = rf(ref, ri, content)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Purge provides a mock function with given fields: id
+func (_m *BatchOperator) Purge(id *providerv1beta1.ResourceId) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*providerv1beta1.ResourceId) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Push provides a mock function with given fields:
+func (_m *BatchOperator) Push() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewBatchOperator interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewBatchOperator creates a new instance of BatchOperator. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewBatchOperator(t mockConstructorTestingTNewBatchOperator) *BatchOperator {
+	mock := &BatchOperator{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
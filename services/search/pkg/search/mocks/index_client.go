@@ -8,7 +8,13 @@ import (
 	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	mock "github.com/stretchr/testify/mock"
 
-	v0 "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	search "github.com/owncloud/ocis/v2/services/search/pkg/search"
+
+	searchv0 "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+
+	time "time"
+
+	v0 "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
 )
 
 // IndexClient is an autogenerated mock type for the IndexClient type
@@ -16,13 +22,27 @@ type IndexClient struct {
 	mock.Mock
 }
 
-// Add provides a mock function with given fields: ref, ri
-func (_m *IndexClient) Add(ref *providerv1beta1.Reference, ri *providerv1beta1.ResourceInfo) error {
-	ret := _m.Called(ref, ri)
+// Add provides a mock function with given fields: ref, ri, content
+func (_m *IndexClient) Add(ref *providerv1beta1.Reference, ri *providerv1beta1.ResourceInfo, content string) error {
+	ret := _m.Called(ref, ri, content)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*providerv1beta1.Reference, *providerv1beta1.ResourceInfo) error); ok {
-		r0 = rf(ref, ri)
+	if rf, ok := ret.Get(0).(func(*providerv1beta1.Reference, *providerv1beta1.ResourceInfo, string) error); ok {
+		r0 = rf(ref, ri, content)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddSpace provides a mock function with given fields: space, readme
+func (_m *IndexClient) AddSpace(space *providerv1beta1.StorageSpace, readme string) error {
+	ret := _m.Called(space, readme)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*providerv1beta1.StorageSpace, string) error); ok {
+		r0 = rf(space, readme)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -65,6 +85,43 @@ func (_m *IndexClient) DocCount() (uint64, error) {
 	return r0, r1
 }
 
+// Get provides a mock function with given fields: id
+func (_m *IndexClient) Get(id *providerv1beta1.ResourceId) (*v0.Entity, error) {
+	ret := _m.Called(id)
+
+	var r0 *v0.Entity
+	if rf, ok := ret.Get(0).(func(*providerv1beta1.ResourceId) *v0.Entity); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v0.Entity)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*providerv1beta1.ResourceId) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListSpace provides a mock function with given fields: rootID, fn
+func (_m *IndexClient) ListSpace(rootID *providerv1beta1.ResourceId, fn func(*v0.Entity) error) error {
+	ret := _m.Called(rootID, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*providerv1beta1.ResourceId, func(*v0.Entity) error) error); ok {
+		r0 = rf(rootID, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Move provides a mock function with given fields: id, parentID, fullPath
 func (_m *IndexClient) Move(id *providerv1beta1.ResourceId, parentID *providerv1beta1.ResourceId, fullPath string) error {
 	ret := _m.Called(id, parentID, fullPath)
@@ -79,6 +136,29 @@ func (_m *IndexClient) Move(id *providerv1beta1.ResourceId, parentID *providerv1
 	return r0
 }
 
+// NewBatch provides a mock function with given fields: size
+func (_m *IndexClient) NewBatch(size int) (search.BatchOperator, error) {
+	ret := _m.Called(size)
+
+	var r0 search.BatchOperator
+	if rf, ok := ret.Get(0).(func(int) search.BatchOperator); ok {
+		r0 = rf(size)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(search.BatchOperator)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(size)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Purge provides a mock function with given fields: id
 func (_m *IndexClient) Purge(id *providerv1beta1.ResourceId) error {
 	ret := _m.Called(id)
@@ -93,6 +173,41 @@ func (_m *IndexClient) Purge(id *providerv1beta1.ResourceId) error {
 	return r0
 }
 
+// PurgeDeleted provides a mock function with given fields: deletedBefore
+func (_m *IndexClient) PurgeDeleted(deletedBefore time.Time) (int, error) {
+	ret := _m.Called(deletedBefore)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(time.Time) int); ok {
+		r0 = rf(deletedBefore)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(deletedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeSpace provides a mock function with given fields: rootID
+func (_m *IndexClient) PurgeSpace(rootID *providerv1beta1.ResourceId) error {
+	ret := _m.Called(rootID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*providerv1beta1.ResourceId) error); ok {
+		r0 = rf(rootID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Restore provides a mock function with given fields: id
 func (_m *IndexClient) Restore(id *providerv1beta1.ResourceId) error {
 	ret := _m.Called(id)
@@ -108,20 +223,20 @@ func (_m *IndexClient) Restore(id *providerv1beta1.ResourceId) error {
 }
 
 // Search provides a mock function with given fields: ctx, req
-func (_m *IndexClient) Search(ctx context.Context, req *v0.SearchIndexRequest) (*v0.SearchIndexResponse, error) {
+func (_m *IndexClient) Search(ctx context.Context, req *searchv0.SearchIndexRequest) (*searchv0.SearchIndexResponse, error) {
 	ret := _m.Called(ctx, req)
 
-	var r0 *v0.SearchIndexResponse
-	if rf, ok := ret.Get(0).(func(context.Context, *v0.SearchIndexRequest) *v0.SearchIndexResponse); ok {
+	var r0 *searchv0.SearchIndexResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *searchv0.SearchIndexRequest) *searchv0.SearchIndexResponse); ok {
 		r0 = rf(ctx, req)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*v0.SearchIndexResponse)
+			r0 = ret.Get(0).(*searchv0.SearchIndexResponse)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *v0.SearchIndexRequest) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, *searchv0.SearchIndexRequest) error); ok {
 		r1 = rf(ctx, req)
 	} else {
 		r1 = ret.Error(1)
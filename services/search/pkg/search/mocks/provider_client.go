@@ -15,6 +15,29 @@ type ProviderClient struct {
 	mock.Mock
 }
 
+// CheckIndex provides a mock function with given fields: ctx, req
+func (_m *ProviderClient) CheckIndex(ctx context.Context, req *v0.CheckIndexRequest) (*v0.CheckIndexResponse, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *v0.CheckIndexResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *v0.CheckIndexRequest) *v0.CheckIndexResponse); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v0.CheckIndexResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *v0.CheckIndexRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // IndexSpace provides a mock function with given fields: ctx, req
 func (_m *ProviderClient) IndexSpace(ctx context.Context, req *v0.IndexSpaceRequest) (*v0.IndexSpaceResponse, error) {
 	ret := _m.Called(ctx, req)
@@ -38,6 +61,20 @@ func (_m *ProviderClient) IndexSpace(ctx context.Context, req *v0.IndexSpaceRequ
 	return r0, r1
 }
 
+// IndexSpaces provides a mock function with given fields: ctx, req, send
+func (_m *ProviderClient) IndexSpaces(ctx context.Context, req *v0.IndexSpacesRequest, send func(*v0.IndexSpacesResponse) error) error {
+	ret := _m.Called(ctx, req, send)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v0.IndexSpacesRequest, func(*v0.IndexSpacesResponse) error) error); ok {
+		r0 = rf(ctx, req, send)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Search provides a mock function with given fields: ctx, req
 func (_m *ProviderClient) Search(ctx context.Context, req *v0.SearchRequest) (*v0.SearchResponse, error) {
 	ret := _m.Called(ctx, req)
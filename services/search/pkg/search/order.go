@@ -0,0 +1,135 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+)
+
+// The fields the search results can be ordered by
+const (
+	// OrderByScore orders the matches by their ranked score
+	OrderByScore = "score"
+	// OrderByName orders the matches by their name, ignoring the case
+	OrderByName = "name"
+	// OrderByMtime orders the matches by their modification time with the precision the index returns
+	// them with, at most milliseconds
+	OrderByMtime = "mtime"
+	// OrderBySize orders the matches by their size
+	OrderBySize = "size"
+)
+
+// Order is the order of search results. Matches sorting the same are ordered by their resource id.
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// DefaultOrder is the order used when no order is requested
+var DefaultOrder = Order{Field: OrderByScore, Descending: true}
+
+// ParseOrder parses the requested order of the search results. The order consists of the field to
+// order by, optionally followed by the direction "asc" or "desc". Without a direction the matches are
+// ordered by score, mtime and size descending and by name ascending.
+func ParseOrder(orderBy string) (Order, error) {
+	parts := strings.Fields(strings.ToLower(orderBy))
+	if len(parts) == 0 {
+		return DefaultOrder, nil
+	}
+	if len(parts) > 2 {
+		return Order{}, fmt.Errorf("invalid order '%s'", orderBy)
+	}
+
+	o := Order{Field: parts[0]}
+	switch o.Field {
+	case OrderByScore, OrderByMtime, OrderBySize:
+		o.Descending = true
+	case OrderByName:
+	default:
+		return Order{}, fmt.Errorf("unknown order field '%s'", parts[0])
+	}
+	if len(parts) == 2 {
+		switch parts[1] {
+		case "asc":
+			o.Descending = false
+		case "desc":
+			o.Descending = true
+		default:
+			return Order{}, fmt.Errorf("unknown order direction '%s'", parts[1])
+		}
+	}
+	return o, nil
+}
+
+// String returns the canonical representation of the order, e.g. "name asc"
+func (o Order) String() string {
+	if o.Descending {
+		return o.Field + " desc"
+	}
+	return o.Field + " asc"
+}
+
+// SortsBefore returns true if match a is ranked before match b
+func (o Order) SortsBefore(a, b *searchmsg.Match) bool {
+	c := o.compare(a, b)
+	if o.Descending {
+		c = -c
+	}
+	if c != 0 {
+		return c < 0
+	}
+	return formatID(a.GetEntity().GetId()) < formatID(b.GetEntity().GetId())
+}
+
+// compare compares the values of the order field of the matches in ascending order
+func (o Order) compare(a, b *searchmsg.Match) int {
+	switch o.Field {
+	case OrderByName:
+		return strings.Compare(SortableName(a.GetEntity().GetName()), SortableName(b.GetEntity().GetName()))
+	case OrderByMtime:
+		return compareInt64(SortableMtime(a.GetEntity()), SortableMtime(b.GetEntity()))
+	case OrderBySize:
+		sa, sb := a.GetEntity().GetSize(), b.GetEntity().GetSize()
+		switch {
+		case sa < sb:
+			return -1
+		case sa > sb:
+			return 1
+		}
+		return 0
+	default:
+		switch {
+		case a.Score < b.Score:
+			return -1
+		case a.Score > b.Score:
+			return 1
+		}
+		return 0
+	}
+}
+
+// SortableName returns the representation of the name the matches are ordered by
+func SortableName(name string) string {
+	return strings.ToLower(name)
+}
+
+// SortableMtime returns the modification time of the entity in milliseconds since the epoch. The matches
+// are ordered with millisecond precision, which is the precision supported by all search engines.
+func SortableMtime(e *searchmsg.Entity) int64 {
+	if e.GetLastModifiedTime() == nil {
+		return 0
+	}
+	return e.GetLastModifiedTime().AsTime().UnixNano() / int64(time.Millisecond)
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package search_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var _ = Describe("Order", func() {
+	var (
+		match = func(opaqueID, name string, score float32, size uint64, mtime time.Time) *searchmsg.Match {
+			return &searchmsg.Match{
+				Score: score,
+				Entity: &searchmsg.Entity{
+					Id:               &searchmsg.ResourceID{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: opaqueID},
+					Name:             name,
+					Size:             size,
+					LastModifiedTime: timestamppb.New(mtime),
+				},
+			}
+		}
+		now = time.Now()
+	)
+
+	DescribeTable("ParseOrder",
+		func(orderBy string, expected search.Order) {
+			order, err := search.ParseOrder(orderBy)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(order).To(Equal(expected))
+		},
+		Entry("defaults to the score", "", search.Order{Field: search.OrderByScore, Descending: true}),
+		Entry("orders names ascending", "name", search.Order{Field: search.OrderByName}),
+		Entry("orders the mtime descending", "mtime", search.Order{Field: search.OrderByMtime, Descending: true}),
+		Entry("orders the size descending", "size", search.Order{Field: search.OrderBySize, Descending: true}),
+		Entry("supports directions", "Name DESC", search.Order{Field: search.OrderByName, Descending: true}),
+		Entry("supports ascending sizes", "size asc", search.Order{Field: search.OrderBySize}),
+	)
+
+	It("rejects unknown orders", func() {
+		for _, orderBy := range []string{"path", "name up", "name asc desc"} {
+			_, err := search.ParseOrder(orderBy)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("sorts by score and id", func() {
+		order := search.DefaultOrder
+		Expect(order.SortsBefore(match("b", "", 2, 0, now), match("a", "", 1, 0, now))).To(BeTrue())
+		Expect(order.SortsBefore(match("a", "", 1, 0, now), match("b", "", 1, 0, now))).To(BeTrue())
+		Expect(order.SortsBefore(match("b", "", 1, 0, now), match("a", "", 1, 0, now))).To(BeFalse())
+	})
+
+	It("sorts by name ignoring the case", func() {
+		order := search.Order{Field: search.OrderByName}
+		Expect(order.SortsBefore(match("b", "apple.txt", 1, 0, now), match("a", "Banana.txt", 2, 0, now))).To(BeTrue())
+		Expect(order.SortsBefore(match("a", "Apple.txt", 1, 0, now), match("b", "apple.txt", 1, 0, now))).To(BeTrue())
+
+		order.Descending = true
+		Expect(order.SortsBefore(match("b", "apple.txt", 1, 0, now), match("a", "Banana.txt", 2, 0, now))).To(BeFalse())
+		// the ids break ties in ascending order regardless of the direction
+		Expect(order.SortsBefore(match("a", "Apple.txt", 1, 0, now), match("b", "apple.txt", 1, 0, now))).To(BeTrue())
+	})
+
+	It("sorts by mtime with millisecond precision", func() {
+		order := search.Order{Field: search.OrderByMtime, Descending: true}
+		Expect(order.SortsBefore(match("b", "", 1, 0, now), match("a", "", 1, 0, now.Add(-time.Second)))).To(BeTrue())
+
+		millis := now.Truncate(time.Millisecond)
+		Expect(order.SortsBefore(match("b", "", 1, 0, millis.Add(time.Microsecond)), match("a", "", 1, 0, millis))).To(BeFalse())
+	})
+
+	It("sorts by size", func() {
+		order := search.Order{Field: search.OrderBySize}
+		Expect(order.SortsBefore(match("b", "", 1, 10, now), match("a", "", 1, 20, now))).To(BeTrue())
+		Expect(order.SortsBefore(match("a", "", 1, 20, now), match("b", "", 1, 10, now))).To(BeFalse())
+	})
+})
@@ -0,0 +1,128 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/storagespace"
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+)
+
+// ErrInvalidPageToken is returned when a page token can not be decoded
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// Search results are ordered according to the requested Order. The page tokens point to the last match of a
+// page, the next page starts with the first match sorting after it. As the matches only carry the score with
+// float32 precision, index implementations have to rank with that precision as well. The tokens also carry the
+// time the matches were ranked at so that the recency boosts don't change from page to page.
+
+// PageToken points to the last match of a page
+type PageToken struct {
+	// Order is the order of the matches the token was created for
+	Order Order
+	// RankedAt is the time the recency of the matches was ranked relative to
+	RankedAt time.Time
+	// Score is the score of the match, only set when ordering by score
+	Score float32
+	// Name is the sortable name of the match, only set when ordering by name
+	Name string
+	// Mtime is the sortable modification time of the match, only set when ordering by mtime
+	Mtime int64
+	// Size is the size of the match, only set when ordering by size
+	Size uint64
+	// ID is the formatted resource id of the match
+	ID string
+}
+
+// EncodePageToken returns the page token pointing to the given match of the matches ordered by the given order
+func EncodePageToken(order Order, rankedAt time.Time, match *searchmsg.Match) string {
+	var value string
+	switch order.Field {
+	case OrderByName:
+		value = hex.EncodeToString([]byte(SortableName(match.GetEntity().GetName())))
+	case OrderByMtime:
+		value = strconv.FormatInt(SortableMtime(match.GetEntity()), 10)
+	case OrderBySize:
+		value = strconv.FormatUint(match.GetEntity().GetSize(), 10)
+	default:
+		value = fmt.Sprintf("%08x", math.Float32bits(match.Score))
+	}
+	raw := fmt.Sprintf("%s:%d:%s:%s", order, rankedAt.UnixNano(), value, formatID(match.GetEntity().GetId()))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodePageToken decodes the given page token
+func DecodePageToken(token string) (PageToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return PageToken{}, ErrInvalidPageToken
+	}
+	parts := strings.SplitN(string(raw), ":", 4)
+	if len(parts) != 4 || parts[3] == "" {
+		return PageToken{}, ErrInvalidPageToken
+	}
+	order, err := ParseOrder(parts[0])
+	if err != nil {
+		return PageToken{}, ErrInvalidPageToken
+	}
+	rankedAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return PageToken{}, ErrInvalidPageToken
+	}
+
+	t := PageToken{Order: order, RankedAt: time.Unix(0, rankedAt), ID: parts[3]}
+	value := parts[2]
+	switch order.Field {
+	case OrderByName:
+		name, err := hex.DecodeString(value)
+		if err != nil {
+			return PageToken{}, ErrInvalidPageToken
+		}
+		t.Name = string(name)
+	case OrderByMtime:
+		if t.Mtime, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return PageToken{}, ErrInvalidPageToken
+		}
+	case OrderBySize:
+		if t.Size, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return PageToken{}, ErrInvalidPageToken
+		}
+	default:
+		if len(value) != 8 {
+			return PageToken{}, ErrInvalidPageToken
+		}
+		bits, err := strconv.ParseUint(value, 16, 32)
+		if err != nil {
+			return PageToken{}, ErrInvalidPageToken
+		}
+		t.Score = math.Float32frombits(uint32(bits))
+	}
+	return t, nil
+}
+
+// DecodePageTokenFor decodes the given page token and checks that it has been created for the given order
+func DecodePageTokenFor(token string, order Order) (PageToken, error) {
+	t, err := DecodePageToken(token)
+	if err != nil {
+		return PageToken{}, err
+	}
+	if t.Order != order {
+		return PageToken{}, fmt.Errorf("the page token was created for the order '%s'", t.Order)
+	}
+	return t, nil
+}
+
+func formatID(id *searchmsg.ResourceID) string {
+	return storagespace.FormatResourceID(providerv1beta1.ResourceId{
+		StorageId: id.GetStorageId(),
+		SpaceId:   id.GetSpaceId(),
+		OpaqueId:  id.GetOpaqueId(),
+	})
+}
@@ -0,0 +1,71 @@
+package search_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var _ = Describe("PageToken", func() {
+	var (
+		match = func(score float32, opaqueID string) *searchmsg.Match {
+			return &searchmsg.Match{
+				Score: score,
+				Entity: &searchmsg.Entity{
+					Id: &searchmsg.ResourceID{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: opaqueID},
+				},
+			}
+		}
+	)
+
+	It("encodes the score and the id of the match", func() {
+		rankedAt := time.Unix(1000, 5)
+		token, err := search.DecodePageToken(search.EncodePageToken(search.DefaultOrder, rankedAt, match(0.123456789, "opaqueid")))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Order).To(Equal(search.DefaultOrder))
+		Expect(token.RankedAt.Equal(rankedAt)).To(BeTrue())
+		Expect(token.Score).To(Equal(float32(0.123456789)))
+		Expect(token.ID).To(Equal("storageid$spaceid!opaqueid"))
+	})
+
+	It("encodes the value of the order field", func() {
+		m := match(1, "opaqueid")
+		m.Entity.Name = "Report: 2022.PDF"
+		m.Entity.Size = 12345
+		m.Entity.LastModifiedTime = timestamppb.New(time.Unix(1000, 123456789))
+
+		token, err := search.DecodePageToken(search.EncodePageToken(search.Order{Field: search.OrderByName}, time.Now(), m))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Name).To(Equal("report: 2022.pdf"))
+
+		token, err = search.DecodePageToken(search.EncodePageToken(search.Order{Field: search.OrderByMtime, Descending: true}, time.Now(), m))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Order.Descending).To(BeTrue())
+		Expect(token.Mtime).To(Equal(int64(1000123)))
+
+		token, err = search.DecodePageToken(search.EncodePageToken(search.Order{Field: search.OrderBySize}, time.Now(), m))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Size).To(Equal(uint64(12345)))
+		Expect(token.ID).To(Equal("storageid$spaceid!opaqueid"))
+	})
+
+	It("fails to decode invalid tokens", func() {
+		for _, token := range []string{"invalid!", "Zm9v", "enp6enp6enp6Om9wYXF1ZQ", "c2NvcmUgZGVzYzoxOnp6enp6enp6Om9wYXF1ZQ"} {
+			_, err := search.DecodePageToken(token)
+			Expect(err).To(MatchError(search.ErrInvalidPageToken))
+		}
+	})
+
+	It("rejects tokens created for another order", func() {
+		token := search.EncodePageToken(search.DefaultOrder, time.Now(), match(1, "opaqueid"))
+		_, err := search.DecodePageTokenFor(token, search.Order{Field: search.OrderByName})
+		Expect(err).To(HaveOccurred())
+		_, err = search.DecodePageTokenFor(token, search.DefaultOrder)
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
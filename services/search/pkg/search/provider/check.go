@@ -0,0 +1,267 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
+	"github.com/cs3org/reva/v2/pkg/storage/utils/walker"
+	"github.com/cs3org/reva/v2/pkg/storagespace"
+	"github.com/cs3org/reva/v2/pkg/utils"
+
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
+)
+
+// The kinds of differences between the storage and the index reported by CheckIndex
+const (
+	// DiscrepancyMissing is reported for resources which are not in the index or marked as deleted
+	DiscrepancyMissing = "missing"
+	// DiscrepancyStalePath is reported for resources indexed with an outdated path
+	DiscrepancyStalePath = "stale_path"
+	// DiscrepancySize is reported for resources indexed with an outdated size
+	DiscrepancySize = "size"
+	// DiscrepancyMtime is reported for resources indexed with an outdated modification time
+	DiscrepancyMtime = "mtime"
+	// DiscrepancyOrphan is reported for documents of resources which don't exist in the storage anymore
+	DiscrepancyOrphan = "orphan"
+)
+
+// CheckIndex compares the resources in the storage with the documents in the index. The given space or all
+// spaces of the user are checked. If requested the differences are repaired. Resources in the trash bins are
+// not checked.
+func (p *Provider) CheckIndex(ctx context.Context, req *searchsvc.CheckIndexRequest) (*searchsvc.CheckIndexResponse, error) {
+	owner := &user.User{
+		Id: &user.UserId{OpaqueId: req.UserId},
+	}
+	ownerCtx, err := p.getAuthContext(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	spaceIDs := []string{req.SpaceId}
+	if req.SpaceId == "" {
+		spaces, err := p.listSpaces(ownerCtx)
+		if err != nil {
+			return nil, err
+		}
+		spaceIDs = spaceIDs[:0]
+		for _, space := range spaces {
+			spaceIDs = append(spaceIDs, formatSpaceID(space))
+		}
+	}
+
+	res := &searchsvc.CheckIndexResponse{}
+	repaired := false
+	for _, spaceID := range spaceIDs {
+		discrepancies, checked, err := p.checkSpace(ownerCtx, spaceID, req.Repair)
+		if err != nil {
+			p.logger.Error().Err(err).Str("spaceID", spaceID).Msg("failed to check the index of the space")
+			return nil, err
+		}
+		res.CheckedSpaces++
+		res.CheckedResources += int32(checked)
+		res.Discrepancies = append(res.Discrepancies, discrepancies...)
+		repaired = repaired || (req.Repair && len(discrepancies) > 0)
+	}
+
+	if repaired {
+		p.logDocCount()
+	}
+	return res, nil
+}
+
+// listSpaces returns the personal and project spaces the user has access to
+func (p *Provider) listSpaces(ctx context.Context) ([]*provider.StorageSpace, error) {
+	res, err := p.gwClient.ListStorageSpaces(ctx, &provider.ListStorageSpacesRequest{
+		Filters: []*provider.ListStorageSpacesRequest_Filter{
+			{
+				Type: provider.ListStorageSpacesRequest_Filter_TYPE_SPACE_TYPE,
+				Term: &provider.ListStorageSpacesRequest_Filter_SpaceType{SpaceType: "personal"},
+			},
+			{
+				Type: provider.ListStorageSpacesRequest_Filter_TYPE_SPACE_TYPE,
+				Term: &provider.ListStorageSpacesRequest_Filter_SpaceType{SpaceType: "project"},
+			},
+		},
+	})
+	if err == nil && res.GetStatus().GetCode() != rpc.Code_CODE_OK {
+		err = errtypes.NewErrtypeFromStatus(res.Status)
+	}
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to list the storage spaces")
+		return nil, err
+	}
+	return res.StorageSpaces, nil
+}
+
+// formatSpaceID returns the id of the space in the format accepted by IndexSpace and CheckIndex
+func formatSpaceID(space *provider.StorageSpace) string {
+	return storagespace.FormatResourceID(provider.ResourceId{
+		StorageId: space.GetRoot().GetStorageId(),
+		SpaceId:   space.GetRoot().GetSpaceId(),
+	})
+}
+
+// checkSpace walks the space and compares every resource with its document in the index. It returns the
+// differences and the number of resources found in the storage.
+func (p *Provider) checkSpace(ctx context.Context, spaceID string, repair bool) ([]*searchmsg.IndexDiscrepancy, int, error) {
+	rootID, err := storagespace.ParseID(spaceID)
+	if err != nil || rootID.StorageId == "" || rootID.SpaceId == "" {
+		return nil, 0, fmt.Errorf("invalid space id '%s'", spaceID)
+	}
+	rootID.OpaqueId = rootID.SpaceId
+
+	var batch search.BatchOperator
+	if repair {
+		if batch, err = p.indexClient.NewBatch(indexBatchSize); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	checker, _ := ctxpkg.ContextGetUser(ctx)
+	discrepancies := []*searchmsg.IndexDiscrepancy{}
+	seen := map[string]struct{}{}
+	err = walker.NewWalker(p.gwClient).Walk(ctx, &rootID, func(wd string, info *provider.ResourceInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			return nil
+		}
+
+		ref := &provider.Reference{
+			Path:       utils.MakeRelativePath(filepath.Join(wd, info.Path)),
+			ResourceId: &rootID,
+		}
+		seen[storagespace.FormatResourceID(*info.Id)] = struct{}{}
+
+		entity, err := p.indexClient.Get(info.Id)
+		if err != nil {
+			if _, ok := err.(errtypes.IsNotFound); !ok {
+				return err
+			}
+		}
+		found := compareEntity(entity, ref, info)
+		if len(found) == 0 {
+			return nil
+		}
+
+		if repair {
+			if err := batch.Add(ref, search.WithoutForeignFavorite(info, checker.GetId()), p.extractContent(ctx, info)); err != nil {
+				p.logger.Error().Err(err).Interface("ref", ref).Msg("error adding resource to the index")
+			} else {
+				for _, d := range found {
+					d.Repaired = true
+				}
+			}
+		}
+		discrepancies = append(discrepancies, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = p.indexClient.ListSpace(&rootID, func(entity *searchmsg.Entity) error {
+		if entity.Deleted {
+			return nil
+		}
+		id := provider.ResourceId{
+			StorageId: entity.GetId().GetStorageId(),
+			SpaceId:   entity.GetId().GetSpaceId(),
+			OpaqueId:  entity.GetId().GetOpaqueId(),
+		}
+		if _, ok := seen[storagespace.FormatResourceID(id)]; ok {
+			return nil
+		}
+
+		d := &searchmsg.IndexDiscrepancy{
+			Kind:    DiscrepancyOrphan,
+			Id:      entity.Id,
+			Ref:     entity.Ref,
+			Indexed: entity.GetRef().GetPath(),
+		}
+		if repair {
+			if err := batch.Purge(&id); err != nil {
+				p.logger.Error().Err(err).Interface("id", id).Msg("error purging resource from the index")
+			} else {
+				d.Repaired = true
+			}
+		}
+		discrepancies = append(discrepancies, d)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if repair {
+		if err := batch.Push(); err != nil {
+			return nil, 0, err
+		}
+	}
+	return discrepancies, len(seen), nil
+}
+
+// compareEntity returns the differences between the indexed entity and the resource in the storage. A nil
+// entity means that the resource is missing in the index.
+func compareEntity(entity *searchmsg.Entity, ref *provider.Reference, info *provider.ResourceInfo) []*searchmsg.IndexDiscrepancy {
+	id := &searchmsg.ResourceID{
+		StorageId: info.GetId().GetStorageId(),
+		SpaceId:   info.GetId().GetSpaceId(),
+		OpaqueId:  info.GetId().GetOpaqueId(),
+	}
+	sref := &searchmsg.Reference{
+		ResourceId: &searchmsg.ResourceID{
+			StorageId: ref.GetResourceId().GetStorageId(),
+			SpaceId:   ref.GetResourceId().GetSpaceId(),
+			OpaqueId:  ref.GetResourceId().GetOpaqueId(),
+		},
+		Path: ref.GetPath(),
+	}
+	discrepancy := func(kind, indexed, actual string) *searchmsg.IndexDiscrepancy {
+		return &searchmsg.IndexDiscrepancy{Kind: kind, Id: id, Ref: sref, Indexed: indexed, Actual: actual}
+	}
+
+	if entity == nil {
+		return []*searchmsg.IndexDiscrepancy{discrepancy(DiscrepancyMissing, "", ref.GetPath())}
+	}
+	if entity.Deleted {
+		return []*searchmsg.IndexDiscrepancy{discrepancy(DiscrepancyMissing, "deleted", ref.GetPath())}
+	}
+
+	found := []*searchmsg.IndexDiscrepancy{}
+	if entity.GetRef().GetPath() != ref.GetPath() {
+		found = append(found, discrepancy(DiscrepancyStalePath, entity.GetRef().GetPath(), ref.GetPath()))
+	}
+	if entity.Size != info.Size {
+		found = append(found, discrepancy(DiscrepancySize, strconv.FormatUint(entity.Size, 10), strconv.FormatUint(info.Size, 10)))
+	}
+	if info.Mtime != nil {
+		actual := utils.TSToTime(info.Mtime).UTC()
+		var indexed time.Time
+		if entity.LastModifiedTime != nil {
+			indexed = entity.LastModifiedTime.AsTime().UTC()
+		}
+		if !sameMtime(indexed, actual) {
+			found = append(found, discrepancy(DiscrepancyMtime, formatTime(indexed), actual.Format(time.RFC3339Nano)))
+		}
+	}
+	return found
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,200 @@
+package provider_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
+	"github.com/cs3org/reva/v2/pkg/rgrpc/status"
+	"github.com/cs3org/reva/v2/pkg/utils"
+	cs3mocks "github.com/cs3org/reva/v2/tests/cs3mocks/mocks"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search/index"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search/mocks"
+	provider "github.com/owncloud/ocis/v2/services/search/pkg/search/provider"
+)
+
+var _ = Describe("CheckIndex", func() {
+	var (
+		p           *provider.Provider
+		gwClient    *cs3mocks.GatewayAPIClient
+		indexClient *mocks.IndexClient
+		batch       *mocks.BatchOperator
+
+		ctx    = context.Background()
+		logger = log.NewLogger()
+		mtime  = time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+
+		rootInfo, dirInfo, fileInfo, newInfo *sprovider.ResourceInfo
+
+		info = func(opaqueID, path string, t sprovider.ResourceType, size uint64) *sprovider.ResourceInfo {
+			return &sprovider.ResourceInfo{
+				Id:    &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: opaqueID},
+				Path:  path,
+				Type:  t,
+				Size:  size,
+				Mtime: utils.TimeToTS(mtime),
+			}
+		}
+		entity = func(opaqueID, path string, size uint64, mtime time.Time, deleted bool) *searchmsg.Entity {
+			return &searchmsg.Entity{
+				Ref:              &searchmsg.Reference{ResourceId: &searchmsg.ResourceID{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "spaceid"}, Path: path},
+				Id:               &searchmsg.ResourceID{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: opaqueID},
+				Size:             size,
+				LastModifiedTime: timestamppb.New(mtime),
+				Deleted:          deleted,
+			}
+		}
+		hasID = func(opaqueID string) interface{} {
+			return mock.MatchedBy(func(id *sprovider.ResourceId) bool { return id.OpaqueId == opaqueID })
+		}
+		kinds = func(res *searchsvc.CheckIndexResponse) map[string]string {
+			found := map[string]string{}
+			for _, d := range res.Discrepancies {
+				found[d.Id.OpaqueId] += d.Kind + " "
+			}
+			return found
+		}
+	)
+
+	BeforeEach(func() {
+		rootInfo = info("spaceid", ".", sprovider.ResourceType_RESOURCE_TYPE_CONTAINER, 30)
+		dirInfo = info("dir", "dir", sprovider.ResourceType_RESOURCE_TYPE_CONTAINER, 0)
+		fileInfo = info("file", "file.txt", sprovider.ResourceType_RESOURCE_TYPE_FILE, 20)
+		newInfo = info("new", "new.txt", sprovider.ResourceType_RESOURCE_TYPE_FILE, 10)
+
+		gwClient = &cs3mocks.GatewayAPIClient{}
+		indexClient = &mocks.IndexClient{}
+		batch = &mocks.BatchOperator{}
+
+		gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
+			Status: status.NewOK(ctx),
+			Token:  "authtoken",
+		}, nil)
+		gwClient.On("Stat", mock.Anything, mock.Anything).Return(&sprovider.StatResponse{
+			Status: status.NewOK(ctx),
+			Info:   rootInfo,
+		}, nil)
+		gwClient.On("ListContainer", mock.Anything, mock.MatchedBy(func(req *sprovider.ListContainerRequest) bool {
+			return req.Ref.ResourceId.OpaqueId == "spaceid"
+		})).Return(&sprovider.ListContainerResponse{
+			Status: status.NewOK(ctx),
+			Infos:  []*sprovider.ResourceInfo{dirInfo, fileInfo, newInfo},
+		}, nil)
+		gwClient.On("ListContainer", mock.Anything, mock.Anything).Return(&sprovider.ListContainerResponse{
+			Status: status.NewOK(ctx),
+			Infos:  []*sprovider.ResourceInfo{},
+		}, nil)
+
+		// the directory has been moved, the file changed, the new file is missing and gone.txt vanished
+		indexClient.On("Get", hasID("spaceid")).Return(entity("spaceid", ".", 30, mtime, false), nil)
+		indexClient.On("Get", hasID("dir")).Return(entity("dir", "./olddir", 0, mtime, false), nil)
+		indexClient.On("Get", hasID("file")).Return(entity("file", "./file.txt", 15, mtime.Add(-time.Hour), false), nil)
+		indexClient.On("Get", hasID("new")).Return(nil, errtypes.NotFound("new"))
+		listSpaceReturns(indexClient,
+			entity("spaceid", ".", 30, mtime, false),
+			entity("dir", "./olddir", 0, mtime, false),
+			entity("file", "./file.txt", 15, mtime, false),
+			entity("gone", "./gone.txt", 5, mtime, false),
+			entity("trashed", "./trashed.txt", 5, mtime, true),
+		)
+		indexClient.On("NewBatch", mock.Anything).Return(batch, nil)
+		indexClient.On("DocCount").Return(uint64(4), nil)
+		batch.On("Add", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		batch.On("Purge", mock.Anything).Return(nil)
+		batch.On("Push").Return(nil)
+
+		p = provider.New(gwClient, indexClient, nil, "", nil, 1000, logger)
+	})
+
+	It("reports the differences between the storage and the index", func() {
+		res, err := p.CheckIndex(ctx, &searchsvc.CheckIndexRequest{
+			SpaceId: "storageid$spaceid",
+			UserId:  "user",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.CheckedSpaces).To(Equal(int32(1)))
+		Expect(res.CheckedResources).To(Equal(int32(4)))
+		Expect(kinds(res)).To(Equal(map[string]string{
+			"dir":  "stale_path ",
+			"file": "size mtime ",
+			"new":  "missing ",
+			"gone": "orphan ",
+		}))
+		for _, d := range res.Discrepancies {
+			Expect(d.Repaired).To(BeFalse())
+			if d.Kind == provider.DiscrepancyStalePath {
+				Expect(d.Indexed).To(Equal("./olddir"))
+				Expect(d.Actual).To(Equal("./dir"))
+			}
+		}
+		indexClient.AssertNotCalled(GinkgoT(), "NewBatch", mock.Anything)
+	})
+
+	It("repairs the differences", func() {
+		res, err := p.CheckIndex(ctx, &searchsvc.CheckIndexRequest{
+			SpaceId: "storageid$spaceid",
+			UserId:  "user",
+			Repair:  true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		for _, d := range res.Discrepancies {
+			Expect(d.Repaired).To(BeTrue())
+		}
+		batch.AssertNumberOfCalls(GinkgoT(), "Add", 3)
+		batch.AssertCalled(GinkgoT(), "Add", mock.Anything, dirInfo, "")
+		batch.AssertCalled(GinkgoT(), "Add", mock.Anything, fileInfo, "")
+		batch.AssertCalled(GinkgoT(), "Add", mock.Anything, newInfo, "")
+		batch.AssertNumberOfCalls(GinkgoT(), "Purge", 1)
+		batch.AssertCalled(GinkgoT(), "Purge", hasID("gone"))
+		batch.AssertNumberOfCalls(GinkgoT(), "Push", 1)
+	})
+
+	It("reports no differences for a real index in sync with the storage", func() {
+		// the index keeps the mtime in seconds only
+		idx, err := index.NewMemOnly()
+		Expect(err).ToNot(HaveOccurred())
+		for path, info := range map[string]*sprovider.ResourceInfo{".": rootInfo, "./dir": dirInfo, "./file.txt": fileInfo, "./new.txt": newInfo} {
+			info.Mtime = utils.TimeToTS(mtime.Add(123456789 * time.Nanosecond))
+			Expect(idx.Add(&sprovider.Reference{ResourceId: rootInfo.Id, Path: path}, info, "")).To(Succeed())
+		}
+		p = provider.New(gwClient, idx, nil, "", nil, 1000, logger)
+
+		res, err := p.CheckIndex(ctx, &searchsvc.CheckIndexRequest{
+			SpaceId: "storageid$spaceid",
+			UserId:  "user",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.CheckedResources).To(Equal(int32(4)))
+		Expect(res.Discrepancies).To(BeEmpty())
+	})
+
+	It("checks all spaces of the user if no space is given", func() {
+		gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&sprovider.ListStorageSpacesResponse{
+			Status: status.NewOK(ctx),
+			StorageSpaces: []*sprovider.StorageSpace{
+				{Root: &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "spaceid"}},
+			},
+		}, nil)
+
+		res, err := p.CheckIndex(ctx, &searchsvc.CheckIndexRequest{UserId: "user"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.CheckedSpaces).To(Equal(int32(1)))
+		Expect(res.Discrepancies).To(HaveLen(5))
+	})
+
+	It("fails for invalid space ids", func() {
+		_, err := p.CheckIndex(ctx, &searchsvc.CheckIndexRequest{SpaceId: "invalid", UserId: "user"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,211 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/cs3org/reva/v2/pkg/events"
+	"github.com/cs3org/reva/v2/pkg/storagespace"
+	mevents "go-micro.dev/v4/events"
+)
+
+// workerQueueSize is the number of events queued for a worker. The events of other spaces are dispatched
+// to their workers while a worker is busy, e.g. retrying an event, until its queue is full.
+const workerQueueSize = 100
+
+// Delivery is an event received from the event system. It is only acknowledged once it has been
+// processed, so it gets delivered again if the service stops before.
+type Delivery struct {
+	Event     interface{}
+	Type      string
+	Timestamp time.Time
+
+	metadata map[string]string
+	payload  []byte
+	err      error
+	ack      func() error
+}
+
+// ConsumeEvents returns a channel emitting the given events as Delivery values. Unlike events.Consume
+// the events have to be acknowledged, which the provider does after processing them. Events which are
+// not of interest are acknowledged right away.
+func ConsumeEvents(s events.Consumer, group string, ackWait time.Duration, evs ...events.Unmarshaller) (<-chan interface{}, error) {
+	c, err := s.Consume(events.MainQueueName, mevents.WithGroup(group), mevents.WithAutoAck(false, ackWait))
+	if err != nil {
+		return nil, err
+	}
+
+	registeredEvents := map[string]events.Unmarshaller{}
+	for _, e := range evs {
+		registeredEvents[reflect.TypeOf(e).String()] = e
+	}
+
+	outchan := make(chan interface{})
+	go func() {
+		for e := range c {
+			e := e
+			et := e.Metadata[events.MetadatakeyEventType]
+			u, ok := registeredEvents[et]
+			if !ok {
+				_ = e.Ack()
+				continue
+			}
+
+			// events which can't be unmarshalled are passed on so they end up in the dead letter subject
+			ev, err := u.Unmarshal(e.Payload)
+			outchan <- Delivery{
+				Event:     ev,
+				Type:      et,
+				Timestamp: e.Timestamp,
+				metadata:  e.Metadata,
+				payload:   e.Payload,
+				err:       err,
+				ack:       e.Ack,
+			}
+		}
+	}()
+	return outchan, nil
+}
+
+// startWorkers processes the events with a fixed number of workers. The events of a space are always
+// handled by the same worker so that they are processed in order.
+func (p *Provider) startWorkers(eventsChan <-chan interface{}) {
+	queues := make([]chan Delivery, p.options.Workers)
+	for i := range queues {
+		queues[i] = make(chan Delivery, workerQueueSize)
+		go func(q <-chan Delivery) {
+			for d := range q {
+				p.process(d)
+			}
+		}(queues[i])
+	}
+
+	go func() {
+		for ev := range eventsChan {
+			d, ok := ev.(Delivery)
+			if !ok {
+				d = Delivery{Event: ev, Type: fmt.Sprintf("%T", ev), Timestamp: time.Now()}
+			}
+			queues[partition(d.Event, len(queues))] <- d
+		}
+	}()
+}
+
+// process handles the event, retrying it with an increasing backoff if it fails. Events which still fail
+// after all attempts are sent to the dead letter subject. The event is acknowledged in any case.
+func (p *Provider) process(d Delivery) {
+	if !d.Timestamp.IsZero() {
+		p.options.Metrics.EventLag.Set(time.Since(d.Timestamp).Seconds())
+	}
+
+	err := d.err
+	attempt := 0
+	if err == nil {
+		backoff := p.options.Backoff
+		for attempt = 1; ; attempt++ {
+			if err = p.handleEvent(d.Event); err == nil {
+				break
+			}
+			p.options.Metrics.EventFailures.WithLabelValues(d.Type).Inc()
+			if attempt >= p.options.MaxAttempts {
+				break
+			}
+			p.logger.Warn().Err(err).Str("type", d.Type).Int("attempt", attempt).Dur("backoff", backoff).Msg("failed to process event, retrying")
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > p.options.MaxBackoff {
+				backoff = p.options.MaxBackoff
+			}
+		}
+	}
+
+	if err != nil {
+		p.logger.Error().Err(err).Str("type", d.Type).Int("attempts", attempt).Msg("giving up processing event")
+		p.options.Metrics.EventsDeadLettered.WithLabelValues(d.Type).Inc()
+		p.deadLetter(d, attempt, err)
+	} else {
+		p.options.Metrics.EventsProcessed.WithLabelValues(d.Type).Inc()
+	}
+
+	if d.ack != nil {
+		if err := d.ack(); err != nil {
+			p.logger.Error().Err(err).Str("type", d.Type).Msg("failed to acknowledge event")
+		}
+	}
+}
+
+// deadLetter publishes the event which could not be processed to the dead letter subject. The metadata
+// of the event is kept and extended by the error and the number of attempts.
+func (p *Provider) deadLetter(d Delivery, attempts int, cause error) {
+	if p.options.DeadLetter == nil || p.options.DeadLetterSubject == "" {
+		return
+	}
+
+	payload := d.payload
+	if payload == nil {
+		var err error
+		if payload, err = json.Marshal(d.Event); err != nil {
+			p.logger.Error().Err(err).Str("type", d.Type).Msg("failed to encode event for the dead letter subject")
+			return
+		}
+	}
+	metadata := map[string]string{}
+	for k, v := range d.metadata {
+		metadata[k] = v
+	}
+	metadata[events.MetadatakeyEventType] = d.Type
+	metadata["error"] = cause.Error()
+	metadata["attempts"] = strconv.Itoa(attempts)
+
+	if err := p.options.DeadLetter.Publish(p.options.DeadLetterSubject, payload, mevents.WithMetadata(metadata)); err != nil {
+		p.logger.Error().Err(err).Str("type", d.Type).Str("subject", p.options.DeadLetterSubject).Msg("failed to publish event to the dead letter subject")
+	}
+}
+
+// partition returns the worker responsible for the space the event belongs to
+func partition(ev interface{}, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(eventSpace(ev)))
+	return int(h.Sum32() % uint32(n))
+}
+
+// eventSpace returns the id of the space the event belongs to
+func eventSpace(ev interface{}) string {
+	switch e := ev.(type) {
+	case events.ItemTrashed:
+		return e.Ref.GetResourceId().GetSpaceId()
+	case events.ItemRestored:
+		return e.Ref.GetResourceId().GetSpaceId()
+	case events.ItemMoved:
+		return e.Ref.GetResourceId().GetSpaceId()
+	case events.ContainerCreated:
+		return e.Ref.GetResourceId().GetSpaceId()
+	case events.FileUploaded:
+		return e.Ref.GetResourceId().GetSpaceId()
+	case events.FileTouched:
+		return e.Ref.GetResourceId().GetSpaceId()
+	case events.FileVersionRestored:
+		return e.Ref.GetResourceId().GetSpaceId()
+	case events.ItemPurged:
+		return e.Ref.GetResourceId().GetSpaceId()
+	case events.SpaceDeleted:
+		return spaceIDOf(e.ID.GetOpaqueId())
+	case events.SpaceDisabled:
+		return spaceIDOf(e.ID.GetOpaqueId())
+	case events.SpaceEnabled:
+		return spaceIDOf(e.ID.GetOpaqueId())
+	}
+	return ""
+}
+
+func spaceIDOf(id string) string {
+	rid, err := storagespace.ParseID(id)
+	if err != nil {
+		return id
+	}
+	return rid.SpaceId
+}
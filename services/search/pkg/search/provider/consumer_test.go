@@ -0,0 +1,228 @@
+package provider_test
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
+	"github.com/cs3org/reva/v2/pkg/events"
+	cs3mocks "github.com/cs3org/reva/v2/tests/cs3mocks/mocks"
+	mevents "go-micro.dev/v4/events"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search/mocks"
+	provider "github.com/owncloud/ocis/v2/services/search/pkg/search/provider"
+)
+
+type published struct {
+	topic    string
+	payload  []byte
+	metadata map[string]string
+}
+
+// fakeStream hands out the events sent to its channel and records the published ones
+type fakeStream struct {
+	events chan mevents.Event
+
+	mutex     sync.Mutex
+	opts      mevents.ConsumeOptions
+	published []published
+	acked     map[string]int
+}
+
+func newFakeStream() *fakeStream {
+	return &fakeStream{events: make(chan mevents.Event), acked: map[string]int{}}
+}
+
+func (s *fakeStream) Consume(topic string, opts ...mevents.ConsumeOption) (<-chan mevents.Event, error) {
+	for _, o := range opts {
+		o(&s.opts)
+	}
+	return s.events, nil
+}
+
+func (s *fakeStream) Publish(topic string, msg interface{}, opts ...mevents.PublishOption) error {
+	options := mevents.PublishOptions{}
+	for _, o := range opts {
+		o(&options)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.published = append(s.published, published{topic: topic, payload: msg.([]byte), metadata: options.Metadata})
+	return nil
+}
+
+func (s *fakeStream) send(id string, ev interface{}, eventType string) {
+	payload, err := json.Marshal(ev)
+	Expect(err).ToNot(HaveOccurred())
+	e := mevents.Event{
+		ID:        id,
+		Timestamp: time.Now(),
+		Metadata:  map[string]string{events.MetadatakeyEventType: eventType},
+		Payload:   payload,
+	}
+	e.SetAckFunc(func() error {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.acked[id]++
+		return nil
+	})
+	s.events <- e
+}
+
+func (s *fakeStream) ackCount(id string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.acked[id]
+}
+
+func (s *fakeStream) deadLetters() []published {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]published{}, s.published...)
+}
+
+var _ = Describe("ConsumeEvents", func() {
+	var (
+		stream      *fakeStream
+		gwClient    *cs3mocks.GatewayAPIClient
+		indexClient *mocks.IndexClient
+
+		logger = log.NewLogger()
+		ref    = &sprovider.Reference{
+			ResourceId: &sprovider.ResourceId{
+				StorageId: "storageid",
+				SpaceId:   "spaceid",
+				OpaqueId:  "opaqueid",
+			},
+			Path: ".",
+		}
+		trashed = events.ItemTrashed{
+			Executant: &userv1beta1.UserId{OpaqueId: "user"},
+			Ref:       ref,
+			ID:        ref.ResourceId,
+		}
+	)
+
+	BeforeEach(func() {
+		stream = newFakeStream()
+		gwClient = &cs3mocks.GatewayAPIClient{}
+		indexClient = &mocks.IndexClient{}
+
+		evts, err := provider.ConsumeEvents(stream, "search", time.Minute, provider.ListenEvents...)
+		Expect(err).ToNot(HaveOccurred())
+		debouncer := provider.NewSpaceDebouncer(time.Hour, func(id *sprovider.StorageSpaceId, userID *userv1beta1.UserId) {})
+		provider.NewWithDebouncer(gwClient, indexClient, nil, "", evts, logger, debouncer,
+			provider.Workers(2),
+			provider.Retries(3, time.Millisecond, 2*time.Millisecond),
+			provider.DeadLetter(stream, "search.dead-letter"),
+		)
+	})
+
+	It("consumes the events with manual acknowledgement", func() {
+		Expect(stream.opts.Group).To(Equal("search"))
+		Expect(stream.opts.AutoAck).To(BeFalse())
+		Expect(stream.opts.AckWait).To(Equal(time.Minute))
+	})
+
+	It("acknowledges events after processing them", func() {
+		processed := make(chan struct{}, 1)
+		indexClient.On("Delete", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			processed <- struct{}{}
+		})
+
+		stream.send("1", trashed, "events.ItemTrashed")
+
+		Eventually(processed).Should(Receive())
+		Eventually(func() int { return stream.ackCount("1") }).Should(Equal(1))
+		Expect(stream.deadLetters()).To(BeEmpty())
+	})
+
+	It("processes the events of other spaces while a space is busy", func() {
+		inSpace := func(spaceID string) interface{} {
+			return mock.MatchedBy(func(id *sprovider.ResourceId) bool { return id.GetSpaceId() == spaceID })
+		}
+		release := make(chan struct{})
+		indexClient.On("Delete", inSpace("spaceid")).Return(nil).Run(func(args mock.Arguments) {
+			<-release
+		})
+		indexClient.On("Delete", inSpace("anotherspaceid")).Return(nil)
+
+		// the spaces are processed by different workers
+		otherRef := &sprovider.Reference{ResourceId: &sprovider.ResourceId{StorageId: "storageid", SpaceId: "anotherspaceid", OpaqueId: "opaqueid"}, Path: "."}
+		go func() {
+			stream.send("1", trashed, "events.ItemTrashed")
+			stream.send("2", trashed, "events.ItemTrashed")
+			stream.send("3", events.ItemTrashed{Executant: trashed.Executant, Ref: otherRef, ID: otherRef.ResourceId}, "events.ItemTrashed")
+		}()
+
+		Eventually(func() int { return stream.ackCount("3") }).Should(Equal(1))
+		Expect(stream.ackCount("1")).To(Equal(0))
+		close(release)
+		Eventually(func() int { return stream.ackCount("2") }).Should(Equal(1))
+	})
+
+	It("acknowledges events which are not of interest right away", func() {
+		stream.send("1", events.ShareCreated{}, "events.ShareCreated")
+
+		Eventually(func() int { return stream.ackCount("1") }).Should(Equal(1))
+		indexClient.AssertNotCalled(GinkgoT(), "Delete", mock.Anything)
+	})
+
+	It("retries failing events and sends them to the dead letter subject", func() {
+		indexClient.On("Delete", mock.Anything).Return(errors.New("index unavailable"))
+
+		stream.send("1", trashed, "events.ItemTrashed")
+
+		Eventually(func() int { return stream.ackCount("1") }).Should(Equal(1))
+		indexClient.AssertNumberOfCalls(GinkgoT(), "Delete", 3)
+
+		deadLetters := stream.deadLetters()
+		Expect(deadLetters).To(HaveLen(1))
+		Expect(deadLetters[0].topic).To(Equal("search.dead-letter"))
+		Expect(deadLetters[0].metadata[events.MetadatakeyEventType]).To(Equal("events.ItemTrashed"))
+		Expect(deadLetters[0].metadata["error"]).To(Equal("index unavailable"))
+		Expect(deadLetters[0].metadata["attempts"]).To(Equal("3"))
+
+		ev, err := events.ItemTrashed{}.Unmarshal(deadLetters[0].payload)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ev.(events.ItemTrashed).ID.OpaqueId).To(Equal("opaqueid"))
+	})
+
+	It("stops retrying once the event has been processed", func() {
+		indexClient.On("Delete", mock.Anything).Return(errors.New("index unavailable")).Once()
+		indexClient.On("Delete", mock.Anything).Return(nil).Once()
+
+		stream.send("1", trashed, "events.ItemTrashed")
+
+		Eventually(func() int { return stream.ackCount("1") }).Should(Equal(1))
+		indexClient.AssertNumberOfCalls(GinkgoT(), "Delete", 2)
+		Expect(stream.deadLetters()).To(BeEmpty())
+	})
+
+	It("acknowledges trashed resources which are not indexed", func() {
+		indexClient.On("Delete", mock.Anything).Return(errtypes.NotFound("opaqueid"))
+
+		stream.send("1", trashed, "events.ItemTrashed")
+
+		Eventually(func() int { return stream.ackCount("1") }).Should(Equal(1))
+		indexClient.AssertNumberOfCalls(GinkgoT(), "Delete", 1)
+		Expect(stream.deadLetters()).To(BeEmpty())
+	})
+
+	It("sends events which can not be decoded to the dead letter subject", func() {
+		stream.send("1", "not an event", "events.ItemTrashed")
+
+		Eventually(func() int { return stream.ackCount("1") }).Should(Equal(1))
+		Expect(stream.deadLetters()).To(HaveLen(1))
+		indexClient.AssertNotCalled(GinkgoT(), "Delete", mock.Anything)
+	})
+})
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,7 +15,11 @@ import (
 	"github.com/cs3org/reva/v2/pkg/errtypes"
 	"github.com/cs3org/reva/v2/pkg/events"
 	"github.com/cs3org/reva/v2/pkg/storagespace"
+	"github.com/cs3org/reva/v2/pkg/utils"
 	"google.golang.org/grpc/metadata"
+
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
 )
 
 // SpaceDebouncer debounces operations on spaces for a configurable amount of time
@@ -61,13 +66,21 @@ func (d *SpaceDebouncer) Debounce(id *provider.StorageSpaceId, userID *user.User
 	})
 }
 
-func (p *Provider) handleEvent(ev interface{}) {
+// handleEvent updates the index according to the event. An error is returned if the event could not be
+// processed and should be retried.
+func (p *Provider) handleEvent(ev interface{}) error {
 	switch e := ev.(type) {
 	case events.ItemTrashed:
 		p.logger.Debug().Interface("event", ev).Msg("marking document as deleted")
 		err := p.indexClient.Delete(e.ID)
+		if _, ok := err.(errtypes.IsNotFound); ok {
+			// there is nothing to mark as deleted if the resource hasn't been indexed
+			p.logger.Debug().Interface("Id", e.ID).Msg("the trashed resource is not indexed")
+			err = nil
+		}
 		if err != nil {
 			p.logger.Error().Err(err).Interface("Id", e.ID).Msg("failed to remove item from index")
+			return err
 		}
 		p.reindexSpace(ev, e.Ref, e.Executant, e.SpaceOwner)
 	case events.ItemRestored:
@@ -78,7 +91,7 @@ func (p *Provider) handleEvent(ev interface{}) {
 
 		ownerCtx, err := p.getAuthContext(owner)
 		if err != nil {
-			return
+			return err
 		}
 		statRes, err := p.statResource(ownerCtx, e.Ref, owner)
 		if err != nil {
@@ -88,7 +101,7 @@ func (p *Provider) handleEvent(ev interface{}) {
 				Str("opaqueid", e.Ref.GetResourceId().GetOpaqueId()).
 				Str("path", e.Ref.GetPath()).
 				Msg("failed to make stat call for the restored resource")
-			return
+			return err
 		}
 
 		switch statRes.Status.Code {
@@ -101,6 +114,7 @@ func (p *Provider) handleEvent(ev interface{}) {
 					Str("opaqueid", e.Ref.GetResourceId().GetOpaqueId()).
 					Str("path", e.Ref.GetPath()).
 					Msg("failed to restore the changed resource in the index")
+				return err
 			}
 		default:
 			p.logger.Error().Interface("statRes", statRes).
@@ -109,6 +123,9 @@ func (p *Provider) handleEvent(ev interface{}) {
 				Str("opaqueid", e.Ref.GetResourceId().GetOpaqueId()).
 				Str("path", e.Ref.GetPath()).
 				Msg("failed to stat the restored resource")
+			if err := statusError(statRes.Status); err != nil {
+				return err
+			}
 		}
 		p.reindexSpace(ev, e.Ref, e.Executant, e.SpaceOwner)
 	case events.ItemMoved:
@@ -119,45 +136,212 @@ func (p *Provider) handleEvent(ev interface{}) {
 
 		ownerCtx, err := p.getAuthContext(owner)
 		if err != nil {
-			return
+			return err
 		}
 		statRes, err := p.statResource(ownerCtx, e.Ref, owner)
 		if err != nil {
 			p.logger.Error().Err(err).Msg("failed to stat the moved resource")
-			return
+			return err
 		}
 		if statRes.Status.Code != rpc.Code_CODE_OK {
 			p.logger.Error().Interface("statRes", statRes).Msg("failed to stat the moved resource")
-			return
+			return statusError(statRes.Status)
 		}
 
 		gpRes, err := p.getPath(ownerCtx, statRes.Info.Id, owner)
 		if err != nil {
 			p.logger.Error().Err(err).Interface("ref", e.Ref).Msg("failed to get path for moved resource")
-			return
+			return err
 		}
 		if gpRes.Status.Code != rpcv1beta1.Code_CODE_OK {
 			p.logger.Error().Interface("status", gpRes.Status).Interface("ref", e.Ref).Msg("failed to get path for moved resource")
-			return
+			return statusError(gpRes.Status)
 		}
 
 		err = p.indexClient.Move(statRes.GetInfo().GetId(), statRes.GetInfo().GetParentId(), gpRes.Path)
 		if err != nil {
 			p.logger.Error().Err(err).Msg("failed to move the changed resource in the index")
+			return err
 		}
 		p.reindexSpace(ev, e.Ref, e.Executant, e.SpaceOwner)
 	case events.ContainerCreated:
 		p.reindexSpace(ev, e.Ref, e.Executant, e.SpaceOwner)
 	case events.FileUploaded:
+		if err := p.indexResource(ev, e.Ref, e.Executant); err != nil {
+			return err
+		}
 		p.reindexSpace(ev, e.Ref, e.Executant, e.SpaceOwner)
 	case events.FileTouched:
 		p.reindexSpace(ev, e.Ref, e.Executant, e.SpaceOwner)
 	case events.FileVersionRestored:
 		p.reindexSpace(ev, e.Ref, e.Executant, e.SpaceOwner)
+	case events.ItemPurged:
+		return p.purgeTrash(ev, e.Ref, e.Executant)
+	case events.SpaceDeleted:
+		return p.purgeSpace(ev, e.ID)
+	case events.SpaceDisabled:
+		return p.purgeSpace(ev, e.ID)
+	case events.SpaceEnabled:
+		// the documents of the space have been purged when it was disabled
+		p.logger.Debug().Interface("event", ev).Msg("space has been enabled, scheduling a space resync")
+		if e.Owner != nil {
+			p.indexSpaceDebouncer.Debounce(e.ID, e.Owner)
+		} else {
+			p.indexSpaceDebouncer.Debounce(e.ID, e.Executant)
+		}
+	case events.SpaceCreated:
+		return p.updateSpaceDocument(ev, e.ID, e.Executant, e.Owner)
+	case events.SpaceRenamed:
+		return p.updateSpaceDocument(ev, e.ID, e.Executant, e.Owner)
 	default:
 		// Not sure what to do here. Skip.
-		return
 	}
+	return nil
+}
+
+// statusError returns the error matching the status of a failed request. Resources which don't exist
+// anymore are no error, they have been removed or moved since the event was emitted and the following
+// events take care of them.
+func statusError(s *rpc.Status) error {
+	if s.GetCode() == rpc.Code_CODE_NOT_FOUND {
+		return nil
+	}
+	return errtypes.NewErrtypeFromStatus(s)
+}
+
+// indexResource adds the given resource including its content to the index right away. The following
+// space reindex will skip it as it hasn't changed since then.
+func (p *Provider) indexResource(ev interface{}, ref *provider.Reference, executant *user.UserId) error {
+	p.logger.Debug().Interface("event", ev).Msg("resource has been uploaded, indexing the document")
+	owner := &user.User{
+		Id: executant,
+	}
+
+	ownerCtx, err := p.getAuthContext(owner)
+	if err != nil {
+		return err
+	}
+	statRes, err := p.statResource(ownerCtx, ref, owner)
+	if err != nil {
+		p.logger.Error().Err(err).Interface("ref", ref).Msg("failed to stat the uploaded resource")
+		return err
+	}
+	if statRes.Status.Code != rpc.Code_CODE_OK {
+		p.logger.Error().Interface("statRes", statRes).Interface("ref", ref).Msg("failed to stat the uploaded resource")
+		return statusError(statRes.Status)
+	}
+
+	gpRes, err := p.getPath(ownerCtx, statRes.Info.Id, owner)
+	if err != nil {
+		p.logger.Error().Err(err).Interface("ref", ref).Msg("failed to get path for uploaded resource")
+		return err
+	}
+	if gpRes.Status.Code != rpcv1beta1.Code_CODE_OK {
+		p.logger.Error().Interface("status", gpRes.Status).Interface("ref", ref).Msg("failed to get path for uploaded resource")
+		return statusError(gpRes.Status)
+	}
+
+	rootRef := &provider.Reference{
+		ResourceId: &provider.ResourceId{
+			StorageId: statRes.Info.Id.StorageId,
+			SpaceId:   statRes.Info.Id.SpaceId,
+			OpaqueId:  statRes.Info.Id.SpaceId,
+		},
+		Path: utils.MakeRelativePath(gpRes.Path),
+	}
+	err = p.indexClient.Add(rootRef, search.WithoutForeignFavorite(statRes.Info, executant), p.extractContent(ownerCtx, statRes.Info))
+	if err != nil {
+		p.logger.Error().Err(err).Interface("ref", rootRef).Msg("failed to add the uploaded resource to the index")
+		return err
+	}
+	return nil
+}
+
+// purgeTrash removes the resources from the index which have been purged from the trash bin of the space.
+// The event doesn't tell which item was purged, so the deleted resources in the index are compared with
+// the items left in the trash bin.
+func (p *Provider) purgeTrash(ev interface{}, ref *provider.Reference, executant *user.UserId) error {
+	p.logger.Debug().Interface("event", ev).Msg("trash bin has been purged, removing the purged documents")
+	owner := &user.User{
+		Id: executant,
+	}
+
+	ownerCtx, err := p.getAuthContext(owner)
+	if err != nil {
+		return err
+	}
+	rootID := &provider.ResourceId{
+		StorageId: ref.GetResourceId().GetStorageId(),
+		SpaceId:   ref.GetResourceId().GetSpaceId(),
+		OpaqueId:  ref.GetResourceId().GetSpaceId(),
+	}
+	res, err := p.gwClient.ListRecycle(ownerCtx, &provider.ListRecycleRequest{
+		Ref: &provider.Reference{ResourceId: rootID, Path: "."},
+	})
+	if err == nil && res.GetStatus().GetCode() != rpc.Code_CODE_OK {
+		err = errtypes.NewErrtypeFromStatus(res.Status)
+	}
+	if err != nil {
+		p.logger.Error().Err(err).Interface("ref", ref).Msg("failed to list the trash bin of the space")
+		return err
+	}
+	keys := map[string]struct{}{}
+	for _, item := range res.RecycleItems {
+		keys[item.Key] = struct{}{}
+	}
+
+	batch, err := p.indexClient.NewBatch(indexBatchSize)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to create a batch")
+		return err
+	}
+	err = p.indexClient.ListSpace(rootID, func(entity *searchmsg.Entity) error {
+		// documents deleted before trash keys were recorded are left to the garbage collection
+		key := strings.SplitN(entity.TrashKey, "/", 2)[0]
+		if !entity.Deleted || key == "" {
+			return nil
+		}
+		if _, ok := keys[key]; ok {
+			return nil
+		}
+		id := &provider.ResourceId{
+			StorageId: entity.GetId().GetStorageId(),
+			SpaceId:   entity.GetId().GetSpaceId(),
+			OpaqueId:  entity.GetId().GetOpaqueId(),
+		}
+		if err := batch.Purge(id); err != nil {
+			p.logger.Error().Err(err).Interface("id", id).Msg("failed to purge the resource from the index")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		p.logger.Error().Err(err).Interface("ref", ref).Msg("failed to list the indexed resources of the space")
+		return err
+	}
+	if err := batch.Push(); err != nil {
+		p.logger.Error().Err(err).Msg("error pushing the batch to the index")
+		return err
+	}
+	return nil
+}
+
+// purgeSpace removes all resources of the given space from the index
+func (p *Provider) purgeSpace(ev interface{}, spaceID *provider.StorageSpaceId) error {
+	p.logger.Debug().Interface("event", ev).Msg("space has been deleted or disabled, removing its documents")
+	rootID, err := storagespace.ParseID(spaceID.GetOpaqueId())
+	if err != nil || rootID.StorageId == "" || rootID.SpaceId == "" {
+		// retrying doesn't help with an invalid id
+		p.logger.Error().Err(err).Interface("spaceID", spaceID).Msg("invalid space id")
+		return nil
+	}
+	rootID.OpaqueId = rootID.SpaceId
+
+	if err := p.indexClient.PurgeSpace(&rootID); err != nil {
+		p.logger.Error().Err(err).Interface("spaceID", spaceID).Msg("failed to purge the space from the index")
+		return err
+	}
+	return nil
 }
 
 func (p *Provider) reindexSpace(ev interface{}, ref *provider.Reference, executant, owner *user.UserId) {
@@ -176,6 +360,22 @@ func (p *Provider) reindexSpace(ev interface{}, ref *provider.Reference, executa
 	}
 }
 
+// updateSpaceDocument updates the document of the given space holding its name, description and readme.
+// Creating or renaming a space doesn't change its resources, so they aren't reindexed. The readme is
+// updated by the resync of the space scheduled when it has been uploaded or moved.
+func (p *Provider) updateSpaceDocument(ev interface{}, spaceID *provider.StorageSpaceId, executant, owner *user.UserId) error {
+	p.logger.Debug().Interface("event", ev).Msg("space has been created or renamed, updating the space document")
+	u := &user.User{Id: owner}
+	if owner == nil {
+		u.Id = executant
+	}
+	ctx, err := p.getAuthContext(u)
+	if err != nil {
+		return err
+	}
+	return p.indexSpaceDocument(ctx, spaceID)
+}
+
 func (p *Provider) statResource(ctx context.Context, ref *provider.Reference, owner *user.User) (*provider.StatResponse, error) {
 	return p.gwClient.Stat(ctx, &provider.StatRequest{Ref: ref})
 }
@@ -17,6 +17,8 @@ import (
 	"github.com/cs3org/reva/v2/pkg/utils"
 	cs3mocks "github.com/cs3org/reva/v2/tests/cs3mocks/mocks"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	contentmocks "github.com/owncloud/ocis/v2/services/search/pkg/content/mocks"
 	"github.com/owncloud/ocis/v2/services/search/pkg/search/mocks"
 	provider "github.com/owncloud/ocis/v2/services/search/pkg/search/provider"
 )
@@ -26,7 +28,8 @@ var _ = Describe("Searchprovider", func() {
 		p                   *provider.Provider
 		gwClient            *cs3mocks.GatewayAPIClient
 		indexClient         *mocks.IndexClient
-		debouncedIndexCalls int
+		extractor           *contentmocks.Extractor
+		debouncedIndexCalls *int
 
 		ctx        context.Context
 		eventsChan chan interface{}
@@ -53,6 +56,7 @@ var _ = Describe("Searchprovider", func() {
 				OpaqueId:  "opaqueid",
 			},
 			Path:  "foo.pdf",
+			Type:  sprovider.ResourceType_RESOURCE_TYPE_FILE,
 			Size:  12345,
 			Mtime: utils.TimeToTS(time.Now().Add(-time.Hour)),
 		}
@@ -63,13 +67,16 @@ var _ = Describe("Searchprovider", func() {
 		eventsChan = make(chan interface{})
 		gwClient = &cs3mocks.GatewayAPIClient{}
 		indexClient = &mocks.IndexClient{}
+		extractor = &contentmocks.Extractor{}
 
-		debouncedIndexCalls = 0
+		// every spec gets its own counter so that reindexes scheduled by previous specs don't count
+		calls := 0
+		debouncedIndexCalls = &calls
 		debouncer := provider.NewSpaceDebouncer(100*time.Millisecond, func(id *sprovider.StorageSpaceId, userID *userv1beta1.UserId) {
-			debouncedIndexCalls += 1
+			calls += 1
 		})
 
-		p = provider.NewWithDebouncer(gwClient, indexClient, "", eventsChan, logger, debouncer)
+		p = provider.NewWithDebouncer(gwClient, indexClient, extractor, "", eventsChan, logger, debouncer)
 
 		gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
 			Status: status.NewOK(ctx),
@@ -84,7 +91,7 @@ var _ = Describe("Searchprovider", func() {
 
 	Describe("New", func() {
 		It("returns a new instance", func() {
-			p = provider.New(gwClient, indexClient, "", eventsChan, 1000, logger)
+			p = provider.New(gwClient, indexClient, extractor, "", eventsChan, 1000, logger)
 			Expect(p).ToNot(BeNil())
 		})
 	})
@@ -101,16 +108,38 @@ var _ = Describe("Searchprovider", func() {
 			})
 
 			It("triggers an index update when a file has been uploaded", func() {
+				extractor.On("Extract", mock.Anything, mock.Anything).Return("", nil)
+				indexClient.On("Add", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 				eventsChan <- events.FileUploaded{
 					Ref:       ref,
 					Executant: user.Id,
 				}
 
 				Eventually(func() int {
-					return debouncedIndexCalls
+					return *debouncedIndexCalls
 				}, "2s").Should(Equal(1))
 			})
 
+			It("indexes the content of uploaded files", func() {
+				called := false
+				extractor.On("Extract", mock.Anything, mock.MatchedBy(func(info *sprovider.ResourceInfo) bool {
+					return info.Id.OpaqueId == ri.Id.OpaqueId
+				})).Return("the content", nil)
+				indexClient.On("Add", mock.MatchedBy(func(ref *sprovider.Reference) bool {
+					return ref.ResourceId.OpaqueId == ri.Id.SpaceId && ref.Path == "./foo.pdf"
+				}), mock.Anything, "the content").Return(nil).Run(func(args mock.Arguments) {
+					called = true
+				})
+				eventsChan <- events.FileUploaded{
+					Ref:       ref,
+					Executant: user.Id,
+				}
+
+				Eventually(func() bool {
+					return called
+				}, "2s").Should(BeTrue())
+			})
+
 			It("triggers an index update when a file has been touched", func() {
 				eventsChan <- events.FileTouched{
 					Ref:       ref,
@@ -118,7 +147,7 @@ var _ = Describe("Searchprovider", func() {
 				}
 
 				Eventually(func() int {
-					return debouncedIndexCalls
+					return *debouncedIndexCalls
 				}, "2s").Should(Equal(1))
 			})
 
@@ -167,7 +196,7 @@ var _ = Describe("Searchprovider", func() {
 				}
 
 				Eventually(func() int {
-					return debouncedIndexCalls
+					return *debouncedIndexCalls
 				}, "2s").Should(Equal(1))
 			})
 		})
@@ -193,6 +222,126 @@ var _ = Describe("Searchprovider", func() {
 				return called
 			}, "2s").Should(BeTrue())
 		})
+
+		It("purges the resources which have been purged from the trash bin", func() {
+			gwClient.On("ListRecycle", mock.Anything, mock.MatchedBy(func(req *sprovider.ListRecycleRequest) bool {
+				return req.Ref.ResourceId.SpaceId == "rootopaqueid" && req.Ref.ResourceId.OpaqueId == "rootopaqueid"
+			})).Return(&sprovider.ListRecycleResponse{
+				Status:       status.NewOK(ctx),
+				RecycleItems: []*sprovider.RecycleItem{{Key: "kept"}},
+			}, nil)
+			entity := func(id, trashKey string, deleted bool) *searchmsg.Entity {
+				return &searchmsg.Entity{
+					Id:       &searchmsg.ResourceID{StorageId: "storageid", SpaceId: "rootopaqueid", OpaqueId: id},
+					Deleted:  deleted,
+					TrashKey: trashKey,
+				}
+			}
+			listSpaceReturns(indexClient,
+				entity("existing", "", false),
+				entity("kept", "kept", true),
+				entity("keptchild", "kept/child", true),
+				entity("purged", "purged", true),
+				entity("purgedchild", "purged/child", true),
+				entity("legacy", "", true),
+			)
+			batch := &mocks.BatchOperator{}
+			batch.On("Purge", mock.Anything).Return(nil)
+			batch.On("Push").Return(nil)
+			indexClient.On("NewBatch", mock.Anything).Return(batch, nil)
+
+			eventsChan <- events.ItemPurged{
+				Ref:       &sprovider.Reference{ResourceId: ref.ResourceId, Path: "."},
+				Executant: user.Id,
+			}
+
+			Eventually(func() int {
+				return len(batch.Calls)
+			}, "2s").Should(Equal(3))
+			purged := []string{}
+			for _, c := range batch.Calls {
+				if c.Method == "Purge" {
+					purged = append(purged, c.Arguments[0].(*sprovider.ResourceId).OpaqueId)
+				}
+			}
+			Expect(purged).To(ConsistOf("purged", "purgedchild"))
+			batch.AssertCalled(GinkgoT(), "Push")
+		})
+
+		It("purges the resources of deleted spaces", func() {
+			called := false
+			indexClient.On("PurgeSpace", mock.MatchedBy(func(id *sprovider.ResourceId) bool {
+				return id.StorageId == "storageid" && id.SpaceId == "spaceid" && id.OpaqueId == "spaceid"
+			})).Return(nil).Run(func(args mock.Arguments) {
+				called = true
+			})
+			eventsChan <- events.SpaceDeleted{
+				ID:        &sprovider.StorageSpaceId{OpaqueId: "storageid$spaceid!spaceid"},
+				Executant: user.Id,
+			}
+
+			Eventually(func() bool {
+				return called
+			}, "2s").Should(BeTrue())
+		})
+
+		It("purges the resources of disabled spaces and reindexes them when they are enabled again", func() {
+			called := false
+			indexClient.On("PurgeSpace", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				called = true
+			})
+			eventsChan <- events.SpaceDisabled{
+				ID:        &sprovider.StorageSpaceId{OpaqueId: "storageid$spaceid"},
+				Executant: user.Id,
+			}
+			Eventually(func() bool {
+				return called
+			}, "2s").Should(BeTrue())
+
+			eventsChan <- events.SpaceEnabled{
+				ID:        &sprovider.StorageSpaceId{OpaqueId: "storageid$spaceid"},
+				Executant: user.Id,
+			}
+			Eventually(func() int {
+				return *debouncedIndexCalls
+			}, "2s").Should(Equal(1))
+		})
+
+		It("updates the space document when a space has been created or renamed", func() {
+			space := &sprovider.StorageSpace{
+				Id:        &sprovider.StorageSpaceId{OpaqueId: "storageid$spaceid"},
+				Root:      &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "spaceid"},
+				Name:      "Project",
+				SpaceType: "project",
+			}
+			gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&sprovider.ListStorageSpacesResponse{
+				Status:        status.NewOK(ctx),
+				StorageSpaces: []*sprovider.StorageSpace{space},
+			}, nil)
+			added := make(chan *sprovider.StorageSpace, 2)
+			indexClient.On("AddSpace", mock.Anything, "").Return(nil).Run(func(args mock.Arguments) {
+				added <- args.Get(0).(*sprovider.StorageSpace)
+			})
+
+			eventsChan <- events.SpaceCreated{
+				ID:        &sprovider.StorageSpaceId{OpaqueId: "storageid$spaceid"},
+				Executant: user.Id,
+				Name:      "Project",
+			}
+			Eventually(added, "2s").Should(Receive(Equal(space)))
+
+			eventsChan <- events.SpaceRenamed{
+				ID:        &sprovider.StorageSpaceId{OpaqueId: "storageid$spaceid"},
+				Executant: user.Id,
+				Name:      "Project",
+			}
+			Eventually(added, "2s").Should(Receive(Equal(space)))
+
+			// the resources of the space aren't reindexed
+			Consistently(func() int {
+				return *debouncedIndexCalls
+			}, "200ms").Should(Equal(0))
+		})
 	})
 })
 
@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// StartGarbageCollection periodically removes the resources from the index which have been deleted
+// longer than the given retention until the context is done
+func (p *Provider) StartGarbageCollection(ctx context.Context, interval, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.CollectGarbage(retention)
+			}
+		}
+	}()
+}
+
+// CollectGarbage removes the resources from the index which have been deleted longer than the given retention
+func (p *Provider) CollectGarbage(retention time.Duration) {
+	purged, err := p.indexClient.PurgeDeleted(time.Now().Add(-retention))
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to purge the deleted resources from the index")
+		return
+	}
+	p.logger.Debug().Int("purged", purged).Dur("retention", retention).Msg("purged the deleted resources from the index")
+	if purged > 0 {
+		p.logDocCount()
+	}
+}
@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+)
+
+// IndexSpaces indexes all personal and project spaces. The spaces are listed as the given user, who needs the
+// permission to list all spaces. Personal spaces are indexed as their owner and project spaces as one of their
+// managers, the given user is only used if none of the managers can be authenticated. Up to req.Concurrency
+// spaces are indexed at the same time, the progress is reported through send.
+func (p *Provider) IndexSpaces(ctx context.Context, req *searchsvc.IndexSpacesRequest, send func(*searchsvc.IndexSpacesResponse) error) error {
+	lister := &user.User{
+		Id: &user.UserId{OpaqueId: req.UserId},
+	}
+	listerCtx, err := p.getAuthContext(lister)
+	if err != nil {
+		return err
+	}
+	spaces, err := p.listSpaces(listerCtx)
+	if err != nil {
+		return err
+	}
+
+	concurrency := int(req.Concurrency)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mutex  sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, concurrency)
+	)
+	report := func(res *searchsvc.IndexSpacesResponse) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		res.TotalSpaces = int32(len(spaces))
+		if res.Done && res.Error != "" {
+			failed++
+		}
+		if err := send(res); err != nil {
+			p.logger.Error().Err(err).Str("spaceID", res.SpaceId).Msg("failed to report the indexing progress")
+		}
+	}
+
+	for _, space := range spaces {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		space := space
+		spaceID := formatSpaceID(space)
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			res := &searchsvc.IndexSpacesResponse{SpaceId: spaceID}
+			userID := p.indexingUser(ctx, space, lister.Id)
+			err := p.indexSpace(ctx, &provider.StorageSpaceId{OpaqueId: spaceID}, userID, func(indexed, errors int) {
+				res.Indexed, res.Errors = int32(indexed), int32(errors)
+				report(&searchsvc.IndexSpacesResponse{SpaceId: spaceID, Indexed: res.Indexed, Errors: res.Errors})
+			})
+			if err != nil {
+				p.logger.Error().Err(err).Str("spaceID", spaceID).Msg("failed to index the space")
+				res.Error = err.Error()
+			}
+			res.Done = true
+			report(res)
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to index %d of %d spaces", failed, len(spaces))
+	}
+	return nil
+}
+
+// indexingUser returns the user the space is indexed as. Personal spaces are indexed as their owner and
+// project spaces as the first of their managers who can be authenticated, the fallback is used otherwise.
+func (p *Provider) indexingUser(ctx context.Context, space *provider.StorageSpace, fallback *user.UserId) *user.UserId {
+	if space.SpaceType == "personal" && space.GetOwner().GetId() != nil {
+		return space.Owner.Id
+	}
+	for _, id := range spaceManagers(space) {
+		// the grants of a space don't tell users and groups apart, only users can be authenticated
+		authRes, err := p.gwClient.Authenticate(ctx, &gateway.AuthenticateRequest{
+			Type:         "machine",
+			ClientId:     "userid:" + id,
+			ClientSecret: p.machineAuthAPIKey,
+		})
+		if err == nil && authRes.GetStatus().GetCode() == rpc.Code_CODE_OK {
+			return &user.UserId{OpaqueId: id}
+		}
+	}
+	p.logger.Debug().Str("spaceID", formatSpaceID(space)).Msg("no manager of the space can be authenticated, indexing it as the listing user")
+	return fallback
+}
+
+// spaceManagers returns the ids of the users and groups allowed to manage the space, sorted to index
+// the space as the same manager every time
+func spaceManagers(space *provider.StorageSpace) []string {
+	entry, ok := space.GetOpaque().GetMap()["grants"]
+	if !ok {
+		return nil
+	}
+	var grants map[string]*provider.ResourcePermissions
+	if err := json.Unmarshal(entry.Value, &grants); err != nil {
+		return nil
+	}
+	managers := make([]string, 0, len(grants))
+	for id, perms := range grants {
+		// only managers are allowed to remove grants
+		if perms.GetRemoveGrant() {
+			managers = append(managers, id)
+		}
+	}
+	sort.Strings(managers)
+	return managers
+}
@@ -0,0 +1,216 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
+	"github.com/cs3org/reva/v2/pkg/rgrpc/status"
+	cs3mocks "github.com/cs3org/reva/v2/tests/cs3mocks/mocks"
+	"google.golang.org/grpc"
+
+	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search/mocks"
+	provider "github.com/owncloud/ocis/v2/services/search/pkg/search/provider"
+)
+
+var _ = Describe("IndexSpaces", func() {
+	var (
+		p           *provider.Provider
+		gwClient    *cs3mocks.GatewayAPIClient
+		indexClient *mocks.IndexClient
+		batch       *mocks.BatchOperator
+
+		mutex     sync.Mutex
+		responses []*searchsvc.IndexSpacesResponse
+		send      = func(res *searchsvc.IndexSpacesResponse) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			responses = append(responses, res)
+			return nil
+		}
+		done = func() map[string]*searchsvc.IndexSpacesResponse {
+			found := map[string]*searchsvc.IndexSpacesResponse{}
+			for _, res := range responses {
+				if res.Done {
+					found[res.SpaceId] = res
+				}
+			}
+			return found
+		}
+
+		ctx    = context.Background()
+		logger = log.NewLogger()
+
+		space = func(spaceType, spaceID string, owner *userv1beta1.User) *sprovider.StorageSpace {
+			return &sprovider.StorageSpace{
+				SpaceType: spaceType,
+				Root:      &sprovider.ResourceId{StorageId: "storageid", SpaceId: spaceID, OpaqueId: spaceID},
+				Owner:     owner,
+			}
+		}
+		authenticatedAs = func(userID string) interface{} {
+			return mock.MatchedBy(func(req *gateway.AuthenticateRequest) bool {
+				return req.ClientId == "userid:"+userID
+			})
+		}
+	)
+
+	BeforeEach(func() {
+		responses = nil
+		gwClient = &cs3mocks.GatewayAPIClient{}
+		indexClient = &mocks.IndexClient{}
+		batch = &mocks.BatchOperator{}
+
+		gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
+			Status: status.NewOK(ctx),
+			Token:  "authtoken",
+		}, nil)
+		gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&sprovider.ListStorageSpacesResponse{
+			Status: status.NewOK(ctx),
+			StorageSpaces: []*sprovider.StorageSpace{
+				space("personal", "personal", &userv1beta1.User{Id: &userv1beta1.UserId{OpaqueId: "owner"}}),
+				space("project", "project", nil),
+			},
+		}, nil)
+		gwClient.On("Stat", mock.Anything, mock.Anything).Return(func(_ context.Context, req *sprovider.StatRequest, _ ...grpc.CallOption) *sprovider.StatResponse {
+			return &sprovider.StatResponse{
+				Status: status.NewOK(ctx),
+				Info: &sprovider.ResourceInfo{
+					Id:   req.Ref.ResourceId,
+					Path: ".",
+					Type: sprovider.ResourceType_RESOURCE_TYPE_FILE,
+				},
+			}
+		}, nil)
+		indexClient.On("Get", mock.Anything).Return(nil, errtypes.NotFound("not found"))
+		listSpaceReturns(indexClient)
+		indexClient.On("NewBatch", mock.Anything).Return(batch, nil)
+		indexClient.On("DocCount").Return(uint64(2), nil)
+		indexClient.On("AddSpace", mock.Anything, mock.Anything).Return(nil)
+		batch.On("Add", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		batch.On("Push").Return(nil)
+
+		p = provider.New(gwClient, indexClient, nil, "", nil, 1000, logger)
+	})
+
+	It("indexes all spaces and reports the progress", func() {
+		err := p.IndexSpaces(ctx, &searchsvc.IndexSpacesRequest{UserId: "admin", Concurrency: 2}, send)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(done()).To(HaveLen(2))
+		for _, res := range done() {
+			Expect(res.TotalSpaces).To(Equal(int32(2)))
+			Expect(res.Indexed).To(Equal(int32(1)))
+			Expect(res.Error).To(BeEmpty())
+		}
+		batch.AssertNumberOfCalls(GinkgoT(), "Add", 2)
+	})
+
+	It("indexes personal spaces as their owner", func() {
+		err := p.IndexSpaces(ctx, &searchsvc.IndexSpacesRequest{UserId: "admin"}, send)
+		Expect(err).ToNot(HaveOccurred())
+
+		gwClient.AssertCalled(GinkgoT(), "Authenticate", mock.Anything, authenticatedAs("owner"))
+		gwClient.AssertCalled(GinkgoT(), "Authenticate", mock.Anything, authenticatedAs("admin"))
+	})
+
+	It("indexes project spaces as one of their managers", func() {
+		project := space("project", "project", nil)
+		project.Opaque = &typesv1beta1.Opaque{Map: map[string]*typesv1beta1.OpaqueEntry{
+			"grants": {Decoder: "json", Value: []byte(`{"group":{"remove_grant":true},"manager":{"remove_grant":true},"viewer":{"stat":true}}`)},
+		}}
+		gwClient.ExpectedCalls = nil
+		gwClient.On("Authenticate", mock.Anything, authenticatedAs("group")).Return(&gateway.AuthenticateResponse{
+			Status: status.NewNotFound(ctx, "unknown user"),
+		}, nil)
+		gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
+			Status: status.NewOK(ctx),
+			Token:  "authtoken",
+		}, nil)
+		gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&sprovider.ListStorageSpacesResponse{
+			Status:        status.NewOK(ctx),
+			StorageSpaces: []*sprovider.StorageSpace{project},
+		}, nil)
+		gwClient.On("Stat", mock.Anything, mock.Anything).Return(&sprovider.StatResponse{
+			Status: status.NewOK(ctx),
+			Info: &sprovider.ResourceInfo{
+				Id:   &sprovider.ResourceId{StorageId: "storageid", SpaceId: "project", OpaqueId: "project"},
+				Path: ".",
+				Type: sprovider.ResourceType_RESOURCE_TYPE_FILE,
+			},
+		}, nil)
+
+		err := p.IndexSpaces(ctx, &searchsvc.IndexSpacesRequest{UserId: "admin"}, send)
+		Expect(err).ToNot(HaveOccurred())
+
+		gwClient.AssertCalled(GinkgoT(), "Authenticate", mock.Anything, authenticatedAs("manager"))
+		gwClient.AssertNotCalled(GinkgoT(), "Authenticate", mock.Anything, authenticatedAs("viewer"))
+		Expect(done()["storageid$project"].Error).To(BeEmpty())
+	})
+
+	It("stops when the context is canceled", func() {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := p.IndexSpaces(canceled, &searchsvc.IndexSpacesRequest{UserId: "admin"}, send)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(done()).To(BeEmpty())
+	})
+
+	It("reports the spaces which failed and goes on with the others", func() {
+		gwClient.ExpectedCalls = nil
+		gwClient.On("Authenticate", mock.Anything, authenticatedAs("owner")).Return(nil, errors.New("unknown user"))
+		gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
+			Status: status.NewOK(ctx),
+			Token:  "authtoken",
+		}, nil)
+		gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&sprovider.ListStorageSpacesResponse{
+			Status: status.NewOK(ctx),
+			StorageSpaces: []*sprovider.StorageSpace{
+				space("personal", "personal", &userv1beta1.User{Id: &userv1beta1.UserId{OpaqueId: "owner"}}),
+				space("project", "project", nil),
+			},
+		}, nil)
+		gwClient.On("Stat", mock.Anything, mock.Anything).Return(&sprovider.StatResponse{
+			Status: status.NewOK(ctx),
+			Info: &sprovider.ResourceInfo{
+				Id:   &sprovider.ResourceId{StorageId: "storageid", SpaceId: "project", OpaqueId: "project"},
+				Path: ".",
+				Type: sprovider.ResourceType_RESOURCE_TYPE_FILE,
+			},
+		}, nil)
+
+		err := p.IndexSpaces(ctx, &searchsvc.IndexSpacesRequest{UserId: "admin"}, send)
+		Expect(err).To(MatchError("failed to index 1 of 2 spaces"))
+
+		Expect(done()).To(HaveLen(2))
+		Expect(done()["storageid$personal"].Error).To(Equal("unknown user"))
+		Expect(done()["storageid$project"].Error).To(BeEmpty())
+	})
+
+	It("fails when the spaces can not be listed", func() {
+		gwClient.ExpectedCalls = nil
+		gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
+			Status: status.NewOK(ctx),
+			Token:  "authtoken",
+		}, nil)
+		gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&sprovider.ListStorageSpacesResponse{
+			Status: status.NewPermissionDenied(ctx, nil, "not allowed"),
+		}, nil)
+
+		err := p.IndexSpaces(ctx, &searchsvc.IndexSpacesRequest{UserId: "user"}, send)
+		Expect(err).To(HaveOccurred())
+		Expect(responses).To(BeEmpty())
+	})
+})
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/cs3org/reva/v2/pkg/events"
+
+	"github.com/owncloud/ocis/v2/services/search/pkg/metrics"
+)
+
+// Option defines a single option function.
+type Option func(o *Options)
+
+// Options defines the available options for the processing of the events.
+type Options struct {
+	Workers           int
+	MaxAttempts       int
+	Backoff           time.Duration
+	MaxBackoff        time.Duration
+	DeadLetter        events.Publisher
+	DeadLetterSubject string
+	Metrics           *metrics.Metrics
+}
+
+func newOptions(opts ...Option) Options {
+	opt := Options{
+		Workers:     1,
+		MaxAttempts: 1,
+	}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	if opt.Workers < 1 {
+		opt.Workers = 1
+	}
+	if opt.MaxAttempts < 1 {
+		opt.MaxAttempts = 1
+	}
+	if opt.MaxBackoff < opt.Backoff {
+		opt.MaxBackoff = opt.Backoff
+	}
+	if opt.Metrics == nil {
+		opt.Metrics = metrics.New()
+	}
+
+	return opt
+}
+
+// Workers sets the number of events processed concurrently.
+func Workers(val int) Option {
+	return func(o *Options) {
+		o.Workers = val
+	}
+}
+
+// Retries sets the number of attempts to process an event and the backoff between them. The backoff is
+// doubled with every attempt up to the given maximum.
+func Retries(attempts int, backoff, maxBackoff time.Duration) Option {
+	return func(o *Options) {
+		o.MaxAttempts = attempts
+		o.Backoff = backoff
+		o.MaxBackoff = maxBackoff
+	}
+}
+
+// DeadLetter sets the publisher and the subject events are sent to after all attempts to process them failed.
+func DeadLetter(publisher events.Publisher, subject string) Option {
+	return func(o *Options) {
+		o.DeadLetter = publisher
+		o.DeadLetterSubject = subject
+	}
+}
+
+// Metrics sets the metrics the processing of the events is reported to.
+func Metrics(val *metrics.Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = val
+	}
+}
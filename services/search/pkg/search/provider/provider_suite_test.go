@@ -3,6 +3,11 @@ package provider_test
 import (
 	"testing"
 
+	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search/mocks"
+	"github.com/stretchr/testify/mock"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -11,3 +16,15 @@ func TestProvider(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Provider Suite")
 }
+
+// listSpaceReturns makes the index client list the given entities for any space
+func listSpaceReturns(indexClient *mocks.IndexClient, entities ...*searchmsg.Entity) {
+	indexClient.On("ListSpace", mock.Anything, mock.Anything).Return(func(_ *sprovider.ResourceId, fn func(*searchmsg.Entity) error) error {
+		for _, e := range entities {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
@@ -23,6 +24,7 @@ import (
 	"github.com/cs3org/reva/v2/pkg/storagespace"
 	"github.com/cs3org/reva/v2/pkg/utils"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
+	"github.com/owncloud/ocis/v2/services/search/pkg/content"
 	"github.com/owncloud/ocis/v2/services/search/pkg/search"
 
 	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
@@ -48,6 +50,12 @@ const (
 	PermissionShare
 )
 
+// indexBatchSize is the number of operations sent to the index at once when indexing a space
+const indexBatchSize = 500
+
+// progressInterval is the number of indexed resources after which the progress of indexing a space is reported
+const progressInterval = 100
+
 var ListenEvents = []events.Unmarshaller{
 	events.ItemTrashed{},
 	events.ItemRestored{},
@@ -56,34 +64,34 @@ var ListenEvents = []events.Unmarshaller{
 	events.FileUploaded{},
 	events.FileTouched{},
 	events.FileVersionRestored{},
+	events.ItemPurged{},
+	events.SpaceDeleted{},
+	events.SpaceDisabled{},
+	events.SpaceEnabled{},
+	events.SpaceCreated{},
+	events.SpaceRenamed{},
 }
 
 type Provider struct {
 	logger            log.Logger
 	gwClient          gateway.GatewayAPIClient
 	indexClient       search.IndexClient
+	extractor         content.Extractor
 	machineAuthAPIKey string
+	options           Options
 
 	indexSpaceDebouncer *SpaceDebouncer
 }
 
-type MatchArray []*searchmsg.Match
-
-func (s MatchArray) Len() int {
-	return len(s)
-}
-func (s MatchArray) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
-}
-func (s MatchArray) Less(i, j int) bool {
-	return s[i].Score > s[j].Score
-}
-
-func New(gwClient gateway.GatewayAPIClient, indexClient search.IndexClient, machineAuthAPIKey string, eventsChan <-chan interface{}, debounceDuration int, logger log.Logger) *Provider {
+// New returns a new Provider instance. The extractor is used for indexing the contents of the files, it may be nil
+// to only index the metadata. The events received from the channel are processed according to the options.
+func New(gwClient gateway.GatewayAPIClient, indexClient search.IndexClient, extractor content.Extractor, machineAuthAPIKey string, eventsChan <-chan interface{}, debounceDuration int, logger log.Logger, opts ...Option) *Provider {
 	p := &Provider{
 		gwClient:          gwClient,
 		indexClient:       indexClient,
+		extractor:         extractor,
 		machineAuthAPIKey: machineAuthAPIKey,
+		options:           newOptions(opts...),
 		logger:            logger,
 	}
 
@@ -94,22 +102,14 @@ func New(gwClient gateway.GatewayAPIClient, indexClient search.IndexClient, mach
 		}
 	})
 
-	go func() {
-		for {
-			ev := <-eventsChan
-			go func() {
-				time.Sleep(1 * time.Second) // Give some time to let everything settle down before trying to access it when indexing
-				p.handleEvent(ev)
-			}()
-		}
-	}()
+	p.startWorkers(eventsChan)
 
 	return p
 }
 
 // NewWithDebouncer returns a new provider with a customer index space debouncer
-func NewWithDebouncer(gwClient gateway.GatewayAPIClient, indexClient search.IndexClient, machineAuthAPIKey string, eventsChan <-chan interface{}, logger log.Logger, debouncer *SpaceDebouncer) *Provider {
-	p := New(gwClient, indexClient, machineAuthAPIKey, eventsChan, 0, logger)
+func NewWithDebouncer(gwClient gateway.GatewayAPIClient, indexClient search.IndexClient, extractor content.Extractor, machineAuthAPIKey string, eventsChan <-chan interface{}, logger log.Logger, debouncer *SpaceDebouncer, opts ...Option) *Provider {
+	p := New(gwClient, indexClient, extractor, machineAuthAPIKey, eventsChan, 0, logger, opts...)
 	p.indexSpaceDebouncer = debouncer
 	return p
 }
@@ -118,7 +118,35 @@ func (p *Provider) Search(ctx context.Context, req *searchsvc.SearchRequest) (*s
 	if req.Query == "" {
 		return nil, errtypes.BadRequest("empty query provided")
 	}
+	order, err := search.ParseOrder(req.OrderBy)
+	if err != nil {
+		return nil, errtypes.BadRequest(err.Error())
+	}
+	// all spaces are ranked at the same time so that the matches can be merged, the following pages
+	// are ranked at the time of the first one
+	rankedAt := time.Now()
+	if req.PageToken != "" {
+		token, err := search.DecodePageTokenFor(req.PageToken, order)
+		if err != nil {
+			return nil, errtypes.BadRequest(err.Error())
+		}
+		rankedAt = token.RankedAt
+	}
+	if !search.IsValidScope(req.Scope) {
+		return nil, errtypes.BadRequest(fmt.Sprintf("unknown scope '%s'", req.Scope))
+	}
+	indexFacets := []string{}
+	for _, f := range req.Facets {
+		if !search.IsValidFacet(f) {
+			return nil, errtypes.BadRequest(fmt.Sprintf("unknown facet '%s'", f))
+		}
+		// the matches per space are counted here as the index doesn't know the ids of the mountpoints
+		if f != search.FacetSpace {
+			indexFacets = append(indexFacets, f)
+		}
+	}
 	p.logger.Debug().Str("query", req.Query).Msg("performing a search")
+	userID := p.currentUserID(ctx)
 
 	listSpacesRes, err := p.gwClient.ListStorageSpaces(ctx, &provider.ListStorageSpacesRequest{
 		Filters: []*provider.ListStorageSpacesRequest_Filter{
@@ -147,8 +175,11 @@ func (p *Provider) Search(ctx context.Context, req *searchsvc.SearchRequest) (*s
 		mountpointMap[grantSpaceId] = space.Id.OpaqueId
 	}
 
-	matches := MatchArray{}
+	matches := []*searchmsg.Match{}
 	total := int32(0)
+	morePages := false
+	facets := []*searchmsg.Facet{}
+	spaceFacet := &searchmsg.Facet{Name: search.FacetSpace}
 	for _, space := range listSpacesRes.StorageSpaces {
 		searchRootId := &searchmsg.ResourceID{
 			StorageId: space.Root.StorageId,
@@ -163,12 +194,22 @@ func (p *Provider) Search(ctx context.Context, req *searchsvc.SearchRequest) (*s
 			continue
 		}
 
+		if req.Scope == search.ScopeTrash && !canRestoreFromTrash(space) {
+			continue
+		}
+		// grants and mountpoints are shared resources rather than spaces the user is a member of
+		if req.Scope == search.ScopeSpaces && (space.SpaceType == "grant" || space.SpaceType == "mountpoint") {
+			continue
+		}
+
 		var (
 			mountpointRootID *searchmsg.ResourceID
+			personalRootID   *searchmsg.ResourceID
 			rootName         string
 			permissions      *provider.ResourcePermissions
 		)
 		mountpointPrefix := ""
+		spaceID := space.Id.OpaqueId
 		switch space.SpaceType {
 		case "mountpoint":
 			continue // mountpoint spaces are only "links" to the shared spaces. we have to search the shared "grant" space instead
@@ -202,21 +243,32 @@ func (p *Provider) Search(ctx context.Context, req *searchsvc.SearchRequest) (*s
 				SpaceId:   spid,
 				OpaqueId:  oid,
 			}
+			spaceID = mountpointID
 
 			rootName = filepath.Join("/", filepath.Base(gpRes.GetPath()))
 			permissions = space.GetRootInfo().GetPermissionSet()
 			p.logger.Debug().Interface("grantSpace", space).Interface("mountpointRootId", mountpointRootID).Msg("searching a grant")
 		case "personal":
 			permissions = space.GetRootInfo().GetPermissionSet()
+			if userID != "" && space.GetOwner().GetId().GetOpaqueId() == userID {
+				personalRootID = searchRootId
+			}
 		}
 
 		res, err := p.indexClient.Search(ctx, &searchsvc.SearchIndexRequest{
-			Query: formatQuery(req.Query),
+			Query: req.Query,
 			Ref: &searchmsg.Reference{
 				ResourceId: searchRootId,
 				Path:       mountpointPrefix,
 			},
-			PageSize: req.PageSize,
+			PageSize:       req.PageSize,
+			PageToken:      req.PageToken, // all spaces share the same order so the page token applies to each of them
+			Facets:         indexFacets,
+			Scope:          req.Scope,
+			UserId:         userID,
+			OrderBy:        req.OrderBy,
+			PersonalRootId: personalRootID,
+			RankedAt:       timestamppb.New(rankedAt),
 		})
 		if err != nil {
 			p.logger.Error().Err(err).Str("space", space.Id.OpaqueId).Msg("failed to search the index")
@@ -225,6 +277,11 @@ func (p *Provider) Search(ctx context.Context, req *searchsvc.SearchRequest) (*s
 		p.logger.Debug().Str("space", space.Id.OpaqueId).Int("hits", len(res.Matches)).Msg("space search done")
 
 		total += res.TotalMatches
+		morePages = morePages || res.NextPageToken != ""
+		facets = search.MergeFacets(facets, res.Facets)
+		if res.TotalMatches > 0 {
+			spaceFacet.Terms = append(spaceFacet.Terms, &searchmsg.FacetTerm{Term: spaceID, Count: res.TotalMatches})
+		}
 		for _, match := range res.Matches {
 			if mountpointPrefix != "" {
 				match.Entity.Ref.Path = utils.MakeRelativePath(strings.TrimPrefix(match.Entity.Ref.Path, mountpointPrefix))
@@ -243,21 +300,78 @@ func (p *Provider) Search(ctx context.Context, req *searchsvc.SearchRequest) (*s
 	}
 
 	// compile one sorted list of matches from all spaces and apply the limit if needed
-	sort.Sort(matches)
+	sort.Slice(matches, func(i, j int) bool {
+		return order.SortsBefore(matches[i], matches[j])
+	})
 	limit := req.PageSize
 	if limit == 0 {
 		limit = 200
 	}
 	if int32(len(matches)) > limit {
 		matches = matches[0:limit]
+		morePages = true
+	}
+
+	nextPageToken := ""
+	if morePages && len(matches) > 0 {
+		nextPageToken = search.EncodePageToken(order, rankedAt, matches[len(matches)-1])
+	}
+
+	// return the facets in the requested order
+	sort.SliceStable(spaceFacet.Terms, func(i, j int) bool {
+		return spaceFacet.Terms[i].Count > spaceFacet.Terms[j].Count
+	})
+	facets = append(facets, spaceFacet)
+	requestedFacets := []*searchmsg.Facet{}
+	for _, name := range req.Facets {
+		for _, f := range facets {
+			if f.Name == name {
+				requestedFacets = append(requestedFacets, f)
+				break
+			}
+		}
 	}
 
 	return &searchsvc.SearchResponse{
-		Matches:      matches,
-		TotalMatches: total,
+		Matches:       matches,
+		TotalMatches:  total,
+		NextPageToken: nextPageToken,
+		Facets:        requestedFacets,
 	}, nil
 }
 
+// currentUserID returns the id of the user the request is made for. The favorites of the user are
+// matched when searching.
+func (p *Provider) currentUserID(ctx context.Context) string {
+	if u, ok := ctxpkg.ContextGetUser(ctx); ok {
+		return u.GetId().GetOpaqueId()
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	tokens := md.Get(ctxpkg.TokenHeader)
+	if len(tokens) == 0 {
+		return ""
+	}
+	res, err := p.gwClient.WhoAmI(ctx, &gateway.WhoAmIRequest{Token: tokens[0]})
+	if err == nil && res.GetStatus().GetCode() != rpc.Code_CODE_OK {
+		err = errtypes.NewErrtypeFromStatus(res.Status)
+	}
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to look up the searching user, favorites won't be matched")
+		return ""
+	}
+	return res.GetUser().GetId().GetOpaqueId()
+}
+
+// canRestoreFromTrash returns true if the user is allowed to restore the items in the trash bin of the
+// given space. The trash bins of shared resources belong to the spaces of their owners.
+func canRestoreFromTrash(space *provider.StorageSpace) bool {
+	switch space.SpaceType {
+	case "grant", "mountpoint":
+		return false
+	}
+	return space.GetRootInfo().GetPermissionSet().GetRestoreRecycleItem()
+}
+
 func (p *Provider) IndexSpace(ctx context.Context, req *searchsvc.IndexSpaceRequest) (*searchsvc.IndexSpaceResponse, error) {
 	err := p.doIndexSpace(ctx, &provider.StorageSpaceId{OpaqueId: req.SpaceId}, &user.UserId{OpaqueId: req.UserId})
 	if err != nil {
@@ -267,12 +381,19 @@ func (p *Provider) IndexSpace(ctx context.Context, req *searchsvc.IndexSpaceRequ
 }
 
 func (p *Provider) doIndexSpace(ctx context.Context, spaceID *provider.StorageSpaceId, userID *user.UserId) error {
+	return p.indexSpace(ctx, spaceID, userID, nil)
+}
+
+// indexSpace walks the space and updates the index. The progress function, if given, is called with the
+// number of resources added to the index and the number of failed ones every progressInterval resources
+// and once the space has been walked.
+func (p *Provider) indexSpace(ctx context.Context, spaceID *provider.StorageSpaceId, userID *user.UserId, progress func(indexed, errors int)) error {
 	authRes, err := p.gwClient.Authenticate(ctx, &gateway.AuthenticateRequest{
 		Type:         "machine",
 		ClientId:     "userid:" + userID.OpaqueId,
 		ClientSecret: p.machineAuthAPIKey,
 	})
-	if err != nil || authRes.GetStatus().GetCode() != rpc.Code_CODE_OK {
+	if err != nil {
 		return err
 	}
 
@@ -294,6 +415,19 @@ func (p *Provider) doIndexSpace(ctx context.Context, spaceID *provider.StorageSp
 	}
 	rootID.OpaqueId = rootID.SpaceId
 
+	// a failure is logged, the resources of the space are indexed nevertheless
+	_ = p.indexSpaceDocument(ownerCtx, spaceID)
+
+	batch, err := p.indexClient.NewBatch(indexBatchSize)
+	if err != nil {
+		return err
+	}
+
+	// the ids of all walked resources and the paths of the subtrees which have been skipped
+	// because their etag didn't change since the last run
+	seen := map[string]struct{}{}
+	unchanged := []string{}
+	indexed, failed := 0, 0
 	err = walker.Walk(ownerCtx, &rootID, func(wd string, info *provider.ResourceInfo, err error) error {
 		if err != nil {
 			p.logger.Error().Err(err).Msg("error walking the tree")
@@ -309,28 +443,46 @@ func (p *Provider) doIndexSpace(ctx context.Context, spaceID *provider.StorageSp
 			ResourceId: &rootID,
 		}
 		p.logger.Debug().Str("path", ref.Path).Msg("Walking tree")
+		seen[storagespace.FormatResourceID(*info.Id)] = struct{}{}
 
-		// Has this item/subtree changed?
-		searchRes, err := p.indexClient.Search(ownerCtx, &searchsvc.SearchIndexRequest{
-			Query: "+ID:" + storagespace.FormatResourceID(*info.Id) + ` +Mtime:>="` + utils.TSToTime(info.Mtime).Format(time.RFC3339Nano) + `"`,
-		})
-		if err == nil && len(searchRes.Matches) >= 1 {
+		// Has this item/subtree changed? The etag and the tree mtime of a container change whenever anything
+		// in its subtree changes.
+		entity, err := p.indexClient.Get(info.Id)
+		if err == nil && isUnchanged(entity, ref, info) {
 			if info.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER {
 				p.logger.Debug().Str("path", ref.Path).Msg("subtree hasn't changed. Skipping.")
+				unchanged = append(unchanged, ref.Path)
 				return filepath.SkipDir
 			}
 			p.logger.Debug().Str("path", ref.Path).Msg("element hasn't changed. Skipping.")
 			return nil
 		}
 
-		err = p.indexClient.Add(ref, info)
+		err = batch.Add(ref, search.WithoutForeignFavorite(info, userID), p.extractContent(ownerCtx, info))
 		if err != nil {
 			p.logger.Error().Err(err).Msg("error adding resource to the index")
+			failed++
 		} else {
 			p.logger.Debug().Interface("ref", ref).Msg("added resource to index")
+			indexed++
+		}
+		if progress != nil && (indexed+failed)%progressInterval == 0 {
+			progress(indexed, failed)
 		}
 		return nil
 	})
+	if err == nil {
+		p.purgeUnseen(batch, &rootID, seen, unchanged)
+	}
+	if progress != nil {
+		progress(indexed, failed)
+	}
+	if pushErr := batch.Push(); pushErr != nil {
+		p.logger.Error().Err(pushErr).Msg("error pushing the batch to the index")
+		if err == nil {
+			err = pushErr
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -339,27 +491,127 @@ func (p *Provider) doIndexSpace(ctx context.Context, spaceID *provider.StorageSp
 	return nil
 }
 
-func (p *Provider) logDocCount() {
-	c, err := p.indexClient.DocCount()
+// indexSpaceDocument adds the document describing the space itself to the index, so that the space can
+// be found by its name, description and readme. A readme which can't be read is left out. Spaces which
+// don't exist anymore are skipped.
+func (p *Provider) indexSpaceDocument(ctx context.Context, spaceID *provider.StorageSpaceId) error {
+	res, err := p.gwClient.ListStorageSpaces(ctx, &provider.ListStorageSpacesRequest{
+		Filters: []*provider.ListStorageSpacesRequest_Filter{
+			{
+				Type: provider.ListStorageSpacesRequest_Filter_TYPE_ID,
+				Term: &provider.ListStorageSpacesRequest_Filter_Id{Id: &provider.StorageSpaceId{OpaqueId: spaceID.OpaqueId}},
+			},
+		},
+	})
+	if err == nil && res.GetStatus().GetCode() != rpc.Code_CODE_OK {
+		err = errtypes.NewErrtypeFromStatus(res.Status)
+	}
 	if err != nil {
-		p.logger.Error().Err(err).Msg("error getting document count from the index")
+		p.logger.Error().Err(err).Interface("spaceID", spaceID).Msg("failed to look up the space")
+		return err
 	}
-	p.logger.Debug().Interface("count", c).Msg("new document count")
+	if len(res.StorageSpaces) == 0 {
+		p.logger.Error().Interface("spaceID", spaceID).Msg("space not found")
+		return nil
+	}
+	space := res.StorageSpaces[0]
+
+	readme := ""
+	if readmeID := search.SpaceReadmeID(space); readmeID != nil {
+		statRes, err := p.gwClient.Stat(ctx, &provider.StatRequest{Ref: &provider.Reference{ResourceId: readmeID}})
+		switch {
+		case err != nil:
+			p.logger.Error().Err(err).Interface("readmeID", readmeID).Msg("failed to stat the readme of the space")
+		case statRes.GetStatus().GetCode() != rpc.Code_CODE_OK:
+			p.logger.Error().Interface("statRes", statRes).Interface("readmeID", readmeID).Msg("failed to stat the readme of the space")
+		default:
+			readme = p.extractContent(ctx, statRes.Info)
+		}
+	}
+
+	if err := p.indexClient.AddSpace(space, readme); err != nil {
+		p.logger.Error().Err(err).Interface("spaceID", spaceID).Msg("failed to add the space to the index")
+		return err
+	}
+	return nil
 }
 
-func formatQuery(q string) string {
-	query := q
-	fields := []string{"RootID", "Path", "ID", "Name", "Size", "Mtime", "MimeType", "Type"}
-	for _, field := range fields {
-		query = strings.ReplaceAll(query, strings.ToLower(field)+":", field+":")
+// isUnchanged returns true if the indexed entity has the etag and mtime of the resource. The storage reports
+// the tree mtime as the mtime of containers, which is stored along with the etag when indexing them.
+func isUnchanged(entity *searchmsg.Entity, ref *provider.Reference, info *provider.ResourceInfo) bool {
+	if info.Etag == "" || entity.Etag != info.Etag || entity.Deleted || entity.GetRef().GetPath() != ref.Path {
+		return false
+	}
+	if info.Mtime == nil {
+		return true
 	}
+	return entity.LastModifiedTime != nil && sameMtime(entity.LastModifiedTime.AsTime(), utils.TSToTime(info.Mtime))
+}
 
-	if strings.Contains(query, ":") {
-		return query // Sophisticated field based search
+// sameMtime compares the indexed mtime with the mtime of the resource. The index keeps the mtime in
+// seconds only, so they are compared at second precision.
+func sameMtime(indexed, actual time.Time) bool {
+	return indexed.Truncate(time.Second).Equal(actual.Truncate(time.Second))
+}
+
+// purgeUnseen removes the resources from the index which haven't been seen while walking the space
+// and which are not part of an unchanged subtree. Resources marked as deleted are kept as they still
+// live in the trash.
+func (p *Provider) purgeUnseen(batch search.BatchOperator, rootID *provider.ResourceId, seen map[string]struct{}, unchanged []string) {
+	err := p.indexClient.ListSpace(rootID, func(entity *searchmsg.Entity) error {
+		if entity.Deleted {
+			return nil
+		}
+		id := provider.ResourceId{
+			StorageId: entity.GetId().GetStorageId(),
+			SpaceId:   entity.GetId().GetSpaceId(),
+			OpaqueId:  entity.GetId().GetOpaqueId(),
+		}
+		if _, ok := seen[storagespace.FormatResourceID(id)]; ok || inSubtree(entity.GetRef().GetPath(), unchanged) {
+			return nil
+		}
+
+		if err := batch.Purge(&id); err != nil {
+			p.logger.Error().Err(err).Interface("id", id).Msg("error purging resource from the index")
+		} else {
+			p.logger.Debug().Interface("id", id).Str("path", entity.GetRef().GetPath()).Msg("purged vanished resource from the index")
+		}
+		return nil
+	})
+	if err != nil {
+		p.logger.Error().Err(err).Msg("error listing the indexed resources of the space")
 	}
+}
+
+func inSubtree(path string, roots []string) bool {
+	for _, root := range roots {
+		if strings.HasPrefix(path, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
 
-	// this is a basic filename search
-	return "Name:*" + strings.ReplaceAll(strings.ToLower(query), " ", `\ `) + "*"
+// extractContent returns the plain text content of the given resource. Extraction errors are
+// logged and result in the resource being indexed without its content.
+func (p *Provider) extractContent(ctx context.Context, ri *provider.ResourceInfo) string {
+	if p.extractor == nil || ri.Type != provider.ResourceType_RESOURCE_TYPE_FILE {
+		return ""
+	}
+	c, err := p.extractor.Extract(ctx, ri)
+	if err != nil {
+		p.logger.Error().Err(err).Interface("id", ri.Id).Str("mimetype", ri.MimeType).Msg("failed to extract the content of the resource")
+		return ""
+	}
+	return c
+}
+
+func (p *Provider) logDocCount() {
+	c, err := p.indexClient.DocCount()
+	if err != nil {
+		p.logger.Error().Err(err).Msg("error getting document count from the index")
+	}
+	p.logger.Debug().Interface("count", c).Msg("new document count")
 }
 
 // NOTE: this converts CS3 to WebDAV permissions
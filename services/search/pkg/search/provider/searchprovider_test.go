@@ -2,6 +2,7 @@ package provider_test
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -12,12 +13,17 @@ import (
 	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	sprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	ctxpkg "github.com/cs3org/reva/v2/pkg/ctx"
+	"github.com/cs3org/reva/v2/pkg/errtypes"
 	"github.com/cs3org/reva/v2/pkg/rgrpc/status"
 	"github.com/cs3org/reva/v2/pkg/utils"
 	cs3mocks "github.com/cs3org/reva/v2/tests/cs3mocks/mocks"
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
 	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
 	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
+	contentmocks "github.com/owncloud/ocis/v2/services/search/pkg/content/mocks"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search"
+	"github.com/owncloud/ocis/v2/services/search/pkg/search/index"
 	"github.com/owncloud/ocis/v2/services/search/pkg/search/mocks"
 	provider "github.com/owncloud/ocis/v2/services/search/pkg/search/provider"
 )
@@ -27,6 +33,7 @@ var _ = Describe("Searchprovider", func() {
 		p           *provider.Provider
 		gwClient    *cs3mocks.GatewayAPIClient
 		indexClient *mocks.IndexClient
+		extractor   *contentmocks.Extractor
 
 		ctx        context.Context
 		eventsChan chan interface{}
@@ -62,6 +69,7 @@ var _ = Describe("Searchprovider", func() {
 				OpaqueId:  "opaqueid",
 			},
 			Path:  "foo.pdf",
+			Type:  sprovider.ResourceType_RESOURCE_TYPE_FILE,
 			Size:  12345,
 			Mtime: utils.TimeToTS(time.Now().Add(-time.Hour)),
 		}
@@ -72,8 +80,9 @@ var _ = Describe("Searchprovider", func() {
 		eventsChan = make(chan interface{})
 		gwClient = &cs3mocks.GatewayAPIClient{}
 		indexClient = &mocks.IndexClient{}
+		extractor = &contentmocks.Extractor{}
 
-		p = provider.New(gwClient, indexClient, "", eventsChan, 1000, logger)
+		p = provider.New(gwClient, indexClient, extractor, "", eventsChan, 1000, logger)
 
 		gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
 			Status: status.NewOK(ctx),
@@ -94,21 +103,36 @@ var _ = Describe("Searchprovider", func() {
 
 	Describe("New", func() {
 		It("returns a new instance", func() {
-			p := provider.New(gwClient, indexClient, "", eventsChan, 1000, logger)
+			p := provider.New(gwClient, indexClient, extractor, "", eventsChan, 1000, logger)
 			Expect(p).ToNot(BeNil())
 		})
 	})
 
 	Describe("IndexSpace", func() {
-		It("walks the space and indexes all files", func() {
+		var batch *mocks.BatchOperator
+
+		BeforeEach(func() {
+			batch = &mocks.BatchOperator{}
+			batch.On("Push").Return(nil)
+			indexClient.On("NewBatch", mock.Anything).Return(batch, nil)
+			indexClient.On("AddSpace", mock.Anything, mock.Anything).Return(nil)
+			gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&sprovider.ListStorageSpacesResponse{
+				Status:        status.NewOK(ctx),
+				StorageSpaces: []*sprovider.StorageSpace{personalSpace},
+			}, nil)
+		})
+
+		It("walks the space and indexes all files including their content", func() {
 			gwClient.On("GetUserByClaim", mock.Anything, mock.Anything).Return(&userv1beta1.GetUserByClaimResponse{
 				Status: status.NewOK(context.Background()),
 				User:   user,
 			}, nil)
-			indexClient.On("Add", mock.Anything, mock.MatchedBy(func(riToIndex *sprovider.ResourceInfo) bool {
+			extractor.On("Extract", mock.Anything, mock.Anything).Return("the content", nil)
+			batch.On("Add", mock.Anything, mock.MatchedBy(func(riToIndex *sprovider.ResourceInfo) bool {
 				return riToIndex.Id.OpaqueId == ri.Id.OpaqueId
-			})).Return(nil)
-			indexClient.On("Search", mock.Anything, mock.Anything).Return(&searchsvc.SearchIndexResponse{}, nil)
+			}), "the content").Return(nil)
+			indexClient.On("Get", mock.Anything).Return(nil, errtypes.NotFound("not found"))
+			listSpaceReturns(indexClient)
 
 			res, err := p.IndexSpace(ctx, &searchsvc.IndexSpaceRequest{
 				SpaceId: "storageid$spaceid!spaceid",
@@ -117,6 +141,226 @@ var _ = Describe("Searchprovider", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(res).ToNot(BeNil())
 		})
+
+		It("adds the space itself including the content of its readme", func() {
+			space := &sprovider.StorageSpace{
+				Opaque: &typesv1beta1.Opaque{
+					Map: map[string]*typesv1beta1.OpaqueEntry{
+						"description": {Decoder: "plain", Value: []byte("all about the project")},
+						"readme":      {Decoder: "plain", Value: []byte("storageid$spaceid!readme")},
+					},
+				},
+				SpaceType: "project",
+				Id:        &sprovider.StorageSpaceId{OpaqueId: "storageid$spaceid"},
+				Root:      &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "spaceid"},
+				Name:      "Project",
+			}
+			gwClient.ExpectedCalls = nil
+			gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
+				Status: status.NewOK(ctx),
+				Token:  "authtoken",
+			}, nil)
+			gwClient.On("ListStorageSpaces", mock.Anything, mock.MatchedBy(func(req *sprovider.ListStorageSpacesRequest) bool {
+				return req.Filters[0].GetId().GetOpaqueId() == "storageid$spaceid!spaceid"
+			})).Return(&sprovider.ListStorageSpacesResponse{
+				Status:        status.NewOK(ctx),
+				StorageSpaces: []*sprovider.StorageSpace{space},
+			}, nil)
+			gwClient.On("Stat", mock.Anything, mock.MatchedBy(func(req *sprovider.StatRequest) bool {
+				return req.Ref.ResourceId.OpaqueId == "readme"
+			})).Return(&sprovider.StatResponse{
+				Status: status.NewOK(ctx),
+				Info: &sprovider.ResourceInfo{
+					Id:   &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "readme"},
+					Path: "readme.md",
+					Type: sprovider.ResourceType_RESOURCE_TYPE_FILE,
+				},
+			}, nil)
+			gwClient.On("Stat", mock.Anything, mock.Anything).Return(&sprovider.StatResponse{
+				Status: status.NewOK(ctx),
+				Info:   ri,
+			}, nil)
+			extractor.On("Extract", mock.Anything, mock.MatchedBy(func(info *sprovider.ResourceInfo) bool {
+				return info.Id.OpaqueId == "readme"
+			})).Return("the readme", nil)
+			extractor.On("Extract", mock.Anything, mock.Anything).Return("the content", nil)
+			batch.On("Add", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			indexClient.On("Get", mock.Anything).Return(nil, errtypes.NotFound("not found"))
+			listSpaceReturns(indexClient)
+
+			_, err := p.IndexSpace(ctx, &searchsvc.IndexSpaceRequest{
+				SpaceId: "storageid$spaceid!spaceid",
+				UserId:  "user",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			indexClient.AssertCalled(GinkgoT(), "AddSpace", space, "the readme")
+		})
+
+		Context("with a previously indexed space", func() {
+			var (
+				rootInfo, dirInfo, fileInfo *sprovider.ResourceInfo
+
+				entity = func(opaqueID, path, etag string, deleted bool) *searchmsg.Entity {
+					return &searchmsg.Entity{
+						Ref:     &searchmsg.Reference{ResourceId: &searchmsg.ResourceID{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "spaceid"}, Path: path},
+						Id:      &searchmsg.ResourceID{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: opaqueID},
+						Etag:    etag,
+						Deleted: deleted,
+					}
+				}
+				hasID = func(opaqueID string) interface{} {
+					return mock.MatchedBy(func(id *sprovider.ResourceId) bool { return id.OpaqueId == opaqueID })
+				}
+			)
+
+			BeforeEach(func() {
+				rootInfo = &sprovider.ResourceInfo{
+					Id:   &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "spaceid"},
+					Path: ".",
+					Type: sprovider.ResourceType_RESOURCE_TYPE_CONTAINER,
+					Etag: "rootetag-2",
+				}
+				dirInfo = &sprovider.ResourceInfo{
+					Id:   &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "dir"},
+					Path: "dir",
+					Type: sprovider.ResourceType_RESOURCE_TYPE_CONTAINER,
+					Etag: "diretag-1",
+				}
+				fileInfo = &sprovider.ResourceInfo{
+					Id:   &sprovider.ResourceId{StorageId: "storageid", SpaceId: "spaceid", OpaqueId: "file"},
+					Path: "file.txt",
+					Type: sprovider.ResourceType_RESOURCE_TYPE_FILE,
+					Etag: "fileetag-2",
+				}
+
+				// the space consists of a changed root, an unchanged directory and a changed file
+				gwClient = &cs3mocks.GatewayAPIClient{}
+				gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
+					Status: status.NewOK(ctx),
+					Token:  "authtoken",
+				}, nil)
+				gwClient.On("Stat", mock.Anything, mock.Anything).Return(&sprovider.StatResponse{
+					Status: status.NewOK(ctx),
+					Info:   rootInfo,
+				}, nil)
+				gwClient.On("ListContainer", mock.Anything, mock.MatchedBy(func(req *sprovider.ListContainerRequest) bool {
+					return req.Ref.ResourceId.OpaqueId == "spaceid"
+				})).Return(&sprovider.ListContainerResponse{
+					Status: status.NewOK(ctx),
+					Infos:  []*sprovider.ResourceInfo{dirInfo, fileInfo},
+				}, nil)
+				gwClient.On("ListContainer", mock.Anything, mock.Anything).Return(&sprovider.ListContainerResponse{
+					Status: status.NewOK(ctx),
+					Infos:  []*sprovider.ResourceInfo{},
+				}, nil)
+				gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&sprovider.ListStorageSpacesResponse{
+					Status: status.NewOK(ctx),
+				}, nil)
+				p = provider.New(gwClient, indexClient, nil, "", eventsChan, 1000, logger)
+
+				indexClient.On("Get", hasID("spaceid")).Return(entity("spaceid", ".", "rootetag-1", false), nil)
+				indexClient.On("Get", hasID("dir")).Return(entity("dir", "./dir", "diretag-1", false), nil)
+				indexClient.On("Get", hasID("file")).Return(entity("file", "./file.txt", "fileetag-1", false), nil)
+				batch.On("Add", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				batch.On("Purge", mock.Anything).Return(nil)
+				listSpaceReturns(indexClient,
+					entity("spaceid", ".", "rootetag-1", false),
+					entity("dir", "./dir", "diretag-1", false),
+					entity("nested", "./dir/nested.txt", "nestedetag", false),
+					entity("file", "./file.txt", "fileetag-1", false),
+					entity("gone", "./gone.txt", "goneetag", false),
+					entity("trashed", "./trashed.txt", "trashedetag", true),
+				)
+			})
+
+			It("re-adds the changed resources only", func() {
+				_, err := p.IndexSpace(ctx, &searchsvc.IndexSpaceRequest{
+					SpaceId: "storageid$spaceid!spaceid",
+					UserId:  "user",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				batch.AssertNumberOfCalls(GinkgoT(), "Add", 2)
+				batch.AssertCalled(GinkgoT(), "Add", mock.Anything, rootInfo, "")
+				batch.AssertCalled(GinkgoT(), "Add", mock.Anything, fileInfo, "")
+			})
+
+			It("skips the subtrees whose etag didn't change", func() {
+				_, err := p.IndexSpace(ctx, &searchsvc.IndexSpaceRequest{
+					SpaceId: "storageid$spaceid!spaceid",
+					UserId:  "user",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				batch.AssertNotCalled(GinkgoT(), "Add", mock.Anything, dirInfo, mock.Anything)
+				indexClient.AssertNumberOfCalls(GinkgoT(), "Get", 3)
+			})
+
+			It("walks the subtrees whose tree mtime changed", func() {
+				dirInfo.Mtime = &typesv1beta1.Timestamp{Seconds: 2000}
+
+				_, err := p.IndexSpace(ctx, &searchsvc.IndexSpaceRequest{
+					SpaceId: "storageid$spaceid!spaceid",
+					UserId:  "user",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				batch.AssertCalled(GinkgoT(), "Add", mock.Anything, dirInfo, "")
+			})
+
+			It("purges the resources which vanished from the space", func() {
+				_, err := p.IndexSpace(ctx, &searchsvc.IndexSpaceRequest{
+					SpaceId: "storageid$spaceid!spaceid",
+					UserId:  "user",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				batch.AssertNumberOfCalls(GinkgoT(), "Purge", 1)
+				batch.AssertCalled(GinkgoT(), "Purge", hasID("gone"))
+			})
+
+			It("skips the unchanged resources found in a real index", func() {
+				// the index keeps the mtime in seconds only
+				dirInfo.Mtime = &typesv1beta1.Timestamp{Seconds: 2000, Nanos: 123456789}
+				fileInfo.Mtime = &typesv1beta1.Timestamp{Seconds: 2000, Nanos: 987654321}
+				idx, err := index.NewMemOnly()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(idx.Add(&sprovider.Reference{ResourceId: rootInfo.Id, Path: "./dir"}, dirInfo, "")).To(Succeed())
+				Expect(idx.Add(&sprovider.Reference{ResourceId: rootInfo.Id, Path: "./file.txt"}, fileInfo, "")).To(Succeed())
+				extractor.On("Extract", mock.Anything, mock.Anything).Return("the content", nil)
+				p = provider.New(gwClient, idx, extractor, "", eventsChan, 1000, logger)
+
+				_, err = p.IndexSpace(ctx, &searchsvc.IndexSpaceRequest{
+					SpaceId: "storageid$spaceid!spaceid",
+					UserId:  "user",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				extractor.AssertNotCalled(GinkgoT(), "Extract", mock.Anything, fileInfo)
+			})
+
+			It("does not purge anything when the walk fails", func() {
+				gwClient.ExpectedCalls = nil
+				gwClient.On("Authenticate", mock.Anything, mock.Anything).Return(&gateway.AuthenticateResponse{
+					Status: status.NewOK(ctx),
+					Token:  "authtoken",
+				}, nil)
+				gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(nil, errors.New("storage unavailable"))
+				gwClient.On("Stat", mock.Anything, mock.Anything).Return(nil, errors.New("storage unavailable"))
+
+				_, err := p.IndexSpace(ctx, &searchsvc.IndexSpaceRequest{
+					SpaceId: "storageid$spaceid!spaceid",
+					UserId:  "user",
+				})
+				Expect(err).To(HaveOccurred())
+				batch.AssertNotCalled(GinkgoT(), "Purge", mock.Anything)
+			})
+		})
+	})
+
+	Describe("CollectGarbage", func() {
+		It("purges the resources deleted longer than the retention", func() {
+			indexClient.On("PurgeDeleted", mock.MatchedBy(func(before time.Time) bool {
+				return before.Before(time.Now().Add(-47*time.Hour)) && before.After(time.Now().Add(-49*time.Hour))
+			})).Return(2, nil)
+			p.CollectGarbage(48 * time.Hour)
+			indexClient.AssertNumberOfCalls(GinkgoT(), "PurgeDeleted", 1)
+		})
 	})
 
 	Describe("Search", func() {
@@ -159,50 +403,18 @@ var _ = Describe("Searchprovider", func() {
 				}, nil)
 			})
 
-			It("lowercases the filename", func() {
-				p.Search(ctx, &searchsvc.SearchRequest{
-					Query: "Foo.pdf",
-				})
-				indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
-					return req.Query == "Name:*foo.pdf*"
-				}))
-			})
-
-			It("does not mess with field-based searches", func() {
-				p.Search(ctx, &searchsvc.SearchRequest{
-					Query: "Size:<10",
-				})
-				indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
-					return req.Query == "Size:<10"
-				}))
-			})
-
-			It("uppercases field names", func() {
-				tests := []struct {
-					Original string
-					Expected string
-				}{
-					{Original: "size:<100", Expected: "Size:<100"},
-				}
-				for _, test := range tests {
-					p.Search(ctx, &searchsvc.SearchRequest{
-						Query: test.Original,
+			It("passes the query to the index unchanged", func() {
+				for _, query := range []string{"Foo oo.pdf", "size<10MB", "name:report* AND NOT mediatype:image"} {
+					_, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query: query,
 					})
+					Expect(err).ToNot(HaveOccurred())
 					indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
-						return req.Query == test.Expected
+						return req.Query == query
 					}))
 				}
 			})
 
-			It("escapes special characters", func() {
-				p.Search(ctx, &searchsvc.SearchRequest{
-					Query: "Foo oo.pdf",
-				})
-				indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
-					return req.Query == `Name:*foo\ oo.pdf*`
-				}))
-			})
-
 			It("searches the personal user space", func() {
 				res, err := p.Search(ctx, &searchsvc.SearchRequest{
 					Query: "foo",
@@ -218,11 +430,80 @@ var _ = Describe("Searchprovider", func() {
 				Expect(match.Entity.Ref.Path).To(Equal("./path/to/Foo.pdf"))
 
 				indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
-					return req.Query == "Name:*foo*" && req.Ref.ResourceId.OpaqueId == personalSpace.Root.OpaqueId && req.Ref.Path == ""
+					return req.Query == "foo" && req.Ref.ResourceId.OpaqueId == personalSpace.Root.OpaqueId && req.Ref.Path == ""
 				}))
 			})
 		})
 
+		Context("in the trash", func() {
+			var (
+				restorableSpace = &sprovider.StorageSpace{
+					SpaceType: "project",
+					Id:        &sprovider.StorageSpaceId{OpaqueId: "storageid$restorable!restorable"},
+					Root:      &sprovider.ResourceId{StorageId: "storageid", SpaceId: "restorable", OpaqueId: "restorable"},
+					RootInfo: &sprovider.ResourceInfo{
+						PermissionSet: &sprovider.ResourcePermissions{RestoreRecycleItem: true},
+					},
+				}
+				viewerSpace = &sprovider.StorageSpace{
+					SpaceType: "project",
+					Id:        &sprovider.StorageSpaceId{OpaqueId: "storageid$viewer!viewer"},
+					Root:      &sprovider.ResourceId{StorageId: "storageid", SpaceId: "viewer", OpaqueId: "viewer"},
+					RootInfo: &sprovider.ResourceInfo{
+						PermissionSet: &sprovider.ResourcePermissions{ListContainer: true},
+					},
+				}
+			)
+
+			BeforeEach(func() {
+				gwClient.On("ListStorageSpaces", mock.Anything, mock.Anything).Return(&sprovider.ListStorageSpacesResponse{
+					Status:        status.NewOK(ctx),
+					StorageSpaces: []*sprovider.StorageSpace{restorableSpace, viewerSpace},
+				}, nil)
+				indexClient.On("Search", mock.Anything, mock.Anything).Return(&searchsvc.SearchIndexResponse{
+					TotalMatches: 1,
+					Matches: []*searchmsg.Match{
+						{
+							Score: 1,
+							Entity: &searchmsg.Entity{
+								Ref: &searchmsg.Reference{
+									ResourceId: &searchmsg.ResourceID{StorageId: "storageid", SpaceId: "restorable", OpaqueId: "restorable"},
+									Path:       "./deleted/Foo.pdf",
+								},
+								Id:       &searchmsg.ResourceID{StorageId: "storageid", SpaceId: "restorable", OpaqueId: "foo-id"},
+								Name:     "Foo.pdf",
+								Deleted:  true,
+								TrashKey: "deleted-id/Foo.pdf",
+							},
+						},
+					},
+				}, nil)
+			})
+
+			It("only searches the trash of the spaces the user can restore items from", func() {
+				res, err := p.Search(ctx, &searchsvc.SearchRequest{
+					Query: "foo",
+					Scope: "trash",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.Matches).To(HaveLen(1))
+				Expect(res.Matches[0].Entity.TrashKey).To(Equal("deleted-id/Foo.pdf"))
+
+				indexClient.AssertNumberOfCalls(GinkgoT(), "Search", 1)
+				indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+					return req.Scope == "trash" && req.Ref.ResourceId.SpaceId == "restorable"
+				}))
+			})
+
+			It("rejects unknown scopes", func() {
+				_, err := p.Search(ctx, &searchsvc.SearchRequest{
+					Query: "foo",
+					Scope: "versions",
+				})
+				Expect(err).To(BeAssignableToTypeOf(errtypes.BadRequest("")))
+			})
+		})
+
 		Context("with received shares", func() {
 			var (
 				grantSpace      *sprovider.StorageSpace
@@ -298,7 +579,7 @@ var _ = Describe("Searchprovider", func() {
 				Expect(match.Entity.Ref.Path).To(Equal("./to/Shared.pdf"))
 
 				indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
-					return req.Query == "Name:*foo*" && req.Ref.ResourceId.StorageId == grantSpace.Root.StorageId && req.Ref.Path == "./grant/path"
+					return req.Query == "Foo" && req.Ref.ResourceId.StorageId == grantSpace.Root.StorageId && req.Ref.Path == "./grant/path"
 				}))
 			})
 
@@ -418,6 +699,157 @@ var _ = Describe("Searchprovider", func() {
 					Expect(len(res.Matches)).To(Equal(2))
 					ids := []string{res.Matches[0].Entity.Id.OpaqueId, res.Matches[1].Entity.Id.OpaqueId}
 					Expect(ids).To(Equal([]string{"grant-shared-id", "foo-id"}))
+					token, err := search.DecodePageToken(res.NextPageToken)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(token.Order).To(Equal(search.DefaultOrder))
+					Expect(token.Score).To(Equal(float32(1)))
+					Expect(token.ID).To(Equal("storageid$!foo-id"))
+					indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+						return req.RankedAt.AsTime().Equal(token.RankedAt)
+					}))
+				})
+
+				It("orders the combined results from all spaces by the requested field", func() {
+					res, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query:   "foo",
+						OrderBy: "name",
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(len(res.Matches)).To(Equal(3))
+					names := []string{res.Matches[0].Entity.Name, res.Matches[1].Entity.Name, res.Matches[2].Entity.Name}
+					Expect(names).To(Equal([]string{"Foo.pdf", "Irrelevant.pdf", "Shared.pdf"}))
+					indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+						return req.OrderBy == "name"
+					}))
+				})
+
+				It("does not search the shares for spaces", func() {
+					_, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query: "foo",
+						Scope: "spaces",
+					})
+					Expect(err).ToNot(HaveOccurred())
+					indexClient.AssertNumberOfCalls(GinkgoT(), "Search", 1)
+					indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+						return req.Scope == "spaces" && req.Ref.ResourceId.SpaceId == personalSpace.Root.SpaceId
+					}))
+				})
+
+				It("rejects unknown orders", func() {
+					_, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query:   "foo",
+						OrderBy: "color",
+					})
+					Expect(err).To(BeAssignableToTypeOf(errtypes.BadRequest("")))
+				})
+
+				It("passes the personal space of the searching user to the index", func() {
+					personalSpace.SpaceType = "personal"
+					personalSpace.Owner = user
+					DeferCleanup(func() {
+						personalSpace.SpaceType = ""
+						personalSpace.Owner = nil
+					})
+
+					_, err := p.Search(ctxpkg.ContextSetUser(ctx, user), &searchsvc.SearchRequest{
+						Query: "foo",
+					})
+					Expect(err).ToNot(HaveOccurred())
+					indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+						return req.Ref.ResourceId.OpaqueId == personalSpace.Root.OpaqueId && req.PersonalRootId.GetOpaqueId() == personalSpace.Root.OpaqueId
+					}))
+					indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+						return req.Ref.ResourceId.OpaqueId == grantSpace.Root.SpaceId && req.PersonalRootId == nil
+					}))
+				})
+
+				It("does not return a page token when all matches fit on the page", func() {
+					res, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query:    "foo",
+						PageSize: 3,
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(len(res.Matches)).To(Equal(3))
+					Expect(res.NextPageToken).To(BeEmpty())
+				})
+
+				It("continues all spaces after the given page token", func() {
+					rankedAt := time.Now().Add(-time.Minute)
+					pageToken := search.EncodePageToken(search.DefaultOrder, rankedAt, &searchmsg.Match{
+						Score:  1,
+						Entity: &searchmsg.Entity{Id: &searchmsg.ResourceID{StorageId: "storageid", OpaqueId: "foo-id"}},
+					})
+					_, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query:     "foo",
+						PageToken: pageToken,
+					})
+					Expect(err).ToNot(HaveOccurred())
+					indexClient.AssertNumberOfCalls(GinkgoT(), "Search", 2)
+					indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+						return req.PageToken == pageToken && req.Ref.ResourceId.OpaqueId == personalSpace.Root.OpaqueId
+					}))
+					indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+						return req.PageToken == pageToken && req.Ref.ResourceId.OpaqueId == grantSpace.Root.SpaceId
+					}))
+					indexClient.AssertNotCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+						return !req.RankedAt.AsTime().Equal(rankedAt)
+					}))
+				})
+
+				It("rejects page tokens created for another order", func() {
+					pageToken := search.EncodePageToken(search.DefaultOrder, time.Now(), &searchmsg.Match{
+						Score:  1,
+						Entity: &searchmsg.Entity{Id: &searchmsg.ResourceID{StorageId: "storageid", OpaqueId: "foo-id"}},
+					})
+					_, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query:     "foo",
+						OrderBy:   "size",
+						PageToken: pageToken,
+					})
+					Expect(err).To(BeAssignableToTypeOf(errtypes.BadRequest("")))
+				})
+
+				It("rejects invalid page tokens", func() {
+					_, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query:     "foo",
+						PageToken: "invalid",
+					})
+					Expect(err).To(HaveOccurred())
+				})
+
+				It("counts the matches per space", func() {
+					res, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query:    "foo",
+						PageSize: 1,
+						Facets:   []string{"space"},
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.Facets).To(HaveLen(1))
+					Expect(res.Facets[0].Name).To(Equal("space"))
+					Expect(res.Facets[0].Terms).To(HaveLen(2))
+					Expect(res.Facets[0].Terms[0].Term).To(Equal(mountpointSpace.Id.OpaqueId))
+					Expect(res.Facets[0].Terms[0].Count).To(Equal(int32(2)))
+					Expect(res.Facets[0].Terms[1].Term).To(Equal(personalSpace.Id.OpaqueId))
+					Expect(res.Facets[0].Terms[1].Count).To(Equal(int32(1)))
+				})
+
+				It("requests the other facets from the index", func() {
+					_, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query:  "foo",
+						Facets: []string{"space", "mimetype"},
+					})
+					Expect(err).ToNot(HaveOccurred())
+					indexClient.AssertCalled(GinkgoT(), "Search", mock.Anything, mock.MatchedBy(func(req *searchsvc.SearchIndexRequest) bool {
+						return len(req.Facets) == 1 && req.Facets[0] == "mimetype"
+					}))
+				})
+
+				It("rejects unknown facets", func() {
+					_, err := p.Search(ctx, &searchsvc.SearchRequest{
+						Query:  "foo",
+						Facets: []string{"color"},
+					})
+					Expect(err).To(HaveOccurred())
 				})
 			})
 		})
@@ -0,0 +1,19 @@
+package search
+
+const (
+	// ScopeFiles searches the resources living in the spaces. It is the default scope.
+	ScopeFiles = "files"
+	// ScopeTrash searches the resources in the trash bins of the spaces
+	ScopeTrash = "trash"
+	// ScopeSpaces searches the spaces themselves by their name, description and readme
+	ScopeSpaces = "spaces"
+)
+
+// IsValidScope returns true if the given scope is supported. An empty scope means ScopeFiles.
+func IsValidScope(scope string) bool {
+	switch scope {
+	case "", ScopeFiles, ScopeTrash, ScopeSpaces:
+		return true
+	}
+	return false
+}
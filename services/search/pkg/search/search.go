@@ -20,27 +20,46 @@ package search
 
 import (
 	"context"
+	"time"
 
 	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	searchmsg "github.com/owncloud/ocis/v2/protogen/gen/ocis/messages/search/v0"
 	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
 )
 
 //go:generate mockery --name=ProviderClient
 //go:generate mockery --name=IndexClient
+//go:generate mockery --name=BatchOperator
 
 // ProviderClient is the interface to the search provider service
 type ProviderClient interface {
 	Search(ctx context.Context, req *searchsvc.SearchRequest) (*searchsvc.SearchResponse, error)
 	IndexSpace(ctx context.Context, req *searchsvc.IndexSpaceRequest) (*searchsvc.IndexSpaceResponse, error)
+	IndexSpaces(ctx context.Context, req *searchsvc.IndexSpacesRequest, send func(*searchsvc.IndexSpacesResponse) error) error
+	CheckIndex(ctx context.Context, req *searchsvc.CheckIndexRequest) (*searchsvc.CheckIndexResponse, error)
 }
 
 // IndexClient is the interface to the search index
 type IndexClient interface {
 	Search(ctx context.Context, req *searchsvc.SearchIndexRequest) (*searchsvc.SearchIndexResponse, error)
-	Add(ref *providerv1beta1.Reference, ri *providerv1beta1.ResourceInfo) error
+	Add(ref *providerv1beta1.Reference, ri *providerv1beta1.ResourceInfo, content string) error
+	AddSpace(space *providerv1beta1.StorageSpace, readme string) error
 	Move(id, parentID *providerv1beta1.ResourceId, fullPath string) error
 	Delete(id *providerv1beta1.ResourceId) error
 	Restore(id *providerv1beta1.ResourceId) error
 	Purge(id *providerv1beta1.ResourceId) error
+	PurgeSpace(rootID *providerv1beta1.ResourceId) error
+	PurgeDeleted(deletedBefore time.Time) (int, error)
+	Get(id *providerv1beta1.ResourceId) (*searchmsg.Entity, error)
+	ListSpace(rootID *providerv1beta1.ResourceId, fn func(*searchmsg.Entity) error) error
 	DocCount() (uint64, error)
+	NewBatch(size int) (BatchOperator, error)
+}
+
+// BatchOperator collects index operations and applies them in bulk. The operations are pushed to
+// the index whenever the batch reaches its size and when calling Push.
+type BatchOperator interface {
+	Add(ref *providerv1beta1.Reference, ri *providerv1beta1.ResourceInfo, content string) error
+	Purge(id *providerv1beta1.ResourceId) error
+	Push() error
 }
@@ -33,6 +33,8 @@ func Server(opts ...Option) grpc.Service {
 	handle, err := svc.NewHandler(
 		svc.Config(options.Config),
 		svc.Logger(options.Logger),
+		svc.Context(options.Context),
+		svc.Metrics(options.Metrics),
 	)
 	if err != nil {
 		options.Logger.Error().
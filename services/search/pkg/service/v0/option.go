@@ -1,8 +1,11 @@
 package service
 
 import (
+	"context"
+
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
 	"github.com/owncloud/ocis/v2/services/search/pkg/config"
+	"github.com/owncloud/ocis/v2/services/search/pkg/metrics"
 )
 
 // Option defines a single option function.
@@ -10,8 +13,10 @@ type Option func(o *Options)
 
 // Options defines the available options for this package.
 type Options struct {
-	Logger log.Logger
-	Config *config.Config
+	Logger  log.Logger
+	Context context.Context
+	Config  *config.Config
+	Metrics *metrics.Metrics
 }
 
 func newOptions(opts ...Option) Options {
@@ -31,9 +36,23 @@ func Logger(val log.Logger) Option {
 	}
 }
 
+// Context provides a function to set the Context option.
+func Context(val context.Context) Option {
+	return func(o *Options) {
+		o.Context = val
+	}
+}
+
 // Config provides a function to set the Config option.
 func Config(val *config.Config) Option {
 	return func(o *Options) {
 		o.Config = val
 	}
 }
+
+// Metrics provides a function to set the Metrics option.
+func Metrics(val *metrics.Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = val
+	}
+}
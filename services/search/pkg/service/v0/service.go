@@ -5,16 +5,18 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/blevesearch/bleve/v2"
 	revactx "github.com/cs3org/reva/v2/pkg/ctx"
 	"github.com/cs3org/reva/v2/pkg/errtypes"
-	"github.com/cs3org/reva/v2/pkg/events"
 	"github.com/cs3org/reva/v2/pkg/events/server"
 	"github.com/cs3org/reva/v2/pkg/rgrpc/todo/pool"
 	"github.com/go-micro/plugins/v4/events/natsjs"
+	"github.com/nats-io/nats.go"
 	merrors "go-micro.dev/v4/errors"
 	"go-micro.dev/v4/metadata"
 	grpcmetadata "google.golang.org/grpc/metadata"
@@ -23,6 +25,7 @@ import (
 	"github.com/owncloud/ocis/v2/ocis-pkg/log"
 	searchsvc "github.com/owncloud/ocis/v2/protogen/gen/ocis/services/search/v0"
 	"github.com/owncloud/ocis/v2/services/search/pkg/config"
+	"github.com/owncloud/ocis/v2/services/search/pkg/content"
 	"github.com/owncloud/ocis/v2/services/search/pkg/search"
 	"github.com/owncloud/ocis/v2/services/search/pkg/search/index"
 	searchprovider "github.com/owncloud/ocis/v2/services/search/pkg/search/provider"
@@ -67,26 +70,41 @@ func NewHandler(opts ...Option) (searchsvc.SearchProviderHandler, error) {
 	if err != nil {
 		return nil, err
 	}
-	evts, err := events.Consume(client, evtsCfg.ConsumerGroup, searchprovider.ListenEvents...)
+	evts, err := searchprovider.ConsumeEvents(client, evtsCfg.ConsumerGroup, time.Duration(evtsCfg.AckWait)*time.Second, searchprovider.ListenEvents...)
 	if err != nil {
 		return nil, err
 	}
+	if evtsCfg.DeadLetterSubject != "" {
+		if err := ensureStream(evtsCfg.Endpoint, tlsConf, evtsCfg.DeadLetterSubject); err != nil {
+			return nil, err
+		}
+	}
 
-	indexDir := filepath.Join(cfg.Datapath, "index.bleve")
-	bleveIndex, err := bleve.Open(indexDir)
-	if err != nil {
-		mapping, err := index.BuildMapping()
+	var idx search.IndexClient
+	indexOptions := []index.Option{
+		index.Fuzziness(cfg.Engine.Fuzziness),
+		index.ExactNameBoost(cfg.Engine.Ranking.ExactNameBoost),
+		index.RecencyBoost(cfg.Engine.Ranking.RecencyBoost, time.Duration(cfg.Engine.Ranking.RecencyHalfLife)*time.Hour),
+		index.PersonalSpaceBoost(cfg.Engine.Ranking.PersonalSpaceBoost),
+		index.MaxOpenSpaces(cfg.Engine.MaxOpenSpaces),
+	}
+	switch cfg.Engine.Type {
+	case "bleve", "":
+		bleveIndex, err := index.NewPersisted(filepath.Join(cfg.Datapath, "spaces.bleve"), indexOptions...)
 		if err != nil {
 			return nil, err
 		}
-		bleveIndex, err = bleve.New(indexDir, mapping)
+		upgradeIndex(bleveIndex, logger)
+		migrateLegacyIndex(bleveIndex, filepath.Join(cfg.Datapath, "index.bleve"), logger)
+		idx = bleveIndex
+	case "opensearch":
+		osCfg := cfg.Engine.OpenSearch
+		idx, err = index.NewOpenSearch(osCfg.URL, osCfg.Index, osCfg.Username, osCfg.Password, osCfg.Insecure, indexOptions...)
 		if err != nil {
 			return nil, err
 		}
-	}
-	index, err := index.New(bleveIndex)
-	if err != nil {
-		return nil, err
+	default:
+		return nil, fmt.Errorf("unknown search engine: %s", cfg.Engine.Type)
 	}
 
 	gwclient, err := pool.GetGatewayServiceClient(cfg.Reva.Address, cfg.Reva.GetRevaOptions()...)
@@ -94,7 +112,30 @@ func NewHandler(opts ...Option) (searchsvc.SearchProviderHandler, error) {
 		logger.Fatal().Err(err).Str("addr", cfg.Reva.Address).Msg("could not get reva client")
 	}
 
-	provider := searchprovider.New(gwclient, index, cfg.MachineAuthAPIKey, evts, cfg.DebounceDuration, logger)
+	var extractor content.Extractor
+	switch cfg.Extractor.Type {
+	case "basic":
+		extractor = content.NewBasicExtractor(content.NewCS3Retriever(gwclient, cfg.Extractor.CS3AllowInsecure), cfg.Extractor.MaxFileSize)
+	case "tika":
+		extractor = content.NewTikaExtractor(content.NewCS3Retriever(gwclient, cfg.Extractor.CS3AllowInsecure), cfg.Extractor.Tika.TikaURL, cfg.Extractor.MaxFileSize)
+	case "none", "":
+	default:
+		return nil, fmt.Errorf("unknown search extractor: %s", cfg.Extractor.Type)
+	}
+
+	provider := searchprovider.New(gwclient, idx, extractor, cfg.MachineAuthAPIKey, evts, cfg.DebounceDuration, logger,
+		searchprovider.Workers(evtsCfg.Workers),
+		searchprovider.Retries(evtsCfg.MaxAttempts, time.Duration(evtsCfg.Backoff)*time.Millisecond, time.Duration(evtsCfg.MaxBackoff)*time.Millisecond),
+		searchprovider.DeadLetter(client, evtsCfg.DeadLetterSubject),
+		searchprovider.Metrics(options.Metrics),
+	)
+	if cfg.GC.Interval > 0 {
+		ctx := options.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		provider.StartGarbageCollection(ctx, time.Duration(cfg.GC.Interval)*time.Minute, time.Duration(cfg.GC.Retention)*time.Hour)
+	}
 
 	return &Service{
 		id:       cfg.GRPC.Namespace + "." + cfg.Service.Name,
@@ -104,6 +145,65 @@ func NewHandler(opts ...Option) (searchsvc.SearchProviderHandler, error) {
 	}, nil
 }
 
+// ensureStream creates the stream storing the messages published to the given subject if it doesn't exist yet.
+// Streams are only created when consuming from them, the dead letter subject is only published to though.
+func ensureStream(endpoint string, tlsConf *tls.Config, subject string) error {
+	opts := []nats.Option{}
+	if tlsConf != nil {
+		opts = append(opts, nats.Secure(tlsConf))
+	}
+	conn, err := nats.Connect(endpoint, opts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	js, err := conn.JetStream()
+	if err != nil {
+		return err
+	}
+	name := strings.NewReplacer(".", "-", "*", "-", ">", "-").Replace(subject)
+	_, err = js.StreamInfo(name)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{Name: name, Subjects: []string{subject}})
+	}
+	return err
+}
+
+// upgradeIndex rebuilds the indexes of the spaces which have been built with a former mapping. If the
+// rebuild fails the former indexes are still used and the rebuild is tried again on the next start.
+func upgradeIndex(idx *index.Index, logger log.Logger) {
+	start := time.Now()
+	rebuilt, err := idx.Upgrade()
+	if err != nil {
+		logger.Error().Err(err).Int("spaces", rebuilt).Msg("could not rebuild the search indexes of the spaces with the current mapping")
+		return
+	}
+	if rebuilt > 0 {
+		logger.Info().Int("spaces", rebuilt).Dur("duration", time.Since(start)).Msg("rebuilt the search indexes of the spaces with the current mapping")
+	}
+}
+
+// migrateLegacyIndex moves the documents of the former index of all spaces to the indexes of the spaces.
+// The former index is renamed afterwards, so that it is only migrated once. If the migration fails it is
+// tried again on the next start.
+func migrateLegacyIndex(idx *index.Index, legacyIndexDir string, logger log.Logger) {
+	if _, err := os.Stat(legacyIndexDir); err != nil {
+		return
+	}
+	logger.Info().Str("path", legacyIndexDir).Msg("moving the documents of the former search index to the indexes of the spaces")
+	migrated, err := idx.Migrate(legacyIndexDir)
+	if err != nil {
+		logger.Error().Err(err).Str("path", legacyIndexDir).Int("documents", migrated).Msg("could not migrate the former search index")
+		return
+	}
+	if err := os.Rename(legacyIndexDir, legacyIndexDir+".migrated"); err != nil {
+		logger.Error().Err(err).Str("path", legacyIndexDir).Msg("could not rename the migrated search index")
+		return
+	}
+	logger.Info().Str("path", legacyIndexDir+".migrated").Int("documents", migrated).Msg("migrated the former search index, it can be removed")
+}
+
 // Service implements the searchServiceHandler interface
 type Service struct {
 	id       string
@@ -122,9 +222,13 @@ func (s Service) Search(ctx context.Context, in *searchsvc.SearchRequest, out *s
 	ctx = grpcmetadata.AppendToOutgoingContext(ctx, revactx.TokenHeader, t)
 
 	res, err := s.provider.Search(ctx, &searchsvc.SearchRequest{
-		Query:    in.Query,
-		PageSize: in.PageSize,
-		Ref:      in.Ref,
+		Query:     in.Query,
+		PageSize:  in.PageSize,
+		PageToken: in.PageToken,
+		Ref:       in.Ref,
+		Facets:    in.Facets,
+		Scope:     in.Scope,
+		OrderBy:   in.OrderBy,
 	})
 	if err != nil {
 		switch err.(type) {
@@ -138,6 +242,7 @@ func (s Service) Search(ctx context.Context, in *searchsvc.SearchRequest, out *s
 	out.Matches = res.Matches
 	out.TotalMatches = res.TotalMatches
 	out.NextPageToken = res.NextPageToken
+	out.Facets = res.Facets
 	return nil
 }
 
@@ -145,3 +250,19 @@ func (s Service) IndexSpace(ctx context.Context, in *searchsvc.IndexSpaceRequest
 	_, err := s.provider.IndexSpace(ctx, in)
 	return err
 }
+
+func (s Service) IndexSpaces(ctx context.Context, in *searchsvc.IndexSpacesRequest, stream searchsvc.SearchProvider_IndexSpacesStream) error {
+	return s.provider.IndexSpaces(ctx, in, stream.Send)
+}
+
+func (s Service) CheckIndex(ctx context.Context, in *searchsvc.CheckIndexRequest, out *searchsvc.CheckIndexResponse) error {
+	res, err := s.provider.CheckIndex(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	out.Discrepancies = res.Discrepancies
+	out.CheckedSpaces = res.CheckedSpaces
+	out.CheckedResources = res.CheckedResources
+	return nil
+}
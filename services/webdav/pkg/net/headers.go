@@ -45,4 +45,5 @@ const (
 	HeaderOCMtime              = "X-OC-Mtime"
 	HeaderExpectedEntityLength = "X-Expected-Entity-Length"
 	HeaderLitmus               = "X-Litmus"
+	HeaderOCNextPageToken      = "OC-Next-Page-Token"
 )
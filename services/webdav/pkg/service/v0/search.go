@@ -50,8 +50,10 @@ func (g Webdav) Search(w http.ResponseWriter, r *http.Request) {
 	ctx = metadata.Set(ctx, revactx.TokenHeader, t)
 
 	req := &searchsvc.SearchRequest{
-		Query:    rep.SearchFiles.Search.Pattern,
-		PageSize: int32(rep.SearchFiles.Search.Limit),
+		Query:     rep.SearchFiles.Search.Pattern,
+		PageSize:  int32(rep.SearchFiles.Search.Limit),
+		PageToken: rep.SearchFiles.Search.PageToken,
+		Scope:     rep.SearchFiles.Search.Scope,
 	}
 
 	// Limit search to the according space when searching /dav/spaces/
@@ -100,6 +102,9 @@ func (g Webdav) sendSearchResponse(rsp *searchsvc.SearchResponse, w http.Respons
 	if len(rsp.Matches) > 0 {
 		w.Header().Set(net.HeaderContentRange, fmt.Sprintf("rows 0-%d/%d", len(rsp.Matches)-1, rsp.TotalMatches))
 	}
+	if rsp.NextPageToken != "" {
+		w.Header().Set(net.HeaderOCNextPageToken, rsp.NextPageToken)
+	}
 	w.WriteHeader(http.StatusMultiStatus)
 	if _, err := w.Write(responsesXML); err != nil {
 		logger.Err(err).Msg("error writing response")
@@ -158,8 +163,16 @@ func matchToPropResponse(ctx context.Context, match *searchmsg.Match) (*propfind
 	if err != nil {
 		return nil, err
 	}
+	href := path.Join("/remote.php/dav/spaces/", ref)
+	if match.Entity.TrashKey != "" {
+		// trashed items are addressed by their key in the trash bin of the space so that they can be restored directly
+		href = path.Join("/remote.php/dav/spaces/trash-bin",
+			storagespace.FormatStorageID(match.Entity.Ref.ResourceId.StorageId, match.Entity.Ref.ResourceId.SpaceId),
+			match.Entity.TrashKey,
+		)
+	}
 	response := propfind.ResponseXML{
-		Href:     net.EncodePath(path.Join("/remote.php/dav/spaces/", ref)),
+		Href:     net.EncodePath(href),
 		Propstat: []propfind.PropstatXML{},
 	}
 
@@ -168,11 +181,21 @@ func matchToPropResponse(ctx context.Context, match *searchmsg.Match) (*propfind
 		Prop:   []prop.PropertyXML{},
 	}
 
+	fileID := match.Entity.Id
+	if match.Space != nil {
+		// spaces are identified by their root like in a PROPFIND on the space
+		fileID = match.Space.RootId
+	}
 	propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:fileid", storagespace.FormatResourceID(provider.ResourceId{
-		StorageId: match.Entity.Id.StorageId,
-		SpaceId:   match.Entity.Id.SpaceId,
-		OpaqueId:  match.Entity.Id.OpaqueId,
+		StorageId: fileID.GetStorageId(),
+		SpaceId:   fileID.GetSpaceId(),
+		OpaqueId:  fileID.GetOpaqueId(),
 	})))
+	if match.Space != nil {
+		propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:spaceid", match.Space.Id))
+		propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:space-type", match.Space.SpaceType))
+		propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:description", match.Space.Description))
+	}
 	if match.Entity.ParentId != nil {
 		propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:file-parent", storagespace.FormatResourceID(provider.ResourceId{
 			StorageId: match.Entity.ParentId.StorageId,
@@ -185,6 +208,10 @@ func matchToPropResponse(ctx context.Context, match *searchmsg.Match) (*propfind
 		propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:shareroot", match.Entity.ShareRootName))
 	}
 	propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:name", match.Entity.Name))
+	if match.Entity.TrashKey != "" {
+		propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:trashbin-original-filename", match.Entity.Name))
+		propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:trashbin-original-location", strings.TrimPrefix(match.Entity.Ref.Path, "./")))
+	}
 	propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("d:getlastmodified", match.Entity.LastModifiedTime.AsTime().Format(time.RFC3339)))
 	propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("d:getcontenttype", match.Entity.MimeType))
 	propstatOK.Prop = append(propstatOK.Prop, prop.Escaped("oc:permissions", match.Entity.Permissions))
@@ -224,9 +251,11 @@ type reportSearchFiles struct {
 	Search  reportSearchFilesSearch `xml:"search"`
 }
 type reportSearchFilesSearch struct {
-	Pattern string `xml:"pattern"`
-	Limit   int    `xml:"limit"`
-	Offset  int    `xml:"offset"`
+	Pattern   string `xml:"pattern"`
+	Limit     int    `xml:"limit"`
+	Offset    int    `xml:"offset"`
+	PageToken string `xml:"page-token"`
+	Scope     string `xml:"scope"`
 }
 
 type reportFilterFiles struct {